@@ -0,0 +1,187 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"sort"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DatabaseInfo holds information about a database returned by the handler
+// from Pool.AdminHandler.
+type DatabaseInfo struct {
+	DatabaseStats
+	Open          bool    `json:"open"`
+	Fragmentation float64 `json:"fragmentation"`
+}
+
+// AdminHandler returns an HTTP handler with a JSON API for operating the
+// pool. It is meant to be mounted under an internal route with
+// http.StripPrefix. If Options.AdminAuthorize is set, requests that are not
+// authorized get the Unauthorized response.
+//
+// Databases are selected with the path query parameter, except for the
+// connections endpoint:
+//
+//	GET  /connections  stats of all open databases
+//	GET  /database     stats, size and fragmentation of a database
+//	GET  /buckets      names of top-level buckets
+//	POST /compact      compact a database that has no references
+//	POST /backup       store a backup to the target
+//	POST /evict        close a database that has no references
+//
+// Backups are not available if the target is nil.
+func (p *Pool) AdminHandler(target BackupTarget) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/connections", adminMethod(http.MethodGet, false, func(string) (interface{}, error) {
+		return p.Stats().Databases, nil
+	}))
+	mux.HandleFunc("/database", adminMethod(http.MethodGet, true, func(path string) (interface{}, error) {
+		return p.databaseInfo(path)
+	}))
+	mux.HandleFunc("/buckets", adminMethod(http.MethodGet, true, func(path string) (interface{}, error) {
+		return p.bucketNames(path)
+	}))
+	mux.HandleFunc("/compact", adminMethod(http.MethodPost, true, func(path string) (interface{}, error) {
+		return nil, p.Compact(path)
+	}))
+	mux.HandleFunc("/backup", adminMethod(http.MethodPost, true, func(path string) (interface{}, error) {
+		if target == nil {
+			return nil, errNoBackupTarget
+		}
+		return nil, p.BackupTo(path, target)
+	}))
+	mux.HandleFunc("/evict", adminMethod(http.MethodPost, true, func(path string) (interface{}, error) {
+		return nil, p.evict(path)
+	}))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a := p.options.AdminAuthorize; a != nil && !a(r) {
+			adminRespond(w, http.StatusUnauthorized, nil, errors.New(http.StatusText(http.StatusUnauthorized)))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+var (
+	errNoBackupTarget = errors.New("boltdbpool: no backup target")
+	errNotOpen        = errors.New("boltdbpool: database not open")
+)
+
+// databaseInfo returns information about the database on path without
+// opening it.
+func (p *Pool) databaseInfo(path string) (info DatabaseInfo, err error) {
+	path, err = p.normalizePath(path)
+	if err != nil {
+		return info, err
+	}
+	p.mu.RLock()
+	c, ok := p.connections[path]
+	if ok {
+		info.DatabaseStats = c.stats()
+		info.Fragmentation = fragmentation(c.DB)
+		info.Open = true
+	}
+	p.mu.RUnlock()
+	if ok {
+		return info, nil
+	}
+	fi, err := p.fs.Stat(path)
+	if err != nil {
+		return info, err
+	}
+	info.Path = path
+	info.Size = fi.Size()
+	return info, nil
+}
+
+// bucketNames returns sorted names of top-level buckets of an existing
+// database.
+func (p *Pool) bucketNames(path string) (names []string, err error) {
+	c, err := p.getExisting(path)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	names = []string{}
+	err = c.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			names = append(names, string(name))
+			return nil
+		})
+	})
+	sort.Strings(names)
+	return names, err
+}
+
+// evict closes the open database on path if it has no references.
+func (p *Pool) evict(path string) error {
+	path, err := p.normalizePath(path)
+	if err != nil {
+		return err
+	}
+	p.mu.RLock()
+	c, ok := p.connections[path]
+	p.mu.RUnlock()
+	if !ok {
+		return errNotOpen
+	}
+	if !p.evictIdle(c) {
+		return ErrInUse
+	}
+	return nil
+}
+
+// adminMethod returns a handler that allows only the method and passes the
+// path query parameter to fn, responding with Bad Request if it is required
+// and not set.
+func adminMethod(method string, pathRequired bool, fn func(path string) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			adminRespond(w, http.StatusMethodNotAllowed, nil, errors.New(http.StatusText(http.StatusMethodNotAllowed)))
+			return
+		}
+		path := r.URL.Query().Get("path")
+		if pathRequired && path == "" {
+			adminRespond(w, http.StatusBadRequest, nil, errors.New("boltdbpool: path parameter required"))
+			return
+		}
+		v, err := fn(path)
+		status := http.StatusOK
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrInUse):
+			status = http.StatusConflict
+		case errors.Is(err, errNotOpen), errors.Is(err, os.ErrNotExist):
+			status = http.StatusNotFound
+		case errors.Is(err, errNoBackupTarget):
+			status = http.StatusNotImplemented
+		default:
+			status = http.StatusInternalServerError
+		}
+		adminRespond(w, status, v, err)
+	}
+}
+
+func adminRespond(w http.ResponseWriter, status int, v interface{}, err error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err != nil {
+		v = map[string]string{"error": err.Error()}
+	} else if v == nil {
+		v = map[string]bool{"ok": true}
+	}
+	_ = json.NewEncoder(w).Encode(v)
+}
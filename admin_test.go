@@ -0,0 +1,128 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestAdminHandler(t *testing.T) {
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+		AdminAuthorize: func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == "secret"
+		},
+	})
+	defer pool.Close()
+
+	dir := t.TempDir()
+	backups := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{"b", "a"} {
+			if _, err := tx.CreateBucket([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := pool.AdminHandler(DirBackupTarget{Dir: backups})
+
+	request := func(method, target string, v interface{}) int {
+		t.Helper()
+
+		r := httptest.NewRequest(method, target, nil)
+		r.Header.Set("Authorization", "secret")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if v != nil {
+			if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w.Code
+	}
+	q := "?path=" + path
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/connections", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("got status %d, expected %d", w.Code, http.StatusUnauthorized)
+	}
+
+	var connections []DatabaseStats
+	if code := request(http.MethodGet, "/connections", &connections); code != http.StatusOK {
+		t.Fatalf("got status %d", code)
+	}
+	if len(connections) != 1 || connections[0].Path != path {
+		t.Errorf("got connections %+v", connections)
+	}
+
+	var info DatabaseInfo
+	if code := request(http.MethodGet, "/database"+q, &info); code != http.StatusOK {
+		t.Fatalf("got status %d", code)
+	}
+	if !info.Open || info.Size == 0 || info.References != 1 {
+		t.Errorf("got database info %+v", info)
+	}
+
+	var buckets []string
+	if code := request(http.MethodGet, "/buckets"+q, &buckets); code != http.StatusOK {
+		t.Fatalf("got status %d", code)
+	}
+	if !reflect.DeepEqual(buckets, []string{"a", "b"}) {
+		t.Errorf("got buckets %v", buckets)
+	}
+
+	if code := request(http.MethodGet, "/database", nil); code != http.StatusBadRequest {
+		t.Errorf("got status %d, expected %d", code, http.StatusBadRequest)
+	}
+	if code := request(http.MethodGet, "/compact"+q, nil); code != http.StatusMethodNotAllowed {
+		t.Errorf("got status %d, expected %d", code, http.StatusMethodNotAllowed)
+	}
+	if code := request(http.MethodPost, "/evict"+q, nil); code != http.StatusConflict {
+		t.Errorf("got status %d, expected %d", code, http.StatusConflict)
+	}
+	if code := request(http.MethodPost, "/backup"+q, nil); code != http.StatusOK {
+		t.Errorf("got status %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(backups, pool.backupName(path))); err != nil {
+		t.Error(err)
+	}
+
+	c.Close()
+	if code := request(http.MethodPost, "/evict"+q, nil); code != http.StatusOK {
+		t.Errorf("got status %d", code)
+	}
+	if pool.Has(path) {
+		t.Error("evicted database is open")
+	}
+	if code := request(http.MethodPost, "/evict"+q, nil); code != http.StatusNotFound {
+		t.Errorf("got status %d, expected %d", code, http.StatusNotFound)
+	}
+	if code := request(http.MethodPost, "/compact"+q, nil); code != http.StatusOK {
+		t.Errorf("got status %d", code)
+	}
+	if code := request(http.MethodGet, "/database?path="+filepath.Join(dir, "missing.db"), nil); code != http.StatusNotFound {
+		t.Errorf("got status %d, expected %d", code, http.StatusNotFound)
+	}
+}
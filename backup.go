@@ -0,0 +1,51 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"io"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Backup writes a consistent copy of the connection database to w within a
+// read transaction and returns the number of bytes written.
+func (c *Connection) Backup(w io.Writer) (n int64, err error) {
+	err = c.DB.View(func(tx *bolt.Tx) error {
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	return n, err
+}
+
+// Backup writes a consistent copy of the database on path to w. The database
+// is opened through the pool if it is not already open, and it is released
+// after the backup in respect to the pool expiration options. An error is
+// returned if the database file does not exist.
+func (p *Pool) Backup(path string, w io.Writer) (n int64, err error) {
+	c, err := p.getExisting(path)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+
+	return c.Backup(w)
+}
+
+// getExisting returns a connection for a database that is already in the
+// pool or that exists on disk, without creating a new database file.
+func (p *Pool) getExisting(path string) (*Connection, error) {
+	path, err := p.normalizePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if !p.Has(path) {
+		if _, err := p.fs.Stat(path); err != nil {
+			return nil, err
+		}
+	}
+	return p.Get(path)
+}
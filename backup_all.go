@@ -0,0 +1,194 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// BackupChecksumsName is the name of the last entry in archives created by
+// Pool.BackupAll that holds SHA256 checksums of all other entries in the
+// format of the sha256sum utility.
+const BackupChecksumsName = "SHA256SUMS"
+
+// BackupAll writes a tar archive to w with a consistent copy of every
+// database that is open in the pool. If Options.BackupRoot is set, all
+// other files under that directory are included, too. Databases open in
+// the pool are copied within a read transaction, while other files are
+// copied as they are. Entry names are relative to the BackupRoot for files
+// under it. The last entry in the archive holds checksums of all files.
+func (p *Pool) BackupAll(w io.Writer) error {
+	root, err := p.backupRoot()
+	if err != nil {
+		return err
+	}
+	open, paths, err := p.backupPaths(root)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	var sums strings.Builder
+	for _, path := range paths {
+		name := backupEntryName(root, path)
+		h := sha256.New()
+		if err := p.backupFile(tw, h, name, path, open[path]); err != nil {
+			return fmt.Errorf("boltdbpool: backup %s: %w", path, err)
+		}
+		fmt.Fprintf(&sums, "%s  %s\n", hex.EncodeToString(h.Sum(nil)), name)
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    BackupChecksumsName,
+		Mode:    0644,
+		Size:    int64(sums.Len()),
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(tw, sums.String()); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// backupFile writes a single archive entry and its content to tw and h.
+// Databases that are open in the pool are copied within a transaction.
+func (p *Pool) backupFile(tw *tar.Writer, h io.Writer, name, path string, open bool) error {
+	if open {
+		c, err := p.getExisting(path)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+
+		return c.DB.View(func(tx *bolt.Tx) error {
+			if err := tw.WriteHeader(&tar.Header{
+				Name:    name,
+				Mode:    0644,
+				Size:    tx.Size(),
+				ModTime: time.Now(),
+			}); err != nil {
+				return err
+			}
+			_, err := tx.WriteTo(io.MultiWriter(tw, h))
+			return err
+		})
+	}
+
+	f, err := p.fs.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.CopyN(io.MultiWriter(tw, h), f, info.Size())
+	return err
+}
+
+// backupRoot returns Options.BackupRoot normalized as database paths are,
+// so that files under it can be matched with databases open in the pool.
+func (p *Pool) backupRoot() (string, error) {
+	if p.options.BackupRoot == "" {
+		return "", nil
+	}
+	return p.normalizePath(p.options.BackupRoot)
+}
+
+// backupPaths returns sorted paths of all open databases and files under
+// the normalized backup root, and a set of paths of open databases.
+func (p *Pool) backupPaths(root string) (open map[string]bool, paths []string, err error) {
+	open = map[string]bool{}
+	seen := map[string]struct{}{}
+	p.mu.RLock()
+	for path := range p.connections {
+		open[path] = true
+		seen[path] = struct{}{}
+	}
+	p.mu.RUnlock()
+
+	if root != "" {
+		if err := p.backupDir(root, seen); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	paths = make([]string, 0, len(seen))
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return open, paths, nil
+}
+
+// backupDir adds normalized paths of all regular files under the directory
+// to seen, reading directories from the pool filesystem.
+func (p *Pool) backupDir(dir string, seen map[string]struct{}) error {
+	entries, err := p.fs.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if e.IsDir() {
+			if err := p.backupDir(path, seen); err != nil {
+				return err
+			}
+			continue
+		}
+		if !e.Type().IsRegular() {
+			continue
+		}
+		// files of open databases are copied within transactions
+		if normalized, err := p.normalizePath(path); err == nil {
+			path = normalized
+		}
+		seen[path] = struct{}{}
+	}
+	return nil
+}
+
+// backupName returns the name of the archive entry for the path.
+func (p *Pool) backupName(path string) string {
+	root, err := p.backupRoot()
+	if err != nil {
+		root = ""
+	}
+	return backupEntryName(root, path)
+}
+
+// backupEntryName returns the name of the archive entry for the path,
+// relative to the normalized backup root if it is under it.
+func backupEntryName(root, path string) string {
+	if root != "" {
+		if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return strings.TrimPrefix(filepath.ToSlash(path), "/")
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackupAll(t *testing.T) {
+	root := t.TempDir()
+	pool := New(&Options{
+		BackupRoot: root,
+	})
+	defer pool.Close()
+
+	for _, name := range []string{"a.db", filepath.Join("tenants", "b.db")} {
+		c, err := pool.Get(filepath.Join(root, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+	}
+	outside := filepath.Join(t.TempDir(), "outside.db")
+	c, err := pool.Get(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := os.WriteFile(filepath.Join(root, "notes.txt"), []byte("notes"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := pool.BackupAll(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	sums := map[string]string{}
+	var checksums string
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Name == BackupChecksumsName {
+			checksums = string(data)
+			continue
+		}
+		sum := sha256.Sum256(data)
+		sums[hdr.Name] = hex.EncodeToString(sum[:])
+	}
+
+	for _, name := range []string{"a.db", "tenants/b.db", "notes.txt", strings.TrimPrefix(filepath.ToSlash(outside), "/")} {
+		sum, ok := sums[name]
+		if !ok {
+			t.Errorf("entry %s not found in archive", name)
+			continue
+		}
+		if !strings.Contains(checksums, sum+"  "+name+"\n") {
+			t.Errorf("checksum for %s not found in %q", name, checksums)
+		}
+	}
+	if len(sums) != 4 {
+		t.Errorf("got %d entries, expected 4", len(sums))
+	}
+}
+
+func TestBackupAllRelativeRoot(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	if err := os.Mkdir("d", 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	pool := New(&Options{
+		BackupRoot: "d",
+	})
+	defer pool.Close()
+
+	c, err := pool.Get(filepath.Join("d", "a.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var buf bytes.Buffer
+	if err := pool.BackupAll(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	if want := []string{"a.db", BackupChecksumsName}; strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("got entries %v, expected %v", names, want)
+	}
+}
+
+// readDirFailFS fails reading directories with err.
+type readDirFailFS struct {
+	OSFS
+	err error
+}
+
+func (fs readDirFailFS) ReadDir(name string) ([]os.DirEntry, error) {
+	return nil, fs.err
+}
+
+func TestBackupAllFS(t *testing.T) {
+	errTest := errors.New("test error")
+	pool := New(&Options{
+		BackupRoot: t.TempDir(),
+		FS:         readDirFailFS{err: errTest},
+	})
+	defer pool.Close()
+
+	if err := pool.BackupAll(io.Discard); !errors.Is(err, errTest) {
+		t.Errorf("got error %v, expected %v", err, errTest)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInvalidBackupName is returned by DirBackupTarget.Store if the backup
+// name is absolute or outside of the target directory.
+var ErrInvalidBackupName = errors.New("boltdbpool: invalid backup name")
+
+// BackupTarget stores backups produced by the pool. Implementations can
+// ship backups to remote storage services.
+type BackupTarget interface {
+	// Store saves the content read from r under the name. The name is a
+	// slash-separated relative path. If the reader returns an error, the
+	// backup must be discarded and that error returned.
+	Store(name string, r io.Reader) error
+}
+
+// DirBackupTarget is a BackupTarget that stores backups as files in a
+// directory. Files are written atomically, so that incomplete backups are
+// never visible under their names.
+type DirBackupTarget struct {
+	Dir string
+}
+
+// Store writes data from r to the file with name in the target directory.
+// Names that are absolute or that are outside of the directory are
+// rejected with ErrInvalidBackupName.
+func (d DirBackupTarget) Store(name string, r io.Reader) (err error) {
+	name = filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(name) || strings.HasPrefix(name, string(filepath.Separator)) || name == "." || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return ErrInvalidBackupName
+	}
+	path := filepath.Join(d.Dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err = io.Copy(f, r); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// BackupTo stores a consistent copy of the database on path to the target.
+// The backup name is the same as the archive entry name in Pool.BackupAll.
+func (p *Pool) BackupTo(path string, target BackupTarget) error {
+	path, err := p.normalizePath(path)
+	if err != nil {
+		return err
+	}
+	c, err := p.getExisting(path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return storeBackup(target, p.backupName(path), func(w io.Writer) error {
+		_, err := c.Backup(w)
+		return err
+	})
+}
+
+// BackupAllTo stores the archive produced by Pool.BackupAll to the target
+// under the name.
+func (p *Pool) BackupAllTo(name string, target BackupTarget) error {
+	return storeBackup(target, name, p.BackupAll)
+}
+
+// storeBackup streams data written by the write function to the target. It
+// returns after the write function returns, with errors from both.
+func storeBackup(target BackupTarget, name string, write func(io.Writer) error) error {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := write(pw)
+		pw.CloseWithError(err)
+		done <- err
+	}()
+	err := target.Store(name, pr)
+	// unblock the writer if the target did not read all data
+	pr.CloseWithError(io.ErrClosedPipe)
+	werr := <-done
+	switch {
+	case werr == nil:
+		return err
+	case err == nil:
+		return werr
+	case errors.Is(werr, io.ErrClosedPipe), errors.Is(err, werr):
+		// the writer is stopped by the target error or the target
+		// reports the writer error
+		return err
+	}
+	return errors.Join(err, werr)
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBackupTo(t *testing.T) {
+	root := t.TempDir()
+	pool := New(&Options{
+		BackupRoot: root,
+	})
+	defer pool.Close()
+
+	path := filepath.Join(root, "tenants", "a.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	target := DirBackupTarget{Dir: t.TempDir()}
+	if err := pool.BackupTo(path, target); err != nil {
+		t.Fatal(err)
+	}
+	db, err := bolt.Open(filepath.Join(target.Dir, "tenants", "a.db"), 0666, &bolt.Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if err := pool.BackupAllTo("all.tar", target); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filepath.Join(target.Dir, "all.tar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	hdr, err := tar.NewReader(f).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Name != "tenants/a.db" {
+		t.Errorf("got entry %q, expected %q", hdr.Name, "tenants/a.db")
+	}
+}
+
+type failingTarget struct{}
+
+func (failingTarget) Store(name string, r io.Reader) error {
+	return errors.New("store failed")
+}
+
+func TestBackupToError(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	c, err := pool.Get(filepath.Join(t.TempDir(), "a.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := pool.BackupAllTo("all.tar", failingTarget{}); err == nil || err.Error() != "store failed" {
+		t.Errorf("got error %v, expected store failed", err)
+	}
+}
+
+// discardingTarget returns without reading the backup.
+type discardingTarget struct {
+	err error
+}
+
+func (t discardingTarget) Store(name string, r io.Reader) error {
+	return t.err
+}
+
+func TestStoreBackupWaitsForWriter(t *testing.T) {
+	var finished bool
+	write := func(w io.Writer) error {
+		_, err := w.Write([]byte("data"))
+		finished = true
+		return err
+	}
+
+	// an incomplete backup is reported
+	if err := storeBackup(discardingTarget{}, "a.db", write); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("got error %v, expected %v", err, io.ErrClosedPipe)
+	}
+	if !finished {
+		t.Error("returned before the writer finished")
+	}
+
+	errStore := errors.New("store failed")
+	errWrite := errors.New("write failed")
+	err := storeBackup(discardingTarget{err: errStore}, "a.db", func(io.Writer) error {
+		return errWrite
+	})
+	if !errors.Is(err, errStore) || !errors.Is(err, errWrite) {
+		t.Errorf("got error %v, expected %v and %v", err, errStore, errWrite)
+	}
+}
+
+func TestDirBackupTargetInvalidName(t *testing.T) {
+	base := t.TempDir()
+	target := DirBackupTarget{Dir: filepath.Join(base, "target")}
+	for _, name := range []string{"../x.db", "a/../../x.db", "/x.db", "..", "."} {
+		if err := target.Store(name, strings.NewReader("data")); !errors.Is(err, ErrInvalidBackupName) {
+			t.Errorf("%q: got error %v, expected %v", name, err, ErrInvalidBackupName)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(base, "x.db")); !os.IsNotExist(err) {
+		t.Errorf("got error %v for file outside of target directory", err)
+	}
+
+	if err := target.Store("a/./b.db", strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(target.Dir, "a", "b.db")); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBackup(t *testing.T) {
+	dir := t.TempDir()
+	pool := New(nil)
+	defer pool.Close()
+
+	path := filepath.Join(dir, "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("bucket"))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("key"), []byte("value"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	n, err := pool.Backup(path, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("got %d bytes written, buffer has %d", n, buf.Len())
+	}
+	if c.count != 1 {
+		t.Errorf("connection reference counter is not 1 after backup: %d", c.count)
+	}
+	c.Close()
+
+	backupPath := filepath.Join(dir, "backup.db")
+	if err := os.WriteFile(backupPath, buf.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+	db, err := bolt.Open(backupPath, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.View(func(tx *bolt.Tx) error {
+		if v := string(tx.Bucket([]byte("bucket")).Get([]byte("key"))); v != "value" {
+			t.Errorf("got %q, expected %q", v, "value")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pool.Backup(filepath.Join(dir, "missing.db"), &buf); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+}
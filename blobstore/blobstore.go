@@ -0,0 +1,416 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package blobstore implements a content-addressable store of blobs in a
+// database managed by boltdbpool.Pool. Blobs are identified by their SHA-256
+// hashes and large blobs are split into chunks that are shared between
+// blobs with the same content.
+package blobstore // import "resenje.org/boltdbpool/blobstore"
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+
+	bolt "go.etcd.io/bbolt"
+
+	"resenje.org/boltdbpool"
+)
+
+var (
+	// ErrNotFound is returned when a blob is not in the store.
+	ErrNotFound = errors.New("blob not found")
+	// ErrInvalidHash is returned by ParseHash for strings that are not hex
+	// encoded SHA-256 hashes.
+	ErrInvalidHash = errors.New("invalid blob hash")
+	// ErrInvalidBlob is returned when a stored blob or chunk can not be
+	// decoded or its chunks are missing.
+	ErrInvalidBlob = errors.New("invalid blob")
+)
+
+// DefaultChunkSize is used when Options.ChunkSize is not set.
+const DefaultChunkSize = 256 * 1024
+
+// gcBatchSize is the maximal number of chunks removed in a single
+// transaction by Store.GC.
+const gcBatchSize = 1000
+
+// Options configure a Store.
+type Options struct {
+	// ChunkSize is the size of chunks in bytes. Blobs larger than the chunk
+	// size are split into chunks that are stored under their own hashes, so
+	// that bolt does not need to allocate large contiguous overflow pages.
+	// Smaller blobs are stored in a single value.
+	ChunkSize int
+}
+
+// Hash is the SHA-256 hash of the blob content that identifies it.
+type Hash [sha256.Size]byte
+
+// ParseHash decodes the hex encoded hash.
+func ParseHash(s string) (h Hash, err error) {
+	if hex.DecodedLen(len(s)) != len(h) {
+		return h, ErrInvalidHash
+	}
+	if _, err := hex.Decode(h[:], []byte(s)); err != nil {
+		return h, ErrInvalidHash
+	}
+	return h, nil
+}
+
+// String returns the hex encoded hash.
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// Store keeps blobs in the database on path. Every Put of a blob increments
+// its reference count and every Delete decrements it, so that the blob is
+// removed when it is not referenced. Chunks that are no longer referenced
+// by any blob are removed by GC.
+type Store struct {
+	pool      *boltdbpool.Pool
+	path      string
+	chunkSize int
+
+	blobsBucket  []byte
+	chunksBucket []byte
+}
+
+// New returns a Store with the name that keeps blobs in the database on
+// path. Multiple stores may be kept in the same database.
+func New(pool *boltdbpool.Pool, path, name string, options *Options) *Store {
+	if options == nil {
+		options = &Options{}
+	}
+	s := &Store{
+		pool:         pool,
+		path:         path,
+		chunkSize:    options.ChunkSize,
+		blobsBucket:  []byte(name + ".blobs"),
+		chunksBucket: []byte(name + ".chunks"),
+	}
+	if s.chunkSize <= 0 {
+		s.chunkSize = DefaultChunkSize
+	}
+	return s
+}
+
+// Put stores the blob or increments its reference count if it is already
+// stored, and returns its hash.
+func (s *Store) Put(data []byte) (h Hash, err error) {
+	h = sha256.Sum256(data)
+	err = s.update(func(b *buckets) error {
+		if v := b.blobs.Get(h[:]); v != nil {
+			r, err := decodeBlob(v)
+			if err != nil {
+				return err
+			}
+			r.refs++
+			return b.blobs.Put(h[:], r.encode())
+		}
+		r := blob{refs: 1, size: uint64(len(data))}
+		if len(data) <= s.chunkSize {
+			r.data = data
+			return b.blobs.Put(h[:], r.encode())
+		}
+		for len(data) > 0 {
+			n := s.chunkSize
+			if n > len(data) {
+				n = len(data)
+			}
+			ch, err := b.addChunk(data[:n])
+			if err != nil {
+				return err
+			}
+			r.chunks = append(r.chunks, ch)
+			data = data[n:]
+		}
+		return b.blobs.Put(h[:], r.encode())
+	})
+	return h, err
+}
+
+// Get returns the content of the blob.
+func (s *Store) Get(h Hash) (data []byte, err error) {
+	err = s.view(func(b *buckets) error {
+		r, err := b.blob(h)
+		if err != nil {
+			return err
+		}
+		data = make([]byte, 0, r.size)
+		return b.readChunks(r, func(p []byte) error {
+			data = append(data, p...)
+			return nil
+		})
+	})
+	return data, err
+}
+
+// WriteTo writes the content of the blob to w, one chunk at a time, within
+// a single read transaction.
+func (s *Store) WriteTo(h Hash, w io.Writer) (n int64, err error) {
+	err = s.view(func(b *buckets) error {
+		r, err := b.blob(h)
+		if err != nil {
+			return err
+		}
+		return b.readChunks(r, func(p []byte) error {
+			m, err := w.Write(p)
+			n += int64(m)
+			return err
+		})
+	})
+	return n, err
+}
+
+// Stat returns the size of the blob and its reference count.
+func (s *Store) Stat(h Hash) (size int64, refs uint64, err error) {
+	err = s.view(func(b *buckets) error {
+		r, err := b.blob(h)
+		if err != nil {
+			return err
+		}
+		size = int64(r.size)
+		refs = r.refs
+		return nil
+	})
+	return size, refs, err
+}
+
+// Delete decrements the reference count of the blob and removes it when it
+// drops to 0. Chunks of removed blobs are removed by GC when they are not
+// referenced by other blobs.
+func (s *Store) Delete(h Hash) error {
+	return s.update(func(b *buckets) error {
+		r, err := b.blob(h)
+		if err != nil {
+			return err
+		}
+		r.refs--
+		if r.refs > 0 {
+			return b.blobs.Put(h[:], r.encode())
+		}
+		if err := b.blobs.Delete(h[:]); err != nil {
+			return err
+		}
+		for _, ch := range r.chunks {
+			if err := b.releaseChunk(ch); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GC removes chunks that are not referenced by any blob and returns the
+// number of removed chunks. Chunks are removed in multiple transactions.
+func (s *Store) GC() (removed int, err error) {
+	for {
+		var keys [][]byte
+		if err := s.view(func(b *buckets) error {
+			c := b.chunks.Cursor()
+			for k, v := c.First(); k != nil && len(keys) < gcBatchSize; k, v = c.Next() {
+				if len(v) < chunkHeaderSize {
+					return ErrInvalidBlob
+				}
+				if binary.BigEndian.Uint64(v) == 0 {
+					keys = append(keys, append([]byte(nil), k...))
+				}
+			}
+			return nil
+		}); err != nil {
+			if err == ErrNotFound {
+				// store is empty
+				return removed, nil
+			}
+			return removed, err
+		}
+		if len(keys) == 0 {
+			return removed, nil
+		}
+		if err := s.update(func(b *buckets) error {
+			for _, k := range keys {
+				// the chunk may be referenced again by a Put after it was
+				// found
+				if v := b.chunks.Get(k); len(v) < chunkHeaderSize || binary.BigEndian.Uint64(v) != 0 {
+					continue
+				}
+				if err := b.chunks.Delete(k); err != nil {
+					return err
+				}
+				removed++
+			}
+			return nil
+		}); err != nil {
+			return removed, err
+		}
+		if len(keys) < gcBatchSize {
+			return removed, nil
+		}
+	}
+}
+
+type buckets struct {
+	blobs  *bolt.Bucket
+	chunks *bolt.Bucket
+}
+
+func (b *buckets) blob(h Hash) (blob, error) {
+	v := b.blobs.Get(h[:])
+	if v == nil {
+		return blob{}, ErrNotFound
+	}
+	return decodeBlob(v)
+}
+
+// addChunk stores the chunk or increments its reference count and returns
+// its hash.
+func (b *buckets) addChunk(data []byte) (h Hash, err error) {
+	h = sha256.Sum256(data)
+	refs := uint64(1)
+	if v := b.chunks.Get(h[:]); v != nil {
+		if len(v) < chunkHeaderSize {
+			return h, ErrInvalidBlob
+		}
+		refs += binary.BigEndian.Uint64(v)
+	}
+	v := make([]byte, chunkHeaderSize+len(data))
+	binary.BigEndian.PutUint64(v, refs)
+	copy(v[chunkHeaderSize:], data)
+	return h, b.chunks.Put(h[:], v)
+}
+
+// releaseChunk decrements the reference count of the chunk.
+func (b *buckets) releaseChunk(h Hash) error {
+	v := b.chunks.Get(h[:])
+	if len(v) < chunkHeaderSize {
+		return ErrInvalidBlob
+	}
+	refs := binary.BigEndian.Uint64(v)
+	if refs == 0 {
+		return nil
+	}
+	n := append([]byte(nil), v...)
+	binary.BigEndian.PutUint64(n, refs-1)
+	return b.chunks.Put(h[:], n)
+}
+
+// readChunks calls fn with the blob content, one chunk at a time. The data
+// is valid only during the call.
+func (b *buckets) readChunks(r blob, fn func(p []byte) error) error {
+	if r.chunks == nil {
+		return fn(r.data)
+	}
+	for _, ch := range r.chunks {
+		v := b.chunks.Get(ch[:])
+		if len(v) < chunkHeaderSize {
+			return ErrInvalidBlob
+		}
+		if err := fn(v[chunkHeaderSize:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) update(fn func(*buckets) error) error {
+	c, err := s.pool.Get(s.path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.Update(func(tx *bolt.Tx) error {
+		blobs, err := tx.CreateBucketIfNotExists(s.blobsBucket)
+		if err != nil {
+			return err
+		}
+		chunks, err := tx.CreateBucketIfNotExists(s.chunksBucket)
+		if err != nil {
+			return err
+		}
+		return fn(&buckets{blobs: blobs, chunks: chunks})
+	})
+}
+
+func (s *Store) view(fn func(*buckets) error) error {
+	c, err := s.pool.Get(s.path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.View(func(tx *bolt.Tx) error {
+		b := buckets{
+			blobs:  tx.Bucket(s.blobsBucket),
+			chunks: tx.Bucket(s.chunksBucket),
+		}
+		if b.blobs == nil || b.chunks == nil {
+			// store is empty until the first blob is put
+			return ErrNotFound
+		}
+		return fn(&b)
+	})
+}
+
+// chunkHeaderSize is the size of the reference count that precedes the
+// chunk data.
+const chunkHeaderSize = 8
+
+// blobHeaderSize is the size of the reference count, the blob size and the
+// chunked flag that precede inline data or chunk hashes.
+const blobHeaderSize = 8 + 8 + 1
+
+// blob is the stored blob record. Blobs that are not larger than the chunk
+// size have inline data and others have the list of chunk hashes.
+type blob struct {
+	refs   uint64
+	size   uint64
+	data   []byte
+	chunks []Hash
+}
+
+func (r blob) encode() []byte {
+	n := len(r.data)
+	if r.chunks != nil {
+		n = len(r.chunks) * sha256.Size
+	}
+	b := make([]byte, blobHeaderSize, blobHeaderSize+n)
+	binary.BigEndian.PutUint64(b, r.refs)
+	binary.BigEndian.PutUint64(b[8:], r.size)
+	if r.chunks == nil {
+		return append(b, r.data...)
+	}
+	b[16] = 1
+	for _, h := range r.chunks {
+		b = append(b, h[:]...)
+	}
+	return b
+}
+
+func decodeBlob(b []byte) (blob, error) {
+	if len(b) < blobHeaderSize {
+		return blob{}, ErrInvalidBlob
+	}
+	r := blob{
+		refs: binary.BigEndian.Uint64(b),
+		size: binary.BigEndian.Uint64(b[8:]),
+	}
+	rest := b[blobHeaderSize:]
+	if b[16] == 0 {
+		r.data = rest
+		return r, nil
+	}
+	if len(rest)%sha256.Size != 0 {
+		return blob{}, ErrInvalidBlob
+	}
+	r.chunks = make([]Hash, len(rest)/sha256.Size)
+	for i := range r.chunks {
+		copy(r.chunks[i][:], rest[i*sha256.Size:])
+	}
+	return r, nil
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package blobstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"resenje.org/boltdbpool"
+)
+
+func newTestStore(t *testing.T, options *Options) *Store {
+	t.Helper()
+
+	pool := boltdbpool.New(nil)
+	t.Cleanup(func() { pool.Close() })
+	return New(pool, filepath.Join(t.TempDir(), "blobs.db"), "attachments", options)
+}
+
+func TestStore(t *testing.T) {
+	s := newTestStore(t, &Options{ChunkSize: 4})
+
+	if _, err := s.Get(Hash{}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got error %v, expected %v", err, ErrNotFound)
+	}
+	if n, err := s.GC(); err != nil || n != 0 {
+		t.Fatalf("got gc %d (%v), expected 0", n, err)
+	}
+
+	for _, data := range [][]byte{
+		{},
+		[]byte("abc"),
+		[]byte("abcd"),
+		[]byte("abcdefghij"),
+	} {
+		h, err := s.Put(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h != sha256.Sum256(data) {
+			t.Errorf("got hash %s for %q", h, data)
+		}
+		got, err := s.Get(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("got blob %q, expected %q", got, data)
+		}
+		var buf bytes.Buffer
+		n, err := s.WriteTo(h, &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(len(data)) || !bytes.Equal(buf.Bytes(), data) {
+			t.Errorf("got written %d %q, expected %q", n, buf.Bytes(), data)
+		}
+		size, refs, err := s.Stat(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != int64(len(data)) || refs != 1 {
+			t.Errorf("got stat %d %d, expected %d 1", size, refs, len(data))
+		}
+	}
+}
+
+func TestStoreReferences(t *testing.T) {
+	s := newTestStore(t, &Options{ChunkSize: 4})
+
+	// blobs share the chunks "abcd" and "efgh"
+	a, err := s.Put([]byte("abcdefgh1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.Put([]byte("abcdefgh2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put([]byte("abcdefgh1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, refs, err := s.Stat(a); err != nil || refs != 2 {
+		t.Fatalf("got refs %d (%v), expected 2", refs, err)
+	}
+
+	if err := s.Delete(a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(a); err != nil {
+		t.Fatalf("blob removed while referenced: %v", err)
+	}
+	if err := s.Delete(a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(a); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got error %v, expected %v", err, ErrNotFound)
+	}
+	if err := s.Delete(a); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got error %v, expected %v", err, ErrNotFound)
+	}
+
+	// only the "1" chunk is not referenced
+	if n, err := s.GC(); err != nil || n != 1 {
+		t.Fatalf("got gc %d (%v), expected 1", n, err)
+	}
+	if got, err := s.Get(b); err != nil || string(got) != "abcdefgh2" {
+		t.Fatalf("got blob %q (%v)", got, err)
+	}
+
+	if err := s.Delete(b); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.GC(); err != nil || n != 3 {
+		t.Fatalf("got gc %d (%v), expected 3", n, err)
+	}
+}
+
+func TestParseHash(t *testing.T) {
+	h := sha256.Sum256([]byte("test"))
+	got, err := ParseHash(Hash(h).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != h {
+		t.Errorf("got hash %s, expected %x", got, h)
+	}
+	for _, s := range []string{"", "abc", Hash(h).String()[1:] + "x"} {
+		if _, err := ParseHash(s); !errors.Is(err, ErrInvalidHash) {
+			t.Errorf("got error %v for %q, expected %v", err, s, ErrInvalidHash)
+		}
+	}
+}
@@ -49,11 +49,14 @@ Example:
 package boltdbpool // import "resenje.org/boltdbpool"
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	bolt "go.etcd.io/bbolt"
@@ -64,126 +67,847 @@ var (
 	DefaultErrorHandler = func(err error) {
 		log.Printf("error: %v", err)
 	}
+
+	// ErrClosed is returned by Pool.Get when the pool is closed.
+	ErrClosed = errors.New("boltdbpool: pool closed")
+
+	// ErrPathOutsideRoot is returned for database paths that are not in the
+	// Options.Root directory.
+	ErrPathOutsideRoot = errors.New("boltdbpool: path outside of root directory")
+
+	// ErrReadOnly is returned by methods that write to databases or database
+	// files when Options.ReadOnly is set.
+	ErrReadOnly = errors.New("boltdbpool: read-only pool")
 )
 
 // Options are used when a new pool is created that.
 type Options struct {
-	// BoltOptions is used on bolt.Open().
+	// BoltOptions is used on bolt.Open() for every database, so all bolt
+	// options, like InitialMmapSize, PageSize, FreelistType, Mlock,
+	// PreLoadFreelist and OpenFile, are applied as they are set.
 	BoltOptions *bolt.Options
 
+	// PathOptions, if set, is called every time a database is opened by the
+	// pool to get bolt options and the file mode for the database on path,
+	// so that databases in different locations can be opened differently.
+	// If it returns nil options, BoltOptions are used, and if it returns 0
+	// file mode, 0666 is used.
+	PathOptions func(path string) (*bolt.Options, os.FileMode)
+
+	// LargeFileSize is the file size in bytes from which existing databases
+	// are opened with defaults suited for large files, for bolt options that
+	// are not set by BoltOptions or PathOptions: the hashmap freelist, which
+	// is faster than the array freelist on large free page counts, and the
+	// initial memory map size of twice the file size, so that readers are
+	// not blocked by remapping while the file grows. If the value is 0
+	// (default), bolt defaults are used.
+	LargeFileSize int64
+
+	// FallbackReadOnly, if true, opens a database read-only when it can not
+	// be opened for writing because another process holds its lock, so that
+	// tools can inspect databases that are in use. Bolt waits for the lock
+	// indefinitely, unless the Timeout bolt option is set. Buckets from
+	// EnsureBuckets are not created in databases opened this way. They
+	// remain read-only until they are closed and opened again.
+	FallbackReadOnly bool
+
+	// OpenDeadline, if positive, is the maximal duration of creating
+	// directories and opening a database file by Pool.Get, after which
+	// OpenTimeoutError is returned. Unlike the Timeout bolt option, which
+	// limits only waiting for the file lock, it also covers slow
+	// filesystem operations, like metadata operations on network
+	// filesystems. Operations that exceed the deadline are not interrupted,
+	// and databases opened after it are closed.
+	OpenDeadline time.Duration
+
+	// MemoryBudget, if positive, is the maximal sum of sizes of open
+	// database files, which approximates the address space used by their
+	// memory maps. When it is exceeded, databases with no references are
+	// closed before their connections expire, in the order of
+	// EvictionPolicy. Databases that are in use are never closed, so the budget can
+	// be exceeded. It has no effect if ConnectionExpires is 0, as databases
+	// are closed when they are released.
+	MemoryBudget int64
+
+	// EvictionPolicy decides which databases with no references are closed
+	// first when the pool is over MemoryBudget. If nil, EvictLRU is used.
+	EvictionPolicy EvictionPolicy
+
+	// MaxParked, if positive, is the number of databases that are parked
+	// instead of closed when their connections expire. A parked database
+	// is synced to disk and removed from the pool, but it is kept open, so
+	// that Get revives it without opening the file again, if the file is
+	// not changed in the meantime. Its labels, usage counters and write
+	// rate limiter are kept, too. Revived databases are not passed to
+	// OnOpen again, nor are EnsureBuckets and Prefault applied. Databases
+	// parked open are counted in MemoryBudget and they are closed, least
+	// recently parked first, before other databases are evicted. Databases
+	// that are compacted on close are parked closed, keeping only their
+	// state.
+	// Least recently parked databases are closed and forgotten first.
+	MaxParked int
+
+	// MaxBackgroundWorkers, if positive, is the maximal number of
+	// functions that the pool runs in the background at the same time,
+	// like closing of expired databases, compactions, prefaulting and
+	// maintenance tasks. Expired databases are then closed in the
+	// background, and maintenance tasks run for multiple databases in
+	// parallel, so that a slow operation on one database, for example on a
+	// network filesystem, does not delay others. Get waits for a database
+	// that is being closed in the background. If the value is 0 (default),
+	// expired databases are closed and maintenance tasks run one at a time.
+	MaxBackgroundWorkers int
+
+	// WriteRateLimit, if set, returns the limit of write transactions for
+	// the database on path, that is applied when the database is opened.
+	// Connection Update, Batch and value helper methods return
+	// RateLimitError when the limit is exceeded, so that clients writing
+	// heavily to one database do not starve others on the same disk.
+	WriteRateLimit func(path string) RateLimit
+
+	// ReadReplicas, if set, returns the interval of refreshing the read
+	// replica of the database on path, which is a snapshot served by
+	// Pool.GetReplica, so that long readers do not hold transactions on the
+	// live database. Databases for which it returns 0 have no replicas.
+	ReadReplicas func(path string) time.Duration
+
+	// WriteCoalescing, if set, returns the configuration of coalescing of
+	// writes done with Pool.Coalesce to the database on path. It is called
+	// once for every database. If nil, Coalescing defaults are used.
+	WriteCoalescing func(path string) Coalescing
+
+	// Prefault, if true, reads every database file in the background after
+	// it is opened, so that the first accesses to its memory map do not
+	// wait for the disk.
+	Prefault bool
+
+	// OnPrefault, if set, is called when a database file is read in the
+	// background because Prefault is set, with the error if reading failed.
+	// If nil, errors are passed to the ErrorHandler.
+	OnPrefault func(path string, err error)
+
+	// FS is the filesystem on which database files are opened, created,
+	// renamed and removed. If the value is nil (default), OSFS is used.
+	// BoltOptions.OpenFile, if set, takes precedence for opening database
+	// files.
+	FS FS
+
+	// Permissions, if set, are applied to database files and directories
+	// that the pool creates, including files that replace databases after
+	// compaction, cloning, restoring and recovery. The FS must implement
+	// PermissionsFS.
+	Permissions *Permissions
+
 	// ConnectionExpires is a duration between the reference count drops to 0 and
 	// the time when the database is closed. It is useful to avoid frequent
 	// openings of the same database. If the value is 0 (default), no caching is done.
 	ConnectionExpires time.Duration
 
+	// ExpirySweepInterval is the interval between two checks for expired
+	// connections. If the value is 0 (default), a check is done
+	// ConnectionExpires after a connection reference count drops to 0.
+	ExpirySweepInterval time.Duration
+
+	// SyncInterval, if positive, opens databases with the NoSync flag set,
+	// so that commits do not wait for fsync calls, and the pool flushes
+	// every open database to disk at this interval and when it is closed.
+	// Writes committed in the last interval can be lost on a system crash,
+	// which trades durability for a much higher write throughput.
+	SyncInterval time.Duration
+
 	// ErrorHandler is the function that handles errors.
 	ErrorHandler func(error)
+
+	// EventsBufferSize, if positive, is the number of connection lifecycle
+	// events buffered in the channel returned by Pool.Events.
+	EventsBufferSize int
+
+	// PanicHandler, if set, is called with panics recovered from functions
+	// provided in options, like ErrorHandler, OnOpen, OnCorrupt, OnPrefault
+	// and maintenance tasks, so that they do not crash pool goroutines.
+	// Recovered panics are also handled as errors, except the ones from
+	// ErrorHandler, which are only recorded in recent errors and sent to
+	// Pool.Errors.
+	PanicHandler func(*PanicError)
+
+	// ErrorsBufferSize, if positive, is the number of errors buffered in the
+	// channel returned by Pool.Errors. If it is set and ErrorHandler is not,
+	// errors are not logged by DefaultErrorHandler.
+	ErrorsBufferSize int
+
+	// VerifyOnOpen returns the level of validation for the database on path
+	// that is performed when it is opened for the first time after the pool
+	// is created. Databases that are reopened after expiration are not
+	// validated again. If nil, no validation is done.
+	VerifyOnOpen func(path string) VerifyLevel
+
+	// Throttle, if set, pauses background maintenance work while latency of
+	// transactions executed with Connection View, Update and Batch methods
+	// is above its target.
+	Throttle *Throttle
+
+	// BackupRoot is a directory whose files are all included in archives
+	// created by Pool.BackupAll, in addition to databases open in the pool.
+	BackupRoot string
+
+	// StatsAuthorize, if set, is called by the handler returned by
+	// Pool.StatsHandler to authorize requests.
+	StatsAuthorize func(r *http.Request) bool
+
+	// AdminAuthorize, if set, is called by the handler returned by
+	// Pool.AdminHandler to authorize requests.
+	AdminAuthorize func(r *http.Request) bool
+
+	// Now returns the current time that is used for connection expiration.
+	// If nil, time.Now is used. It allows expiration to be controlled by a
+	// fake clock in tests.
+	Now func() time.Time
+
+	// CompactOnClose is the fragmentation threshold, the ratio of free pages
+	// to all database pages, above which a database is compacted in the
+	// background after it is closed because its connection expired. If the
+	// value is 0 (default), databases are not compacted.
+	CompactOnClose float64
+
+	// Maintenance configures periodic tasks that are executed for every
+	// database in the pool.
+	Maintenance *Maintenance
+
+	// Journal, if set, enables the write-ahead journal for Pool.Append and
+	// replays journals of databases when they are opened.
+	Journal *Journal
+
+	// StatsSampling, if set, periodically records pool stats that are
+	// returned by Pool.StatsHistory.
+	StatsSampling *StatsSampling
+
+	// OnCorrupt, if set, enables recovery of corrupted databases. When a
+	// database fails to open or Pool.Check reports corruption, the file is
+	// renamed by appending the ".corrupt" extension, readable buckets are
+	// copied to a new database file on the original path, and the function
+	// is called with the recovery report.
+	OnCorrupt func(Recovery)
+
+	// Encryption, if set, encrypts values stored with pool value helpers,
+	// like Connection.PutValue, and store layers that use Pool.EncodeValue.
+	Encryption *Encryption
+
+	// Compression, if set, compresses values stored with pool value helpers
+	// and store layers that use Pool.EncodeValue.
+	Compression *Compression
+
+	// MinFreeSpace is the number of bytes that must be available on the
+	// filesystem for the pool to create a new database file. Errors are
+	// returned by Get and passed to the ErrorHandler. If the value is 0
+	// (default), free space is not checked.
+	MinFreeSpace uint64
+
+	// NetworkFilesystem defines whether databases on network filesystems,
+	// like NFS and CIFS, are opened, opened with a warning or refused. The
+	// filesystem is detected on Linux, macOS and FreeBSD. The default is
+	// NetworkFilesystemAllow.
+	NetworkFilesystem NetworkFilesystemPolicy
+
+	// LowDiskSpaceReadOnly makes Connection Update, Batch and value helper
+	// methods return ErrLowDiskSpace while the free space is below
+	// MinFreeSpace.
+	LowDiskSpaceReadOnly bool
+
+	// RotateSize is the file size in bytes above which Pool.GetRotated
+	// creates a new database generation. If the value is 0 (default),
+	// databases are not rotated.
+	RotateSize int64
+
+	// LiteralPaths disables normalization of database paths. By default,
+	// paths are made absolute and cleaned before they are used as pool keys,
+	// so that different paths to the same file do not open the database
+	// twice.
+	LiteralPaths bool
+
+	// ResolveSymlinks makes the pool resolve symbolic links in database
+	// paths, in addition to the default normalization. It has no effect if
+	// LiteralPaths is true.
+	ResolveSymlinks bool
+
+	// Root, if set, confines all database paths to the directory. Relative
+	// paths are resolved against it, instead of against the working
+	// directory, and paths that are outside of it, lexically or through
+	// symbolic links, are rejected with ErrPathOutsideRoot. Symbolic links
+	// are evaluated on the operating system filesystem, regardless of the
+	// FS option. It can not be used with LiteralPaths.
+	Root string
+
+	// Names are logical database names mapped to database file paths that
+	// are registered when the pool is created. Databases can be obtained by
+	// name with Pool.GetNamed.
+	Names map[string]string
+
+	// CacheSize is the approximate size in bytes of the in-memory cache of
+	// decoded values read with Connection.GetValue. Values written or
+	// deleted with Connection PutValue and DeleteValue are invalidated,
+	// but writes done directly on the database are not visible through the
+	// cache. If the value is 0 (default), values are not cached.
+	CacheSize int64
+
+	// OnOpen, if set, is called every time a database is opened by the pool,
+	// before it is returned by Get. If it returns an error, the database is
+	// closed and the error is returned by Get. It can be used to prepare the
+	// database, like applying schema migrations.
+	OnOpen func(path string, db *bolt.DB) error
+
+	// OnCopyProgress, if set, is called by Pool CopyBucket, MoveBucket and
+	// MergeInto after every transaction written to the destination
+	// database.
+	OnCopyProgress func(progress CopyProgress)
+
+	// OnImportProgress, if set, is called by Pool.ImportRecords after every
+	// transaction written to the database.
+	OnImportProgress func(progress ImportProgress)
+
+	// ConflictResolver, if set, decides the values of keys that exist in
+	// the destination database when they are copied by Pool CopyBucket,
+	// MoveBucket and MergeInto. If nil, copied values replace existing ones.
+	ConflictResolver ConflictResolver
+
+	// EnsureBuckets are bucket paths that are created, if they do not exist,
+	// every time a database is opened by the pool, before OnOpen is called.
+	// Nested bucket names are separated by a slash, like "users/by-email".
+	EnsureBuckets [][]byte
+
+	// Oplog enables recording of changes made with the pool value helpers
+	// in the OplogBucket of every database, in the same transaction as the
+	// change, so that they can be replicated to other databases. Recorded
+	// changes are kept until they are removed with Connection.TrimOplog.
+	Oplog bool
+
+	// ReadOnly opens every database with bolt's ReadOnly option, so that
+	// files are never written by the pool. Database files and directories
+	// are not created, Connection Update, Batch and value helper methods
+	// return ErrReadOnly, as do pool methods that change database files,
+	// like Compact, Remove, Rename and Restore. Databases are not compacted
+	// on close nor recovered when Options.OnCorrupt is set.
+	ReadOnly bool
 }
 
 // Pool keeps track of connections.
 type Pool struct {
 	options       *Options
+	fs            FS
 	connections   map[string]*Connection
+	verified      map[string]struct{}
+	compacting    map[string]chan struct{}
 	mu            sync.RWMutex
 	removeTrigger chan struct{}
+	budgetTrigger chan struct{}
 	quit          chan struct{}
+	background    sync.WaitGroup
+
+	recentErrors []ErrorRecord
+	errors       chan error
+	errorsClosed bool
+	errorsMu     sync.Mutex
+
+	events *events
+
+	diskSpace diskSpace
+	rotateMu  sync.Mutex
+
+	// snapshots are open snapshots that are closed with the pool
+	snapshots map[*Snapshot]struct{}
+
+	// parked holds the state of parked databases by their paths
+	parked map[string]*parkedDB
+
+	// known holds paths of databases found by Scan
+	known map[string]struct{}
+
+	// workers limits the number of background functions if
+	// Options.MaxBackgroundWorkers is set
+	workers chan struct{}
+
+	// createdDirs are directories created by the pool
+	createdDirs map[string]struct{}
+
+	names   map[string]string
+	namesMu sync.RWMutex
+
+	cache *valueCache
+
+	statsHistory *statsHistory
+
+	changes changeWatchers
+
+	coalescers coalescers
+
+	replicas replicas
+
+	// index mirrors connections for lookups of open databases without
+	// the pool lock, so that Get and Has on different paths do not contend
+	index sync.Map
+
+	// draining is set to 1 by Shutdown when the pool stops returning
+	// connections
+	draining int32
+
+	// done is closed when the pool is closed and its background work is
+	// done
+	done chan struct{}
 }
 
 // New creates new pool with provided options and also starts database closing goroutone
-// and goroutine for errors handling to ErrorHandler.
+// and goroutine for errors handling to ErrorHandler. Options that are not
+// valid according to Options.Validate are reported to the ErrorHandler, use
+// NewWithError to get the validation error instead.
 func New(options *Options) *Pool {
 	if options == nil {
 		options = &Options{}
 	}
 	if options.ErrorHandler == nil {
-		options.ErrorHandler = DefaultErrorHandler
+		if options.ErrorsBufferSize > 0 {
+			options.ErrorHandler = func(error) {}
+		} else {
+			options.ErrorHandler = DefaultErrorHandler
+		}
 	}
 	p := &Pool{
 		options:       options,
+		fs:            options.FS,
 		connections:   map[string]*Connection{},
+		verified:      map[string]struct{}{},
+		compacting:    map[string]chan struct{}{},
+		createdDirs:   map[string]struct{}{},
+		snapshots:     map[*Snapshot]struct{}{},
+		parked:        map[string]*parkedDB{},
+		known:         map[string]struct{}{},
+		names:         map[string]string{},
 		removeTrigger: make(chan struct{}, 1),
 		quit:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	if p.fs == nil {
+		p.fs = OSFS{}
+	}
+	if options.ErrorsBufferSize > 0 {
+		p.errors = make(chan error, options.ErrorsBufferSize)
+	}
+	if options.EventsBufferSize > 0 {
+		p.events = &events{ch: make(chan Event, options.EventsBufferSize)}
+	}
+	if err := options.Validate(); err != nil {
+		p.handleError(err)
+	}
+	if options.CacheSize > 0 {
+		p.cache = newValueCache(options.CacheSize)
+	}
+	for name, path := range options.Names {
+		if err := p.Register(name, path); err != nil {
+			p.handleError(err)
+		}
+	}
+	if m := options.Maintenance; m != nil && m.Interval > 0 && len(m.Tasks) > 0 {
+		p.background.Add(1)
+		go p.maintain(m)
+	}
+	if j := options.Journal; j != nil && j.Interval > 0 {
+		p.background.Add(1)
+		go p.flushJournals(j.Interval)
+	}
+	if options.SyncInterval > 0 {
+		p.background.Add(1)
+		go p.syncPeriodically(options.SyncInterval)
+	}
+	if options.MaxBackgroundWorkers > 0 {
+		p.workers = make(chan struct{}, options.MaxBackgroundWorkers)
+	}
+	if options.MemoryBudget > 0 {
+		p.budgetTrigger = make(chan struct{}, 1)
+		p.background.Add(1)
+		go p.watchMemoryBudget()
+	}
+	if s := options.StatsSampling; s != nil && s.Interval > 0 {
+		p.statsHistory = newStatsHistory(s.Size)
+		p.background.Add(1)
+		go p.sampleStats(s.Interval)
 	}
-	go func() {
+	go p.sweepExpired()
+	return p
+}
+
+// sweepExpired closes expired connections periodically, as configured by
+// Options.ExpirySweepInterval, or after connections are released, until the
+// pool is closed.
+func (p *Pool) sweepExpired() {
+	if interval := p.options.ExpirySweepInterval; interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
 		for {
 			select {
-			case <-p.removeTrigger:
-				select {
-				case <-time.After(p.options.ConnectionExpires):
-				case <-p.quit:
-					return
-				}
-				p.mu.Lock()
-				for _, c := range p.connections {
-					c.mu.RLock()
-					if !c.closeTime.IsZero() && c.closeTime.Before(time.Now()) {
-						p.handleError(c.remove())
-					}
-					c.mu.RUnlock()
-				}
-				p.mu.Unlock()
+			case <-ticker.C:
+				p.CloseExpired()
 			case <-p.quit:
 				return
 			}
 		}
-	}()
-	return p
+	}
+	for {
+		select {
+		case <-p.removeTrigger:
+			select {
+			case <-time.After(p.options.ConnectionExpires):
+			case <-p.quit:
+				return
+			}
+			p.CloseExpired()
+		case <-p.quit:
+			return
+		}
+	}
 }
 
 // Get returns a connection that contains a database or creates a new connection
 // with newly opened database based on options specified on pool creation.
 func (p *Pool) Get(path string) (*Connection, error) {
+	return p.get(path, nil)
+}
+
+// get returns a connection as Get does and attaches labels to it.
+func (p *Pool) get(path string, labels Labels) (*Connection, error) {
+	// open databases are indexed by normalized paths, so a path that is
+	// found as given does not need to be normalized
+	if c := p.lookup(path); c != nil {
+		return c.reused(labels), nil
+	}
+	path, err := p.normalizePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if c := p.lookup(path); c != nil {
+		return c.reused(labels), nil
+	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	if p.isClosed() || p.isDraining() {
+		return nil, ErrClosed
+	}
+	p.waitCompaction(path)
 	if c, ok := p.connections[path]; ok {
 		c.mu.Lock()
 		c.increment()
 		c.mu.Unlock()
+		return c.reused(labels), nil
+	}
+	if c := p.unpark(path); c != nil {
+		c.addLabels(labels)
+		c.mu.Lock()
+		c.increment()
+		p.connections[path] = c
+		p.index.Store(path, c)
+		c.mu.Unlock()
+		c.countGet()
+		c.emit(EventRevived, nil)
+		p.enforceMemoryBudget()
 		return c, nil
 	}
-	if _, err := os.Stat(filepath.Dir(path)); os.IsNotExist(err) {
-		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+	deadline := p.openDeadline()
+	var created bool
+	if !p.options.ReadOnly {
+		if err := p.mkdirAllUntil(filepath.Dir(path), path, deadline); err != nil {
 			return nil, err
 		}
-	} else if err != nil {
+		if _, err := p.fs.Stat(path); os.IsNotExist(err) {
+			if err := p.checkDiskSpace(filepath.Dir(path)); err != nil {
+				p.handleError(err)
+				return nil, err
+			}
+			created = true
+		}
+	}
+	if err := p.checkNetworkFilesystem(path); err != nil {
 		return nil, err
 	}
-	db, err := bolt.Open(path, 0666, p.options.BoltOptions)
+	db, err := p.open(path, deadline)
+	if err != nil && p.options.OnCorrupt != nil && !p.isReadOnly(path) && IsCorruption(err) {
+		if rerr := p.recoverFile(path, err); rerr != nil {
+			p.handleError(rerr)
+		} else {
+			db, err = p.open(path, deadline)
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
+	if created && !db.IsReadOnly() {
+		if err := p.applyFilePermissions(path); err != nil {
+			p.handleError(db.Close())
+			return nil, err
+		}
+	}
 	c := &Connection{
 		DB:   db,
 		path: path,
 		pool: p,
 	}
+	if p.options.Journal != nil {
+		c.journal = &journal{}
+	}
+	if p.options.WriteRateLimit != nil {
+		c.limiter = newRateLimiter(p.options.WriteRateLimit(path), p.now())
+	}
+	event := EventOpened
+	if p.revive(c) {
+		event = EventRevived
+	}
+	c.addLabels(labels)
 	c.mu.Lock()
 	c.increment()
 	p.connections[path] = c
+	p.index.Store(path, c)
 	c.mu.Unlock()
+	c.countGet()
+	c.emit(event, nil)
+	p.enforceMemoryBudget()
 	return c, nil
 }
 
+// reused counts the use of the connection that is returned by Get for a
+// database that is already open.
+func (c *Connection) reused(labels Labels) *Connection {
+	c.countGet()
+	c.addLabels(labels)
+	c.emit(EventReused, nil)
+	return c
+}
+
+// lookup returns the connection of an open database on path with an
+// incremented reference count, without acquiring the pool lock. It returns
+// nil if the database is not open or the pool is not returning connections.
+func (p *Pool) lookup(path string) *Connection {
+	v, ok := p.index.Load(path)
+	if !ok {
+		return nil
+	}
+	c := v.(*Connection)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// the connection is removed under its lock when it has no references,
+	// so it is valid if it is still indexed
+	if v, ok := p.index.Load(path); !ok || v != c || p.isClosed() || p.isDraining() {
+		return nil
+	}
+	c.increment()
+	return c
+}
+
+// CloseExpired closes and removes from the pool all databases with zero
+// references whose expiration time has passed. It is called periodically
+// by the pool, but it can be used to release databases without waiting.
+func (p *Pool) CloseExpired() {
+	now := p.now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, c := range p.connections {
+		c.mu.RLock()
+		if c.count == 0 && !c.closeTime.IsZero() && c.closeTime.Before(now) {
+			p.removeExpired(c)
+		}
+		c.mu.RUnlock()
+	}
+}
+
+// open opens the database on path and validates it according to
+// Options.VerifyOnOpen. OpenTimeoutError is returned if the file is not
+// opened until the deadline, if it is not zero. Pool lock must be held.
+func (p *Pool) open(path string, deadline time.Time) (db *bolt.DB, err error) {
+	level := p.verifyLevel(path)
+	if level >= VerifyHeader {
+		if err := verifyHeader(p.fs, path); err != nil {
+			return nil, &VerifyError{Path: path, Level: level, Err: err}
+		}
+	}
+	defer func() {
+		// bolt panics on some corrupted pages
+		if r := recover(); r != nil {
+			if db != nil {
+				p.handleError(db.Close())
+			}
+			db, err = nil, &VerifyError{Path: path, Level: level, Err: fmt.Errorf("%w: %v", ErrCorrupted, r)}
+		}
+	}()
+	options, mode := p.boltOptions(path)
+	db, err = p.boltOpen(path, mode, options, deadline)
+	if err != nil {
+		return nil, err
+	}
+	if p.options.SyncInterval > 0 && !db.IsReadOnly() {
+		// no transactions are started on the new database
+		db.NoSync = true
+	}
+	if level > VerifyHeader {
+		if err := verifyDB(db, level); err != nil {
+			p.handleError(db.Close())
+			return nil, &VerifyError{Path: path, Level: level, Err: err}
+		}
+	}
+	if level > VerifyNone {
+		p.verified[path] = struct{}{}
+	}
+	if len(p.options.EnsureBuckets) > 0 && !db.IsReadOnly() {
+		if err := ensureBuckets(db, p.options.EnsureBuckets); err != nil {
+			p.handleError(db.Close())
+			return nil, err
+		}
+	}
+	if p.options.Journal != nil && !db.IsReadOnly() {
+		if err := p.replayJournal(path, db); err != nil {
+			p.handleError(db.Close())
+			return nil, err
+		}
+	}
+	if p.options.OnOpen != nil {
+		// panics are not handled as database corruption
+		if err := p.protect("OnOpen", func() error {
+			return p.options.OnOpen(path, db)
+		}); err != nil {
+			p.handleError(db.Close())
+			return nil, err
+		}
+	}
+	if p.options.Prefault {
+		p.prefault(path)
+	}
+	return db, nil
+}
+
+// boltOptions returns options and the file mode for opening the database
+// on path, with the ReadOnly option set if the pool is read-only and files
+// opened on the pool filesystem.
+func (p *Pool) boltOptions(path string) (options *bolt.Options, mode os.FileMode) {
+	options, mode = p.options.BoltOptions, 0666
+	if p.options.PathOptions != nil {
+		o, m := p.options.PathOptions(path)
+		if o != nil {
+			options = o
+		}
+		if m != 0 {
+			mode = m
+		}
+	}
+	if p.options.LargeFileSize > 0 {
+		if info, err := p.fs.Stat(path); err == nil && info.Size() >= p.options.LargeFileSize {
+			options = largeFileOptions(options, info.Size())
+		}
+	}
+	if p.options.ReadOnly && (options == nil || !options.ReadOnly) {
+		o := bolt.Options{}
+		if options != nil {
+			o = *options
+		}
+		o.ReadOnly = true
+		options = &o
+	}
+	if options == nil || options.OpenFile == nil {
+		o := bolt.Options{}
+		if options != nil {
+			o = *options
+		}
+		o.OpenFile = p.fs.OpenFile
+		options = &o
+	}
+	return options, mode
+}
+
+// largeFileOptions returns a copy of options with defaults for a database
+// file of the size.
+func largeFileOptions(options *bolt.Options, size int64) *bolt.Options {
+	o := bolt.Options{}
+	if options != nil {
+		o = *options
+	}
+	if o.FreelistType == "" {
+		o.FreelistType = bolt.FreelistMapType
+	}
+	if o.InitialMmapSize == 0 {
+		if mmapSize := 2 * size; mmapSize > 0 && int64(int(mmapSize)) == mmapSize {
+			o.InitialMmapSize = int(mmapSize)
+		}
+	}
+	return &o
+}
+
+// isReadOnly returns true if the database on path is opened read-only by
+// the pool.
+func (p *Pool) isReadOnly(path string) bool {
+	options, _ := p.boltOptions(path)
+	return options != nil && options.ReadOnly
+}
+
 // Has returns true if a database with a file path is in the pool.
 func (p *Pool) Has(path string) bool {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
+	path, err := p.normalizePath(path)
+	if err != nil {
+		return false
+	}
 
-	_, ok := p.connections[path]
+	_, ok := p.index.Load(path)
 	return ok
 }
 
 // Close function closes and removes from the pool all databases. After the execution
-// pool is not usable.
+// pool is not usable. Databases are closed in parallel, outside of the pool
+// lock. It waits for background compactions to finish. Errors
+// from closing databases and snapshots are passed to the ErrorHandler, use
+// CloseAll to get them. Calling Close on a closed pool has no effect.
 func (p *Pool) Close() {
-	p.mu.Lock()
-	defer p.mu.Unlock()
+	p.CloseAll()
+}
 
-	for _, c := range p.connections {
-		p.handleError(c.remove())
+// CloseAll closes the pool as Close does and returns errors from closing
+// databases and snapshots joined together. If Shutdown is in progress,
+// CloseAll waits for it to finish. Calling CloseAll on a closed pool has no
+// effect.
+func (p *Pool) CloseAll() error {
+	p.mu.Lock()
+	if p.isClosed() {
+		p.mu.Unlock()
+		return nil
+	}
+	if p.isDraining() {
+		p.mu.Unlock()
+		<-p.done
+		return nil
 	}
 	close(p.quit)
+	connections := p.detachAll()
+	p.mu.Unlock()
+
+	return errors.Join(p.closeDetached(connections)...)
+}
+
+// closeDetached closes databases of connections detached from the closed
+// pool and snapshots, waits for background work, closes databases that are
+// parked open and closes channels returned by Errors and Events. It returns
+// errors from closing databases and snapshots.
+func (p *Pool) closeDetached(connections []*Connection) []error {
+	errs := p.closeAll(connections)
+	errs = append(errs, p.closeSnapshots()...)
+	p.background.Wait()
+
+	// databases can be parked by background functions until they finish
+	p.mu.Lock()
+	parked := p.detachParked()
+	p.mu.Unlock()
+	errs = append(errs, p.closeAll(parked)...)
+	p.closeErrors()
+	p.closeEvents()
+	close(p.done)
+	return errs
 }
 
 func (p *Pool) remove(path string) error {
@@ -191,13 +915,60 @@ func (p *Pool) remove(path string) error {
 	if !ok {
 		return fmt.Errorf("boltdbpool: unknown db %s", path)
 	}
+	p.detach(path)
+	return p.closeDB(c)
+}
+
+// detach removes the connection on path from the pool without closing its
+// database. Pool lock must be held.
+func (p *Pool) detach(path string) {
 	delete(p.connections, path)
-	return c.DB.Close()
+	p.index.Delete(path)
+}
+
+// closeDB closes the connection database, after applying pending journal
+// entries and flushing it to disk if the pool manages syncing with
+// Options.SyncInterval.
+func (p *Pool) closeDB(c *Connection) error {
+	var errs []error
+	if c.journal != nil {
+		if err := c.journal.close(c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if p.options.SyncInterval > 0 && !c.DB.IsReadOnly() {
+		if err := c.DB.Sync(); err != nil {
+			errs = append(errs, fmt.Errorf("boltdbpool: sync %s: %w", c.path, err))
+		}
+	}
+	if err := c.DB.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("boltdbpool: close %s: %w", c.path, err))
+	}
+	err := errors.Join(errs...)
+	c.emit(EventClosed, err)
+	return err
+}
+
+func (p *Pool) now() time.Time {
+	if p.options.Now != nil {
+		return p.options.Now()
+	}
+	return time.Now()
 }
 
 func (p *Pool) handleError(err error) {
-	if err != nil {
+	if err == nil {
+		return
+	}
+	p.recordError(err)
+	p.sendError(err)
+	if perr := p.protect("ErrorHandler", func() error {
 		p.options.ErrorHandler(err)
+		return nil
+	}); perr != nil {
+		// the panic is not passed to the ErrorHandler again
+		p.recordError(perr)
+		p.sendError(perr)
 	}
 }
 
@@ -210,6 +981,24 @@ type Connection struct {
 	count     int64
 	closeTime time.Time
 	mu        sync.RWMutex
+
+	usage connectionUsage
+
+	// limiter is nil if writes are not limited
+	limiter *rateLimiter
+
+	// journal is nil if Options.Journal is not set
+	journal *journal
+
+	// labels holds Labels that are replaced, not modified, under labelsMu
+	labels   atomic.Value
+	labelsMu sync.Mutex
+}
+
+// ReadOnly returns true if the connection database is opened read-only,
+// by the pool options or by Options.FallbackReadOnly.
+func (c *Connection) ReadOnly() bool {
+	return c.DB.IsReadOnly()
 }
 
 // Close function on Connection decrements reference counter and closes the database if needed.
@@ -222,7 +1011,13 @@ func (c *Connection) Close() {
 	if c.count > 0 {
 		return
 	}
+	c.expire()
+}
 
+// expire removes the connection that has no references from the pool if
+// connections do not expire, or schedules its expiration. Connection lock
+// must be held.
+func (c *Connection) expire() {
 	if c.pool.options.ConnectionExpires == 0 {
 		c.pool.mu.Lock()
 		c.pool.handleError(c.remove())
@@ -230,13 +1025,18 @@ func (c *Connection) Close() {
 		return
 	}
 
-	c.closeTime = time.Now().Add(c.pool.options.ConnectionExpires)
+	c.closeTime = c.pool.now().Add(c.pool.options.ConnectionExpires)
+	c.emit(EventExpiryScheduled, nil)
 	select {
 	case c.pool.removeTrigger <- struct{}{}:
 	default:
 	}
+	c.pool.triggerMemoryBudget()
 }
 
+// increment adds a reference to the connection. Usage of connections
+// returned by the pool is counted with countGet outside of the connection
+// lock, to keep the lock short on the Get path.
 func (c *Connection) increment() {
 	// Reset the closing time
 	c.closeTime = time.Time{}
@@ -6,9 +6,13 @@
 package boltdbpool
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -152,6 +156,32 @@ func TestConnection(t *testing.T) {
 	}
 }
 
+func TestConnectionReuseUnnormalizedPath(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+	c1, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c1.Close()
+
+	c2, err := pool.Get(dir + "/./sub/../test.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+
+	if c1 != c2 {
+		t.Error("unnormalized path got a different connection")
+	}
+	if c1.count != 2 {
+		t.Errorf("got reference count %d, expected 2", c1.count)
+	}
+}
+
 func TestConnectionCounter(t *testing.T) {
 	pool := New(nil)
 	defer pool.Close()
@@ -263,6 +293,43 @@ func TestExpires(t *testing.T) {
 	connection.Close()
 }
 
+func TestExpirySweepInterval(t *testing.T) {
+	var mu sync.Mutex
+	now := time.Now()
+	pool := New(&Options{
+		ConnectionExpires:   time.Hour,
+		ExpirySweepInterval: 10 * time.Millisecond,
+		Now: func() time.Time {
+			mu.Lock()
+			defer mu.Unlock()
+			return now
+		},
+	})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	time.Sleep(50 * time.Millisecond)
+	if !pool.Has(path) {
+		t.Fatal("connection closed before it expired")
+	}
+
+	mu.Lock()
+	now = now.Add(2 * time.Hour)
+	mu.Unlock()
+	for deadline := time.Now().Add(5 * time.Second); pool.Has(path); {
+		if time.Now().After(deadline) {
+			t.Fatal("expired connection not closed by the sweep")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
 func TestErrorHandler(t *testing.T) {
 	path := tempfile()
 	defer func() {
@@ -293,6 +360,7 @@ func TestErrorHandler(t *testing.T) {
 
 	pool.mu.Lock()
 	delete(pool.connections, path)
+	pool.index.Delete(path)
 	pool.mu.Unlock()
 
 	connection.DB.Close()
@@ -328,9 +396,304 @@ func TestErrorHandler(t *testing.T) {
 
 }
 
+func TestReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+
+	writer := New(nil)
+	c, err := writer.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PutValue([]byte("bucket"), []byte("key"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	writer.Close()
+
+	pool := New(&Options{
+		ReadOnly: true,
+		VerifyOnOpen: func(string) VerifyLevel {
+			return VerifyFull
+		},
+	})
+	defer pool.Close()
+
+	if _, err := pool.Get(filepath.Join(dir, "missing", "test.db")); !os.IsNotExist(err) {
+		t.Errorf("got error %v, expected not exist error", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Error("directory created by read-only pool")
+	}
+
+	c, err = pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.DB.IsReadOnly() {
+		t.Error("database is not opened read-only")
+	}
+	if v, err := c.GetValue([]byte("bucket"), []byte("key")); err != nil || string(v) != "value" {
+		t.Errorf("got value %q (%v)", v, err)
+	}
+	if err := c.PutValue([]byte("bucket"), []byte("key"), nil); err != ErrReadOnly {
+		t.Errorf("got error %v, expected %v", err, ErrReadOnly)
+	}
+	if err := c.Batch(func(*bolt.Tx) error { return nil }); err != ErrReadOnly {
+		t.Errorf("got error %v, expected %v", err, ErrReadOnly)
+	}
+	if err := pool.Check(path); err != nil {
+		t.Error(err)
+	}
+	c.Close()
+
+	for name, err := range map[string]error{
+		"compact": pool.Compact(path),
+		"remove":  pool.Remove(path),
+		"rename":  pool.Rename(path, path+".new"),
+		"restore": pool.Restore(path, nil),
+		"import":  pool.Import(path, nil),
+		"records": pool.ImportRecords(path, "bucket", nil, CSV),
+	} {
+		if err != ErrReadOnly {
+			t.Errorf("%s: got error %v, expected %v", name, err, ErrReadOnly)
+		}
+	}
+}
+
+func TestFallbackReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	writer := New(nil)
+	c, err := writer.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PutValue([]byte("bucket"), []byte("key"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	writer.Close()
+
+	// another reader holds a shared lock that prevents writers
+	db, err := bolt.Open(path, 0, &bolt.Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	options := &bolt.Options{Timeout: 100 * time.Millisecond}
+
+	pool := New(&Options{BoltOptions: options})
+	if _, err := pool.Get(path); err != bolt.ErrTimeout {
+		t.Errorf("got error %v, expected %v", err, bolt.ErrTimeout)
+	}
+	pool.Close()
+
+	pool = New(&Options{
+		BoltOptions:      options,
+		FallbackReadOnly: true,
+		EnsureBuckets:    [][]byte{[]byte("other")},
+	})
+	defer pool.Close()
+
+	c, err = pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if !c.ReadOnly() {
+		t.Error("connection is not read-only")
+	}
+	if options.ReadOnly {
+		t.Error("bolt options are changed")
+	}
+	if v, err := c.GetValue([]byte("bucket"), []byte("key")); err != nil || string(v) != "value" {
+		t.Errorf("got value %q (%v)", v, err)
+	}
+	if err := c.PutValue([]byte("bucket"), []byte("key"), nil); err != ErrReadOnly {
+		t.Errorf("got error %v, expected %v", err, ErrReadOnly)
+	}
+	if s := pool.Stats(); len(s.Databases) != 1 || !s.Databases[0].ReadOnly {
+		t.Errorf("got stats %+v, expected a read-only database", s.Databases)
+	}
+}
+
 func tempfile() string {
 	f, _ := ioutil.TempFile("", "boltdbpool-")
 	f.Close()
 	os.Remove(f.Name())
 	return f.Name()
 }
+
+func BenchmarkGetParallel(b *testing.B) {
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+	})
+	defer pool.Close()
+
+	dir := b.TempDir()
+	paths := make([]string, 64)
+	for i := range paths {
+		paths[i] = filepath.Join(dir, fmt.Sprintf("%d.db", i))
+		c, err := pool.Get(paths[i])
+		if err != nil {
+			b.Fatal(err)
+		}
+		c.Close()
+	}
+
+	var n uint32
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		path := paths[int(atomic.AddUint32(&n, 1))%len(paths)]
+		for pb.Next() {
+			c, err := pool.Get(path)
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			c.Close()
+		}
+	})
+}
+
+func TestPathOptions(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "archive", "old.db")
+	live := filepath.Join(dir, "live", "new.db")
+
+	writer := New(nil)
+	c, err := writer.Get(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	writer.Close()
+
+	pool := New(&Options{
+		PathOptions: func(path string) (*bolt.Options, os.FileMode) {
+			if filepath.Base(filepath.Dir(path)) == "archive" {
+				return &bolt.Options{ReadOnly: true}, 0
+			}
+			return nil, 0600
+		},
+	})
+	defer pool.Close()
+
+	c, err = pool.Get(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.DB.IsReadOnly() {
+		t.Error("archive database is not read-only")
+	}
+	c.Close()
+	if err := pool.Compact(archive); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("got error %v, expected %v", err, ErrReadOnly)
+	}
+
+	c, err = pool.Get(live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.DB.IsReadOnly() {
+		t.Error("live database is read-only")
+	}
+	info, err := os.Stat(live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("got file mode %v, expected %v", mode, os.FileMode(0600))
+	}
+}
+
+func TestBoltOptions(t *testing.T) {
+	dir := t.TempDir()
+	var opened []string
+	pool := New(&Options{
+		BoltOptions: &bolt.Options{
+			PageSize: 8192,
+			OpenFile: func(name string, flag int, mode os.FileMode) (*os.File, error) {
+				opened = append(opened, name)
+				return os.OpenFile(name, flag, mode)
+			},
+		},
+		LargeFileSize: 1,
+	})
+	defer pool.Close()
+
+	path := filepath.Join(dir, "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.DB.Info().PageSize; got != 8192 {
+		t.Errorf("got page size %d, expected %d", got, 8192)
+	}
+	// new files use bolt defaults
+	if c.DB.FreelistType == bolt.FreelistMapType {
+		t.Errorf("got freelist type %q for a new file", c.DB.FreelistType)
+	}
+	c.Close()
+	if len(opened) != 1 || opened[0] != path {
+		t.Errorf("got opened files %v", opened)
+	}
+
+	c, err = pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.DB.FreelistType != bolt.FreelistMapType {
+		t.Errorf("got freelist type %q for a large file, expected %q", c.DB.FreelistType, bolt.FreelistMapType)
+	}
+	if got := c.DB.Info().PageSize; got != 8192 {
+		t.Errorf("got page size %d, expected %d", got, 8192)
+	}
+}
+
+// removeFailFS fails removal of all files.
+type removeFailFS struct {
+	OSFS
+	err error
+}
+
+func (fs removeFailFS) Remove(string) error {
+	return fs.err
+}
+
+func TestCloseErrors(t *testing.T) {
+	errTest := errors.New("test error")
+
+	pool := New(&Options{
+		ConnectionExpires: time.Minute,
+		Journal:           &Journal{Interval: time.Hour},
+		FS:                removeFailFS{err: errTest},
+		ErrorHandler:      func(error) {},
+	})
+
+	dir := t.TempDir()
+	for _, name := range []string{"a.db", "b.db"} {
+		path := filepath.Join(dir, name)
+		if err := pool.Append(path, []JournalEntry{
+			{Type: ChangePut, Bucket: []byte("bucket"), Key: []byte("key"), Value: []byte("value")},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := pool.CloseAll()
+	if !errors.Is(err, errTest) {
+		t.Fatalf("got error %v, expected %v", err, errTest)
+	}
+	if errs := err.(interface{ Unwrap() []error }).Unwrap(); len(errs) != 2 {
+		t.Errorf("got %d errors, expected 2", len(errs))
+	}
+	if err := pool.CloseAll(); err != nil {
+		t.Errorf("got error %v closing closed pool", err)
+	}
+}
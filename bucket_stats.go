@@ -0,0 +1,63 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// BucketStats returns stats of all top-level buckets of the existing
+// database on path, keyed by bucket names, within a single read
+// transaction. Stats of a bucket include its nested buckets.
+func (p *Pool) BucketStats(path string) (map[string]bolt.BucketStats, error) {
+	return p.bucketStats(path, false)
+}
+
+// NestedBucketStats returns stats of all buckets of the existing database
+// on path, including nested ones, keyed by bucket paths in the format of
+// Options.EnsureBuckets, within a single read transaction. Stats of a
+// bucket include its nested buckets.
+func (p *Pool) NestedBucketStats(path string) (map[string]bolt.BucketStats, error) {
+	return p.bucketStats(path, true)
+}
+
+func (p *Pool) bucketStats(path string, nested bool) (map[string]bolt.BucketStats, error) {
+	c, err := p.getExisting(path)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	stats := make(map[string]bolt.BucketStats)
+	if err := c.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			if nested {
+				addNestedBucketStats(stats, name, b)
+			} else {
+				stats[string(name)] = b.Stats()
+			}
+			return nil
+		})
+	}); err != nil {
+		return nil, fmt.Errorf("boltdbpool: bucket stats %s: %w", path, err)
+	}
+	return stats, nil
+}
+
+func addNestedBucketStats(stats map[string]bolt.BucketStats, path []byte, b *bolt.Bucket) {
+	stats[string(path)] = b.Stats()
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if v != nil {
+			continue
+		}
+		if nested := b.Bucket(k); nested != nil {
+			addNestedBucketStats(stats, joinBucketPath(path, k), nested)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBucketStats(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.GetWithBuckets(path, []byte("users/index"), []byte("empty"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Update(func(tx *bolt.Tx) error {
+		for i := 0; i < 10; i++ {
+			if err := bucket(tx, []byte("users")).Put([]byte(fmt.Sprint("user", i)), []byte("value")); err != nil {
+				return err
+			}
+		}
+		return bucket(tx, []byte("users/index")).Put([]byte("k"), []byte("v"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := pool.BucketStats(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bucketNames(stats); fmt.Sprint(got) != "[empty users]" {
+		t.Errorf("got buckets %v", got)
+	}
+	// nested bucket key and its key are included
+	if n := stats["users"].KeyN; n != 12 {
+		t.Errorf("got %d users keys, expected 12", n)
+	}
+	if n := stats["empty"].KeyN; n != 0 {
+		t.Errorf("got %d empty keys, expected 0", n)
+	}
+
+	stats, err = pool.NestedBucketStats(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bucketNames(stats); fmt.Sprint(got) != "[empty users users/index]" {
+		t.Errorf("got nested buckets %v", got)
+	}
+	if n := stats["users/index"].KeyN; n != 1 {
+		t.Errorf("got %d index keys, expected 1", n)
+	}
+
+	if _, err := pool.BucketStats(filepath.Join(t.TempDir(), "missing.db")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v, expected %v", err, os.ErrNotExist)
+	}
+}
+
+func bucketNames(m map[string]bolt.BucketStats) []string {
+	s := make([]string, 0, len(m))
+	for k := range m {
+		s = append(s, k)
+	}
+	sort.Strings(s)
+	return s
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"bytes"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// bucketPathSeparator separates nested bucket names in bucket paths.
+var bucketPathSeparator = []byte("/")
+
+// GetWithBuckets returns a connection as Get does and creates buckets that
+// do not exist in the database. Bucket paths have the same format as
+// Options.EnsureBuckets. Buckets are checked in a read-only transaction, so
+// the database is written only when a bucket is missing.
+func (p *Pool) GetWithBuckets(path string, buckets ...[]byte) (*Connection, error) {
+	c, err := p.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureBuckets(c.DB, buckets); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// ensureBuckets creates all missing buckets in a single transaction.
+func ensureBuckets(db *bolt.DB, buckets [][]byte) error {
+	missing := false
+	if err := db.View(func(tx *bolt.Tx) error {
+		for _, path := range buckets {
+			if bucket(tx, path) == nil {
+				missing = true
+				return nil
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if !missing {
+		return nil
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		for _, path := range buckets {
+			if _, err := createBucket(tx, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// bucket returns the bucket on the slash-separated path or nil if it does
+// not exist.
+func bucket(tx *bolt.Tx, path []byte) *bolt.Bucket {
+	names := bytes.Split(path, bucketPathSeparator)
+	b := tx.Bucket(names[0])
+	for _, name := range names[1:] {
+		if b == nil {
+			return nil
+		}
+		b = b.Bucket(name)
+	}
+	return b
+}
+
+// createBucket creates the bucket on the slash-separated path and all its
+// parents if they do not exist.
+func createBucket(tx *bolt.Tx, path []byte) (b *bolt.Bucket, err error) {
+	names := bytes.Split(path, bucketPathSeparator)
+	if b, err = tx.CreateBucketIfNotExists(names[0]); err != nil {
+		return nil, err
+	}
+	for _, name := range names[1:] {
+		if b, err = b.CreateBucketIfNotExists(name); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestEnsureBuckets(t *testing.T) {
+	pool := New(&Options{
+		EnsureBuckets: [][]byte{
+			[]byte("users"),
+			[]byte("users/by-email"),
+			[]byte("settings/ui/theme"),
+		},
+	})
+	defer pool.Close()
+
+	c, err := pool.Get(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	assertBuckets(t, c, "users", "users/by-email", "settings", "settings/ui", "settings/ui/theme")
+}
+
+func TestGetWithBuckets(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.GetWithBuckets(path, []byte("a/b"), []byte("c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	assertBuckets(t, c, "a", "a/b", "c")
+
+	txID := 0
+	if err := c.View(func(tx *bolt.Tx) error {
+		txID = tx.ID()
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// existing buckets do not require a write transaction
+	c2, err := pool.GetWithBuckets(path, []byte("a/b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	if err := c2.View(func(tx *bolt.Tx) error {
+		if tx.ID() != txID {
+			t.Errorf("got transaction id %d, expected %d", tx.ID(), txID)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertBuckets(t *testing.T, c *Connection, paths ...string) {
+	t.Helper()
+
+	if err := c.View(func(tx *bolt.Tx) error {
+		for _, path := range paths {
+			if bucket(tx, []byte(path)) == nil {
+				t.Errorf("bucket %s not found", path)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"container/list"
+	"sync"
+)
+
+// cacheEntryOverhead is the approximate number of bytes used by a cache
+// entry in addition to its key and value.
+const cacheEntryOverhead = 96
+
+type cacheKey struct {
+	path   string
+	bucket string
+	key    string
+}
+
+type cacheEntry struct {
+	key   cacheKey
+	value []byte
+}
+
+// valueCache is a least recently used cache of decoded values with the
+// size limited in bytes.
+type valueCache struct {
+	max     int64
+	size    int64
+	entries map[cacheKey]*list.Element
+	lru     *list.List
+	// version is incremented on every invalidation so that values read
+	// concurrently with writes are not cached
+	version uint64
+	mu      sync.Mutex
+}
+
+func newValueCache(max int64) *valueCache {
+	return &valueCache{
+		max:     max,
+		entries: map[cacheKey]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// get returns the cached value, whether it is found and the cache version
+// that should be passed to add after the value is read from the database.
+func (c *valueCache) get(k cacheKey) (value []byte, ok bool, version uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[k]
+	if !ok {
+		return nil, false, c.version
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*cacheEntry).value, true, c.version
+}
+
+// add caches the value if no invalidation happened since the version was
+// returned by get.
+func (c *valueCache) add(k cacheKey, value []byte, version uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if version != c.version {
+		return
+	}
+	size := entrySize(k, value)
+	if size > c.max {
+		return
+	}
+	if e, ok := c.entries[k]; ok {
+		c.removeElement(e)
+	}
+	c.entries[k] = c.lru.PushFront(&cacheEntry{key: k, value: value})
+	c.size += size
+	for c.size > c.max {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+// invalidate removes the cached value.
+func (c *valueCache) invalidate(k cacheKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version++
+	if e, ok := c.entries[k]; ok {
+		c.removeElement(e)
+	}
+}
+
+// invalidatePath removes all cached values of the database on path.
+func (c *valueCache) invalidatePath(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version++
+	for k, e := range c.entries {
+		if k.path == path {
+			c.removeElement(e)
+		}
+	}
+}
+
+func (c *valueCache) removeElement(e *list.Element) {
+	entry := c.lru.Remove(e).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= entrySize(entry.key, entry.value)
+}
+
+func entrySize(k cacheKey, value []byte) int64 {
+	return int64(len(k.path)+len(k.bucket)+len(k.key)+len(value)) + cacheEntryOverhead
+}
+
+// invalidateCache removes cached values of the database on path if the
+// cache is enabled.
+func (p *Pool) invalidateCache(path string) {
+	if p.cache != nil {
+		p.cache.invalidatePath(path)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestValueCache(t *testing.T) {
+	pool := New(&Options{
+		CacheSize: 1 << 20,
+	})
+	defer pool.Close()
+
+	c, err := pool.Get(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	bucket, key := []byte("bucket"), []byte("key")
+	if err := c.PutValue(bucket, key, []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+	assertValue(t, c, bucket, key, "v1")
+
+	// direct writes are not visible through the cache
+	if err := c.DB.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Put(key, []byte("direct"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	assertValue(t, c, bucket, key, "v1")
+
+	// returned values are copies
+	v, err := c.GetValue(bucket, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v[0] = 'x'
+	assertValue(t, c, bucket, key, "v1")
+
+	if err := c.PutValue(bucket, key, []byte("v2")); err != nil {
+		t.Fatal(err)
+	}
+	assertValue(t, c, bucket, key, "v2")
+
+	if err := c.DeleteValue(bucket, key); err != nil {
+		t.Fatal(err)
+	}
+	v, err = c.GetValue(bucket, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != nil {
+		t.Errorf("got deleted value %q", v)
+	}
+}
+
+func TestValueCacheEviction(t *testing.T) {
+	k1 := cacheKey{path: "p", bucket: "b", key: "1"}
+	k2 := cacheKey{path: "p", bucket: "b", key: "2"}
+	k3 := cacheKey{path: "q", bucket: "b", key: "3"}
+	value := make([]byte, 100)
+
+	c := newValueCache(2 * entrySize(k1, value))
+	for _, k := range []cacheKey{k1, k2} {
+		_, _, version := c.get(k)
+		c.add(k, value, version)
+	}
+	// use k1 so that k2 is the least recently used
+	if _, ok, _ := c.get(k1); !ok {
+		t.Fatal("k1 not cached")
+	}
+	_, _, version := c.get(k3)
+	c.add(k3, value, version)
+	if _, ok, _ := c.get(k2); ok {
+		t.Error("least recently used value not evicted")
+	}
+	if _, ok, _ := c.get(k1); !ok {
+		t.Error("recently used value evicted")
+	}
+	if c.size > c.max {
+		t.Errorf("cache size %d above limit %d", c.size, c.max)
+	}
+
+	// values read before an invalidation are not cached
+	_, _, version = c.get(k2)
+	c.invalidate(k1)
+	c.add(k2, value, version)
+	if _, ok, _ := c.get(k2); ok {
+		t.Error("stale value cached")
+	}
+
+	c.invalidatePath("q")
+	if _, ok, _ := c.get(k3); ok {
+		t.Error("value of invalidated path cached")
+	}
+}
+
+func assertValue(t *testing.T, c *Connection, bucket, key []byte, want string) {
+	t.Helper()
+
+	v, err := c.GetValue(bucket, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(v) != want {
+		t.Errorf("got value %q, expected %q", v, want)
+	}
+}
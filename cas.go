@@ -0,0 +1,78 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"bytes"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// CompareAndSwap stores the new value under the key in the bucket of the
+// database on path only if the current decoded value is equal to old, as
+// Connection.CompareAndSwap does.
+func (p *Pool) CompareAndSwap(path string, bucket, key, old, new []byte) (swapped bool, err error) {
+	c, err := p.Get(path)
+	if err != nil {
+		return false, err
+	}
+	defer c.Close()
+
+	return c.CompareAndSwap(bucket, key, old, new)
+}
+
+// CompareAndSwap stores the new value under the key in the bucket within a
+// single read-write transaction, only if the current decoded value is equal
+// to old, and reports whether the value is swapped. If old is nil, the key
+// must not exist, and if new is nil, the key is deleted. The bucket is
+// created if it does not exist. Values are encoded and decoded as with
+// Connection PutValue and GetValue.
+func (c *Connection) CompareAndSwap(bucket, key, old, new []byte) (swapped bool, err error) {
+	var v []byte
+	if new != nil {
+		if v, err = c.pool.EncodeValue(bucket, key, new); err != nil {
+			return false, err
+		}
+	}
+	defer c.invalidateValue(bucket, key)
+
+	t := ChangePut
+	if new == nil {
+		t = ChangeDelete
+	}
+	err = c.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(bucket)
+		if err != nil {
+			return err
+		}
+		current := b.Get(key)
+		if current != nil {
+			if current, err = c.pool.DecodeValue(bucket, key, current); err != nil {
+				return err
+			}
+		}
+		if (current == nil) != (old == nil) || !bytes.Equal(current, old) {
+			return nil
+		}
+		if new == nil {
+			err = b.Delete(key)
+		} else {
+			err = b.Put(key, v)
+		}
+		if err != nil {
+			return err
+		}
+		swapped = true
+		return c.pool.logChange(tx, t, bucket, key, v)
+	})
+	if err != nil {
+		return false, err
+	}
+	if swapped {
+		c.notifyChange(nil, t, bucket, key, new)
+	}
+	return swapped, nil
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestCompareAndSwap(t *testing.T) {
+	pool := New(&Options{
+		CacheSize:   1 << 20,
+		Compression: &Compression{},
+	})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	bucket, key := []byte("b"), []byte("k")
+
+	get := func(want string, exists bool) {
+		t.Helper()
+
+		c, err := pool.Get(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		v, err := c.GetValue(bucket, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (v != nil) != exists || string(v) != want {
+			t.Errorf("got value %q, expected %q", v, want)
+		}
+	}
+
+	for _, tc := range []struct {
+		old, new []byte
+		swapped  bool
+		value    string
+		exists   bool
+	}{
+		{old: []byte("a"), new: []byte("b"), swapped: false},
+		{old: nil, new: []byte("a"), swapped: true, value: "a", exists: true},
+		{old: nil, new: []byte("b"), swapped: false, value: "a", exists: true},
+		{old: []byte("b"), new: []byte("c"), swapped: false, value: "a", exists: true},
+		{old: []byte("a"), new: []byte("c"), swapped: true, value: "c", exists: true},
+		{old: []byte("c"), new: []byte{}, swapped: true, value: "", exists: true},
+		{old: nil, new: []byte("d"), swapped: false, value: "", exists: true},
+		{old: []byte{}, new: nil, swapped: true},
+	} {
+		swapped, err := pool.CompareAndSwap(path, bucket, key, tc.old, tc.new)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if swapped != tc.swapped {
+			t.Errorf("swap %q to %q: got swapped %v, expected %v", tc.old, tc.new, swapped, tc.swapped)
+		}
+		get(tc.value, tc.exists)
+	}
+}
+
+func TestCompareAndSwapConcurrent(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	bucket, key := []byte("b"), []byte("counter")
+
+	const workers, increments = 8, 20
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			c, err := pool.Get(path)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer c.Close()
+			for done := 0; done < increments; {
+				old, err := c.GetValue(bucket, key)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				n, _ := strconv.Atoi(string(old))
+				swapped, err := c.CompareAndSwap(bucket, key, old, []byte(strconv.Itoa(n+1)))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if swapped {
+					done++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	v, err := c.GetValue(bucket, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strconv.Itoa(workers * increments); string(v) != want {
+		t.Errorf("got counter %s, expected %s", v, want)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"strings"
+	"sync"
+)
+
+// ChangeType is the kind of a change of a value.
+type ChangeType int
+
+// Types of value changes.
+const (
+	ChangePut ChangeType = iota + 1
+	ChangeDelete
+)
+
+func (t ChangeType) String() string {
+	switch t {
+	case ChangePut:
+		return "put"
+	case ChangeDelete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// ChangeEvent describes a value change committed through the pool value
+// helpers, like Connection PutValue, DeleteValue and Increment.
+type ChangeEvent struct {
+	Type   ChangeType
+	Path   string
+	Bucket []byte
+	Key    []byte
+	// Value is the new decoded value for put changes.
+	Value []byte
+}
+
+// watchBuffer is the number of events buffered for every watcher.
+const watchBuffer = 64
+
+type changeWatcher struct {
+	path   string
+	prefix string
+	ch     chan ChangeEvent
+}
+
+type changeWatchers struct {
+	watchers map[*changeWatcher]struct{}
+	mu       sync.RWMutex
+}
+
+// Watch returns a channel that receives events for changes committed
+// through the pool value helpers in the database on path, for buckets whose
+// names start with the bucket prefix. Changes made directly on the database
+// are not reported. Events are delivered without blocking writers, so they
+// are dropped if the channel buffer is full. The returned function stops
+// the watch and closes the channel.
+func (p *Pool) Watch(path, bucketPrefix string) (events <-chan ChangeEvent, cancel func()) {
+	if normalized, err := p.normalizePath(path); err == nil {
+		path = normalized
+	}
+	w := &changeWatcher{
+		path:   path,
+		prefix: bucketPrefix,
+		ch:     make(chan ChangeEvent, watchBuffer),
+	}
+
+	p.changes.mu.Lock()
+	if p.changes.watchers == nil {
+		p.changes.watchers = map[*changeWatcher]struct{}{}
+	}
+	p.changes.watchers[w] = struct{}{}
+	p.changes.mu.Unlock()
+
+	var once sync.Once
+	return w.ch, func() {
+		once.Do(func() {
+			p.changes.mu.Lock()
+			delete(p.changes.watchers, w)
+			close(w.ch)
+			p.changes.mu.Unlock()
+		})
+	}
+}
+
+// notifyChange sends the event to all matching watchers.
+func (p *Pool) notifyChange(e ChangeEvent) {
+	p.changes.mu.RLock()
+	defer p.changes.mu.RUnlock()
+
+	for w := range p.changes.watchers {
+		if w.path != e.Path || !strings.HasPrefix(string(e.Bucket), w.prefix) {
+			continue
+		}
+		select {
+		case w.ch <- e:
+		default:
+		}
+	}
+}
+
+// notifyChange reports the change of the value in the connection database
+// if the write succeeded.
+func (c *Connection) notifyChange(err error, t ChangeType, bucket, key, value []byte) {
+	if err != nil {
+		return
+	}
+	e := ChangeEvent{
+		Type:   t,
+		Path:   c.path,
+		Bucket: append([]byte{}, bucket...),
+		Key:    append([]byte{}, key...),
+	}
+	if t == ChangePut {
+		e.Value = append([]byte{}, value...)
+	}
+	c.pool.notifyChange(e)
+}
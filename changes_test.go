@@ -0,0 +1,74 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestWatch(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+	events, cancel := pool.Watch(path, "users")
+	defer cancel()
+
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.PutValue([]byte("settings"), []byte("theme"), []byte("dark")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PutValue([]byte("users"), []byte("ana"), []byte("31")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteValue([]byte("users-archive"), []byte("bob")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Increment([]byte("users.count"), []byte("n"), 1); err != nil {
+		t.Fatal(err)
+	}
+	// other databases are not reported
+	if _, err := pool.Increment(filepath.Join(dir, "other.db"), "users", "n", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []struct {
+		typ    ChangeType
+		bucket string
+		key    string
+		value  string
+	}{
+		{ChangePut, "users", "ana", "31"},
+		{ChangeDelete, "users-archive", "bob", ""},
+		{ChangePut, "users.count", "n", "\x00\x00\x00\x00\x00\x00\x00\x01"},
+	} {
+		e := <-events
+		if e.Type != want.typ || string(e.Bucket) != want.bucket || string(e.Key) != want.key || string(e.Value) != want.value || e.Path != path {
+			t.Errorf("got event %s %s %s %s %q, expected %s %s %s %q", e.Type, e.Path, e.Bucket, e.Key, e.Value, want.typ, want.bucket, want.key, want.value)
+		}
+	}
+	select {
+	case e := <-events:
+		t.Errorf("unexpected event %+v", e)
+	default:
+	}
+
+	cancel()
+	if _, ok := <-events; ok {
+		t.Error("channel not closed after cancel")
+	}
+	// writes after cancel do not panic
+	if err := c.PutValue([]byte("users"), []byte("eve"), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+}
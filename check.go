@@ -0,0 +1,75 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import "sort"
+
+// Check runs bolt's consistency check on the database on path. The
+// database is opened through the pool if it is not already open, but no
+// new database file is created. Writes to the database are blocked while
+// the check is running. If Options.OnCorrupt is set and the database is
+// not referenced by other connections, a failing database is recovered.
+func (p *Pool) Check(path string) error {
+	c, err := p.getExisting(path)
+	if err != nil {
+		return err
+	}
+	err = c.check()
+	if err != nil && p.options.OnCorrupt != nil && !c.DB.IsReadOnly() {
+		return p.recoverConnection(c, err)
+	}
+	c.Close()
+	return err
+}
+
+// CheckAll runs bolt's consistency check on every database open in the
+// pool and returns the results for every database path. Nil values are set
+// for databases that passed the check.
+func (p *Pool) CheckAll() map[string]error {
+	p.mu.RLock()
+	paths := make([]string, 0, len(p.connections))
+	for path := range p.connections {
+		paths = append(paths, path)
+	}
+	p.mu.RUnlock()
+	sort.Strings(paths)
+
+	results := make(map[string]error, len(paths))
+	for _, path := range paths {
+		c := p.acquire(path)
+		if c == nil {
+			continue
+		}
+		results[path] = c.check()
+		c.release()
+	}
+	return results
+}
+
+// recoverConnection releases the connection and recovers its database if
+// it has no other references. It returns the cause.
+func (p *Pool) recoverConnection(c *Connection, cause error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.decrement()
+	if c.count > 0 {
+		return cause
+	}
+	p.handleError(c.remove())
+	delete(p.verified, c.path)
+	if err := p.recoverFile(c.path, cause); err != nil {
+		p.handleError(err)
+	}
+	return cause
+}
+
+func (c *Connection) check() error {
+	return verifyDB(c.DB, VerifyFull)
+}
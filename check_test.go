@@ -0,0 +1,49 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	pool := New(nil)
+	defer pool.Close()
+
+	paths := []string{filepath.Join(dir, "a.db"), filepath.Join(dir, "b.db")}
+	for _, path := range paths {
+		c, err := pool.Get(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		fragment(t, c.DB)
+	}
+
+	if err := pool.Check(paths[0]); err != nil {
+		t.Error(err)
+	}
+	if err := pool.Check(filepath.Join(dir, "missing.db")); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+
+	results := pool.CheckAll()
+	if len(results) != 2 {
+		t.Errorf("got %d results, expected 2", len(results))
+	}
+	for _, path := range paths {
+		err, ok := results[path]
+		if !ok {
+			t.Errorf("no result for %s", path)
+		}
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Clone writes a consistent copy of the database on srcPath to a new
+// database file on dstPath within a single read transaction, and opens the
+// copy in the pool, in respect to the pool expiration options. Pending
+// journal entries of the source database are applied before it is copied.
+// ErrExists is returned if a database on dstPath already exists. Parent
+// directories of dstPath are created if needed.
+func (p *Pool) Clone(srcPath, dstPath string) (err error) {
+	if p.options.ReadOnly {
+		return ErrReadOnly
+	}
+	if dstPath, err = p.normalizePath(dstPath); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("boltdbpool: clone %s: %w", dstPath, err)
+		}
+	}()
+
+	p.mu.Lock()
+	err = p.checkClonePath(dstPath)
+	if err == nil {
+		err = p.mkdirAll(filepath.Dir(dstPath))
+	}
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	src, err := p.getExisting(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if err := p.FlushJournal(src.path); err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(filepath.Dir(dstPath), fmt.Sprintf(".%s.clone-%d", filepath.Base(dstPath), time.Now().UnixNano()))
+	_, mode := p.boltOptions(dstPath)
+	f, err := p.fs.OpenFile(tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	defer p.fs.Remove(tmp)
+	if err := src.View(func(tx *bolt.Tx) error {
+		_, err := tx.WriteTo(f)
+		return err
+	}); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := p.applyFilePermissions(tmp); err != nil {
+		return err
+	}
+
+	if err := p.moveClone(tmp, dstPath); err != nil {
+		return err
+	}
+	c, err := p.Get(dstPath)
+	if err != nil {
+		return err
+	}
+	c.Close()
+	return nil
+}
+
+// checkClonePath returns ErrExists if a database exists on path. Pool lock
+// must be held.
+func (p *Pool) checkClonePath(path string) error {
+	if p.isClosed() {
+		return ErrClosed
+	}
+	p.waitCompaction(path)
+	if _, ok := p.connections[path]; ok {
+		return ErrExists
+	}
+	if _, err := p.fs.Stat(path); err == nil {
+		return ErrExists
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// moveClone renames the copied file to the database path if no database
+// was created on it while the file was written.
+func (p *Pool) moveClone(tmp, path string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.checkClonePath(path); err != nil {
+		return err
+	}
+	p.invalidateCache(path)
+	return p.fs.Rename(tmp, path)
+}
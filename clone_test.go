@@ -0,0 +1,76 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClone(t *testing.T) {
+	pool := New(&Options{
+		ConnectionExpires: time.Minute,
+		Journal:           &Journal{Interval: time.Hour},
+	})
+	defer pool.Close()
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "tenant.db")
+	dst := filepath.Join(dir, "staging", "tenant.db")
+
+	c, err := pool.Get(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.PutValue([]byte("bucket"), []byte("key"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Append(src, []JournalEntry{
+		{Type: ChangePut, Bucket: []byte("bucket"), Key: []byte("journal"), Value: []byte("value")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pool.Clone(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if !pool.Has(dst) {
+		t.Error("clone is not opened in the pool")
+	}
+
+	// databases are independent
+	if err := c.PutValue([]byte("bucket"), []byte("key"), []byte("changed")); err != nil {
+		t.Fatal(err)
+	}
+	cc, err := pool.Get(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	for key, want := range map[string]string{"key": "value", "journal": "value"} {
+		if v, err := cc.GetValue([]byte("bucket"), []byte(key)); err != nil || string(v) != want {
+			t.Errorf("%s: got value %q (%v), expected %q", key, v, err, want)
+		}
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "staging", ".*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) > 0 {
+		t.Errorf("temporary files are not removed: %v", matches)
+	}
+
+	if err := pool.Clone(src, dst); !errors.Is(err, ErrExists) {
+		t.Errorf("got error %v, expected %v", err, ErrExists)
+	}
+	if err := pool.Clone(filepath.Join(dir, "missing.db"), filepath.Join(dir, "new.db")); !os.IsNotExist(errors.Unwrap(err)) {
+		t.Errorf("got error %v, expected not exist error", err)
+	}
+}
@@ -0,0 +1,353 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command boltdbpool operates on a directory of bolt databases managed by
+// boltdbpool or timed pools.
+//
+// Usage:
+//
+//	boltdbpool <command> [flags] <dir>
+//
+// Commands:
+//
+//	ls       list databases and their sizes
+//	stats    print stats of all databases as JSON
+//	backup   write a tar archive of the directory
+//	compact  compact all databases
+//	check    run consistency checks on all databases
+//	export   write every database in the JSON lines export format
+//	sql      write every database as SQL statements for SQLite
+//
+// The export command writes a file for every database to the output
+// directory, named by appending the ".jsonl" extension to the database
+// path, in the format of boltdbpool.Pool.Export. The sql command writes
+// files with the ".sql" extension in the same way, as written by
+// boltdbpool.Pool.ExportSQL, which can be loaded with the sqlite3 command.
+//
+// Databases are files with the extension set by the -ext flag, or files
+// that are in the directory layout of a timed pool if the -period flag is
+// set.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"resenje.org/boltdbpool"
+	"resenje.org/boltdbpool/timed"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "boltdbpool:", err)
+		}
+		os.Exit(2)
+	}
+}
+
+var commands = []struct {
+	name string
+	help string
+	run  func(cmd *command) error
+}{
+	{"ls", "list databases and their sizes", ls},
+	{"stats", "print stats of all databases as JSON", stats},
+	{"backup", "write a tar archive of the directory", backup},
+	{"compact", "compact all databases", compact},
+	{"check", "run consistency checks on all databases", check},
+	{"export", "write every database in the JSON lines export format", export},
+	{"sql", "write every database as SQL statements for SQLite", exportSQL},
+}
+
+// command holds parsed flags and the directory of a single invocation.
+type command struct {
+	dir    string
+	period string
+	ext    string
+	output string
+	stdout io.Writer
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		usage(stderr)
+		return flag.ErrHelp
+	}
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+		cmd := &command{
+			stdout: stdout,
+		}
+		fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		fs.StringVar(&cmd.period, "period", "", "timed pool period: minutely, minutes like 15m, hourly, daily, weekly, monthly or yearly")
+		fs.StringVar(&cmd.ext, "ext", ".db", "database file extension")
+		switch c.name {
+		case "backup":
+			fs.StringVar(&cmd.output, "o", "-", "output file")
+		case "export", "sql":
+			fs.StringVar(&cmd.output, "o", ".", "output directory")
+		}
+		fs.Usage = func() {
+			fmt.Fprintf(stderr, "usage: boltdbpool %s [flags] <dir>\n\n%s\n\n", c.name, c.help)
+			fs.PrintDefaults()
+		}
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			fs.Usage()
+			return flag.ErrHelp
+		}
+		cmd.dir = fs.Arg(0)
+		return c.run(cmd)
+	}
+	usage(stderr)
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: boltdbpool <command> [flags] <dir>")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.help)
+	}
+}
+
+// databases returns sorted paths of all databases in the directory.
+func (cmd *command) databases() ([]string, error) {
+	if cmd.period != "" {
+		period, err := timed.ParsePeriod(cmd.period)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := timed.New(cmd.dir, period, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer pool.Close()
+
+		return pool.Paths(), nil
+	}
+	var paths []string
+	err := filepath.Walk(cmd.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// skip temporary files created by compaction and backups
+		if !info.Mode().IsRegular() || strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+		if filepath.Ext(path) == cmd.ext {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// name returns the database path relative to the directory.
+func (cmd *command) name(path string) string {
+	if rel, err := filepath.Rel(cmd.dir, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// create returns the writer for the output flag.
+func (cmd *command) create() (io.WriteCloser, error) {
+	if cmd.output == "-" {
+		return nopCloser{cmd.stdout}, nil
+	}
+	return os.Create(cmd.output)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+func ls(cmd *command) error {
+	paths, err := cmd.databases()
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.stdout, "%s\t%d\n", cmd.name(path), info.Size())
+	}
+	return nil
+}
+
+func stats(cmd *command) error {
+	paths, err := cmd.databases()
+	if err != nil {
+		return err
+	}
+	pool := boltdbpool.New(nil)
+	defer pool.Close()
+
+	for _, path := range paths {
+		c, err := pool.Get(path)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+	}
+	s := pool.Stats()
+	for i := range s.Databases {
+		s.Databases[i].Path = cmd.name(s.Databases[i].Path)
+		// references are held only by this command
+		s.Databases[i].References = 0
+	}
+	e := json.NewEncoder(cmd.stdout)
+	e.SetIndent("", "  ")
+	return e.Encode(s.Databases)
+}
+
+func backup(cmd *command) (err error) {
+	paths, err := cmd.databases()
+	if err != nil {
+		return err
+	}
+	options := &boltdbpool.Options{}
+	if cmd.period == "" {
+		options.BackupRoot = cmd.dir
+	}
+	pool := boltdbpool.New(options)
+	defer pool.Close()
+
+	for _, path := range paths {
+		c, err := pool.Get(path)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+	}
+	w, err := cmd.create()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	return pool.BackupAll(w)
+}
+
+func compact(cmd *command) error {
+	paths, err := cmd.databases()
+	if err != nil {
+		return err
+	}
+	pool := boltdbpool.New(nil)
+	defer pool.Close()
+
+	for _, path := range paths {
+		before, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if err := pool.Compact(path); err != nil {
+			return err
+		}
+		after, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.stdout, "%s\t%d\t%d\n", cmd.name(path), before.Size(), after.Size())
+	}
+	return nil
+}
+
+func check(cmd *command) error {
+	paths, err := cmd.databases()
+	if err != nil {
+		return err
+	}
+	pool := boltdbpool.New(&boltdbpool.Options{
+		ErrorHandler: func(error) {},
+	})
+	defer pool.Close()
+
+	var failed int
+	for _, path := range paths {
+		if err := pool.Check(path); err != nil {
+			failed++
+			fmt.Fprintf(cmd.stdout, "%s\t%v\n", cmd.name(path), err)
+			continue
+		}
+		fmt.Fprintf(cmd.stdout, "%s\tok\n", cmd.name(path))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d databases failed the check", failed, len(paths))
+	}
+	return nil
+}
+
+func export(cmd *command) error {
+	paths, err := cmd.databases()
+	if err != nil {
+		return err
+	}
+	pool := boltdbpool.New(nil)
+	defer pool.Close()
+
+	for _, path := range paths {
+		if err := exportFile(pool.Export, path, filepath.Join(cmd.output, filepath.FromSlash(cmd.name(path))+".jsonl")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportSQL(cmd *command) error {
+	paths, err := cmd.databases()
+	if err != nil {
+		return err
+	}
+	pool := boltdbpool.New(nil)
+	defer pool.Close()
+
+	for _, path := range paths {
+		if err := exportFile(pool.ExportSQL, path, filepath.Join(cmd.output, filepath.FromSlash(cmd.name(path))+".sql")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportFile writes the database on path with the export function to the
+// file.
+func exportFile(export func(path string, w io.Writer) error, path, filename string) (err error) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	return export(path, f)
+}
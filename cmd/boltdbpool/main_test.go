@@ -0,0 +1,227 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"resenje.org/boltdbpool"
+	"resenje.org/boltdbpool/timed"
+)
+
+func newTestDir(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	pool := boltdbpool.New(nil)
+	defer pool.Close()
+
+	for _, name := range []string{"a.db", filepath.Join("sub", "b.db")} {
+		c, err := pool.Get(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Update(func(tx *bolt.Tx) error {
+			b, err := tx.CreateBucketIfNotExists([]byte("bucket"))
+			if err != nil {
+				return err
+			}
+			nb, err := b.CreateBucketIfNotExists([]byte("nested"))
+			if err != nil {
+				return err
+			}
+			if err := nb.Put([]byte("n"), []byte("1")); err != nil {
+				return err
+			}
+			return b.Put([]byte("key"), []byte(name))
+		}); err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+	}
+	return dir
+}
+
+func runCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	err := run(args, &stdout, &stderr)
+	return stdout.String(), err
+}
+
+func TestLs(t *testing.T) {
+	dir := newTestDir(t)
+
+	out, err := runCommand(t, "ls", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "a.db\t") || !strings.HasPrefix(lines[1], "sub/b.db\t") {
+		t.Errorf("got output %q", out)
+	}
+}
+
+func TestLsPeriod(t *testing.T) {
+	dir := t.TempDir()
+	pool, err := timed.New(dir, timed.Daily, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := pool.NewConnection(time.Date(2020, 3, 4, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	pool.Close()
+
+	out, err := runCommand(t, "ls", "-period", "daily", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "202003/20200304.db\t") {
+		t.Errorf("got output %q", out)
+	}
+	if _, err := runCommand(t, "ls", "-period", "fortnightly", dir); err != timed.ErrUnknownPeriod {
+		t.Errorf("got error %v, expected %v", err, timed.ErrUnknownPeriod)
+	}
+}
+
+func TestStats(t *testing.T) {
+	dir := newTestDir(t)
+
+	out, err := runCommand(t, "stats", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []boltdbpool.DatabaseStats
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Path != "a.db" || got[1].Path != "sub/b.db" || got[0].Size == 0 {
+		t.Errorf("got stats %+v", got)
+	}
+}
+
+func TestBackup(t *testing.T) {
+	dir := newTestDir(t)
+
+	out, err := runCommand(t, "backup", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	tr := tar.NewReader(strings.NewReader(out))
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, h.Name)
+	}
+	if strings.Join(names, ",") != "a.db,sub/b.db,"+boltdbpool.BackupChecksumsName {
+		t.Errorf("got archive entries %v", names)
+	}
+}
+
+func TestCompactAndCheck(t *testing.T) {
+	dir := newTestDir(t)
+
+	out, err := runCommand(t, "compact", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(out, "\n"); n != 2 {
+		t.Errorf("got output %q", out)
+	}
+
+	out, err = runCommand(t, "check", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "a.db\tok\nsub/b.db\tok\n" {
+		t.Errorf("got output %q", out)
+	}
+}
+
+func TestExport(t *testing.T) {
+	dir := newTestDir(t)
+	out := t.TempDir()
+
+	if _, err := runCommand(t, "export", "-o", out, dir); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(out, "sub", "b.db.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := boltdbpool.New(nil)
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "imported.db")
+	if err := pool.Import(path, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("bucket"))
+		if v := b.Get([]byte("key")); string(v) != filepath.Join("sub", "b.db") {
+			t.Errorf("got value %q", v)
+		}
+		if v := b.Bucket([]byte("nested")).Get([]byte("n")); string(v) != "1" {
+			t.Errorf("got nested value %q", v)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSQL(t *testing.T) {
+	dir := newTestDir(t)
+	out := t.TempDir()
+
+	if _, err := runCommand(t, "sql", "-o", out, dir); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(out, "sub", "b.db.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`CREATE TABLE "bucket" ("key" BLOB PRIMARY KEY, "value" BLOB);`,
+		`CREATE TABLE "bucket/nested" ("key" BLOB PRIMARY KEY, "value" BLOB);`,
+		`INSERT INTO "bucket/nested" VALUES(X'6e',X'31');`,
+	} {
+		if !strings.Contains(string(data), want+"\n") {
+			t.Errorf("output does not contain %s:\n%s", want, data)
+		}
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	if _, err := runCommand(t, "unknown"); err == nil {
+		t.Error("expected error")
+	}
+}
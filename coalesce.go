@@ -0,0 +1,236 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Defaults for Coalescing fields that are not set.
+const (
+	DefaultCoalescingWindow    = 10 * time.Millisecond
+	DefaultCoalescingMaxWrites = 1000
+)
+
+// Coalescing configures how writes done with Pool.Coalesce to a single
+// database are combined into transactions.
+type Coalescing struct {
+	// Window is the maximal duration that a write waits for other writes
+	// before they are executed. If the value is 0, DefaultCoalescingWindow
+	// is used.
+	Window time.Duration
+	// MaxWrites is the number of writes after which they are executed
+	// without waiting for the window to pass. If the value is 0,
+	// DefaultCoalescingMaxWrites is used.
+	MaxWrites int
+}
+
+// CoalescingStats are counters of writes done with Pool.Coalesce to a
+// single database.
+type CoalescingStats struct {
+	Path string `json:"path"`
+	// Writes is the number of write functions whose results are returned
+	// to their callers.
+	Writes uint64 `json:"writes"`
+	// Transactions is the number of read-write transactions in which
+	// write functions were executed, including the failed ones.
+	Transactions uint64 `json:"transactions"`
+	// Retries is the number of transactions that were repeated without a
+	// write function that returned an error.
+	Retries uint64 `json:"retries"`
+}
+
+// Coalesce calls fn as a part of a read-write transaction on the database
+// on path that is shared with other functions passed to Coalesce for the
+// same database within the Coalescing window from Options.WriteCoalescing.
+// It is like bolt DB.Batch, but across connections, and tuned per database.
+// If fn returns an error, the transaction is rolled back and repeated
+// without it, and the error is returned only to its caller. Functions may
+// be called multiple times, so they must be idempotent. A panic in fn is
+// returned to its caller as PanicError. Coalesce blocks until the
+// transaction with fn is committed or fails.
+func (p *Pool) Coalesce(path string, fn func(*bolt.Tx) error) error {
+	path, err := p.normalizePath(path)
+	if err != nil {
+		return err
+	}
+	return p.coalescer(path).add(fn)
+}
+
+// CoalescingStats returns counters of writes done with Coalesce sorted by
+// database paths.
+func (p *Pool) CoalescingStats() []CoalescingStats {
+	p.coalescers.mu.Lock()
+	coalescers := make([]*coalescer, 0, len(p.coalescers.coalescers))
+	for _, c := range p.coalescers.coalescers {
+		coalescers = append(coalescers, c)
+	}
+	p.coalescers.mu.Unlock()
+
+	stats := make([]CoalescingStats, 0, len(coalescers))
+	for _, c := range coalescers {
+		c.mu.Lock()
+		stats = append(stats, c.stats)
+		c.mu.Unlock()
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Path < stats[j].Path
+	})
+	return stats
+}
+
+type coalescers struct {
+	coalescers map[string]*coalescer
+	mu         sync.Mutex
+}
+
+// coalescer returns the coalescer for the database on path, creating it
+// with the configuration from Options.WriteCoalescing if needed.
+func (p *Pool) coalescer(path string) *coalescer {
+	p.coalescers.mu.Lock()
+	defer p.coalescers.mu.Unlock()
+
+	if c, ok := p.coalescers.coalescers[path]; ok {
+		return c
+	}
+	var config Coalescing
+	if p.options.WriteCoalescing != nil {
+		config = p.options.WriteCoalescing(path)
+	}
+	if config.Window <= 0 {
+		config.Window = DefaultCoalescingWindow
+	}
+	if config.MaxWrites <= 0 {
+		config.MaxWrites = DefaultCoalescingMaxWrites
+	}
+	c := &coalescer{
+		pool:   p,
+		path:   path,
+		config: config,
+		stats:  CoalescingStats{Path: path},
+	}
+	if p.coalescers.coalescers == nil {
+		p.coalescers.coalescers = make(map[string]*coalescer)
+	}
+	p.coalescers.coalescers[path] = c
+	return c
+}
+
+// coalescer collects write functions for a single database.
+type coalescer struct {
+	pool   *Pool
+	path   string
+	config Coalescing
+
+	pending []coalescedCall
+	timer   *time.Timer
+	stats   CoalescingStats
+	mu      sync.Mutex
+}
+
+type coalescedCall struct {
+	fn  func(*bolt.Tx) error
+	err chan error
+}
+
+// add schedules fn for execution and waits for its result.
+func (c *coalescer) add(fn func(*bolt.Tx) error) error {
+	call := coalescedCall{fn: fn, err: make(chan error, 1)}
+
+	c.mu.Lock()
+	c.pending = append(c.pending, call)
+	switch {
+	case len(c.pending) >= c.config.MaxWrites:
+		calls := c.take()
+		c.mu.Unlock()
+		c.run(calls)
+	case len(c.pending) == 1:
+		c.timer = time.AfterFunc(c.config.Window, c.flush)
+		c.mu.Unlock()
+	default:
+		c.mu.Unlock()
+	}
+	return <-call.err
+}
+
+// take returns pending calls and stops the window timer. Coalescer lock
+// must be held.
+func (c *coalescer) take() []coalescedCall {
+	calls := c.pending
+	c.pending = nil
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	return calls
+}
+
+// flush executes pending calls when the window passes.
+func (c *coalescer) flush() {
+	c.mu.Lock()
+	calls := c.take()
+	c.mu.Unlock()
+
+	if len(calls) > 0 {
+		c.run(calls)
+	}
+}
+
+// run executes calls in a single transaction, repeating it without the
+// call that returned an error, until the transaction is committed or fails
+// for other reasons.
+func (c *coalescer) run(calls []coalescedCall) {
+	conn, err := c.pool.Get(c.path)
+	if err != nil {
+		for _, call := range calls {
+			call.err <- err
+		}
+		return
+	}
+	defer conn.Close()
+
+	for retry := false; len(calls) > 0; retry = true {
+		failed := -1
+		var failedErr error
+		err := conn.Update(func(tx *bolt.Tx) error {
+			for i, call := range calls {
+				if err := c.pool.protect("Coalesce", func() error {
+					return call.fn(tx)
+				}); err != nil {
+					failed, failedErr = i, err
+					return err
+				}
+			}
+			return nil
+		})
+
+		c.mu.Lock()
+		c.stats.Transactions++
+		if retry {
+			c.stats.Retries++
+		}
+		if failed < 0 {
+			c.stats.Writes += uint64(len(calls))
+		} else {
+			c.stats.Writes++
+		}
+		c.mu.Unlock()
+
+		if failed < 0 {
+			// committed, or failed before or after the calls
+			for _, call := range calls {
+				call.err <- err
+			}
+			return
+		}
+		calls[failed].err <- failedErr
+		calls = append(calls[:failed:failed], calls[failed+1:]...)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestCoalesce(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+
+	pool := New(&Options{
+		WriteCoalescing: func(p string) Coalescing {
+			if p != path {
+				t.Errorf("got coalescing path %q, expected %q", p, path)
+			}
+			return Coalescing{Window: time.Hour, MaxWrites: 10}
+		},
+	})
+	defer pool.Close()
+
+	errTest := errors.New("test")
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, 10)
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			errs[i] = pool.Coalesce(path, func(tx *bolt.Tx) error {
+				if i == 3 {
+					return errTest
+				}
+				if i == 5 {
+					panic("test")
+				}
+				b, err := tx.CreateBucketIfNotExists([]byte("b"))
+				if err != nil {
+					return err
+				}
+				return b.Put([]byte(fmt.Sprint(i)), nil)
+			})
+		}(i)
+	}
+	// the window is long, so writes are executed when MaxWrites is reached
+	wg.Wait()
+
+	for i, err := range errs {
+		switch i {
+		case 3:
+			if err != errTest {
+				t.Errorf("write %d: got error %v, expected %v", i, err, errTest)
+			}
+		case 5:
+			var perr *PanicError
+			if !errors.As(err, &perr) || perr.Value != "test" {
+				t.Errorf("write %d: got error %v, expected panic", i, err)
+			}
+		default:
+			if err != nil {
+				t.Errorf("write %d: got error %v", i, err)
+			}
+		}
+	}
+
+	if err := pool.With(path, func(db *bolt.DB) error {
+		return db.View(func(tx *bolt.Tx) error {
+			if n := tx.Bucket([]byte("b")).Stats().KeyN; n != 8 {
+				t.Errorf("got %d keys, expected 8", n)
+			}
+			return nil
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := pool.CoalescingStats()
+	if len(stats) != 1 {
+		t.Fatalf("got %d stats, expected 1", len(stats))
+	}
+	want := CoalescingStats{Path: path, Writes: 10, Transactions: 3, Retries: 2}
+	if stats[0] != want {
+		t.Errorf("got stats %+v, expected %+v", stats[0], want)
+	}
+}
+
+func TestCoalesceWindow(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	if err := pool.Coalesce(path, func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucket([]byte("b"))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	stats := pool.CoalescingStats()
+	want := []CoalescingStats{{Path: path, Writes: 1, Transactions: 1}}
+	if fmt.Sprint(stats) != fmt.Sprint(want) {
+		t.Errorf("got stats %+v, expected %+v", stats, want)
+	}
+}
+
+func TestCoalesceReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	pool := New(nil)
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	pool.Close()
+
+	pool = New(&Options{ReadOnly: true})
+	defer pool.Close()
+
+	if err := pool.Coalesce(path, func(tx *bolt.Tx) error {
+		return nil
+	}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("got error %v, expected %v", err, ErrReadOnly)
+	}
+}
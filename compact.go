@@ -0,0 +1,282 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// compactTxMaxSize is the maximal size of a single transaction used for
+// copying data during compaction.
+const compactTxMaxSize = 64 * 1024 * 1024
+
+// fragmentation returns the ratio of free pages to all allocated pages of
+// an open database.
+func fragmentation(db *bolt.DB) (f float64) {
+	pageSize := int64(db.Info().PageSize)
+	if pageSize == 0 {
+		return 0
+	}
+	s := db.Stats()
+	_ = db.View(func(tx *bolt.Tx) error {
+		if pages := tx.Size() / pageSize; pages > 0 {
+			f = float64(s.FreePageN+s.PendingPageN) / float64(pages)
+		}
+		return nil
+	})
+	return f
+}
+
+// removeExpired closes an expired database and schedules its compaction in
+// the background if its fragmentation is above the Options.CompactOnClose
+// threshold. Pool lock must be held.
+func (p *Pool) removeExpired(c *Connection) {
+	c.emit(EventExpired, nil)
+	p.removeAndCompact(c, p.options.CompactOnClose, true)
+}
+
+// removeAndCompact closes the database, or parks it if park is true, and
+// schedules its compaction in the background if its fragmentation is above
+// the threshold. Databases that are compacted are closed before they are
+// parked. If Options.MaxBackgroundWorkers is set, the database is also
+// closed or parked in the background and Get waits for it as for the
+// compaction. Pool lock must be held.
+func (p *Pool) removeAndCompact(c *Connection, threshold float64, park bool) {
+	compact := threshold > 0 && !c.DB.IsReadOnly() && fragmentation(c.DB) > threshold
+	parkOpen := park && !compact && p.options.MaxParked > 0
+	if p.workers != nil {
+		p.detach(c.path)
+		unmark := p.markBusy(c.path)
+		p.goBackground(func() {
+			defer unmark()
+
+			if parkOpen {
+				open := p.flushParked(c)
+				p.mu.Lock()
+				p.park(c, open)
+				p.mu.Unlock()
+				return
+			}
+			if err := p.closeDB(c); err != nil {
+				p.handleError(err)
+				return
+			}
+			if park {
+				p.mu.Lock()
+				p.park(c, false)
+				p.mu.Unlock()
+			}
+			if compact {
+				p.throttle()
+				p.handleError(p.compactFile(c.path))
+			}
+		})
+		return
+	}
+	if parkOpen {
+		p.detach(c.path)
+		p.park(c, p.flushParked(c))
+		return
+	}
+	if err := c.remove(); err != nil {
+		p.handleError(err)
+		return
+	}
+	if park {
+		p.park(c, false)
+	}
+	if !compact {
+		return
+	}
+	unmark := p.markBusy(c.path)
+	p.goBackground(func() {
+		defer unmark()
+
+		p.throttle()
+		p.handleError(p.compactFile(c.path))
+	})
+}
+
+// waitCompaction blocks until the database on path is not being compacted.
+// Pool lock must be held and it is released while waiting.
+func (p *Pool) waitCompaction(path string) {
+	for {
+		done, ok := p.compacting[path]
+		if !ok {
+			return
+		}
+		p.mu.Unlock()
+		<-done
+		p.mu.Lock()
+	}
+}
+
+// compactFile copies all data from the closed database on path to a new
+// file and replaces the original file with it.
+func (p *Pool) compactFile(path string) (err error) {
+	p.emit(EventCompactionStarted, path, nil, nil)
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("boltdbpool: compact %s: %w", path, err)
+		}
+		p.emit(EventCompactionFinished, path, nil, err)
+	}()
+
+	info, err := p.fs.Stat(path)
+	if err != nil {
+		return err
+	}
+	options, _ := p.boltOptions(path)
+	if options != nil && options.ReadOnly {
+		return ErrReadOnly
+	}
+	src, err := bolt.Open(path, info.Mode(), options)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".compact")
+	dst, err := bolt.Open(tmp, info.Mode(), options)
+	if err != nil {
+		return err
+	}
+	defer p.fs.Remove(tmp)
+	if err := bolt.Compact(dst, src, compactTxMaxSize); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	if err := p.applyFilePermissions(tmp); err != nil {
+		return err
+	}
+	return p.fs.Rename(tmp, path)
+}
+
+// throttle blocks background work while Options.Throttle reports a degraded
+// serving path and the pool is not closed.
+func (p *Pool) throttle() {
+	if p.options.Throttle == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-p.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	_ = p.options.Throttle.Wait(ctx)
+}
+
+// Compact closes the database on path if it has no references and copies
+// all its data to a new file that replaces the original one. ErrInUse is
+// returned if there are connections that reference the database. The
+// database is opened again on the next Pool.Get call, which waits for the
+// compaction to finish.
+func (p *Pool) Compact(path string) error {
+	if p.options.ReadOnly {
+		return ErrReadOnly
+	}
+	path, err := p.normalizePath(path)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.waitCompaction(path)
+	p.closeParked(path)
+	if c, ok := p.connections[path]; ok {
+		c.mu.Lock()
+		if c.count > 0 {
+			c.mu.Unlock()
+			p.mu.Unlock()
+			return ErrInUse
+		}
+		err := c.remove()
+		c.mu.Unlock()
+		if err != nil {
+			p.mu.Unlock()
+			return err
+		}
+	}
+	done := make(chan struct{})
+	p.compacting[path] = done
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		delete(p.compacting, path)
+		p.mu.Unlock()
+		close(done)
+	}()
+
+	return p.compactFile(path)
+}
+
+// CompactIdle compacts databases that are open in the pool, have no
+// references and have free pages, one at a time, as Compact does. Each
+// database is copied to a temporary file that atomically replaces the
+// original one, and it is opened again on the next Pool.Get. Databases
+// that are referenced again before they are compacted are skipped. Work is
+// paused while Options.Throttle reports a degraded serving path. It returns
+// when all databases are compacted or the context is done, with errors from
+// compactions and the context error joined.
+func (p *Pool) CompactIdle(ctx context.Context) error {
+	if p.options.ReadOnly {
+		return ErrReadOnly
+	}
+	var errs []error
+	for _, path := range p.idleFragmented() {
+		if t := p.options.Throttle; t != nil {
+			if err := t.Wait(ctx); err != nil {
+				errs = append(errs, err)
+				break
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if err := p.Compact(path); err != nil && err != ErrInUse {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// idleFragmented returns sorted paths of writable databases that have no
+// references and have free pages.
+func (p *Pool) idleFragmented() (paths []string) {
+	p.mu.RLock()
+	connections := make([]*Connection, 0, len(p.connections))
+	for _, c := range p.connections {
+		connections = append(connections, c)
+	}
+	p.mu.RUnlock()
+
+	// connection locks are not acquired under the pool lock, as
+	// Connection.Close acquires them in the reverse order
+	for _, c := range connections {
+		c.mu.RLock()
+		if v, ok := p.index.Load(c.path); ok && v == c && c.count == 0 && !c.DB.IsReadOnly() && fragmentation(c.DB) > 0 {
+			paths = append(paths, c.path)
+		}
+		c.mu.RUnlock()
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,209 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestCompactOnClose(t *testing.T) {
+	now := time.Now()
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+		CompactOnClose:    0.5,
+		Now: func() time.Time {
+			return now
+		},
+	})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fragment(t, c.DB)
+	c.Close()
+
+	before := fileSize(t, path)
+
+	now = now.Add(2 * time.Hour)
+	pool.CloseExpired()
+
+	c, err = pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if after := fileSize(t, path); after >= before {
+		t.Errorf("database not compacted: size before %d, after %d", before, after)
+	}
+	if err := c.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket([]byte("bucket")).Get([]byte("key-0")); v == nil {
+			t.Error("data lost after compaction")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fragment writes and deletes a lot of data, leaving a single key in the
+// database.
+func fragment(t *testing.T, db *bolt.DB) {
+	t.Helper()
+
+	value := make([]byte, 1024)
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte("bucket"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 1000; i++ {
+			if err := b.Put([]byte(fmt.Sprintf("key-%d", i)), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("bucket"))
+		for i := 1; i < 1000; i++ {
+			if err := b.Delete([]byte(fmt.Sprintf("key-%d", i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+func TestCompact(t *testing.T) {
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+	})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fragment(t, c.DB)
+
+	if err := pool.Compact(path); err != ErrInUse {
+		t.Errorf("got error %v, expected %v", err, ErrInUse)
+	}
+	c.Close()
+
+	before := fileSize(t, path)
+	if err := pool.Compact(path); err != nil {
+		t.Fatal(err)
+	}
+	if pool.Has(path) {
+		t.Error("compacted database is open")
+	}
+	if after := fileSize(t, path); after >= before {
+		t.Errorf("database not compacted: size before %d, after %d", before, after)
+	}
+}
+
+func TestCompactIdle(t *testing.T) {
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+	})
+	defer pool.Close()
+
+	dir := t.TempDir()
+	idle := filepath.Join(dir, "idle.db")
+	used := filepath.Join(dir, "used.db")
+	c, err := pool.Get(idle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fragment(t, c.DB)
+	c.Close()
+	u, err := pool.Get(used)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer u.Close()
+	fragment(t, u.DB)
+
+	idleBefore, usedBefore := fileSize(t, idle), fileSize(t, used)
+	if err := pool.CompactIdle(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if pool.Has(idle) {
+		t.Error("compacted database is open")
+	}
+	if after := fileSize(t, idle); after >= idleBefore {
+		t.Errorf("idle database not compacted: size before %d, after %d", idleBefore, after)
+	}
+	if after := fileSize(t, used); after != usedBefore {
+		t.Errorf("used database compacted: size before %d, after %d", usedBefore, after)
+	}
+
+	c, err = pool.Get(idle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket([]byte("bucket")).Get([]byte("key-0")); v == nil {
+			t.Error("data lost after compaction")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompactIdleCanceled(t *testing.T) {
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+	})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fragment(t, c.DB)
+	c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := pool.CompactIdle(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, expected %v", err, context.Canceled)
+	}
+	if !pool.Has(path) {
+		t.Error("database is compacted after the context is canceled")
+	}
+}
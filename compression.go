@@ -0,0 +1,116 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+)
+
+// ErrInvalidCompression is returned when a stored value does not have a
+// valid compression header.
+var ErrInvalidCompression = errors.New("boltdbpool: invalid compressed value")
+
+// Compressor compresses and decompresses values. Only gzip is provided by
+// this package, as GzipCompressor, to keep the module free of dependencies
+// outside of the standard library. Other algorithms, like snappy or zstd,
+// can be used by implementing this interface with their packages and
+// setting it as Compression.Compressor.
+type Compressor interface {
+	Compress(value []byte) ([]byte, error)
+	Decompress(value []byte) ([]byte, error)
+}
+
+// Compression configures compression of values stored with pool value
+// helpers. Every stored value is prefixed with a single byte that marks if
+// it is compressed, so values that do not benefit from compression are
+// stored as they are. Compression is applied before encryption.
+type Compression struct {
+	// Compressor is the compression algorithm. If nil, GzipCompressor{}
+	// with the default level is used.
+	Compressor Compressor
+	// MinSize is the minimal length of a value that is compressed.
+	MinSize int
+}
+
+const (
+	valueRaw        byte = 0
+	valueCompressed byte = 1
+)
+
+func (c *Compression) compressor() Compressor {
+	if c.Compressor != nil {
+		return c.Compressor
+	}
+	return GzipCompressor{}
+}
+
+func (c *Compression) compress(value []byte) ([]byte, error) {
+	if len(value) >= c.MinSize {
+		v, err := c.compressor().Compress(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(v) < len(value) {
+			return append([]byte{valueCompressed}, v...), nil
+		}
+	}
+	return append([]byte{valueRaw}, value...), nil
+}
+
+func (c *Compression) decompress(value []byte) ([]byte, error) {
+	if len(value) == 0 {
+		return nil, ErrInvalidCompression
+	}
+	switch value[0] {
+	case valueRaw:
+		return value[1:], nil
+	case valueCompressed:
+		return c.compressor().Decompress(value[1:])
+	}
+	return nil, ErrInvalidCompression
+}
+
+// GzipCompressor is a Compressor that uses gzip format.
+type GzipCompressor struct {
+	// Level is the gzip compression level. If 0, which is
+	// gzip.NoCompression, gzip.DefaultCompression is used instead, so that
+	// the zero value compresses values.
+	Level int
+}
+
+// Compress returns gzip compressed value.
+func (g GzipCompressor) Compress(value []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	level := g.Level
+	if level == gzip.NoCompression {
+		level = gzip.DefaultCompression
+	}
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(value); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress returns decompressed gzip value.
+func (g GzipCompressor) Decompress(value []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(value))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
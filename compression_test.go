@@ -0,0 +1,85 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestCompression(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		encryption *Encryption
+	}{
+		{name: "plain"},
+		{name: "encrypted", encryption: &Encryption{Key: bytes.Repeat([]byte{1}, 16)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pool := New(&Options{
+				Compression: &Compression{MinSize: 64},
+				Encryption:  tc.encryption,
+			})
+			defer pool.Close()
+
+			c, err := pool.Get(filepath.Join(t.TempDir(), "test.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			bucket := []byte("bucket")
+			values := map[string][]byte{
+				"large": bytes.Repeat([]byte(`{"text": "compressible"}`), 100),
+				"small": []byte("small"),
+				"empty": {},
+			}
+			for k, v := range values {
+				if err := c.PutValue(bucket, []byte(k), v); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for k, v := range values {
+				got, err := c.GetValue(bucket, []byte(k))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, v) {
+					t.Errorf("%s: got value %q, expected %q", k, got, v)
+				}
+			}
+			if err := c.View(func(tx *bolt.Tx) error {
+				if l := len(tx.Bucket(bucket).Get([]byte("large"))); l >= len(values["large"]) {
+					t.Errorf("stored value length %d is not compressed", l)
+				}
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestGzipCompressorZeroValue(t *testing.T) {
+	value := bytes.Repeat([]byte("compressible text "), 100)
+	v, err := GzipCompressor{}.Compress(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v) >= len(value)/2 {
+		t.Errorf("got compressed length %d for value of length %d", len(v), len(value))
+	}
+	d, err := GzipCompressor{}.Decompress(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(d, value) {
+		t.Error("decompressed value differs")
+	}
+}
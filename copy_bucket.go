@@ -0,0 +1,351 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrSameDatabase is returned by Pool.CopyBucket when the source and the
+// destination are the same database.
+var ErrSameDatabase = errors.New("boltdbpool: same source and destination database")
+
+// copyTxMaxSize is the approximate size of keys and values written in a
+// single transaction by Pool.CopyBucket.
+var copyTxMaxSize = compactTxMaxSize
+
+// CopyProgress describes the state of a Pool CopyBucket, MoveBucket or
+// MergeInto call for one source database. It is passed to
+// Options.OnCopyProgress after every committed transaction.
+type CopyProgress struct {
+	SrcPath string
+	DstPath string
+	// Bucket is the path of the copied bucket, or nil if all buckets are
+	// copied by MergeInto.
+	Bucket []byte
+	// Keys is the number of copied keys, including keys of nested buckets.
+	Keys int64
+	// Bytes is the size of copied keys and values.
+	Bytes int64
+	// Done is true when all keys are copied.
+	Done bool
+}
+
+// CopyBucket copies all keys and nested buckets of the bucket from the
+// database on srcPath to the database on dstPath, creating the bucket in
+// the destination if it does not exist. Values of keys that exist in the
+// destination are replaced, or resolved with Options.ConflictResolver if it
+// is set. Bucket paths have the same format as Options.EnsureBuckets. The
+// source is read within a single read transaction, and the destination is
+// written in multiple transactions, so that large buckets do not require a
+// single large write. The copy is not atomic and a partial copy remains in
+// the destination if an error is returned. Options.OnCopyProgress is
+// called after every transaction. The database on srcPath must exist and
+// the database on dstPath is created if needed.
+func (p *Pool) CopyBucket(srcPath, dstPath string, bucketPath []byte) (err error) {
+	if p.options.ReadOnly {
+		return ErrReadOnly
+	}
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("boltdbpool: copy bucket %s from %s to %s: %w", bucketPath, srcPath, dstPath, err)
+		}
+	}()
+
+	src, dst, err := p.getCopyDatabases(srcPath, dstPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	defer dst.Close()
+
+	return p.copyBuckets(src, dst, bucketPath)
+}
+
+// MoveBucket copies the bucket from the database on srcPath to the database
+// on dstPath as CopyBucket does and deletes it from the source database when
+// all its keys are copied. The bucket remains in the source database if an
+// error is returned.
+func (p *Pool) MoveBucket(srcPath, dstPath string, bucketPath []byte) (err error) {
+	if p.options.ReadOnly {
+		return ErrReadOnly
+	}
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("boltdbpool: move bucket %s from %s to %s: %w", bucketPath, srcPath, dstPath, err)
+		}
+	}()
+
+	src, dst, err := p.getCopyDatabases(srcPath, dstPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	defer dst.Close()
+
+	if err := p.copyBuckets(src, dst, bucketPath); err != nil {
+		return err
+	}
+	defer p.invalidateCache(src.path)
+
+	return src.Update(func(tx *bolt.Tx) error {
+		return deleteBucket(tx, bucketPath)
+	})
+}
+
+// getCopyDatabases returns connections to the existing source database and
+// to the destination database, which is created if needed.
+func (p *Pool) getCopyDatabases(srcPath, dstPath string) (src, dst *Connection, err error) {
+	src, err = p.getExisting(srcPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	dst, err = p.Get(dstPath)
+	if err != nil {
+		src.Close()
+		return nil, nil, err
+	}
+	if src == dst {
+		src.Close()
+		dst.Close()
+		return nil, nil, ErrSameDatabase
+	}
+	return src, dst, nil
+}
+
+// copyBuckets copies buckets on bucketPath from the source to the
+// destination database within a single read transaction. If bucketPath is
+// nil, all root buckets are copied.
+func (p *Pool) copyBuckets(src, dst *Connection, bucketPath []byte) error {
+	defer p.invalidateCache(dst.path)
+
+	return src.View(func(tx *bolt.Tx) error {
+		bc := &bucketCopier{
+			pool:     p,
+			dst:      dst,
+			resolver: p.options.ConflictResolver,
+			progress: CopyProgress{
+				SrcPath: src.path,
+				DstPath: dst.path,
+				Bucket:  bucketPath,
+			},
+		}
+		if bucketPath != nil {
+			b := bucket(tx, bucketPath)
+			if b == nil {
+				return bolt.ErrBucketNotFound
+			}
+			if err := bc.copyRoot(b, bucketPath, nil); err != nil {
+				return err
+			}
+		} else if err := tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			return bc.copyRoot(b, name, name)
+		}); err != nil {
+			return err
+		}
+		bc.progress.Done = true
+		return bc.flush()
+	})
+}
+
+// deleteBucket deletes the bucket on the slash-separated path.
+func deleteBucket(tx *bolt.Tx, path []byte) error {
+	i := bytes.LastIndex(path, bucketPathSeparator)
+	if i < 0 {
+		return tx.DeleteBucket(path)
+	}
+	parent := bucket(tx, path[:i])
+	if parent == nil {
+		return bolt.ErrBucketNotFound
+	}
+	return parent.DeleteBucket(path[i+len(bucketPathSeparator):])
+}
+
+// copiedBucket is a bucket written by bucketCopier, identified by its index
+// in bucketCopier.buckets. Copied buckets have no parent and others are
+// their nested buckets. Copied buckets are created by path, unless they are
+// root buckets with name set.
+type copiedBucket struct {
+	parent   int
+	name     []byte
+	path     []byte
+	sequence uint64
+}
+
+// copiedKey is a key that is written to the destination bucket on the
+// next bucketCopier flush. If value is nil, the key is a nested bucket with
+// the index child.
+type copiedKey struct {
+	bucket int
+	key    []byte
+	value  []byte
+	child  int
+}
+
+// bucketCopier buffers keys from the source bucket and writes them to the
+// destination database when their size reaches copyTxMaxSize. Buffered keys
+// and values reference the source read transaction memory, so they must be
+// flushed before it is closed.
+type bucketCopier struct {
+	pool     *Pool
+	dst      *Connection
+	resolver ConflictResolver
+	progress CopyProgress
+	buckets  []copiedBucket
+	pending  []copiedKey
+	size     int
+}
+
+// copyRoot buffers all keys of the source bucket that is copied to the
+// bucket on the same path in the destination database. If name is set, the
+// bucket is the root bucket with that name, which may contain the bucket
+// path separator.
+func (bc *bucketCopier) copyRoot(b *bolt.Bucket, path, name []byte) error {
+	i := len(bc.buckets)
+	bc.buckets = append(bc.buckets, copiedBucket{
+		parent:   -1,
+		name:     name,
+		path:     path,
+		sequence: b.Sequence(),
+	})
+	return bc.copy(b, i)
+}
+
+// copy buffers all keys of the source bucket that is written to the
+// destination bucket with the index i, flushing them when needed.
+func (bc *bucketCopier) copy(b *bolt.Bucket, i int) error {
+	return b.ForEach(func(k, v []byte) error {
+		child := -1
+		if v == nil {
+			nested := b.Bucket(k)
+			child = len(bc.buckets)
+			bc.buckets = append(bc.buckets, copiedBucket{
+				parent:   i,
+				name:     k,
+				path:     joinBucketPath(bc.buckets[i].path, k),
+				sequence: nested.Sequence(),
+			})
+			bc.pending = append(bc.pending, copiedKey{bucket: i, key: k, child: child})
+			bc.size += len(k)
+			return bc.copy(nested, child)
+		}
+		bc.pending = append(bc.pending, copiedKey{bucket: i, key: k, value: v, child: child})
+		bc.size += len(k) + len(v)
+		if bc.size < copyTxMaxSize {
+			return nil
+		}
+		return bc.flush()
+	})
+}
+
+// flush writes buffered keys to the destination database in a single
+// transaction and reports the progress.
+func (bc *bucketCopier) flush() error {
+	if err := bc.dst.Update(func(tx *bolt.Tx) error {
+		resolved := make(map[int]*bolt.Bucket)
+		for _, k := range bc.pending {
+			b, err := bc.bucket(tx, resolved, k.bucket)
+			if err != nil {
+				return err
+			}
+			if k.value == nil {
+				if _, err := bc.bucket(tx, resolved, k.child); err != nil {
+					return err
+				}
+				continue
+			}
+			value := k.value
+			if bc.resolver != nil {
+				if existing := b.Get(k.key); existing != nil {
+					v, err := bc.resolver(bc.buckets[k.bucket].path, k.key, existing, k.value)
+					if err != nil {
+						return err
+					}
+					if bytes.Equal(v, existing) {
+						continue
+					}
+					value = append([]byte(nil), v...)
+				}
+			}
+			if err := b.Put(k.key, value); err != nil {
+				return err
+			}
+		}
+		if bc.progress.Done {
+			// buckets without keys after the last flush still need their
+			// sequences
+			for i := range bc.buckets {
+				if _, err := bc.bucket(tx, resolved, i); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, k := range bc.pending {
+		if k.value != nil {
+			bc.progress.Keys++
+			bc.progress.Bytes += int64(len(k.key) + len(k.value))
+		}
+	}
+	bc.pending = bc.pending[:0]
+	bc.size = 0
+
+	if bc.pool.options.OnCopyProgress == nil {
+		return nil
+	}
+	return bc.pool.protect("OnCopyProgress", func() error {
+		bc.pool.options.OnCopyProgress(bc.progress)
+		return nil
+	})
+}
+
+// bucket returns the destination bucket with the index i, creating it and
+// its parents if they do not exist and setting its sequence to the one of
+// the source bucket.
+func (bc *bucketCopier) bucket(tx *bolt.Tx, resolved map[int]*bolt.Bucket, i int) (b *bolt.Bucket, err error) {
+	if b, ok := resolved[i]; ok {
+		return b, nil
+	}
+	cb := bc.buckets[i]
+	switch {
+	case cb.parent < 0 && cb.name != nil:
+		b, err = tx.CreateBucketIfNotExists(cb.name)
+	case cb.parent < 0:
+		b, err = createBucket(tx, cb.path)
+	default:
+		var parent *bolt.Bucket
+		if parent, err = bc.bucket(tx, resolved, cb.parent); err != nil {
+			return nil, err
+		}
+		b, err = parent.CreateBucketIfNotExists(cb.name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if b.Sequence() != cb.sequence {
+		if err := b.SetSequence(cb.sequence); err != nil {
+			return nil, err
+		}
+	}
+	resolved[i] = b
+	return b, nil
+}
+
+// joinBucketPath returns the path of the nested bucket with the name in the
+// bucket on path.
+func joinBucketPath(path, name []byte) []byte {
+	p := make([]byte, 0, len(path)+len(bucketPathSeparator)+len(name))
+	p = append(p, path...)
+	p = append(p, bucketPathSeparator...)
+	return append(p, name...)
+}
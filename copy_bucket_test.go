@@ -0,0 +1,236 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestCopyBucket(t *testing.T) {
+	defer func(s int) { copyTxMaxSize = s }(copyTxMaxSize)
+	copyTxMaxSize = 100
+
+	var progress []CopyProgress
+	pool := New(&Options{
+		OnCopyProgress: func(p CopyProgress) {
+			progress = append(progress, p)
+		},
+	})
+	defer pool.Close()
+
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "src.db")
+	dstPath := filepath.Join(dir, "dst.db")
+
+	src, err := pool.GetWithBuckets(srcPath, []byte("tenants/a/users"), []byte("tenants/a/empty"), []byte("tenants/b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	if err := src.Update(func(tx *bolt.Tx) error {
+		b := bucket(tx, []byte("tenants/a"))
+		if err := b.SetSequence(42); err != nil {
+			return err
+		}
+		if err := b.Put([]byte("name"), []byte("A")); err != nil {
+			return err
+		}
+		if err := b.Put([]byte("blank"), []byte{}); err != nil {
+			return err
+		}
+		users := b.Bucket([]byte("users"))
+		for i := 0; i < 20; i++ {
+			if err := users.Put([]byte(fmt.Sprintf("user%02d", i)), []byte("0123456789")); err != nil {
+				return err
+			}
+		}
+		return bucket(tx, []byte("tenants/b")).Put([]byte("name"), []byte("B"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := pool.GetWithBuckets(dstPath, []byte("tenants/a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	if err := dst.Update(func(tx *bolt.Tx) error {
+		return bucket(tx, []byte("tenants/a")).Put([]byte("name"), []byte("old"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pool.CopyBucket(srcPath, dstPath, []byte("tenants/a")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dst.View(func(tx *bolt.Tx) error {
+		b := bucket(tx, []byte("tenants/a"))
+		if b == nil {
+			t.Fatal("bucket not copied")
+		}
+		if s := b.Sequence(); s != 42 {
+			t.Errorf("got sequence %d, expected 42", s)
+		}
+		if v := string(b.Get([]byte("name"))); v != "A" {
+			t.Errorf("got name %q, expected A", v)
+		}
+		if v := b.Get([]byte("blank")); v == nil || len(v) != 0 {
+			t.Errorf("got blank value %q", v)
+		}
+		if b.Bucket([]byte("empty")) == nil {
+			t.Error("empty nested bucket not copied")
+		}
+		if n := b.Bucket([]byte("users")).Stats().KeyN; n != 20 {
+			t.Errorf("got %d users, expected 20", n)
+		}
+		if bucket(tx, []byte("tenants/b")) != nil {
+			t.Error("sibling bucket copied")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(progress) < 2 {
+		t.Fatalf("got %d progress calls, expected multiple transactions", len(progress))
+	}
+	last := progress[len(progress)-1]
+	if !last.Done {
+		t.Error("last progress is not done")
+	}
+	if last.Keys != 22 {
+		t.Errorf("got %d keys, expected 22", last.Keys)
+	}
+	for i, p := range progress[:len(progress)-1] {
+		if p.Done {
+			t.Errorf("progress %d is done", i)
+		}
+		if p.Keys > progress[i+1].Keys {
+			t.Errorf("progress %d keys decreased", i)
+		}
+	}
+}
+
+func TestCopyBucketErrors(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "src.db")
+	dstPath := filepath.Join(dir, "dst.db")
+
+	if err := pool.CopyBucket(srcPath, dstPath, []byte("a")); err == nil {
+		t.Error("copied from missing database")
+	}
+
+	c, err := pool.Get(srcPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := pool.CopyBucket(srcPath, dstPath, []byte("a")); !errors.Is(err, bolt.ErrBucketNotFound) {
+		t.Errorf("got error %v, expected %v", err, bolt.ErrBucketNotFound)
+	}
+	if err := pool.CopyBucket(srcPath, srcPath, []byte("a")); !errors.Is(err, ErrSameDatabase) {
+		t.Errorf("got error %v, expected %v", err, ErrSameDatabase)
+	}
+
+	ro := New(&Options{ReadOnly: true})
+	defer ro.Close()
+	if err := ro.CopyBucket(srcPath, dstPath, []byte("a")); err != ErrReadOnly {
+		t.Errorf("got error %v, expected %v", err, ErrReadOnly)
+	}
+}
+
+func TestMoveBucket(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "src.db")
+	dstPath := filepath.Join(dir, "dst.db")
+
+	src, err := pool.GetWithBuckets(srcPath, []byte("a/b"), []byte("a/c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	if err := src.Update(func(tx *bolt.Tx) error {
+		return bucket(tx, []byte("a/b")).Put([]byte("k"), []byte("v"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pool.MoveBucket(srcPath, dstPath, []byte("a/b")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := src.View(func(tx *bolt.Tx) error {
+		if bucket(tx, []byte("a/b")) != nil {
+			t.Error("moved bucket exists in source")
+		}
+		if bucket(tx, []byte("a/c")) == nil {
+			t.Error("sibling bucket removed from source")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.With(dstPath, func(db *bolt.DB) error {
+		return db.View(func(tx *bolt.Tx) error {
+			if v := string(bucket(tx, []byte("a/b")).Get([]byte("k"))); v != "v" {
+				t.Errorf("got value %q, expected v", v)
+			}
+			return nil
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pool.MoveBucket(srcPath, dstPath, []byte("a/b")); !errors.Is(err, bolt.ErrBucketNotFound) {
+		t.Errorf("got error %v, expected %v", err, bolt.ErrBucketNotFound)
+	}
+}
+
+func TestMoveBucketCache(t *testing.T) {
+	pool := New(&Options{
+		CacheSize: 1 << 20,
+	})
+	defer pool.Close()
+
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "src.db")
+	dstPath := filepath.Join(dir, "dst.db")
+
+	src, err := pool.Get(srcPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	bucket, key := []byte("bucket"), []byte("key")
+	if err := src.PutValue(bucket, key, []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	assertValue(t, src, bucket, key, "v")
+
+	if err := pool.MoveBucket(srcPath, dstPath, bucket); err != nil {
+		t.Fatal(err)
+	}
+	v, err := src.GetValue(bucket, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != nil {
+		t.Errorf("got moved value %q from source", v)
+	}
+}
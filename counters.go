@@ -0,0 +1,137 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Increment adds delta to the counter stored under the key in the bucket of
+// the database on path and returns the new value. Counters that do not
+// exist start from 0. Values are stored as 8 byte big-endian integers,
+// encoded according to the pool options, and updates are batched with
+// other concurrent increments.
+func (p *Pool) Increment(path, bucket, key string, delta int64) (value int64, err error) {
+	c, err := p.Get(path)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+
+	return c.Increment([]byte(bucket), []byte(key), delta)
+}
+
+// Increment adds delta to the counter stored under the key in the bucket
+// and returns the new value.
+func (c *Connection) Increment(bucket, key []byte, delta int64) (value int64, err error) {
+	defer c.invalidateValue(bucket, key)
+
+	err = c.Batch(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(bucket)
+		if err != nil {
+			return err
+		}
+		value = delta
+		if v := b.Get(key); v != nil {
+			v, err := c.pool.DecodeValue(bucket, key, v)
+			if err != nil {
+				return err
+			}
+			if len(v) != 8 {
+				return fmt.Errorf("boltdbpool: invalid counter length %d", len(v))
+			}
+			value += int64(binary.BigEndian.Uint64(v))
+		}
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, uint64(value))
+		v, err = c.pool.EncodeValue(bucket, key, v)
+		if err != nil {
+			return err
+		}
+		if err := b.Put(key, v); err != nil {
+			return err
+		}
+		return c.pool.logChange(tx, ChangePut, bucket, key, v)
+	})
+	if err == nil {
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, uint64(value))
+		c.notifyChange(nil, ChangePut, bucket, key, v)
+	}
+	return value, err
+}
+
+// Sequence allocates unique increasing integers from the bucket sequence
+// of a pooled database. Ranges of integers are reserved in a single
+// transaction, so that most calls to Next do not write to the database.
+// Integers that are reserved but not allocated before the program exits
+// are never allocated.
+type Sequence struct {
+	pool   *Pool
+	path   string
+	bucket []byte
+	size   uint64
+
+	next uint64
+	end  uint64
+	mu   sync.Mutex
+}
+
+// NewSequence returns a Sequence for the bucket in the database on path
+// that reserves size integers at once. If size is 0, one integer is
+// reserved on every call.
+func (p *Pool) NewSequence(path, bucket string, size uint64) *Sequence {
+	if size == 0 {
+		size = 1
+	}
+	return &Sequence{
+		pool:   p,
+		path:   path,
+		bucket: []byte(bucket),
+		size:   size,
+	}
+}
+
+// Next returns the next integer from the sequence.
+func (s *Sequence) Next() (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.next == s.end {
+		if err := s.reserve(); err != nil {
+			return 0, err
+		}
+	}
+	s.next++
+	return s.next, nil
+}
+
+// reserve reserves the next range of integers by advancing the bucket
+// sequence. Sequence lock must be held.
+func (s *Sequence) reserve() error {
+	c, err := s.pool.Get(s.path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(s.bucket)
+		if err != nil {
+			return err
+		}
+		seq := b.Sequence()
+		if err := b.SetSequence(seq + s.size); err != nil {
+			return err
+		}
+		s.next, s.end = seq, seq+s.size
+		return nil
+	})
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestIncrement(t *testing.T) {
+	pool := New(&Options{
+		Compression: &Compression{},
+		CacheSize:   1 << 20,
+	})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "counters.db")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := pool.Increment(path, "hits", "home", 2); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	v, err := pool.Increment(path, "hits", "home", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 99 {
+		t.Errorf("got value %d, expected 99", v)
+	}
+	if v, err := pool.Increment(path, "hits", "other", -5); err != nil || v != -5 {
+		t.Errorf("got value %d (%v), expected -5", v, err)
+	}
+}
+
+func TestSequence(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "ids.db")
+	s := pool.NewSequence(path, "ids", 10)
+
+	seen := map[uint64]bool{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < 25; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := s.Next()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if seen[id] {
+				t.Errorf("duplicate id %d", id)
+			}
+			seen[id] = true
+		}()
+	}
+	wg.Wait()
+	for id := uint64(1); id <= 25; id++ {
+		if !seen[id] {
+			t.Errorf("id %d not allocated", id)
+		}
+	}
+
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.View(func(tx *bolt.Tx) error {
+		if seq := tx.Bucket([]byte("ids")).Sequence(); seq != 30 {
+			t.Errorf("got bucket sequence %d, expected 30", seq)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new sequence continues after the reserved range
+	id, err := pool.NewSequence(path, "ids", 0).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 31 {
+		t.Errorf("got id %d, expected 31", id)
+	}
+}
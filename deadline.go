@@ -0,0 +1,162 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// OpenTimeoutError is returned by Pool.Get when creating directories and
+// opening the database do not finish within Options.OpenDeadline.
+type OpenTimeoutError struct {
+	Path     string
+	Deadline time.Duration
+	// LockHolder is the ID of the process that holds the lock of the
+	// database file, or 0 if the lock is not held or its holder can not be
+	// determined.
+	LockHolder int
+}
+
+func (e *OpenTimeoutError) Error() string {
+	if e.LockHolder != 0 {
+		return fmt.Sprintf("boltdbpool: open %s: deadline %v exceeded: locked by process %d", e.Path, e.Deadline, e.LockHolder)
+	}
+	return fmt.Sprintf("boltdbpool: open %s: deadline %v exceeded", e.Path, e.Deadline)
+}
+
+// Timeout returns true, so that the error is recognized as a timeout.
+func (e *OpenTimeoutError) Timeout() bool {
+	return true
+}
+
+// Unwrap returns os.ErrDeadlineExceeded.
+func (e *OpenTimeoutError) Unwrap() error {
+	return os.ErrDeadlineExceeded
+}
+
+// openDeadline returns the time until which a database has to be opened,
+// or zero time if Options.OpenDeadline is not set.
+func (p *Pool) openDeadline() time.Time {
+	if p.options.OpenDeadline <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(p.options.OpenDeadline)
+}
+
+// mkdirAllUntil creates the directory as mkdirAll does and returns
+// OpenTimeoutError if it is not created until the deadline. Directories
+// created after the deadline are not removed by the pool. Pool lock must
+// be held.
+func (p *Pool) mkdirAllUntil(dir, path string, deadline time.Time) error {
+	var (
+		created []string
+		err     error
+	)
+	if !runUntil(deadline, func() {
+		created, err = makeDirs(p.fs, dir)
+	}, nil) {
+		return &OpenTimeoutError{Path: path, Deadline: p.options.OpenDeadline}
+	}
+	p.addCreatedDirs(created)
+	if err != nil {
+		return err
+	}
+	return p.applyDirPermissions(created)
+}
+
+// boltOpen opens the database on path, read-only if it is locked by
+// another process and Options.FallbackReadOnly is set. OpenTimeoutError is
+// returned if the database is not opened until the deadline, and the
+// database that is opened after it is closed.
+func (p *Pool) boltOpen(path string, mode os.FileMode, options *bolt.Options, deadline time.Time) (*bolt.DB, error) {
+	var (
+		db  *bolt.DB
+		err error
+	)
+	if !runUntil(deadline, func() {
+		db, err = bolt.Open(path, mode, options)
+		if err == bolt.ErrTimeout && p.options.FallbackReadOnly && !options.ReadOnly {
+			o := *options
+			o.ReadOnly = true
+			db, err = bolt.Open(path, mode, &o)
+		}
+	}, func(r interface{}) {
+		if r != nil {
+			p.handleError(fmt.Errorf("boltdbpool: open %s after deadline: %v", path, r))
+			return
+		}
+		if db != nil {
+			p.handleError(db.Close())
+		}
+	}) {
+		return nil, &OpenTimeoutError{
+			Path:       path,
+			Deadline:   p.options.OpenDeadline,
+			LockHolder: lockHolder(p.fs, path),
+		}
+	}
+	return db, err
+}
+
+// runUntil calls fn and returns false if it does not return until the
+// deadline. In that case fn keeps running in the background and abandon,
+// if set, is called after it returns, with the recovered panic value, if
+// any. Panics from fn are propagated to the caller if it returns in time.
+// Zero deadline waits for fn to return.
+func runUntil(deadline time.Time, fn func(), abandon func(r interface{})) bool {
+	if deadline.IsZero() {
+		fn()
+		return true
+	}
+	var (
+		finished  bool
+		abandoned bool
+		panicked  interface{}
+		mu        sync.Mutex
+	)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		func() {
+			defer func() {
+				panicked = recover()
+			}()
+			fn()
+		}()
+		mu.Lock()
+		finished = true
+		a := abandoned
+		mu.Unlock()
+		if a && abandon != nil {
+			abandon(panicked)
+		}
+	}()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
+		mu.Lock()
+		if !finished {
+			abandoned = true
+			mu.Unlock()
+			return false
+		}
+		mu.Unlock()
+	}
+	if panicked != nil {
+		panic(panicked)
+	}
+	return true
+}
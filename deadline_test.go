@@ -0,0 +1,120 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestOpenDeadlineLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	// another open database holds the exclusive lock
+	db, err := bolt.Open(path, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := New(&Options{
+		OpenDeadline: 100 * time.Millisecond,
+	})
+	defer pool.Close()
+
+	_, err = pool.Get(path)
+	var terr *OpenTimeoutError
+	if !errors.As(err, &terr) {
+		db.Close()
+		t.Fatalf("got error %v, expected OpenTimeoutError", err)
+	}
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("error %v is not %v", err, os.ErrDeadlineExceeded)
+	}
+	if terr.Path != path {
+		t.Errorf("got path %q, expected %q", terr.Path, path)
+	}
+	if runtime.GOOS == "linux" && terr.LockHolder != os.Getpid() {
+		t.Errorf("got lock holder %d, expected %d", terr.LockHolder, os.Getpid())
+	}
+	if pool.Has(path) {
+		t.Error("database is open after the deadline")
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the database opened after the deadline is closed in the background
+	timeout := time.After(5 * time.Second)
+	for {
+		c, err := pool.Get(path)
+		if err == nil {
+			c.Close()
+			break
+		}
+		if !errors.As(err, &terr) {
+			t.Fatal(err)
+		}
+		select {
+		case <-timeout:
+			t.Fatal("database not opened")
+		default:
+		}
+	}
+}
+
+// blockingMkdirFS blocks directory creation until unblock is closed.
+type blockingMkdirFS struct {
+	OSFS
+	unblock chan struct{}
+}
+
+func (fs blockingMkdirFS) Mkdir(name string, perm os.FileMode) error {
+	<-fs.unblock
+	return fs.OSFS.Mkdir(name, perm)
+}
+
+func TestOpenDeadlineMkdir(t *testing.T) {
+	fs := blockingMkdirFS{unblock: make(chan struct{})}
+	pool := New(&Options{
+		FS:           fs,
+		OpenDeadline: 50 * time.Millisecond,
+	})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "dir", "test.db")
+	_, err := pool.Get(path)
+	var terr *OpenTimeoutError
+	if !errors.As(err, &terr) {
+		t.Fatalf("got error %v, expected OpenTimeoutError", err)
+	}
+	if terr.LockHolder != 0 {
+		t.Errorf("got lock holder %d, expected 0", terr.LockHolder)
+	}
+	close(fs.unblock)
+
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+}
+
+func TestRunUntilPanic(t *testing.T) {
+	defer func() {
+		if r := recover(); r != "test" {
+			t.Errorf("got panic %v, expected test", r)
+		}
+	}()
+	runUntil(time.Now().Add(time.Minute), func() {
+		panic("test")
+	}, nil)
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DebugInfo holds the state of the pool rendered by the handler from
+// Pool.DebugHandler.
+type DebugInfo struct {
+	Time        time.Time       `json:"time"`
+	Healthy     bool            `json:"healthy"`
+	MemoryUsage int64           `json:"memoryUsage"`
+	Databases   []DatabaseStats `json:"databases"`
+	Parked      []string        `json:"parked,omitempty"`
+	Known       []string        `json:"known,omitempty"`
+	Errors      []ErrorRecord   `json:"errors"`
+}
+
+// DebugInfo returns the current state of the pool for inspection.
+func (p *Pool) DebugInfo() DebugInfo {
+	s := p.Stats()
+	var size int64
+	for _, d := range s.Databases {
+		size += d.Size
+	}
+	return DebugInfo{
+		Time:        p.now(),
+		Healthy:     s.Healthy,
+		MemoryUsage: size,
+		Databases:   s.Databases,
+		Parked:      p.Parked(),
+		Known:       s.Known,
+		Errors:      p.RecentErrors(),
+	}
+}
+
+// DebugHandler returns an HTTP handler that renders open databases, their
+// references, expiration times, sizes and labels, and recent errors as an
+// HTML page for quick inspection, similar to /debug/pprof. The state is
+// encoded as JSON if the format query parameter is "json" or the request
+// accepts only JSON. It is meant to be mounted under an internal route, like
+// /debug/boltdbpool. The handler does not modify the pool and it responds
+// only to GET and HEAD requests. If Options.AdminAuthorize is set, requests
+// that are not authorized get the Unauthorized response.
+func (p *Pool) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a := p.options.AdminAuthorize; a != nil && !a(r) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		info := p.DebugInfo()
+		w.Header().Set("Cache-Control", "no-store")
+		if r.URL.Query().Get("format") == "json" || r.Header.Get("Accept") == "application/json" {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_ = json.NewEncoder(w).Encode(info)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if err := debugTemplate.Execute(w, info); err != nil {
+			p.handleError(err)
+		}
+	})
+}
+
+var debugTemplate = template.Must(template.New("").Funcs(template.FuncMap{
+	"labels": func(l Labels) string {
+		pairs := make([]string, 0, len(l))
+		for k, v := range l {
+			pairs = append(pairs, k+"="+v)
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ", ")
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>boltdbpool</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 2px 6px; text-align: left; }
+td.n { text-align: right; }
+</style>
+</head>
+<body>
+<h1>boltdbpool</h1>
+<p>{{.Time.Format "2006-01-02T15:04:05.000Z07:00"}} &middot; {{if .Healthy}}healthy{{else}}not healthy{{end}} &middot; {{len .Databases}} open databases, {{.MemoryUsage}} bytes &middot; <a href="?format=json">json</a></p>
+<h2>Open databases</h2>
+<table>
+<tr><th>Path</th><th>References</th><th>Expires</th><th>Size</th><th>Read-only</th><th>Gets</th><th>Last used</th><th>Labels</th></tr>
+{{range .Databases}}<tr><td>{{.Path}}</td><td class="n">{{.References}}</td><td>{{with .Expires}}{{.Format "2006-01-02T15:04:05Z07:00"}}{{end}}</td><td class="n">{{.Size}}</td><td>{{if .ReadOnly}}yes{{end}}</td><td class="n">{{.Usage.Gets}}</td><td>{{if not .Usage.LastUsed.IsZero}}{{.Usage.LastUsed.Format "2006-01-02T15:04:05Z07:00"}}{{end}}</td><td>{{labels .Labels}}</td></tr>
+{{end}}</table>
+{{with .Parked}}<h2>Parked databases</h2>
+<ul>
+{{range .}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}{{with .Known}}<h2>Known databases</h2>
+<ul>
+{{range .}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}<h2>Recent errors</h2>
+{{with .Errors}}<table>
+<tr><th>Time</th><th>Error</th></tr>
+{{range .}}<tr><td>{{.Time.Format "2006-01-02T15:04:05.000Z07:00"}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>
+{{else}}<p>none</p>
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,94 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebugHandler(t *testing.T) {
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+		ErrorHandler:      func(error) {},
+	})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "<test>.db")
+	c, err := pool.GetWithLabels(path, Labels{"tenant": "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	pool.handleError(errors.New("test error"))
+
+	h := pool.DebugHandler()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/boltdbpool", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, expected %d", w.Code, http.StatusOK)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("got content type %q", ct)
+	}
+	body := w.Body.String()
+	for _, s := range []string{
+		strings.ReplaceAll(strings.ReplaceAll(path, "<", "&lt;"), ">", "&gt;"),
+		"tenant=a",
+		"test error",
+	} {
+		if !strings.Contains(body, s) {
+			t.Errorf("page does not contain %q", s)
+		}
+	}
+	if strings.Contains(body, "<test>") {
+		t.Error("path is not escaped")
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/boltdbpool?format=json", nil))
+	var info DebugInfo
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Databases) != 1 || info.Databases[0].Path != path || info.Databases[0].References != 1 {
+		t.Errorf("got databases %+v", info.Databases)
+	}
+	if len(info.Errors) != 1 || info.Errors[0].Error != "test error" {
+		t.Errorf("got errors %+v", info.Errors)
+	}
+	if info.MemoryUsage != pool.MemoryUsage() {
+		t.Errorf("got memory usage %d, expected %d", info.MemoryUsage, pool.MemoryUsage())
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/debug/boltdbpool", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("got status %d, expected %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestDebugHandlerAuthorize(t *testing.T) {
+	pool := New(&Options{
+		AdminAuthorize: func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == "secret"
+		},
+	})
+	defer pool.Close()
+
+	w := httptest.NewRecorder()
+	pool.DebugHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("got status %d, expected %d", w.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrLowDiskSpace is returned when the free space on the filesystem is
+// below Options.MinFreeSpace.
+var ErrLowDiskSpace = errors.New("boltdbpool: low disk space")
+
+// diskSpaceCacheTTL is the duration for which the free space of a
+// directory is cached.
+const diskSpaceCacheTTL = time.Second
+
+// diskSpace caches free space checks for directories.
+type diskSpace struct {
+	checks map[string]diskSpaceCheck
+	mu     sync.Mutex
+}
+
+type diskSpaceCheck struct {
+	time time.Time
+	err  error
+}
+
+// checkDiskSpace returns ErrLowDiskSpace if the free space on the
+// filesystem of the directory is below Options.MinFreeSpace.
+func (p *Pool) checkDiskSpace(dir string) error {
+	min := p.options.MinFreeSpace
+	if min == 0 {
+		return nil
+	}
+
+	p.diskSpace.mu.Lock()
+	defer p.diskSpace.mu.Unlock()
+
+	now := time.Now()
+	if c, ok := p.diskSpace.checks[dir]; ok && now.Sub(c.time) < diskSpaceCacheTTL {
+		return c.err
+	}
+	free, err := freeSpace(dir)
+	if err == nil && free < min {
+		err = fmt.Errorf("%w: %d bytes available in %s", ErrLowDiskSpace, free, dir)
+	}
+	if p.diskSpace.checks == nil {
+		p.diskSpace.checks = map[string]diskSpaceCheck{}
+	}
+	p.diskSpace.checks[dir] = diskSpaceCheck{time: now, err: err}
+	return err
+}
+
+// checkWritable returns ErrReadOnly if the pool or the connection database
+// is read-only, or ErrLowDiskSpace if the free space for the connection
+// database is low and Options.LowDiskSpaceReadOnly is set.
+func (c *Connection) checkWritable() error {
+	if c.pool.options.ReadOnly || c.DB.IsReadOnly() {
+		return ErrReadOnly
+	}
+	if !c.pool.options.LowDiskSpaceReadOnly {
+		return nil
+	}
+	if err := c.pool.checkDiskSpace(filepath.Dir(c.path)); err != nil {
+		c.pool.handleError(err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package boltdbpool
+
+import "math"
+
+// freeSpace reports unlimited space on platforms where it can not be
+// determined.
+func freeSpace(dir string) (uint64, error) {
+	return math.MaxUint64, nil
+}
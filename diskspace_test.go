@@ -0,0 +1,59 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestMinFreeSpace(t *testing.T) {
+	dir := t.TempDir()
+
+	pool := New(nil)
+	c, err := pool.Get(filepath.Join(dir, "existing.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	pool.Close()
+
+	if free, _ := freeSpace(dir); free == math.MaxUint64 {
+		t.Skip("free space is not supported")
+	}
+
+	var handled error
+	pool = New(&Options{
+		MinFreeSpace:         math.MaxUint64,
+		LowDiskSpaceReadOnly: true,
+		ErrorHandler: func(err error) {
+			handled = err
+		},
+	})
+	defer pool.Close()
+
+	if _, err := pool.Get(filepath.Join(dir, "new.db")); !errors.Is(err, ErrLowDiskSpace) {
+		t.Errorf("got error %v, expected %v", err, ErrLowDiskSpace)
+	}
+	if !errors.Is(handled, ErrLowDiskSpace) {
+		t.Errorf("got handled error %v, expected %v", handled, ErrLowDiskSpace)
+	}
+
+	c, err = pool.Get(filepath.Join(dir, "existing.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.PutValue([]byte("bucket"), []byte("key"), []byte("value")); !errors.Is(err, ErrLowDiskSpace) {
+		t.Errorf("got error %v, expected %v", err, ErrLowDiskSpace)
+	}
+	if _, err := c.GetValue([]byte("bucket"), []byte("key")); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"sync"
+)
+
+// ErrDecrypt is returned when a stored value can not be decrypted.
+var ErrDecrypt = errors.New("boltdbpool: value decryption failed")
+
+// Encryption configures encryption at rest of values stored with pool value
+// helpers. Every value is sealed with a new random nonce which is stored
+// in front of the ciphertext. Bucket name and key are authenticated as
+// additional data, so that values can not be moved between keys.
+type Encryption struct {
+	// Key is used to create an AES-GCM AEAD if AEAD is not set. It must be
+	// 16, 24 or 32 bytes long.
+	Key []byte
+	// AEAD is the authenticated encryption used to seal values.
+	AEAD cipher.AEAD
+
+	once sync.Once
+	aead cipher.AEAD
+	err  error
+}
+
+func (e *Encryption) cipher() (cipher.AEAD, error) {
+	e.once.Do(func() {
+		if e.AEAD != nil {
+			e.aead = e.AEAD
+			return
+		}
+		block, err := aes.NewCipher(e.Key)
+		if err != nil {
+			e.err = err
+			return
+		}
+		e.aead, e.err = cipher.NewGCM(block)
+	})
+	return e.aead, e.err
+}
+
+func (e *Encryption) seal(bucket, key, value []byte) ([]byte, error) {
+	aead, err := e.cipher()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, aead.NonceSize(), aead.NonceSize()+len(value)+aead.Overhead())
+	if _, err := rand.Read(out); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, out, value, additionalData(bucket, key)), nil
+}
+
+func (e *Encryption) open(bucket, key, value []byte) ([]byte, error) {
+	aead, err := e.cipher()
+	if err != nil {
+		return nil, err
+	}
+	n := aead.NonceSize()
+	if len(value) < n {
+		return nil, ErrDecrypt
+	}
+	v, err := aead.Open(nil, value[:n], value[n:], additionalData(bucket, key))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return v, nil
+}
+
+// additionalData returns the length-prefixed bucket name followed by the
+// key.
+func additionalData(bucket, key []byte) []byte {
+	ad := make([]byte, 0, 4+len(bucket)+len(key))
+	l := len(bucket)
+	ad = append(ad, byte(l>>24), byte(l>>16), byte(l>>8), byte(l))
+	ad = append(ad, bucket...)
+	return append(ad, key...)
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestEncryption(t *testing.T) {
+	pool := New(&Options{
+		Encryption: &Encryption{
+			Key: bytes.Repeat([]byte{1}, 32),
+		},
+	})
+	defer pool.Close()
+
+	c, err := pool.Get(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	bucket := []byte("secrets")
+	value := []byte("plain value")
+	if err := c.PutValue(bucket, []byte("a"), value); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PutValue(bucket, []byte("b"), value); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.GetValue(bucket, []byte("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, value) {
+		t.Errorf("got value %q, expected %q", got, value)
+	}
+
+	if err := c.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+		a, bv := b.Get([]byte("a")), b.Get([]byte("b"))
+		if bytes.Contains(a, value) {
+			t.Error("value stored in plain text")
+		}
+		if bytes.Equal(a, bv) {
+			t.Error("same ciphertext for equal values")
+		}
+		// move the encrypted value to another key
+		return b.Put([]byte("b"), append([]byte(nil), a...))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetValue(bucket, []byte("b")); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("got error %v, expected %v", err, ErrDecrypt)
+	}
+
+	got, err = c.GetValue(bucket, []byte("missing"))
+	if err != nil || got != nil {
+		t.Errorf("got value %q and error %v for missing key", got, err)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+// Errors returns the channel that receives errors handled by the pool if
+// Options.ErrorsBufferSize is set, so that they can be consumed in a
+// separate goroutine instead of by the ErrorHandler. When the buffer is
+// full, the oldest error is dropped. The channel is closed when the pool is
+// closed and all its background work is done. Nil is returned if
+// Options.ErrorsBufferSize is not set.
+func (p *Pool) Errors() <-chan error {
+	return p.errors
+}
+
+// sendError sends the error to the channel returned by Errors, dropping the
+// oldest error if the channel buffer is full.
+func (p *Pool) sendError(err error) {
+	p.errorsMu.Lock()
+	defer p.errorsMu.Unlock()
+
+	if p.errors == nil || p.errorsClosed {
+		return
+	}
+	for {
+		select {
+		case p.errors <- err:
+			return
+		default:
+		}
+		select {
+		case <-p.errors:
+		default:
+		}
+	}
+}
+
+// closeErrors closes the channel returned by Errors.
+func (p *Pool) closeErrors() {
+	p.errorsMu.Lock()
+	defer p.errorsMu.Unlock()
+
+	if p.errors == nil || p.errorsClosed {
+		return
+	}
+	p.errorsClosed = true
+	close(p.errors)
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	pool := New(&Options{
+		ErrorsBufferSize: 3,
+	})
+
+	for i := 0; i < 5; i++ {
+		pool.handleError(fmt.Errorf("error %d", i))
+	}
+	pool.Close()
+	// errors handled after close are not sent
+	pool.handleError(errors.New("closed"))
+
+	var got []string
+	for err := range pool.Errors() {
+		got = append(got, err.Error())
+	}
+	want := []string{"error 2", "error 3", "error 4"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got errors %v, expected %v", got, want)
+	}
+	if l := len(pool.RecentErrors()); l != 6 {
+		t.Errorf("got %d recent errors, expected 6", l)
+	}
+}
+
+func TestErrorsDisabled(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	if pool.Errors() != nil {
+		t.Error("errors channel is created without ErrorsBufferSize")
+	}
+}
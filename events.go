@@ -0,0 +1,162 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// EventType is the type of a connection lifecycle event.
+type EventType uint8
+
+// Types of connection lifecycle events.
+const (
+	// EventOpened is emitted when a database is opened by the pool.
+	EventOpened EventType = iota + 1
+	// EventReused is emitted when Get returns a connection of a database
+	// that is already open.
+	EventReused
+	// EventExpiryScheduled is emitted when a connection is released and its
+	// database is scheduled to be closed after Options.ConnectionExpires.
+	EventExpiryScheduled
+	// EventExpired is emitted when a database is removed from the pool
+	// because its connection expired.
+	EventExpired
+	// EventEvicted is emitted when an idle database is removed from the
+	// pool before its connection expired.
+	EventEvicted
+	// EventClosed is emitted when a database is closed.
+	EventClosed
+	// EventCompactionStarted is emitted when a database compaction starts.
+	EventCompactionStarted
+	// EventCompactionFinished is emitted when a database compaction ends,
+	// with the compaction error, if any.
+	EventCompactionFinished
+	// EventParked is emitted when a database whose connection expired is
+	// parked, as configured by Options.MaxParked.
+	EventParked
+	// EventRevived is emitted instead of EventOpened when a parked
+	// database is opened.
+	EventRevived
+)
+
+// String returns the lowercase name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventOpened:
+		return "opened"
+	case EventReused:
+		return "reused"
+	case EventExpiryScheduled:
+		return "expiry scheduled"
+	case EventExpired:
+		return "expired"
+	case EventEvicted:
+		return "evicted"
+	case EventClosed:
+		return "closed"
+	case EventCompactionStarted:
+		return "compaction started"
+	case EventCompactionFinished:
+		return "compaction finished"
+	case EventParked:
+		return "parked"
+	case EventRevived:
+		return "revived"
+	}
+	return fmt.Sprintf("unknown event type %d", t)
+}
+
+// Event is a connection lifecycle event.
+type Event struct {
+	Type EventType
+	Path string
+	// Labels are attached to the connection of the database by
+	// Pool.GetWithLabels. They are not set for compaction events.
+	Labels Labels
+	// Time is when the event occurred, as reported by Options.Now.
+	Time time.Time
+	// Err is the error of a failed operation.
+	Err error
+}
+
+// events is a bounded channel of events that drops the oldest event when
+// it is full.
+type events struct {
+	ch     chan Event
+	closed bool
+	mu     sync.Mutex
+}
+
+// Events returns the channel that receives connection lifecycle events if
+// Options.EventsBufferSize is set. When the buffer is full, the oldest event
+// is dropped. The channel is closed when the pool is closed and all its
+// background work is done. Nil is returned if Options.EventsBufferSize is
+// not set.
+func (p *Pool) Events() <-chan Event {
+	if p.events == nil {
+		return nil
+	}
+	return p.events.ch
+}
+
+// emit sends the event to the channel returned by Events.
+func (p *Pool) emit(t EventType, path string, labels Labels, err error) {
+	if p.events == nil {
+		return
+	}
+	e := Event{
+		Type:   t,
+		Path:   path,
+		Labels: labels,
+		Time:   p.now(),
+		Err:    err,
+	}
+
+	p.events.mu.Lock()
+	defer p.events.mu.Unlock()
+
+	if p.events.closed {
+		return
+	}
+	for {
+		select {
+		case p.events.ch <- e:
+			return
+		default:
+		}
+		select {
+		case <-p.events.ch:
+		default:
+		}
+	}
+}
+
+// emit sends the event for the connection database to the channel returned
+// by Events. Labels are copied only if events are enabled.
+func (c *Connection) emit(t EventType, err error) {
+	if c.pool.events == nil {
+		return
+	}
+	c.pool.emit(t, c.path, c.Labels(), err)
+}
+
+// closeEvents closes the channel returned by Events.
+func (p *Pool) closeEvents() {
+	if p.events == nil {
+		return
+	}
+	p.events.mu.Lock()
+	defer p.events.mu.Unlock()
+
+	if p.events.closed {
+		return
+	}
+	p.events.closed = true
+	close(p.events.ch)
+}
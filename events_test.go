@@ -0,0 +1,129 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	now := time.Date(2015, 1, 2, 3, 4, 5, 0, time.UTC)
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+		EventsBufferSize:  100,
+		Now:               func() time.Time { return now },
+	})
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2.Close()
+	c.Close()
+	if err := pool.Compact(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.CloseAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for e := range pool.Events() {
+		if e.Path != path {
+			t.Errorf("got event %s path %q, expected %q", e.Type, e.Path, path)
+		}
+		if !e.Time.Equal(now) {
+			t.Errorf("got event %s time %v, expected %v", e.Type, e.Time, now)
+		}
+		if e.Err != nil {
+			t.Errorf("got event %s error %v", e.Type, e.Err)
+		}
+		got = append(got, e.Type.String())
+	}
+	want := []string{
+		"opened",
+		"reused",
+		"expiry scheduled",
+		"closed",
+		"compaction started",
+		"compaction finished",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got events %q, expected %q", got, want)
+	}
+}
+
+func TestEventsExpired(t *testing.T) {
+	pool := New(&Options{
+		ConnectionExpires: time.Millisecond,
+		EventsBufferSize:  100,
+	})
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	timeout := time.After(5 * time.Second)
+	for pool.Has(path) {
+		select {
+		case <-timeout:
+			t.Fatal("database not closed")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	pool.Close()
+
+	var got []EventType
+	for e := range pool.Events() {
+		got = append(got, e.Type)
+	}
+	want := []EventType{EventOpened, EventExpiryScheduled, EventExpired, EventClosed}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got events %v, expected %v", got, want)
+	}
+}
+
+func TestEventsBufferFull(t *testing.T) {
+	pool := New(&Options{
+		EventsBufferSize: 2,
+	})
+
+	for i := 0; i < 5; i++ {
+		pool.emit(EventOpened, fmt.Sprintf("%d.db", i), nil, nil)
+	}
+	pool.Close()
+	// events emitted after close are not sent
+	pool.emit(EventOpened, "closed.db", nil, nil)
+
+	var got []string
+	for e := range pool.Events() {
+		got = append(got, e.Path)
+	}
+	want := []string{"3.db", "4.db"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got events %v, expected %v", got, want)
+	}
+}
+
+func TestEventsDisabled(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	if pool.Events() != nil {
+		t.Error("events channel is created without EventsBufferSize")
+	}
+}
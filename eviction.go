@@ -0,0 +1,66 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+// EvictionCandidate describes a database with no references that can be
+// closed before its connection expires.
+type EvictionCandidate struct {
+	Path string
+	// Size is the size of the database file.
+	Size   int64
+	Usage  Usage
+	Labels Labels
+}
+
+// EvictionPolicy decides the order in which databases with no references
+// are closed when the pool is over Options.MemoryBudget.
+type EvictionPolicy interface {
+	// Less returns true if the database a should be closed before the
+	// database b.
+	Less(a, b EvictionCandidate) bool
+}
+
+// EvictionPolicyFunc is an adapter to use a function as an
+// EvictionPolicy.
+type EvictionPolicyFunc func(a, b EvictionCandidate) bool
+
+// Less calls f(a, b).
+func (f EvictionPolicyFunc) Less(a, b EvictionCandidate) bool {
+	return f(a, b)
+}
+
+var (
+	// EvictLRU closes least recently used databases first. It is the
+	// default eviction policy.
+	EvictLRU EvictionPolicy = EvictionPolicyFunc(func(a, b EvictionCandidate) bool {
+		if !a.Usage.LastUsed.Equal(b.Usage.LastUsed) {
+			return a.Usage.LastUsed.Before(b.Usage.LastUsed)
+		}
+		return a.Path < b.Path
+	})
+	// EvictLFU closes least frequently used databases first, counting
+	// Get, View, Update and Batch calls since they are opened. Databases
+	// that are used equally are closed in the least recently used order.
+	EvictLFU EvictionPolicy = EvictionPolicyFunc(func(a, b EvictionCandidate) bool {
+		if fa, fb := useCount(a.Usage), useCount(b.Usage); fa != fb {
+			return fa < fb
+		}
+		return EvictLRU.Less(a, b)
+	})
+)
+
+// useCount returns the number of times the connection is used.
+func useCount(u Usage) int64 {
+	return u.Gets + u.Views + u.Updates
+}
+
+// evictionPolicy returns the configured eviction policy or EvictLRU.
+func (p *Pool) evictionPolicy() EvictionPolicy {
+	if p.options.EvictionPolicy != nil {
+		return p.options.EvictionPolicy
+	}
+	return EvictLRU
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testEviction opens databases a and b with labels, in that order, the
+// number of times in gets, and returns the database that is evicted when
+// the database c is opened over a budget for two databases.
+func testEviction(t *testing.T, policy EvictionPolicy, gets map[string]int, labels map[string]Labels) (evicted []string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	size := emptyDatabaseSize(t)
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+		MemoryBudget:      2*size + size/2,
+		EvictionPolicy:    policy,
+	})
+	defer pool.Close()
+
+	for _, name := range []string{"a", "b"} {
+		path := filepath.Join(dir, name+".db")
+		for i := 0; i < gets[name]; i++ {
+			c, err := pool.GetWithLabels(path, labels[name])
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.Close()
+		}
+	}
+	c, err := pool.Get(filepath.Join(dir, "c.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for _, name := range []string{"a", "b"} {
+		if !pool.Has(filepath.Join(dir, name+".db")) {
+			evicted = append(evicted, name)
+		}
+	}
+	return evicted
+}
+
+// emptyDatabaseSize returns the size of a newly created database file.
+func emptyDatabaseSize(t *testing.T) int64 {
+	t.Helper()
+
+	pool := New(nil)
+	defer pool.Close()
+
+	c, err := pool.Get(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	return fileSize(t, c.path)
+}
+
+func TestEvictionPolicies(t *testing.T) {
+	gets := map[string]int{"a": 3, "b": 1}
+	for name, tc := range map[string]struct {
+		policy EvictionPolicy
+		want   string
+	}{
+		"default": {nil, "a"},
+		"lru":     {EvictLRU, "a"},
+		"lfu":     {EvictLFU, "b"},
+		"custom": {EvictionPolicyFunc(func(a, b EvictionCandidate) bool {
+			return a.Labels["tier"] == "low" && b.Labels["tier"] != "low"
+		}), "b"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			labels := map[string]Labels{
+				"a": {"tier": "high"},
+				"b": {"tier": "low"},
+			}
+			evicted := testEviction(t, tc.policy, gets, labels)
+			if len(evicted) != 1 || evicted[0] != tc.want {
+				t.Errorf("got evicted %v, expected [%s]", evicted, tc.want)
+			}
+		})
+	}
+}
+
+func TestEvictionPolicyPanic(t *testing.T) {
+	var perr *PanicError
+	policy := EvictionPolicyFunc(func(a, b EvictionCandidate) bool {
+		panic("test")
+	})
+	dir := t.TempDir()
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+		MemoryBudget:      2*emptyDatabaseSize(t) + 1,
+		EvictionPolicy:    policy,
+		ErrorHandler: func(err error) {
+			errors.As(err, &perr)
+		},
+	})
+
+	for _, name := range []string{"a", "b", "c"} {
+		c, err := pool.Get(filepath.Join(dir, name+".db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+	}
+	// wait for the eviction in the background
+	pool.Close()
+	if perr == nil || perr.Hook != "EvictionPolicy" {
+		t.Errorf("got panic error %v, expected one from EvictionPolicy", perr)
+	}
+}
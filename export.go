@@ -0,0 +1,179 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrInvalidRecord is returned by Pool.Import for records that do not have
+// a bucket or that have a value without a key, and by Pool.ImportRecords
+// for records without a key or a value.
+var ErrInvalidRecord = errors.New("boltdbpool: invalid export record")
+
+// ExportRecord is a single line of the export format used by Pool.Export
+// and Pool.Import. Every bucket, including nested ones, is written as a
+// record without a key, followed by records of its keys and values, so that
+// empty buckets are preserved:
+//
+//	{"bucket":["users"]}
+//	{"bucket":["users"],"key":"am9obg==","value":"eyJhZ2UiOjMwfQ=="}
+//	{"bucket":["users","index"]}
+//
+// Bucket is the path of bucket names from the top-level bucket. Keys and
+// values are base64 encoded, as stored in the database, without decoding
+// by Options.Compression or Options.Encryption.
+type ExportRecord struct {
+	Bucket []string `json:"bucket"`
+	Key    []byte   `json:"key,omitempty"`
+	Value  []byte   `json:"value,omitempty"`
+}
+
+// importTxSize is the number of records imported in a single transaction.
+const importTxSize = 10000
+
+// Export writes all buckets, keys and values of the existing database on
+// path to w as line-delimited JSON ExportRecords within a single read
+// transaction. Bucket names must be valid UTF-8.
+func (p *Pool) Export(path string, w io.Writer) error {
+	c, err := p.getExisting(path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	bw := bufio.NewWriter(w)
+	e := json.NewEncoder(bw)
+	if err := c.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			return exportBucket(e, nil, name, b)
+		})
+	}); err != nil {
+		return fmt.Errorf("boltdbpool: export %s: %w", path, err)
+	}
+	return bw.Flush()
+}
+
+func exportBucket(e *json.Encoder, parent []string, name []byte, b *bolt.Bucket) error {
+	if !utf8.Valid(name) {
+		return fmt.Errorf("bucket name %q is not valid UTF-8", name)
+	}
+	path := append(parent[:len(parent):len(parent)], string(name))
+	if err := e.Encode(ExportRecord{Bucket: path}); err != nil {
+		return err
+	}
+	var nested [][]byte
+	if err := b.ForEach(func(k, v []byte) error {
+		if v == nil && b.Bucket(k) != nil {
+			nested = append(nested, k)
+			return nil
+		}
+		if v == nil {
+			v = []byte{}
+		}
+		return e.Encode(ExportRecord{Bucket: path, Key: k, Value: v})
+	}); err != nil {
+		return err
+	}
+	for _, k := range nested {
+		if err := exportBucket(e, path, k, b.Bucket(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Import reads line-delimited JSON ExportRecords from r, as written by
+// Pool.Export, and stores them in the database on path, which is created
+// if it does not exist. Existing keys are overwritten. Records are written
+// in transactions of limited size, so the database may hold a part of the
+// records if an error is returned.
+func (p *Pool) Import(path string, r io.Reader) (err error) {
+	if p.options.ReadOnly {
+		return ErrReadOnly
+	}
+	c, err := p.Get(path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	defer p.invalidateCache(c.path)
+
+	d := json.NewDecoder(bufio.NewReader(r))
+	for line := 1; ; {
+		records := make([]ExportRecord, 0, importTxSize)
+		for len(records) < importTxSize && d.More() {
+			var r ExportRecord
+			if err := d.Decode(&r); err != nil {
+				return fmt.Errorf("boltdbpool: import %s: record %d: %w", path, line, err)
+			}
+			if len(r.Bucket) == 0 || (r.Key == nil && r.Value != nil) {
+				return fmt.Errorf("boltdbpool: import %s: record %d: %w", path, line, ErrInvalidRecord)
+			}
+			records = append(records, r)
+			line++
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		if err := c.Update(func(tx *bolt.Tx) error {
+			return importRecords(tx, records)
+		}); err != nil {
+			return fmt.Errorf("boltdbpool: import %s: %w", path, err)
+		}
+	}
+}
+
+func importRecords(tx *bolt.Tx, records []ExportRecord) error {
+	var (
+		b    *bolt.Bucket
+		last []string
+	)
+	for _, r := range records {
+		if b == nil || !equalStrings(r.Bucket, last) {
+			var err error
+			if b, err = tx.CreateBucketIfNotExists([]byte(r.Bucket[0])); err != nil {
+				return err
+			}
+			for _, name := range r.Bucket[1:] {
+				if b, err = b.CreateBucketIfNotExists([]byte(name)); err != nil {
+					return err
+				}
+			}
+			last = r.Bucket
+		}
+		if r.Key == nil {
+			continue
+		}
+		value := r.Value
+		if value == nil {
+			value = []byte{}
+		}
+		if err := b.Put(r.Key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
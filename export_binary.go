@@ -0,0 +1,388 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrInvalidBinaryExport is returned by Pool.Restore when the data is not
+// in the binary export format, is truncated or fails the checksum.
+var ErrInvalidBinaryExport = errors.New("boltdbpool: invalid binary export")
+
+// binaryExportMagic starts every binary export and holds the format version
+// in its last byte.
+const binaryExportMagic = "boltdbx\x01"
+
+// maxBinaryRecordSize limits memory allocated for a single record read by
+// Pool.Restore.
+const maxBinaryRecordSize = 1 << 31
+
+// Record types of the binary export format.
+const (
+	binaryRecordBucket byte = iota + 1
+	binaryRecordValue
+	binaryRecordEnd
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// ExportBinary writes all buckets, keys and values of the existing database
+// on path to w in a compact binary format, within a single read
+// transaction. The format starts with an 8 byte magic string, followed by
+// records, each framed as a 4 byte big-endian payload length, the payload
+// and the CRC-32C checksum of the payload. The first payload byte is the
+// record type:
+//
+//	1 bucket: the number of bucket names in the path, followed by the
+//	  names, as uvarint length-prefixed bytes; following values are in
+//	  this bucket
+//	2 value: uvarint length-prefixed key, followed by the value
+//	3 end: uvarint number of preceding records
+//
+// Values are exported as stored in the database, without decoding by
+// Options.Compression or Options.Encryption. Use Pool.Restore to read the
+// data back.
+func (p *Pool) ExportBinary(path string, w io.Writer) error {
+	c, err := p.getExisting(path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	e := &binaryEncoder{w: bufio.NewWriter(w)}
+	if _, err := e.w.WriteString(binaryExportMagic); err != nil {
+		return err
+	}
+	if err := c.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			return e.bucket([][]byte{name}, b)
+		})
+	}); err != nil {
+		return fmt.Errorf("boltdbpool: export %s: %w", path, err)
+	}
+	if err := e.record(binaryRecordEnd, appendUvarint(nil, e.count)); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+type binaryEncoder struct {
+	w     *bufio.Writer
+	buf   []byte
+	count uint64
+}
+
+func (e *binaryEncoder) bucket(path [][]byte, b *bolt.Bucket) error {
+	payload := appendUvarint(nil, uint64(len(path)))
+	for _, name := range path {
+		payload = appendBytes(payload, name)
+	}
+	if err := e.record(binaryRecordBucket, payload); err != nil {
+		return err
+	}
+	var nested [][]byte
+	if err := b.ForEach(func(k, v []byte) error {
+		if v == nil && b.Bucket(k) != nil {
+			nested = append(nested, k)
+			return nil
+		}
+		e.buf = append(appendBytes(e.buf[:0], k), v...)
+		return e.record(binaryRecordValue, e.buf)
+	}); err != nil {
+		return err
+	}
+	for _, k := range nested {
+		if err := e.bucket(append(path[:len(path):len(path)], k), b.Bucket(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *binaryEncoder) record(t byte, payload []byte) error {
+	var h [5]byte
+	binary.BigEndian.PutUint32(h[:4], uint32(len(payload)+1))
+	h[4] = t
+	if _, err := e.w.Write(h[:]); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(payload); err != nil {
+		return err
+	}
+	crc := crc32.Update(crc32.Checksum(h[4:], crc32c), crc32c, payload)
+	binary.BigEndian.PutUint32(h[:4], crc)
+	if _, err := e.w.Write(h[:4]); err != nil {
+		return err
+	}
+	if t != binaryRecordEnd {
+		e.count++
+	}
+	return nil
+}
+
+// Restore reads data in the format written by Pool.ExportBinary from r into
+// a new temporary file next to the database on path and replaces the
+// database with it when all data is read and validated. If the database is
+// open in the pool, it is closed before the replacement and opened again
+// if it has a reference, replacing the Connection DB field, so the
+// connection must not be used while the database is restored. ErrInUse is
+// returned if the connection has more than one reference. The database on
+// path is not changed if an error is returned.
+func (p *Pool) Restore(path string, r io.Reader) (err error) {
+	path, err = p.normalizePath(path)
+	if err != nil {
+		return err
+	}
+	if p.isReadOnly(path) {
+		return ErrReadOnly
+	}
+	p.mu.Lock()
+	err = p.mkdirAll(filepath.Dir(path))
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.restore-%d", filepath.Base(path), time.Now().UnixNano()))
+	defer func() {
+		if err != nil {
+			p.fs.Remove(tmp)
+			err = fmt.Errorf("boltdbpool: restore %s: %w", path, err)
+		}
+	}()
+
+	options, mode := p.boltOptions(path)
+	db, err := bolt.Open(tmp, mode, options)
+	if err != nil {
+		return err
+	}
+	if err := restoreRecords(db, bufio.NewReader(r)); err != nil {
+		db.Close()
+		return err
+	}
+	if err := db.Close(); err != nil {
+		return err
+	}
+	if err := p.applyFilePermissions(tmp); err != nil {
+		return err
+	}
+	return p.replaceFile(path, tmp)
+}
+
+// restoreRecords validates the binary export magic and writes all records
+// to the database in transactions of limited size.
+func restoreRecords(db *bolt.DB, r *bufio.Reader) error {
+	magic := make([]byte, len(binaryExportMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != binaryExportMagic {
+		return ErrInvalidBinaryExport
+	}
+	var (
+		records []ExportRecord
+		bucket  []string
+		count   uint64
+	)
+	flush := func() error {
+		if len(records) == 0 {
+			return nil
+		}
+		err := db.Update(func(tx *bolt.Tx) error {
+			return importRecords(tx, records)
+		})
+		records = records[:0]
+		return err
+	}
+	for {
+		t, payload, err := readBinaryRecord(r)
+		if err != nil {
+			return err
+		}
+		switch t {
+		case binaryRecordBucket:
+			n, payload, err := readUvarint(payload)
+			if err != nil {
+				return err
+			}
+			if n == 0 || n > uint64(len(payload)) {
+				return ErrInvalidBinaryExport
+			}
+			bucket = make([]string, n)
+			for i := range bucket {
+				var name []byte
+				if name, payload, err = readBytes(payload); err != nil {
+					return err
+				}
+				bucket[i] = string(name)
+			}
+			records = append(records, ExportRecord{Bucket: bucket})
+		case binaryRecordValue:
+			if bucket == nil {
+				return ErrInvalidBinaryExport
+			}
+			key, value, err := readBytes(payload)
+			if err != nil {
+				return err
+			}
+			records = append(records, ExportRecord{Bucket: bucket, Key: key, Value: value})
+		case binaryRecordEnd:
+			n, _, err := readUvarint(payload)
+			if err != nil || n != count {
+				return ErrInvalidBinaryExport
+			}
+			return flush()
+		default:
+			return ErrInvalidBinaryExport
+		}
+		count++
+		if len(records) == importTxSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readBinaryRecord reads a single framed record and validates its checksum.
+func readBinaryRecord(r io.Reader) (t byte, payload []byte, err error) {
+	data, ok := readFrame(r)
+	if !ok {
+		return 0, nil, ErrInvalidBinaryExport
+	}
+	return data[0], data[1:], nil
+}
+
+// readFrame reads data framed as a 4 byte big-endian length, the data and
+// its CRC-32C checksum. It returns false if the frame is truncated, too
+// large or fails the checksum.
+func readFrame(r io.Reader) (data []byte, ok bool) {
+	var h [4]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return nil, false
+	}
+	n := binary.BigEndian.Uint32(h[:])
+	if n == 0 || n > maxBinaryRecordSize {
+		return nil, false
+	}
+	data = make([]byte, n+4)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, false
+	}
+	if crc32.Checksum(data[:n], crc32c) != binary.BigEndian.Uint32(data[n:]) {
+		return nil, false
+	}
+	return data[:n], true
+}
+
+// appendFrame appends the data framed as read by readFrame.
+func appendFrame(b, data []byte) []byte {
+	var h [4]byte
+	binary.BigEndian.PutUint32(h[:], uint32(len(data)))
+	b = append(append(b, h[:]...), data...)
+	binary.BigEndian.PutUint32(h[:], crc32.Checksum(data, crc32c))
+	return append(b, h[:]...)
+}
+
+// replaceFile renames the file on tmp to the database path, closing and
+// reopening the database if it is open in the pool. If the database can
+// not be opened, the original file is put back and opened again.
+func (p *Pool) replaceFile(path, tmp string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.isClosed() {
+		return ErrClosed
+	}
+	p.waitCompaction(path)
+	p.closeParked(path)
+	delete(p.verified, path)
+	p.invalidateCache(path)
+
+	c, ok := p.connections[path]
+	if !ok {
+		return p.fs.Rename(tmp, path)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.count > 1 {
+		return ErrInUse
+	}
+	if err := p.remove(path); err != nil {
+		return err
+	}
+	if c.count == 0 {
+		return p.fs.Rename(tmp, path)
+	}
+	// the original file is kept until the new database is opened
+	original := tmp + ".original"
+	if err := p.fs.Rename(path, original); err != nil {
+		p.handleError(p.reopen(c))
+		return err
+	}
+	if err := p.fs.Rename(tmp, path); err != nil {
+		p.restoreOriginal(c, original)
+		return err
+	}
+	if err := p.reopen(c); err != nil {
+		// the new file is removed by the caller
+		if rerr := p.fs.Rename(path, tmp); rerr != nil {
+			p.handleError(rerr)
+		}
+		p.restoreOriginal(c, original)
+		return err
+	}
+	// the database is replaced even if the original file is left
+	p.handleError(p.fs.Remove(original))
+	return nil
+}
+
+// restoreOriginal moves the original database file back to the connection
+// path and opens it. Pool and connection locks must be held.
+func (p *Pool) restoreOriginal(c *Connection, original string) {
+	if err := p.fs.Rename(original, c.path); err != nil {
+		p.handleError(err)
+		return
+	}
+	p.handleError(p.reopen(c))
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func appendBytes(b, v []byte) []byte {
+	return append(appendUvarint(b, uint64(len(v))), v...)
+}
+
+func readUvarint(b []byte) (v uint64, rest []byte, err error) {
+	v, n := binary.Uvarint(b)
+	if n <= 0 {
+		return 0, nil, ErrInvalidBinaryExport
+	}
+	return v, b[n:], nil
+}
+
+func readBytes(b []byte) (v, rest []byte, err error) {
+	n, b, err := readUvarint(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n > uint64(len(b)) {
+		return nil, nil, ErrInvalidBinaryExport
+	}
+	return b[:n], b[n:], nil
+}
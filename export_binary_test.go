@@ -0,0 +1,217 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestExportBinaryRestore(t *testing.T) {
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+	})
+	defer pool.Close()
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.db")
+	c, err := pool.Get(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("users"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < importTxSize+10; i++ {
+			if err := b.Put([]byte(fmt.Sprintf("key-%06d", i)), []byte(fmt.Sprint(i))); err != nil {
+				return err
+			}
+		}
+		nb, err := b.CreateBucket([]byte{0xff, '/'})
+		if err != nil {
+			return err
+		}
+		if err := nb.Put([]byte("empty"), []byte{}); err != nil {
+			return err
+		}
+		_, err = tx.CreateBucket([]byte("empty"))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	var data bytes.Buffer
+	if err := pool.ExportBinary(src, &data); err != nil {
+		t.Fatal(err)
+	}
+
+	// restore over an open database that has a reference
+	dst := filepath.Join(dir, "dst", "dst.db")
+	dc, err := pool.Get(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dc.Close()
+	if err := pool.Restore(dst, bytes.NewReader(data.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dc.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket([]byte("users")).Bucket([]byte{0xff, '/'}).Get([]byte("empty")); v == nil || len(v) != 0 {
+			t.Errorf("got value %q", v)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dc.View(func(tx *bolt.Tx) error {
+		// values, the nested bucket and its key
+		if n := tx.Bucket([]byte("users")).Stats().KeyN; n != importTxSize+12 {
+			t.Errorf("got %d keys", n)
+		}
+		return tx.Bucket([]byte("empty")).ForEach(func(k, v []byte) error {
+			t.Errorf("unexpected key %q", k)
+			return nil
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "dst", ".*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("temporary files left: %v", matches)
+	}
+}
+
+func TestRestoreInvalid(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.db")
+	c, err := pool.Get(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PutValue([]byte("b"), []byte("k"), []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	var data bytes.Buffer
+	if err := pool.ExportBinary(src, &data); err != nil {
+		t.Fatal(err)
+	}
+	b := data.Bytes()
+	corrupted := append([]byte(nil), b...)
+	corrupted[len(binaryExportMagic)+6] ^= 0xff
+
+	dst := filepath.Join(dir, "dst.db")
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"magic":     []byte("invalid!"),
+		"truncated": b[:len(b)-10],
+		"checksum":  corrupted,
+	} {
+		if err := pool.Restore(dst, bytes.NewReader(data)); !errors.Is(err, ErrInvalidBinaryExport) {
+			t.Errorf("%s: got error %v, expected %v", name, err, ErrInvalidBinaryExport)
+		}
+		if _, err := os.Stat(dst); !os.IsNotExist(err) {
+			t.Errorf("%s: database created", name)
+		}
+	}
+	if err := pool.Restore(dst, bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+	c, err = pool.Get(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if v, err := c.GetValue([]byte("b"), []byte("k")); err != nil || string(v) != "v" {
+		t.Errorf("got value %q (%v)", v, err)
+	}
+}
+
+func TestRestoreOpenFailed(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.db")
+	dst := filepath.Join(dir, "dst.db")
+	errOpen := errors.New("open refused")
+	pool := New(&Options{
+		// the restored database is not opened
+		OnOpen: func(path string, db *bolt.DB) error {
+			return db.View(func(tx *bolt.Tx) error {
+				if path == dst && tx.Bucket([]byte("refused")) != nil {
+					return errOpen
+				}
+				return nil
+			})
+		},
+	})
+	defer pool.Close()
+
+	c, err := pool.Get(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucket([]byte("refused"))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	var data bytes.Buffer
+	if err := pool.ExportBinary(src, &data); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = pool.Get(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.PutValue([]byte("b"), []byte("k"), []byte("original")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pool.Restore(dst, bytes.NewReader(data.Bytes())); !errors.Is(err, errOpen) {
+		t.Fatalf("got error %v, expected %v", err, errOpen)
+	}
+	if v, err := c.GetValue([]byte("b"), []byte("k")); err != nil || string(v) != "original" {
+		t.Errorf("got value %q (%v), expected %q", v, err, "original")
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, ".*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("temporary files left: %v", matches)
+	}
+
+	c2, err := pool.Get(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	if err := pool.Restore(dst, bytes.NewReader(data.Bytes())); !errors.Is(err, ErrInUse) {
+		t.Errorf("got error %v, expected %v", err, ErrInUse)
+	}
+}
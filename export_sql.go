@@ -0,0 +1,93 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ExportSQL writes all buckets, keys and values of the existing database on
+// path to w as SQL statements in the SQLite dialect within a single read
+// transaction, so that the data can be loaded into SQLite, for example with
+// "sqlite3 data.sqlite < data.sql", and queried with SQL tools. Every
+// bucket, including nested ones, is a table named by its slash-separated
+// bucket path, with "key" and "value" BLOB columns. Keys and values are
+// written as stored in the database, without decoding by
+// Options.Compression or Options.Encryption, and values can be read as
+// text with CAST(value AS TEXT). Bucket names must be valid UTF-8 and bucket
+// paths must be unique.
+func (p *Pool) ExportSQL(path string, w io.Writer) error {
+	c, err := p.getExisting(path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	bw := bufio.NewWriter(w)
+	e := &sqlExporter{w: bw, tables: make(map[string]struct{})}
+	fmt.Fprintln(bw, "BEGIN TRANSACTION;")
+	if err := c.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			return e.exportBucket("", name, b)
+		})
+	}); err != nil {
+		return fmt.Errorf("boltdbpool: export sql %s: %w", path, err)
+	}
+	fmt.Fprintln(bw, "COMMIT;")
+	return bw.Flush()
+}
+
+type sqlExporter struct {
+	w      *bufio.Writer
+	tables map[string]struct{}
+}
+
+func (e *sqlExporter) exportBucket(parent string, name []byte, b *bolt.Bucket) error {
+	if !utf8.Valid(name) {
+		return fmt.Errorf("bucket name %q is not valid UTF-8", name)
+	}
+	table := string(name)
+	if parent != "" {
+		table = parent + string(bucketPathSeparator) + table
+	}
+	if _, ok := e.tables[table]; ok {
+		return fmt.Errorf("duplicate table %q", table)
+	}
+	e.tables[table] = struct{}{}
+	quoted := quoteSQLIdentifier(table)
+	fmt.Fprintf(e.w, "CREATE TABLE %s (\"key\" BLOB PRIMARY KEY, \"value\" BLOB);\n", quoted)
+
+	var nested [][]byte
+	if err := b.ForEach(func(k, v []byte) error {
+		if v == nil && b.Bucket(k) != nil {
+			nested = append(nested, k)
+			return nil
+		}
+		_, err := fmt.Fprintf(e.w, "INSERT INTO %s VALUES(X'%s',X'%s');\n", quoted, hex.EncodeToString(k), hex.EncodeToString(v))
+		return err
+	}); err != nil {
+		return err
+	}
+	for _, k := range nested {
+		if err := e.exportBucket(table, k, b.Bucket(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// quoteSQLIdentifier returns the identifier in double quotes, with double
+// quotes in it escaped.
+func quoteSQLIdentifier(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
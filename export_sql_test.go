@@ -0,0 +1,84 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestExportSQL(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte(`us"ers`))
+		if err != nil {
+			return err
+		}
+		if err := b.Put([]byte("ana"), []byte(`{"age":31}`)); err != nil {
+			return err
+		}
+		if _, err := b.CreateBucket([]byte("index")); err != nil {
+			return err
+		}
+		_, err = tx.CreateBucket([]byte("empty"))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := pool.ExportSQL(path, &buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `BEGIN TRANSACTION;
+CREATE TABLE "empty" ("key" BLOB PRIMARY KEY, "value" BLOB);
+CREATE TABLE "us""ers" ("key" BLOB PRIMARY KEY, "value" BLOB);
+INSERT INTO "us""ers" VALUES(X'616e61',X'7b22616765223a33317d');
+CREATE TABLE "us""ers/index" ("key" BLOB PRIMARY KEY, "value" BLOB);
+COMMIT;
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nexpected\n%s", got, want)
+	}
+}
+
+func TestExportSQLDuplicateTable(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.GetWithBuckets(path, []byte("a/b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucket([]byte("a/b"))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pool.ExportSQL(path, &bytes.Buffer{}); err == nil {
+		t.Error("exported duplicate tables")
+	}
+	if err := pool.ExportSQL(filepath.Join(t.TempDir(), "missing.db"), &bytes.Buffer{}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v, expected %v", err, os.ErrNotExist)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestExportImport(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.db")
+	c, err := pool.Get(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("users"))
+		if err != nil {
+			return err
+		}
+		if err := b.Put([]byte("john"), []byte(`{"age":30}`)); err != nil {
+			return err
+		}
+		if err := b.Put([]byte("empty"), []byte{}); err != nil {
+			return err
+		}
+		if _, err := b.CreateBucket([]byte("index")); err != nil {
+			return err
+		}
+		_, err = tx.CreateBucket([]byte("empty"))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	var buf bytes.Buffer
+	if err := pool.Export(src, &buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"bucket":["empty"]}
+{"bucket":["users"]}
+{"bucket":["users"],"key":"ZW1wdHk="}
+{"bucket":["users"],"key":"am9obg==","value":"eyJhZ2UiOjMwfQ=="}
+{"bucket":["users","index"]}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got export\n%s\nexpected\n%s", got, want)
+	}
+
+	dst := filepath.Join(dir, "dst.db")
+	if err := pool.Import(dst, &buf); err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	if err := pool.Export(dst, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want {
+		t.Errorf("got export of imported database\n%s\nexpected\n%s", got.String(), want)
+	}
+
+	if err := pool.Export(filepath.Join(dir, "missing.db"), &got); err == nil {
+		t.Error("expected error for missing database")
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	for _, data := range []string{
+		`{"key":"YQ=="}`,
+		`{"bucket":["a"],"value":"YQ=="}`,
+	} {
+		if err := pool.Import(path, strings.NewReader(data)); !errors.Is(err, ErrInvalidRecord) {
+			t.Errorf("%s: got error %v, expected %v", data, err, ErrInvalidRecord)
+		}
+	}
+	if err := pool.Import(path, strings.NewReader(`{"bucket":`)); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
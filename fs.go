@@ -0,0 +1,170 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FS is the filesystem on which the pool stores database files and creates
+// their directories. Database files must be opened as *os.File, as bolt
+// maps them into memory, but implementations can translate paths, like
+// confining them to a directory, or observe and inject failures of
+// filesystem operations in tests.
+type FS interface {
+	// OpenFile opens the named file, like os.OpenFile. It is used by bolt
+	// to open database files.
+	OpenFile(name string, flag int, perm os.FileMode) (*os.File, error)
+	// Stat returns information about the named file, like os.Stat.
+	Stat(name string) (os.FileInfo, error)
+	// ReadDir returns entries of the named directory sorted by their names,
+	// like os.ReadDir.
+	ReadDir(name string) ([]os.DirEntry, error)
+	// Mkdir creates a directory, like os.Mkdir.
+	Mkdir(name string, perm os.FileMode) error
+	// Rename renames a file, like os.Rename.
+	Rename(oldpath, newpath string) error
+	// Remove removes a file or an empty directory, like os.Remove.
+	Remove(name string) error
+}
+
+// FS returns the filesystem on which the pool stores database files,
+// Options.FS or OSFS if it is not set.
+func (p *Pool) FS() FS {
+	return p.fs
+}
+
+// OSFS is the FS that uses functions from the os package. It is used when
+// Options.FS is not set.
+type OSFS struct{}
+
+// OpenFile calls os.OpenFile.
+func (OSFS) OpenFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+// Stat calls os.Stat.
+func (OSFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// ReadDir calls os.ReadDir.
+func (OSFS) ReadDir(name string) ([]os.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+// Mkdir calls os.Mkdir.
+func (OSFS) Mkdir(name string, perm os.FileMode) error {
+	return os.Mkdir(name, perm)
+}
+
+// Rename calls os.Rename.
+func (OSFS) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+// Remove calls os.Remove.
+func (OSFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+// Chmod calls os.Chmod.
+func (OSFS) Chmod(name string, mode os.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+// Chown calls os.Chown.
+func (OSFS) Chown(name string, uid, gid int) error {
+	return os.Chown(name, uid, gid)
+}
+
+// Glob returns the names of all files on the filesystem matching the
+// pattern, with the same syntax and semantics as filepath.Glob.
+func Glob(fs FS, pattern string) (matches []string, err error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	if !hasMeta(pattern) {
+		if _, err := fs.Stat(pattern); err != nil {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+
+	dir, file := filepath.Split(pattern)
+	dir = cleanGlobPath(dir)
+	if !hasMeta(dir) {
+		return globDir(fs, dir, file, nil)
+	}
+	if dir == pattern {
+		return nil, filepath.ErrBadPattern
+	}
+	dirs, err := Glob(fs, dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range dirs {
+		if matches, err = globDir(fs, d, file, matches); err != nil {
+			return nil, err
+		}
+	}
+	return matches, nil
+}
+
+// globDir appends to matches the names in the directory that match the
+// pattern. Errors reading the directory are ignored, like in filepath.Glob.
+func globDir(fs FS, dir, pattern string, matches []string) ([]string, error) {
+	if info, err := fs.Stat(dir); err != nil || !info.IsDir() {
+		return matches, nil
+	}
+	entries, err := fs.ReadDir(dir)
+	if err != nil {
+		return matches, nil
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		matched, err := filepath.Match(pattern, n)
+		if err != nil {
+			return matches, err
+		}
+		if matched {
+			matches = append(matches, filepath.Join(dir, n))
+		}
+	}
+	return matches, nil
+}
+
+func cleanGlobPath(path string) string {
+	switch path {
+	case "":
+		return "."
+	case string(filepath.Separator):
+		return path
+	default:
+		return path[:len(path)-1]
+	}
+}
+
+func hasMeta(path string) bool {
+	magic := `*?[`
+	if filepath.Separator != '\\' {
+		magic = `*?[\`
+	}
+	for _, c := range path {
+		for _, m := range magic {
+			if c == m {
+				return true
+			}
+		}
+	}
+	return false
+}
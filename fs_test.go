@@ -0,0 +1,185 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// recordingFS records names passed to OSFS methods and fails operations on
+// the names in fail.
+type recordingFS struct {
+	OSFS
+	mu    sync.Mutex
+	calls map[string][]string
+	fail  map[string]error
+}
+
+func newRecordingFS() *recordingFS {
+	return &recordingFS{
+		calls: map[string][]string{},
+		fail:  map[string]error{},
+	}
+}
+
+func (fs *recordingFS) record(op, name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.calls[op] = append(fs.calls[op], name)
+	return fs.fail[name]
+}
+
+func (fs *recordingFS) called(op, name string) bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	for _, n := range fs.calls[op] {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (fs *recordingFS) OpenFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	if err := fs.record("open", name); err != nil {
+		return nil, err
+	}
+	return fs.OSFS.OpenFile(name, flag, perm)
+}
+
+func (fs *recordingFS) Mkdir(name string, perm os.FileMode) error {
+	if err := fs.record("mkdir", name); err != nil {
+		return err
+	}
+	return fs.OSFS.Mkdir(name, perm)
+}
+
+func (fs *recordingFS) Rename(oldpath, newpath string) error {
+	if err := fs.record("rename", oldpath); err != nil {
+		return err
+	}
+	return fs.OSFS.Rename(oldpath, newpath)
+}
+
+func (fs *recordingFS) Remove(name string) error {
+	if err := fs.record("remove", name); err != nil {
+		return err
+	}
+	return fs.OSFS.Remove(name)
+}
+
+func TestFS(t *testing.T) {
+	fs := newRecordingFS()
+	pool := New(&Options{FS: fs})
+	defer pool.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a", "b", "test.db")
+
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	if !fs.called("open", path) {
+		t.Error("database file is not opened on the filesystem")
+	}
+	for _, d := range []string{filepath.Join(dir, "a"), filepath.Join(dir, "a", "b")} {
+		if !fs.called("mkdir", d) {
+			t.Errorf("directory %s is not created on the filesystem", d)
+		}
+	}
+
+	newPath := filepath.Join(dir, "a", "b", "new.db")
+	if err := pool.Rename(path, newPath); err != nil {
+		t.Fatal(err)
+	}
+	if !fs.called("rename", path) {
+		t.Error("database file is not renamed on the filesystem")
+	}
+
+	if err := pool.Remove(newPath); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{newPath, filepath.Join(dir, "a", "b"), filepath.Join(dir, "a")} {
+		if !fs.called("remove", name) {
+			t.Errorf("%s is not removed on the filesystem", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a")); !os.IsNotExist(err) {
+		t.Errorf("got error %v, want not exist", err)
+	}
+}
+
+func TestFSError(t *testing.T) {
+	errTest := errors.New("test error")
+
+	fs := newRecordingFS()
+	pool := New(&Options{FS: fs})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	fs.fail[path] = errTest
+
+	if _, err := pool.Get(path); !errors.Is(err, errTest) {
+		t.Fatalf("got error %v, want %v", err, errTest)
+	}
+	if pool.Has(path) {
+		t.Fatal("database that failed to open is in the pool")
+	}
+}
+
+func TestGlob(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		filepath.Join("202101", "20210101.db"),
+		filepath.Join("202101", "20210102.db"),
+		filepath.Join("202101", "other.txt"),
+		filepath.Join("202102", "20210201.db"),
+		"root.db",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, pattern := range []string{
+		filepath.Join(dir, "??????", "????????.db"),
+		filepath.Join(dir, "*.db"),
+		filepath.Join(dir, "*", "*"),
+		filepath.Join(dir, "root.db"),
+		filepath.Join(dir, "missing.db"),
+		filepath.Join(dir, "2021*", "other.txt"),
+	} {
+		want, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Glob(OSFS{}, pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("pattern %s: got %v, want %v", pattern, got, want)
+		}
+	}
+
+	if _, err := Glob(OSFS{}, "["); err != filepath.ErrBadPattern {
+		t.Errorf("got error %v, want %v", err, filepath.ErrBadPattern)
+	}
+}
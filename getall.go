@@ -0,0 +1,40 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import "fmt"
+
+// Connections is a list of connections that are released together.
+type Connections []*Connection
+
+// Close closes all connections.
+func (cs Connections) Close() {
+	for _, c := range cs {
+		c.Close()
+	}
+}
+
+// GetAll returns connections for all database files that match the
+// pattern, with the syntax of filepath.Glob, ordered by their paths. If any
+// database can not be opened, connections that are already returned by the
+// pool are closed and the error is returned. Returned connections should
+// be released with a single Connections.Close call.
+func (p *Pool) GetAll(pattern string) (Connections, error) {
+	paths, err := Glob(p.fs, pattern)
+	if err != nil {
+		return nil, err
+	}
+	cs := make(Connections, 0, len(paths))
+	for _, path := range paths {
+		c, err := p.Get(path)
+		if err != nil {
+			cs.Close()
+			return nil, fmt.Errorf("boltdbpool: get %s: %w", path, err)
+		}
+		cs = append(cs, c)
+	}
+	return cs, nil
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGetAll(t *testing.T) {
+	dir := t.TempDir()
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+	})
+	defer pool.Close()
+
+	for _, name := range []string{"b.db", "a.db", "c.txt"} {
+		c, err := pool.Get(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+	}
+
+	cs, err := pool.GetAll(filepath.Join(dir, "*.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cs) != 2 {
+		t.Fatalf("got %d connections, expected 2", len(cs))
+	}
+	for i, name := range []string{"a.db", "b.db"} {
+		if want := filepath.Join(dir, name); cs[i].path != want {
+			t.Errorf("got connection %d path %q, expected %q", i, cs[i].path, want)
+		}
+		if cs[i].count != 1 {
+			t.Errorf("got connection %d references %d, expected 1", i, cs[i].count)
+		}
+	}
+	cs.Close()
+	for i, c := range cs {
+		if c.count != 0 {
+			t.Errorf("got connection %d references %d after close, expected 0", i, c.count)
+		}
+	}
+}
+
+func TestGetAllError(t *testing.T) {
+	dir := t.TempDir()
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+	})
+	defer pool.Close()
+
+	a, err := pool.Get(filepath.Join(dir, "a.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Close()
+	// a file that is not a database
+	if err := os.WriteFile(filepath.Join(dir, "b.db"), []byte("not a database"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pool.GetAll(filepath.Join(dir, "*.db")); err == nil {
+		t.Fatal("expected error")
+	}
+	if a.count != 0 {
+		t.Errorf("got references %d, expected 0", a.count)
+	}
+
+	if _, err := pool.GetAll("["); !errors.Is(err, filepath.ErrBadPattern) {
+		t.Errorf("got error %v, expected %v", err, filepath.ErrBadPattern)
+	}
+}
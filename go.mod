@@ -1,6 +1,6 @@
 module resenje.org/boltdbpool
 
-go 1.17
+go 1.20
 
 require go.etcd.io/bbolt v1.3.7
 
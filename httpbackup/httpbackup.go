@@ -0,0 +1,80 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package httpbackup provides an HTTP handler that streams hot backups of
+// databases from a boltdbpool.Pool.
+package httpbackup // import "resenje.org/boltdbpool/httpbackup"
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	bolt "go.etcd.io/bbolt"
+
+	"resenje.org/boltdbpool"
+)
+
+// Handler returns an HTTP handler that responds with a consistent copy of
+// the database whose path is the request URL path relative to the root
+// directory. Both boltdbpool and timed pools can be used. Use
+// http.StripPrefix if the handler is mounted under a prefix. Only GET and
+// HEAD methods are allowed. Databases are opened through the pool, but no
+// new database files are created.
+//
+// Responses have Content-Length header set to the database size and ETag
+// header derived from the database transaction ID, so that clients can skip
+// downloading unchanged databases with If-None-Match header.
+func Handler(pool boltdbpool.Getter, root string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		name := path.Clean("/" + r.URL.Path)
+		if name == "/" {
+			http.NotFound(w, r)
+			return
+		}
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if !pool.Has(p) {
+			if info, err := os.Stat(p); err != nil || !info.Mode().IsRegular() {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		c, err := pool.Get(p)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		defer c.Close()
+
+		if err := c.DB.View(func(tx *bolt.Tx) error {
+			etag := fmt.Sprintf(`"%x-%x"`, tx.ID(), tx.Size())
+			w.Header().Set("ETag", etag)
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return nil
+			}
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(name)))
+			w.Header().Set("Content-Length", strconv.FormatInt(tx.Size(), 10))
+			if r.Method == http.MethodHead {
+				return nil
+			}
+			_, err := tx.WriteTo(w)
+			return err
+		}); err != nil {
+			// headers may be already written, the error can only be
+			// reported by terminating the response
+			panic(http.ErrAbortHandler)
+		}
+	})
+}
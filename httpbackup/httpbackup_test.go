@@ -0,0 +1,76 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpbackup
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+
+	"resenje.org/boltdbpool"
+)
+
+func TestHandler(t *testing.T) {
+	root := t.TempDir()
+	pool := boltdbpool.New(nil)
+	defer pool.Close()
+
+	c, err := pool.Get(filepath.Join(root, "tenants", "a.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DB.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucket([]byte("bucket"))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	handler := Handler(pool, root)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tenants/a.db", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, expected %d", w.Code, http.StatusOK)
+	}
+	if l := w.Header().Get("Content-Length"); l != strconv.Itoa(w.Body.Len()) {
+		t.Errorf("got content length %s, body length %d", l, w.Body.Len())
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("etag not set")
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/tenants/a.db", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("got status %d, expected %d", w.Code, http.StatusNotModified)
+	}
+
+	for _, p := range []string{"/missing.db", "/../a.db", "/tenants"} {
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, p, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: got status %d, expected %d", p, w.Code, http.StatusNotFound)
+		}
+	}
+	if pool.Has(filepath.Join(root, "missing.db")) {
+		t.Error("missing database created")
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/tenants/a.db", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("got status %d, expected %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
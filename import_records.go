@@ -0,0 +1,198 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Format is the format of records read by Pool.ImportRecords.
+type Format int
+
+// Formats supported by Pool.ImportRecords.
+const (
+	// CSV records have two fields, the key and the value, without a header
+	// row.
+	CSV Format = iota + 1
+	// JSONL records are JSON objects, one per line, with a string "key"
+	// and any "value". String values are stored as their text and other
+	// values as compact JSON.
+	JSONL
+)
+
+// String returns the name of the format.
+func (f Format) String() string {
+	switch f {
+	case CSV:
+		return "csv"
+	case JSONL:
+		return "jsonl"
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// ErrUnknownFormat is returned by Pool.ImportRecords for unsupported
+// formats.
+var ErrUnknownFormat = errors.New("boltdbpool: unknown format")
+
+// ImportProgress describes the state of a Pool.ImportRecords call. It is
+// passed to Options.OnImportProgress after every committed transaction.
+type ImportProgress struct {
+	Path   string
+	Bucket string
+	// Records is the number of imported records.
+	Records int64
+	// Bytes is the size of imported keys and values before they are
+	// encoded.
+	Bytes int64
+	// Done is true when all records are imported.
+	Done bool
+}
+
+// ImportRecords reads key and value records in the format from r and
+// stores them in the bucket of the database on path, creating the database
+// and the bucket if needed. Values are encoded as by Connection.PutValue,
+// so that they can be read with Connection.GetValue, and existing keys are
+// overwritten. Records are written in transactions of
+// limited size, so the database may hold a part of the records if an error
+// is returned. Options.OnImportProgress is called after every transaction.
+func (p *Pool) ImportRecords(path, bucket string, r io.Reader, format Format) (err error) {
+	if p.options.ReadOnly {
+		return ErrReadOnly
+	}
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("boltdbpool: import %s records to bucket %s of %s: %w", format, bucket, path, err)
+		}
+	}()
+
+	var read func() (key, value []byte, err error)
+	switch format {
+	case CSV:
+		read = csvRecordReader(r)
+	case JSONL:
+		read = jsonlRecordReader(r)
+	default:
+		return ErrUnknownFormat
+	}
+	if bucket == "" {
+		return bolt.ErrBucketNameRequired
+	}
+
+	c, err := p.Get(path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	defer p.invalidateCache(c.path)
+
+	progress := ImportProgress{
+		Path:   c.path,
+		Bucket: bucket,
+	}
+	for line := 1; !progress.Done; {
+		var records []KeyValue
+		for len(records) < importTxSize {
+			key, value, err := read()
+			if err == io.EOF {
+				progress.Done = true
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("record %d: %w", line, err)
+			}
+			records = append(records, KeyValue{Key: key, Value: value})
+			line++
+		}
+		if err := c.Update(func(tx *bolt.Tx) error {
+			b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+			if err != nil {
+				return err
+			}
+			for _, r := range records {
+				v, err := p.EncodeValue([]byte(bucket), r.Key, r.Value)
+				if err != nil {
+					return err
+				}
+				if err := b.Put(r.Key, v); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, r := range records {
+			progress.Records++
+			progress.Bytes += int64(len(r.Key) + len(r.Value))
+		}
+		if p.options.OnImportProgress != nil {
+			if err := p.protect("OnImportProgress", func() error {
+				p.options.OnImportProgress(progress)
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// csvRecordReader returns a function that reads key and value records from
+// CSV rows.
+func csvRecordReader(r io.Reader) func() (key, value []byte, err error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 2
+	cr.ReuseRecord = true
+	return func() (key, value []byte, err error) {
+		record, err := cr.Read()
+		if err != nil {
+			return nil, nil, err
+		}
+		if record[0] == "" {
+			return nil, nil, ErrInvalidRecord
+		}
+		return []byte(record[0]), []byte(record[1]), nil
+	}
+}
+
+// jsonlRecordReader returns a function that reads key and value records
+// from line-delimited JSON objects.
+func jsonlRecordReader(r io.Reader) func() (key, value []byte, err error) {
+	d := json.NewDecoder(bufio.NewReader(r))
+	return func() (key, value []byte, err error) {
+		var record struct {
+			Key   *string         `json:"key"`
+			Value json.RawMessage `json:"value"`
+		}
+		if err := d.Decode(&record); err != nil {
+			return nil, nil, err
+		}
+		if record.Key == nil || *record.Key == "" || record.Value == nil {
+			return nil, nil, ErrInvalidRecord
+		}
+		if record.Value[0] == '"' {
+			var s string
+			if err := json.Unmarshal(record.Value, &s); err != nil {
+				return nil, nil, err
+			}
+			return []byte(*record.Key), []byte(s), nil
+		}
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, record.Value); err != nil {
+			return nil, nil, err
+		}
+		return []byte(*record.Key), buf.Bytes(), nil
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImportRecords(t *testing.T) {
+	for _, tc := range []struct {
+		format Format
+		data   string
+	}{
+		{
+			format: CSV,
+			data:   "ana,\"{\"\"age\"\":31}\"\nbob,builder\nempty,\n",
+		},
+		{
+			format: JSONL,
+			data:   "{\"key\":\"ana\",\"value\":{\"age\": 31}}\n{\"key\":\"bob\",\"value\":\"builder\"}\n{\"key\":\"empty\",\"value\":\"\"}\n",
+		},
+	} {
+		t.Run(tc.format.String(), func(t *testing.T) {
+			var progress []ImportProgress
+			pool := New(&Options{
+				Compression: &Compression{},
+				OnImportProgress: func(p ImportProgress) {
+					progress = append(progress, p)
+				},
+			})
+			defer pool.Close()
+
+			path := filepath.Join(t.TempDir(), "test.db")
+			if err := pool.ImportRecords(path, "users", strings.NewReader(tc.data), tc.format); err != nil {
+				t.Fatal(err)
+			}
+
+			c, err := pool.Get(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			for key, want := range map[string]string{
+				"ana":   `{"age":31}`,
+				"bob":   "builder",
+				"empty": "",
+			} {
+				v, err := c.GetValue([]byte("users"), []byte(key))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if v == nil || string(v) != want {
+					t.Errorf("%s: got value %q, expected %q", key, v, want)
+				}
+			}
+
+			if len(progress) != 1 {
+				t.Fatalf("got %d progress calls, expected 1", len(progress))
+			}
+			if p := progress[0]; !p.Done || p.Records != 3 || p.Bucket != "users" || p.Path != c.path {
+				t.Errorf("got progress %+v", p)
+			}
+		})
+	}
+}
+
+func TestImportRecordsBatches(t *testing.T) {
+	var progress []ImportProgress
+	pool := New(&Options{
+		OnImportProgress: func(p ImportProgress) {
+			progress = append(progress, p)
+		},
+	})
+	defer pool.Close()
+
+	var data strings.Builder
+	n := importTxSize + 10
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&data, "key%05d,%d\n", i, i)
+	}
+	path := filepath.Join(t.TempDir(), "test.db")
+	if err := pool.ImportRecords(path, "numbers", strings.NewReader(data.String()), CSV); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(progress) != 2 {
+		t.Fatalf("got %d progress calls, expected 2", len(progress))
+	}
+	if p := progress[0]; p.Done || p.Records != importTxSize {
+		t.Errorf("got first progress %+v", p)
+	}
+	if p := progress[1]; !p.Done || p.Records != int64(n) {
+		t.Errorf("got last progress %+v", p)
+	}
+}
+
+func TestImportRecordsInvalid(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	for _, tc := range []struct {
+		format Format
+		data   string
+		err    error
+	}{
+		{format: CSV, data: ",value\n", err: ErrInvalidRecord},
+		{format: JSONL, data: `{"value":1}`, err: ErrInvalidRecord},
+		{format: JSONL, data: `{"key":"k"}`, err: ErrInvalidRecord},
+		{format: Format(0), data: "", err: ErrUnknownFormat},
+	} {
+		if err := pool.ImportRecords(path, "bucket", strings.NewReader(tc.data), tc.format); !errors.Is(err, tc.err) {
+			t.Errorf("%s %q: got error %v, expected %v", tc.format, tc.data, err, tc.err)
+		}
+	}
+	if err := pool.ImportRecords(path, "bucket", strings.NewReader("a,b,c\n"), CSV); err == nil {
+		t.Error("imported a record with three fields")
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package index maintains secondary indexes for BoltDB buckets. Index
+// entries are updated in the same transaction as the values, so they are
+// always consistent with the data.
+package index // import "resenje.org/boltdbpool/index"
+
+import (
+	"bytes"
+	"errors"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// ErrUnknownIndex is returned when a query references an index that is
+	// not declared.
+	ErrUnknownIndex = errors.New("unknown index")
+	// ErrInvalidIndex is returned by New for indexes without a name or an
+	// extractor function, or with duplicate names.
+	ErrInvalidIndex = errors.New("invalid index")
+	// ErrUniqueViolation is returned by Bucket.Put when a value of a unique
+	// index is already used by another key.
+	ErrUniqueViolation = errors.New("unique index violation")
+)
+
+// Index declares a secondary index.
+type Index struct {
+	// Name identifies the index in queries.
+	Name string
+	// Extract returns indexed values for the stored value. A value may be
+	// indexed under multiple or no index values.
+	Extract func(key, value []byte) [][]byte
+	// Unique restricts every index value to a single key.
+	Unique bool
+}
+
+// Bucket stores values in a bucket and maintains its indexes.
+type Bucket struct {
+	name    []byte
+	indexes map[string]Index
+}
+
+// New returns a Bucket that stores values in the bucket with the name and
+// keeps the indexes in top-level buckets named by the bucket name and the
+// index name, separated by ".index.".
+func New(name string, indexes ...Index) (*Bucket, error) {
+	b := &Bucket{
+		name:    []byte(name),
+		indexes: make(map[string]Index, len(indexes)),
+	}
+	for _, i := range indexes {
+		if i.Name == "" || i.Extract == nil {
+			return nil, ErrInvalidIndex
+		}
+		if _, ok := b.indexes[i.Name]; ok {
+			return nil, ErrInvalidIndex
+		}
+		b.indexes[i.Name] = i
+	}
+	return b, nil
+}
+
+// Get returns the value stored under the key, or nil if it does not exist.
+// The value is valid only for the life of the transaction.
+func (b *Bucket) Get(tx *bolt.Tx, key []byte) []byte {
+	bucket := tx.Bucket(b.name)
+	if bucket == nil {
+		return nil
+	}
+	return bucket.Get(key)
+}
+
+// Put stores the value under the key and updates all indexes.
+func (b *Bucket) Put(tx *bolt.Tx, key, value []byte) error {
+	bucket, err := tx.CreateBucketIfNotExists(b.name)
+	if err != nil {
+		return err
+	}
+	old := bucket.Get(key)
+	for _, i := range b.indexes {
+		ib, err := tx.CreateBucketIfNotExists(b.indexName(i.Name))
+		if err != nil {
+			return err
+		}
+		var oldValues [][]byte
+		if old != nil {
+			oldValues = i.Extract(key, old)
+		}
+		newValues := i.Extract(key, value)
+		for _, v := range oldValues {
+			if !contains(newValues, v) {
+				if err := removeEntry(ib, v, key); err != nil {
+					return err
+				}
+			}
+		}
+		for _, v := range newValues {
+			if err := addEntry(ib, v, key, i.Unique); err != nil {
+				return err
+			}
+		}
+	}
+	return bucket.Put(key, value)
+}
+
+// Delete removes the value stored under the key and its index entries.
+func (b *Bucket) Delete(tx *bolt.Tx, key []byte) error {
+	bucket := tx.Bucket(b.name)
+	if bucket == nil {
+		return nil
+	}
+	old := bucket.Get(key)
+	if old == nil {
+		return nil
+	}
+	for _, i := range b.indexes {
+		ib := tx.Bucket(b.indexName(i.Name))
+		if ib == nil {
+			continue
+		}
+		for _, v := range i.Extract(key, old) {
+			if err := removeEntry(ib, v, key); err != nil {
+				return err
+			}
+		}
+	}
+	return bucket.Delete(key)
+}
+
+// Lookup returns sorted keys whose values are indexed under the index value.
+func (b *Bucket) Lookup(tx *bolt.Tx, index string, value []byte) (keys [][]byte, err error) {
+	if _, ok := b.indexes[index]; !ok {
+		return nil, ErrUnknownIndex
+	}
+	ib := tx.Bucket(b.indexName(index))
+	if ib == nil {
+		return nil, nil
+	}
+	eb := ib.Bucket(value)
+	if eb == nil {
+		return nil, nil
+	}
+	err = eb.ForEach(func(k, _ []byte) error {
+		keys = append(keys, append([]byte{}, k...))
+		return nil
+	})
+	return keys, err
+}
+
+// Range calls the function for every key and value whose index value is
+// between min and max, inclusive, ordered by the index value and the key. A
+// nil min or max leaves that side of the range open. Iteration stops on the
+// first error returned by the function.
+func (b *Bucket) Range(tx *bolt.Tx, index string, min, max []byte, fn func(indexValue, key, value []byte) error) error {
+	if _, ok := b.indexes[index]; !ok {
+		return ErrUnknownIndex
+	}
+	ib := tx.Bucket(b.indexName(index))
+	bucket := tx.Bucket(b.name)
+	if ib == nil || bucket == nil {
+		return nil
+	}
+	c := ib.Cursor()
+	var iv []byte
+	if min == nil {
+		iv, _ = c.First()
+	} else {
+		iv, _ = c.Seek(min)
+	}
+	for ; iv != nil; iv, _ = c.Next() {
+		if max != nil && bytes.Compare(iv, max) > 0 {
+			return nil
+		}
+		eb := ib.Bucket(iv)
+		if eb == nil {
+			continue
+		}
+		if err := eb.ForEach(func(k, _ []byte) error {
+			return fn(iv, k, bucket.Get(k))
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rebuild removes all entries of all indexes and indexes every stored
+// value again. It should be used when indexes are added or changed.
+func (b *Bucket) Rebuild(tx *bolt.Tx) error {
+	for name := range b.indexes {
+		if err := tx.DeleteBucket(b.indexName(name)); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+	}
+	bucket := tx.Bucket(b.name)
+	if bucket == nil {
+		return nil
+	}
+	for _, i := range b.indexes {
+		ib, err := tx.CreateBucket(b.indexName(i.Name))
+		if err != nil {
+			return err
+		}
+		if err := bucket.ForEach(func(k, v []byte) error {
+			if v == nil {
+				return nil
+			}
+			for _, iv := range i.Extract(k, v) {
+				if err := addEntry(ib, iv, k, i.Unique); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Bucket) indexName(index string) []byte {
+	return []byte(string(b.name) + ".index." + index)
+}
+
+// addEntry adds the key to the nested bucket of the index value.
+func addEntry(ib *bolt.Bucket, value, key []byte, unique bool) error {
+	eb, err := ib.CreateBucketIfNotExists(value)
+	if err != nil {
+		return err
+	}
+	if unique {
+		if k, _ := eb.Cursor().First(); k != nil && !bytes.Equal(k, key) {
+			return ErrUniqueViolation
+		}
+	}
+	return eb.Put(key, nil)
+}
+
+// removeEntry removes the key from the nested bucket of the index value,
+// and the nested bucket if it is left empty.
+func removeEntry(ib *bolt.Bucket, value, key []byte) error {
+	eb := ib.Bucket(value)
+	if eb == nil {
+		return nil
+	}
+	if err := eb.Delete(key); err != nil {
+		return err
+	}
+	if k, _ := eb.Cursor().First(); k == nil {
+		return ib.DeleteBucket(value)
+	}
+	return nil
+}
+
+func contains(values [][]byte, value []byte) bool {
+	for _, v := range values {
+		if bytes.Equal(v, value) {
+			return true
+		}
+	}
+	return false
+}
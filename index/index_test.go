@@ -0,0 +1,167 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package index
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+
+	"resenje.org/boltdbpool"
+)
+
+// users are stored as "email,city,age" values under user IDs
+var users = []Index{
+	{
+		Name:   "email",
+		Unique: true,
+		Extract: func(_, value []byte) [][]byte {
+			return [][]byte{[]byte(field(value, 0))}
+		},
+	},
+	{
+		Name: "city",
+		Extract: func(_, value []byte) [][]byte {
+			return [][]byte{[]byte(field(value, 1))}
+		},
+	},
+	{
+		Name: "age",
+		Extract: func(_, value []byte) [][]byte {
+			return [][]byte{[]byte(field(value, 2))}
+		},
+	},
+}
+
+func field(value []byte, i int) string {
+	return strings.Split(string(value), ",")[i]
+}
+
+func TestBucket(t *testing.T) {
+	pool := boltdbpool.New(nil)
+	defer pool.Close()
+
+	c, err := pool.Get(filepath.Join(t.TempDir(), "users.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	b, err := New("users", users...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Update(func(tx *bolt.Tx) error {
+		for k, v := range map[string]string{
+			"1": "ana@example.com,belgrade,31",
+			"2": "bob@example.com,berlin,25",
+			"3": "eve@example.com,belgrade,42",
+		} {
+			if err := b.Put(tx, []byte(k), []byte(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	assertLookup(t, c, b, "city", "belgrade", "1", "3")
+	assertLookup(t, c, b, "email", "bob@example.com", "2")
+
+	// move eve and change the email
+	if err := c.Update(func(tx *bolt.Tx) error {
+		return b.Put(tx, []byte("3"), []byte("eve@example.org,berlin,42"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	assertLookup(t, c, b, "city", "belgrade", "1")
+	assertLookup(t, c, b, "city", "berlin", "2", "3")
+	assertLookup(t, c, b, "email", "eve@example.com")
+
+	err = c.Update(func(tx *bolt.Tx) error {
+		return b.Put(tx, []byte("4"), []byte("ana@example.com,paris,20"))
+	})
+	if !errors.Is(err, ErrUniqueViolation) {
+		t.Errorf("got error %v, expected %v", err, ErrUniqueViolation)
+	}
+
+	if err := c.View(func(tx *bolt.Tx) error {
+		var keys []string
+		if err := b.Range(tx, "age", []byte("30"), []byte("50"), func(_, key, _ []byte) error {
+			keys = append(keys, string(key))
+			return nil
+		}); err != nil {
+			return err
+		}
+		if want := []string{"1", "3"}; !reflect.DeepEqual(keys, want) {
+			t.Errorf("got keys %v, expected %v", keys, want)
+		}
+		if _, err := b.Lookup(tx, "name", nil); !errors.Is(err, ErrUnknownIndex) {
+			t.Errorf("got error %v, expected %v", err, ErrUnknownIndex)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Update(func(tx *bolt.Tx) error {
+		return b.Delete(tx, []byte("2"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	assertLookup(t, c, b, "city", "berlin", "3")
+	assertLookup(t, c, b, "email", "bob@example.com")
+
+	if err := c.Update(func(tx *bolt.Tx) error {
+		return b.Rebuild(tx)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	assertLookup(t, c, b, "city", "berlin", "3")
+	assertLookup(t, c, b, "city", "belgrade", "1")
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, indexes := range [][]Index{
+		{{Name: "a"}},
+		{{Extract: users[0].Extract}},
+		{users[0], users[0]},
+	} {
+		if _, err := New("b", indexes...); !errors.Is(err, ErrInvalidIndex) {
+			t.Errorf("got error %v, expected %v", err, ErrInvalidIndex)
+		}
+	}
+}
+
+func assertLookup(t *testing.T, c *boltdbpool.Connection, b *Bucket, index, value string, want ...string) {
+	t.Helper()
+
+	if err := c.View(func(tx *bolt.Tx) error {
+		keys, err := b.Lookup(tx, index, []byte(value))
+		if err != nil {
+			return err
+		}
+		got := make([]string, 0, len(keys))
+		for _, k := range keys {
+			got = append(got, string(k))
+		}
+		if want == nil {
+			want = []string{}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s %s: got keys %v, expected %v", index, value, got, want)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
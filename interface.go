@@ -0,0 +1,47 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"io"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Getter provides connections to databases by their file paths.
+type Getter interface {
+	// Get returns a connection to the database on path.
+	Get(path string) (*Connection, error)
+	// Has returns true if a database with a file path is open.
+	Has(path string) bool
+}
+
+// Interface is the core set of methods implemented by Pool and pools built
+// on top of it, like the timed pool. Middleware, metrics, handlers and
+// backup subsystems that are written against this interface work with all
+// of them.
+type Interface interface {
+	Getter
+	// Backup writes a consistent copy of the database on path to w.
+	Backup(path string, w io.Writer) (int64, error)
+	// Close closes all databases.
+	Close()
+}
+
+// Conn is the interface implemented by Connection and connections of pools
+// built on top of Pool that embed it.
+type Conn interface {
+	View(fn func(*bolt.Tx) error) error
+	Update(fn func(*bolt.Tx) error) error
+	Batch(fn func(*bolt.Tx) error) error
+	Backup(w io.Writer) (int64, error)
+	Close()
+}
+
+var (
+	_ Interface = (*Pool)(nil)
+	_ Conn      = (*Connection)(nil)
+)
@@ -0,0 +1,82 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"bytes"
+	"errors"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrStopScan can be returned by functions passed to Pool ScanPrefix and
+// ScanRange to stop the iteration without an error.
+var ErrStopScan = errors.New("boltdbpool: stop scan")
+
+// ScanPrefix calls fn for every key in the bucket of the database on path
+// that starts with the prefix, in the key order, within a single read
+// transaction. Values are decoded as by Connection.GetValue and nested
+// buckets are skipped. Keys and values are valid only during the fn call.
+// Iteration stops on the first error returned by fn, which is returned,
+// unless it is ErrStopScan. If the bucket does not exist, fn is not called.
+func (p *Pool) ScanPrefix(path string, bucket, prefix []byte, fn func(key, value []byte) error) error {
+	return p.scan(path, bucket, func(c *bolt.Cursor) ([]byte, []byte) {
+		return c.Seek(prefix)
+	}, func(key []byte) bool {
+		return bytes.HasPrefix(key, prefix)
+	}, fn)
+}
+
+// ScanRange calls fn for every key in the bucket of the database on path
+// that is greater than or equal to from and less than to, as ScanPrefix
+// does. If from is nil, iteration starts from the first key, and if to is
+// nil, it continues to the last key.
+func (p *Pool) ScanRange(path string, bucket, from, to []byte, fn func(key, value []byte) error) error {
+	return p.scan(path, bucket, func(c *bolt.Cursor) ([]byte, []byte) {
+		if from == nil {
+			return c.First()
+		}
+		return c.Seek(from)
+	}, func(key []byte) bool {
+		return to == nil || bytes.Compare(key, to) < 0
+	}, fn)
+}
+
+// scan iterates over keys in the bucket from the key returned by seek while
+// in returns true.
+func (p *Pool) scan(path string, bucket []byte, seek func(*bolt.Cursor) ([]byte, []byte), in func(key []byte) bool, fn func(key, value []byte) error) error {
+	c, err := p.Get(path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	err = c.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+		if b == nil {
+			return nil
+		}
+		cursor := b.Cursor()
+		for k, v := seek(cursor); k != nil && in(k); k, v = cursor.Next() {
+			if v == nil {
+				// nested bucket
+				continue
+			}
+			v, err := p.DecodeValue(bucket, k, v)
+			if err != nil {
+				return err
+			}
+			if err := fn(k, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == ErrStopScan {
+		return nil
+	}
+	return err
+}
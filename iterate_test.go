@@ -0,0 +1,133 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestScanPrefixRange(t *testing.T) {
+	pool := New(&Options{
+		Compression: &Compression{},
+	})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.GetWithBuckets(path, []byte("b/nested"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, k := range []string{"a", "ab", "abc", "ac", "b", "ba"} {
+		if err := c.PutValue([]byte("b"), []byte(k), []byte("v-"+k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scan := func(fn func(func(key, value []byte) error) error, limit int) string {
+		t.Helper()
+
+		var got []string
+		if err := fn(func(key, value []byte) error {
+			if limit > 0 && len(got) == limit {
+				return ErrStopScan
+			}
+			got = append(got, string(key)+"="+string(value))
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return fmt.Sprint(got)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		fn    func(func(key, value []byte) error) error
+		limit int
+		want  string
+	}{
+		{
+			name: "prefix",
+			fn: func(fn func(key, value []byte) error) error {
+				return pool.ScanPrefix(path, []byte("b"), []byte("ab"), fn)
+			},
+			want: "[ab=v-ab abc=v-abc]",
+		},
+		{
+			name: "empty prefix",
+			fn: func(fn func(key, value []byte) error) error {
+				return pool.ScanPrefix(path, []byte("b"), nil, fn)
+			},
+			want: "[a=v-a ab=v-ab abc=v-abc ac=v-ac b=v-b ba=v-ba]",
+		},
+		{
+			name: "prefix stop",
+			fn: func(fn func(key, value []byte) error) error {
+				return pool.ScanPrefix(path, []byte("b"), []byte("a"), fn)
+			},
+			limit: 2,
+			want:  "[a=v-a ab=v-ab]",
+		},
+		{
+			name: "range",
+			fn: func(fn func(key, value []byte) error) error {
+				return pool.ScanRange(path, []byte("b"), []byte("ab"), []byte("b"), fn)
+			},
+			want: "[ab=v-ab abc=v-abc ac=v-ac]",
+		},
+		{
+			name: "open range",
+			fn: func(fn func(key, value []byte) error) error {
+				return pool.ScanRange(path, []byte("b"), nil, nil, fn)
+			},
+			want: "[a=v-a ab=v-ab abc=v-abc ac=v-ac b=v-b ba=v-ba]",
+		},
+		{
+			name: "range from",
+			fn: func(fn func(key, value []byte) error) error {
+				return pool.ScanRange(path, []byte("b"), []byte("b"), nil, fn)
+			},
+			want: "[b=v-b ba=v-ba]",
+		},
+		{
+			name: "missing bucket",
+			fn: func(fn func(key, value []byte) error) error {
+				return pool.ScanPrefix(path, []byte("missing"), nil, fn)
+			},
+			want: "[]",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := scan(tc.fn, tc.limit); got != tc.want {
+				t.Errorf("got %s, expected %s", got, tc.want)
+			}
+		})
+	}
+
+	errTest := errors.New("test")
+	if err := pool.ScanRange(path, []byte("b"), nil, nil, func(key, value []byte) error {
+		return errTest
+	}); err != errTest {
+		t.Errorf("got error %v, expected %v", err, errTest)
+	}
+
+	// values that are not encoded by the pool can not be decoded
+	if err := c.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("b")).Put([]byte("raw"), []byte{9})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.ScanPrefix(path, []byte("b"), []byte("raw"), func(key, value []byte) error {
+		return nil
+	}); !errors.Is(err, ErrInvalidCompression) {
+		t.Errorf("got error %v, expected %v", err, ErrInvalidCompression)
+	}
+}
@@ -0,0 +1,376 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// JournalExtension is appended to the database file name to get the path
+// of its journal file.
+const JournalExtension = ".journal"
+
+// DefaultJournalMaxEntries is the number of pending journal entries at which
+// they are applied to the database if Journal.MaxEntries is not set.
+const DefaultJournalMaxEntries = 1000
+
+// ErrNoJournal is returned by Pool.Append if Options.Journal is not set.
+var ErrNoJournal = errors.New("boltdbpool: journal not enabled")
+
+// Journal configures the write-ahead journal used by Pool.Append. Entries
+// are appended and synced to a journal file next to the database file and
+// applied to the database in a single transaction for many entries, which
+// is much faster than a transaction for every write. Journals are replayed
+// when databases are opened, so that entries appended before a crash are
+// not lost.
+type Journal struct {
+	// Interval is the maximal duration between appending entries and
+	// applying them to the database.
+	Interval time.Duration
+	// MaxEntries is the number of pending entries at which they are applied
+	// to the database by Pool.Append. If the value is 0,
+	// DefaultJournalMaxEntries is used.
+	MaxEntries int
+}
+
+// JournalEntry is a change of a value that is appended to the journal.
+type JournalEntry struct {
+	// Type is ChangePut or ChangeDelete.
+	Type   ChangeType
+	Bucket []byte
+	Key    []byte
+	// Value is stored for ChangePut entries, encoded according to the pool
+	// options.
+	Value []byte
+}
+
+// journal holds the open journal file of a connection and entries that are
+// appended to it, but not yet applied to the database.
+type journal struct {
+	f       *os.File
+	size    int64
+	pending []JournalEntry
+	mu      sync.Mutex
+}
+
+// Append appends entries to the journal of the database on path and
+// returns when they are synced to disk. Entries are applied to the database
+// in order, when Journal.Interval passes, when the number of pending entries
+// reaches Journal.MaxEntries, on Pool.FlushJournal call and when the
+// database is closed. Until then, they are not visible to transactions.
+// ErrNoJournal is returned if Options.Journal is not set.
+func (p *Pool) Append(path string, entries []JournalEntry) error {
+	if p.options.Journal == nil {
+		return ErrNoJournal
+	}
+	if p.options.ReadOnly {
+		return ErrReadOnly
+	}
+	for _, e := range entries {
+		if e.Type != ChangePut && e.Type != ChangeDelete {
+			return fmt.Errorf("boltdbpool: append %s: unknown change type %v", path, e.Type)
+		}
+	}
+	c, err := p.Get(path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	if err := c.checkRateLimit(); err != nil {
+		return err
+	}
+	c.countUpdate()
+
+	encoded := make([]JournalEntry, len(entries))
+	for i, e := range entries {
+		encoded[i] = JournalEntry{
+			Type:   e.Type,
+			Bucket: append([]byte{}, e.Bucket...),
+			Key:    append([]byte{}, e.Key...),
+		}
+		if e.Type == ChangePut {
+			v, err := p.EncodeValue(e.Bucket, e.Key, e.Value)
+			if err != nil {
+				return err
+			}
+			encoded[i].Value = append([]byte{}, v...)
+		}
+	}
+
+	// the connection lock prevents the database from being replaced
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if err := c.journal.append(c, encoded); err != nil {
+		return fmt.Errorf("boltdbpool: append %s: %w", c.path, err)
+	}
+	return nil
+}
+
+// FlushJournal applies entries appended to the journal of the database on
+// path to the database. It does nothing if the database is not open.
+func (p *Pool) FlushJournal(path string) error {
+	path, err := p.normalizePath(path)
+	if err != nil {
+		return err
+	}
+	v, ok := p.index.Load(path)
+	if !ok {
+		return nil
+	}
+	c := v.(*Connection)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.journal == nil {
+		return nil
+	}
+	return c.journal.flush(c)
+}
+
+func (j *journal) append(c *Connection, entries []JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if c.pool.isClosed() {
+		return ErrClosed
+	}
+	if j.f == nil {
+		f, err := c.pool.fs.OpenFile(c.path+JournalExtension, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+		if err != nil {
+			return err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return err
+		}
+		j.f, j.size = f, info.Size()
+	}
+
+	var buf []byte
+	for _, e := range entries {
+		data, err := OplogEntry{Type: e.Type, Bucket: e.Bucket, Key: e.Key, Value: e.Value}.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		buf = appendFrame(buf, data)
+	}
+	if _, err := j.f.Write(buf); err != nil {
+		j.truncate(c)
+		return err
+	}
+	if err := j.f.Sync(); err != nil {
+		j.truncate(c)
+		return err
+	}
+	j.size += int64(len(buf))
+	j.pending = append(j.pending, entries...)
+
+	max := c.pool.options.Journal.MaxEntries
+	if max <= 0 {
+		max = DefaultJournalMaxEntries
+	}
+	if len(j.pending) >= max {
+		return j.flushLocked(c)
+	}
+	return nil
+}
+
+// truncate removes partially written entries from the end of the journal
+// file, so that entries appended later are not lost on replay.
+func (j *journal) truncate(c *Connection) {
+	if err := j.f.Truncate(j.size); err != nil {
+		c.pool.handleError(fmt.Errorf("boltdbpool: truncate journal %s: %w", c.path, err))
+	}
+}
+
+func (j *journal) flush(c *Connection) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.flushLocked(c)
+}
+
+// flushLocked applies pending entries to the connection database in a
+// single transaction and truncates the journal file. Journal lock must be
+// held.
+func (j *journal) flushLocked(c *Connection) error {
+	if len(j.pending) == 0 {
+		return nil
+	}
+	if err := c.DB.Update(func(tx *bolt.Tx) error {
+		c.countWrite(tx)
+		return c.pool.applyJournal(tx, j.pending)
+	}); err != nil {
+		return fmt.Errorf("boltdbpool: apply journal %s: %w", c.path, err)
+	}
+	for _, e := range j.pending {
+		c.invalidateValue(e.Bucket, e.Key)
+		var value []byte
+		if e.Type == ChangePut {
+			v, err := c.pool.DecodeValue(e.Bucket, e.Key, e.Value)
+			if err != nil {
+				// the value is applied, but it can not be reported
+				c.pool.handleError(err)
+				continue
+			}
+			value = v
+		}
+		c.notifyChange(nil, e.Type, e.Bucket, e.Key, value)
+	}
+	j.pending = nil
+	if err := j.f.Truncate(0); err != nil {
+		return fmt.Errorf("boltdbpool: truncate journal %s: %w", c.path, err)
+	}
+	j.size = 0
+	return nil
+}
+
+// close applies pending entries to the connection database and removes
+// the journal file. The file is kept for replay if the entries can not be
+// applied. The database must not be closed.
+func (j *journal) close(c *Connection) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.f == nil {
+		return nil
+	}
+	err := j.flushLocked(c)
+	if cerr := j.f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = c.pool.fs.Remove(c.path + JournalExtension)
+	}
+	j.f, j.size, j.pending = nil, 0, nil
+	return err
+}
+
+// applyJournal applies journal entries within the transaction.
+func (p *Pool) applyJournal(tx *bolt.Tx, entries []JournalEntry) error {
+	for _, e := range entries {
+		switch e.Type {
+		case ChangePut:
+			b, err := tx.CreateBucketIfNotExists(e.Bucket)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(e.Key, e.Value); err != nil {
+				return err
+			}
+		case ChangeDelete:
+			if b := tx.Bucket(e.Bucket); b != nil {
+				if err := b.Delete(e.Key); err != nil {
+					return err
+				}
+			}
+		default:
+			return ErrInvalidOplogEntry
+		}
+		if err := p.logChange(tx, e.Type, e.Bucket, e.Key, e.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayJournal applies entries from the journal file of the database on
+// path that is being opened and removes the file. Entries after a
+// truncated or corrupted one are not applied, as they are never reported
+// as appended. Pool lock must be held.
+func (p *Pool) replayJournal(path string, db *bolt.DB) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("boltdbpool: replay journal %s: %w", path, err)
+		}
+	}()
+
+	f, err := p.fs.OpenFile(path+JournalExtension, os.O_RDONLY, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	var entries []JournalEntry
+	r := bufio.NewReader(f)
+	for {
+		data, ok := readFrame(r)
+		if !ok {
+			break
+		}
+		var e OplogEntry
+		if err := e.UnmarshalBinary(data); err != nil {
+			break
+		}
+		entries = append(entries, JournalEntry{Type: e.Type, Bucket: e.Bucket, Key: e.Key, Value: e.Value})
+	}
+	if len(entries) > 0 {
+		if err := db.Update(func(tx *bolt.Tx) error {
+			return p.applyJournal(tx, entries)
+		}); err != nil {
+			return err
+		}
+		p.invalidateCache(path)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return p.fs.Remove(path + JournalExtension)
+}
+
+// flushJournals applies pending journal entries of all open databases at
+// the interval until the pool is closed.
+func (p *Pool) flushJournals(interval time.Duration) {
+	defer p.background.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.quit:
+			return
+		}
+
+		p.mu.RLock()
+		connections := make([]*Connection, 0, len(p.connections))
+		for _, c := range p.connections {
+			connections = append(connections, c)
+		}
+		p.mu.RUnlock()
+		sort.Slice(connections, func(i, j int) bool {
+			return connections[i].path < connections[j].path
+		})
+
+		for _, c := range connections {
+			c.mu.RLock()
+			// skip databases closed after the connections are listed
+			if v, ok := p.index.Load(c.path); ok && v == c {
+				p.handleError(c.journal.flush(c))
+			}
+			c.mu.RUnlock()
+		}
+	}
+}
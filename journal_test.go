@@ -0,0 +1,181 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAppend(t *testing.T) {
+	pool := New(&Options{
+		ConnectionExpires: time.Minute,
+		Journal:           &Journal{Interval: time.Hour},
+	})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.PutValue([]byte("bucket"), []byte("deleted"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pool.Append(path, []JournalEntry{
+		{Type: ChangePut, Bucket: []byte("bucket"), Key: []byte("key"), Value: []byte("value")},
+		{Type: ChangeDelete, Bucket: []byte("bucket"), Key: []byte("deleted")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + JournalExtension); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := c.GetValue([]byte("bucket"), []byte("key")); err != nil || v != nil {
+		t.Errorf("got value %q (%v) before flush", v, err)
+	}
+
+	if err := pool.FlushJournal(path); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := c.GetValue([]byte("bucket"), []byte("key")); err != nil || string(v) != "value" {
+		t.Errorf("got value %q (%v)", v, err)
+	}
+	if v, err := c.GetValue([]byte("bucket"), []byte("deleted")); err != nil || v != nil {
+		t.Errorf("got deleted value %q (%v)", v, err)
+	}
+	if info, err := os.Stat(path + JournalExtension); err != nil || info.Size() != 0 {
+		t.Errorf("journal is not truncated: %v", err)
+	}
+
+	if err := pool.Append(path, []JournalEntry{
+		{Type: ChangePut, Bucket: []byte("bucket"), Key: []byte("closed"), Value: []byte("value")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	pool.Close()
+
+	if _, err := os.Stat(path + JournalExtension); !os.IsNotExist(err) {
+		t.Errorf("got error %v, expected journal to be removed", err)
+	}
+	pool = New(nil)
+	defer pool.Close()
+	c, err = pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if v, err := c.GetValue([]byte("bucket"), []byte("closed")); err != nil || string(v) != "value" {
+		t.Errorf("got value %q (%v)", v, err)
+	}
+}
+
+func TestAppendFlush(t *testing.T) {
+	pool := New(&Options{
+		Journal: &Journal{Interval: time.Millisecond, MaxEntries: 10},
+		// entries are encoded in the journal
+		Compression: &Compression{},
+	})
+	defer pool.Close()
+
+	dir := t.TempDir()
+
+	// flush on MaxEntries
+	path := filepath.Join(dir, "entries.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	var entries []JournalEntry
+	for i := 0; i < 10; i++ {
+		entries = append(entries, JournalEntry{Type: ChangePut, Bucket: []byte("bucket"), Key: []byte(fmt.Sprint(i)), Value: []byte("value")})
+	}
+	if err := pool.Append(path, entries); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := c.GetValue([]byte("bucket"), []byte("9")); err != nil || string(v) != "value" {
+		t.Errorf("got value %q (%v)", v, err)
+	}
+
+	// flush on Interval
+	path = filepath.Join(dir, "interval.db")
+	c, err = pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := pool.Append(path, entries[:1]); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		v, err := c.GetValue([]byte("bucket"), []byte("0"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(v) == "value" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("journal is not flushed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAppendReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	var data []byte
+	for i := 0; i < 3; i++ {
+		e, err := OplogEntry{Type: ChangePut, Bucket: []byte("bucket"), Key: []byte(fmt.Sprint(i)), Value: []byte("value")}.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = appendFrame(data, e)
+	}
+	// a torn write of the last entry
+	data = data[:len(data)-3]
+	if err := os.WriteFile(path+JournalExtension, data, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	pool := New(&Options{
+		Journal: &Journal{Interval: time.Hour},
+	})
+	defer pool.Close()
+
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i, want := range []string{"value", "value", ""} {
+		if v, err := c.GetValue([]byte("bucket"), []byte(fmt.Sprint(i))); err != nil || string(v) != want {
+			t.Errorf("key %d: got value %q (%v), expected %q", i, v, err, want)
+		}
+	}
+	if _, err := os.Stat(path + JournalExtension); !os.IsNotExist(err) {
+		t.Errorf("got error %v, expected journal to be removed", err)
+	}
+}
+
+func TestAppendWithoutJournal(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	if err := pool.Append(filepath.Join(t.TempDir(), "test.db"), nil); err != ErrNoJournal {
+		t.Errorf("got error %v, expected %v", err, ErrNoJournal)
+	}
+}
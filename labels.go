@@ -0,0 +1,51 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+// Labels are key/value metadata attached to a connection, like tenant IDs
+// or request origins, that are reported in stats and events.
+type Labels map[string]string
+
+// GetWithLabels returns a connection as Get does and attaches labels to it.
+// Labels are merged with labels attached by previous calls for the same
+// open database, replacing values with the same keys. Labels are discarded
+// when the database is closed.
+func (p *Pool) GetWithLabels(path string, labels Labels) (*Connection, error) {
+	return p.get(path, labels)
+}
+
+// Labels returns a copy of labels attached to the connection or nil if
+// there are none.
+func (c *Connection) Labels() Labels {
+	labels, _ := c.labels.Load().(Labels)
+	if len(labels) == 0 {
+		return nil
+	}
+	l := make(Labels, len(labels))
+	for k, v := range labels {
+		l[k] = v
+	}
+	return l
+}
+
+// addLabels merges labels with the labels attached to the connection.
+func (c *Connection) addLabels(labels Labels) {
+	if len(labels) == 0 {
+		return
+	}
+	c.labelsMu.Lock()
+	defer c.labelsMu.Unlock()
+
+	old, _ := c.labels.Load().(Labels)
+	l := make(Labels, len(old)+len(labels))
+	for k, v := range old {
+		l[k] = v
+	}
+	for k, v := range labels {
+		l[k] = v
+	}
+	c.labels.Store(l)
+}
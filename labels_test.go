@@ -0,0 +1,85 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGetWithLabels(t *testing.T) {
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+		EventsBufferSize:  100,
+	})
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.GetWithLabels(path, Labels{"tenant": "a", "origin": "api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := pool.GetWithLabels(path, Labels{"tenant": "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c2 != c {
+		t.Fatal("got a different connection for the same path")
+	}
+	want := Labels{"tenant": "b", "origin": "api"}
+	if got := c.Labels(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got labels %v, expected %v", got, want)
+	}
+	// returned labels are copies
+	c.Labels()["tenant"] = "c"
+
+	s := pool.Stats()
+	if len(s.Databases) != 1 {
+		t.Fatalf("got %d databases, expected 1", len(s.Databases))
+	}
+	if got := s.Databases[0].Labels; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got stats labels %v, expected %v", got, want)
+	}
+	c2.Close()
+	c.Close()
+	pool.Close()
+
+	var got []string
+	for e := range pool.Events() {
+		got = append(got, fmt.Sprintf("%s %v", e.Type, e.Labels))
+	}
+	wantEvents := []string{
+		"opened map[origin:api tenant:a]",
+		"reused map[origin:api tenant:b]",
+		"expiry scheduled map[origin:api tenant:b]",
+		"closed map[origin:api tenant:b]",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(wantEvents) {
+		t.Errorf("got events %q, expected %q", got, wantEvents)
+	}
+}
+
+func TestLabelsDiscarded(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.GetWithLabels(path, Labels{"tenant": "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	c, err = pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if l := c.Labels(); l != nil {
+		t.Errorf("got labels %v of a closed database", l)
+	}
+}
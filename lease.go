@@ -0,0 +1,57 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrLeaseExpired is passed to Options.ErrorHandler, wrapped with the
+// database path, when a connection returned by Pool.GetFor is released
+// because it is not closed in time.
+var ErrLeaseExpired = errors.New("boltdbpool: connection not closed before its lease expired")
+
+// Lease is a connection reference returned by Pool.GetFor that is released
+// automatically if it is not closed in time.
+type Lease struct {
+	*Connection
+
+	timer *time.Timer
+	once  sync.Once
+}
+
+// GetFor returns a connection as Get does, but releases its reference after
+// the holdFor duration if Close is not called before that, reporting
+// ErrLeaseExpired to Options.ErrorHandler. It prevents callers that fail
+// to close connections from keeping databases open forever. The connection
+// must not be used after the lease expires. Close may be called multiple
+// times, and after the lease expires, without releasing other references
+// to the database.
+func (p *Pool) GetFor(path string, holdFor time.Duration) (*Lease, error) {
+	c, err := p.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	l := &Lease{Connection: c}
+	l.timer = time.AfterFunc(holdFor, func() {
+		l.once.Do(func() {
+			c.Close()
+			p.handleError(fmt.Errorf("%w: %s held for %s", ErrLeaseExpired, c.path, holdFor))
+		})
+	})
+	return l, nil
+}
+
+// Close releases the connection reference if the lease did not expire.
+func (l *Lease) Close() {
+	l.once.Do(func() {
+		l.timer.Stop()
+		l.Connection.Close()
+	})
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGetFor(t *testing.T) {
+	errs := make(chan error, 1)
+	pool := New(&Options{
+		ErrorHandler: func(err error) {
+			errs <- err
+		},
+	})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	l, err := pool.GetFor(path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Connection != c {
+		t.Fatal("got a different connection")
+	}
+	if n := references(c); n != 2 {
+		t.Fatalf("got %d references, expected 2", n)
+	}
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrLeaseExpired) {
+			t.Errorf("got error %v, expected %v", err, ErrLeaseExpired)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("lease did not expire")
+	}
+	if n := references(c); n != 1 {
+		t.Errorf("got %d references after expiry, expected 1", n)
+	}
+
+	// closing the expired lease does not release other references
+	l.Close()
+	if n := references(c); n != 1 {
+		t.Errorf("got %d references after close, expected 1", n)
+	}
+}
+
+func TestGetForClose(t *testing.T) {
+	errs := make(chan error, 1)
+	pool := New(&Options{
+		ErrorHandler: func(err error) {
+			errs <- err
+		},
+	})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	l, err := pool.GetFor(path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	l.Close()
+	if pool.Has(path) {
+		t.Error("database is open after close")
+	}
+
+	select {
+	case err := <-errs:
+		t.Errorf("got error %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func references(c *Connection) int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.count
+}
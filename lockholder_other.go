@@ -0,0 +1,15 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package boltdbpool
+
+// lockHolder returns 0 on platforms where lock holders can not be
+// determined.
+func lockHolder(fs FS, path string) int {
+	return 0
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Maintenance configures the scheduler that periodically runs tasks for
+// every database open in the pool. Before every database is processed, the
+// scheduler waits for a quiet period if Options.Throttle is set.
+type Maintenance struct {
+	// Interval is the duration between two walks over pooled databases.
+	Interval time.Duration
+	// Tasks are executed in order for every database.
+	Tasks []MaintenanceTask
+}
+
+// MaintenanceTask is a job executed by the maintenance scheduler.
+type MaintenanceTask interface {
+	// Name identifies the task in errors.
+	Name() string
+	// Run executes the task for the database on path.
+	Run(p *Pool, path string) error
+}
+
+type maintenanceTask struct {
+	name string
+	run  func(p *Pool, path string) error
+}
+
+func (t maintenanceTask) Name() string                   { return t.name }
+func (t maintenanceTask) Run(p *Pool, path string) error { return t.run(p, path) }
+
+// CompactTask returns a task that compacts databases with no references
+// whose fragmentation is above the threshold. Compacted databases are
+// closed and reopened on the next Get.
+func CompactTask(threshold float64) MaintenanceTask {
+	return maintenanceTask{
+		name: "compact",
+		run: func(p *Pool, path string) error {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+
+			c, ok := p.connections[path]
+			if !ok {
+				return nil
+			}
+			c.mu.RLock()
+			defer c.mu.RUnlock()
+
+			if c.count == 0 {
+				p.removeAndCompact(c, threshold, false)
+			}
+			return nil
+		},
+	}
+}
+
+// CheckTask returns a task that runs bolt's consistency check on databases.
+func CheckTask() MaintenanceTask {
+	return maintenanceTask{
+		name: "check",
+		run: func(p *Pool, path string) error {
+			c := p.acquire(path)
+			if c == nil {
+				return nil
+			}
+			defer c.release()
+
+			return c.check()
+		},
+	}
+}
+
+// StatsTask returns a task that passes database stats to the function.
+func StatsTask(fn func(DatabaseStats)) MaintenanceTask {
+	return maintenanceTask{
+		name: "stats",
+		run: func(p *Pool, path string) error {
+			c := p.acquire(path)
+			if c == nil {
+				return nil
+			}
+			defer c.release()
+
+			s := c.stats()
+			// exclude the reference held by this task
+			s.References--
+			fn(s)
+			return nil
+		},
+	}
+}
+
+// BackupTask returns a task that stores backups of databases to the target.
+func BackupTask(target BackupTarget) MaintenanceTask {
+	return maintenanceTask{
+		name: "backup",
+		run: func(p *Pool, path string) error {
+			c := p.acquire(path)
+			if c == nil {
+				return nil
+			}
+			defer c.release()
+
+			return storeBackup(target, p.backupName(path), func(w io.Writer) error {
+				_, err := c.Backup(w)
+				return err
+			})
+		},
+	}
+}
+
+// maintain runs maintenance tasks every interval until the pool is closed.
+func (p *Pool) maintain(m *Maintenance) {
+	defer p.background.Done()
+
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.quit:
+			return
+		}
+		p.mu.RLock()
+		paths := make([]string, 0, len(p.connections))
+		for path := range p.connections {
+			paths = append(paths, path)
+		}
+		p.mu.RUnlock()
+		sort.Strings(paths)
+
+		if p.workers != nil {
+			p.runParallel(paths, func(path string) {
+				p.throttle()
+				p.runTasks(m, path)
+			})
+			continue
+		}
+		for _, path := range paths {
+			p.throttle()
+			p.runTasks(m, path)
+		}
+	}
+}
+
+// runTasks runs maintenance tasks in order for the database on path.
+func (p *Pool) runTasks(m *Maintenance, path string) {
+	for _, t := range m.Tasks {
+		if p.isClosed() {
+			return
+		}
+		if err := p.protect("maintenance task "+t.Name(), func() error {
+			return t.Run(p, path)
+		}); err != nil {
+			p.handleError(fmt.Errorf("boltdbpool: maintenance %s %s: %w", t.Name(), path, err))
+		}
+	}
+}
+
+// acquire returns an open connection with incremented reference count
+// without changing its expiration time, or nil if the database is not open.
+// Connection must be released with the release method.
+func (p *Pool) acquire(path string) *Connection {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c, ok := p.connections[path]
+	if !ok {
+		return nil
+	}
+	c.mu.Lock()
+	c.count++
+	c.mu.Unlock()
+	return c
+}
+
+// release decrements the reference count incremented by acquire and
+// triggers the expiration if the database is not referenced any more. If
+// the last reference returned by Get was closed while the connection was
+// acquired, the connection is expired as Connection.Close would do.
+func (c *Connection) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.decrement()
+	if c.count > 0 {
+		return
+	}
+	if c.closeTime.IsZero() {
+		c.expire()
+		return
+	}
+	select {
+	case c.pool.removeTrigger <- struct{}{}:
+	default:
+	}
+	c.pool.triggerMemoryBudget()
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestMaintenance(t *testing.T) {
+	dir := t.TempDir()
+	target := DirBackupTarget{Dir: t.TempDir()}
+
+	var mu sync.Mutex
+	stats := map[string]DatabaseStats{}
+	pool := New(&Options{
+		BackupRoot:        dir,
+		ConnectionExpires: time.Hour,
+		Maintenance: &Maintenance{
+			Interval: 10 * time.Millisecond,
+			Tasks: []MaintenanceTask{
+				CheckTask(),
+				StatsTask(func(s DatabaseStats) {
+					mu.Lock()
+					stats[s.Path] = s
+					mu.Unlock()
+				}),
+				BackupTask(target),
+				CompactTask(0.5),
+			},
+		},
+	})
+	defer pool.Close()
+
+	active := filepath.Join(dir, "active.db")
+	c, err := pool.Get(active)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	idle := filepath.Join(dir, "idle.db")
+	c, err = pool.Get(idle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fragment(t, c.DB)
+	c.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(stats)
+		mu.Unlock()
+		if n == 2 && !pool.Has(idle) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("maintenance not done: %d stats, idle database open %v", n, pool.Has(idle))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	if s := stats[active]; s.References != 1 {
+		t.Errorf("got %d references, expected 1", s.References)
+	}
+	mu.Unlock()
+	if _, err := os.Stat(filepath.Join(target.Dir, "active.db")); err != nil {
+		t.Error(err)
+	}
+	if !pool.Has(active) {
+		t.Error("active database closed")
+	}
+}
+
+func TestGetClosed(t *testing.T) {
+	pool := New(nil)
+	pool.Close()
+
+	if _, err := pool.Get(filepath.Join(t.TempDir(), "test.db")); !errors.Is(err, ErrClosed) {
+		t.Errorf("got error %v, expected %v", err, ErrClosed)
+	}
+}
+
+func TestReleaseAfterClose(t *testing.T) {
+	for _, expires := range []time.Duration{0, time.Hour} {
+		t.Run(expires.String(), func(t *testing.T) {
+			var mu sync.Mutex
+			now := time.Now()
+			pool := New(&Options{
+				ConnectionExpires: expires,
+				Now: func() time.Time {
+					mu.Lock()
+					defer mu.Unlock()
+					return now
+				},
+			})
+			defer pool.Close()
+
+			path := filepath.Join(t.TempDir(), "db")
+			c, err := pool.Get(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// a maintenance task holds the reference while the last
+			// connection returned by Get is closed
+			a := pool.acquire(path)
+			if a == nil {
+				t.Fatal("database not acquired")
+			}
+			c.Close()
+			if !pool.Has(path) {
+				t.Fatal("acquired database closed")
+			}
+			a.release()
+
+			if expires > 0 {
+				if !pool.Has(path) {
+					t.Fatal("database closed before expiration")
+				}
+				mu.Lock()
+				now = now.Add(2 * expires)
+				mu.Unlock()
+				pool.CloseExpired()
+			}
+			if pool.Has(path) {
+				t.Fatal("released database not closed")
+			}
+			if err := c.DB.View(func(*bolt.Tx) error { return nil }); err != bolt.ErrDatabaseNotOpen {
+				t.Errorf("got error %v, expected %v", err, bolt.ErrDatabaseNotOpen)
+			}
+		})
+	}
+}
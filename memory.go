@@ -0,0 +1,126 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"sort"
+)
+
+// MemoryUsage returns the sum of sizes of database files that are open in
+// the pool, including databases parked open, which approximates the address
+// space used by their memory maps.
+func (p *Pool) MemoryUsage() int64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	total := p.parkedSize()
+	for _, c := range p.connections {
+		total += p.fileSize(c.path)
+	}
+	return total
+}
+
+// fileSize returns the size of the file on path or 0 if it can not be
+// determined.
+func (p *Pool) fileSize(path string) int64 {
+	info, err := p.fs.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// triggerMemoryBudget schedules enforcement of Options.MemoryBudget in the
+// background, without blocking.
+func (p *Pool) triggerMemoryBudget() {
+	if p.budgetTrigger == nil {
+		return
+	}
+	select {
+	case p.budgetTrigger <- struct{}{}:
+	default:
+	}
+}
+
+// watchMemoryBudget enforces Options.MemoryBudget when connections are
+// released until the pool is closed.
+func (p *Pool) watchMemoryBudget() {
+	defer p.background.Done()
+
+	for {
+		select {
+		case <-p.budgetTrigger:
+		case <-p.quit:
+			return
+		}
+		p.mu.Lock()
+		if !p.isClosed() {
+			p.enforceMemoryBudget()
+		}
+		p.mu.Unlock()
+	}
+}
+
+// enforceMemoryBudget closes databases parked open and then evicts idle
+// databases, in the order of Options.EvictionPolicy, while the sum of sizes
+// of open database files is above Options.MemoryBudget. Pool lock must be
+// held.
+func (p *Pool) enforceMemoryBudget() {
+	if p.options.MemoryBudget <= 0 {
+		return
+	}
+	total := p.parkedSize()
+	for _, c := range p.connections {
+		total += p.fileSize(c.path)
+	}
+	total = p.closeParkedOverBudget(total)
+	// without expiration, databases are closed when they are released,
+	// and connection locks must not be acquired as Connection.Close holds
+	// them while it waits for the pool lock
+	if total <= p.options.MemoryBudget || p.options.ConnectionExpires == 0 {
+		return
+	}
+	type candidate struct {
+		EvictionCandidate
+		c *Connection
+	}
+	var idle []candidate
+	for _, c := range p.connections {
+		size := p.fileSize(c.path)
+		c.mu.RLock()
+		count := c.count
+		c.mu.RUnlock()
+		if count == 0 {
+			idle = append(idle, candidate{
+				EvictionCandidate: EvictionCandidate{
+					Path:   c.path,
+					Size:   size,
+					Usage:  c.Usage(),
+					Labels: c.Labels(),
+				},
+				c: c,
+			})
+		}
+	}
+	policy := p.evictionPolicy()
+	if err := p.protect("EvictionPolicy", func() error {
+		sort.Slice(idle, func(i, j int) bool {
+			return policy.Less(idle[i].EvictionCandidate, idle[j].EvictionCandidate)
+		})
+		return nil
+	}); err != nil {
+		p.handleError(err)
+		return
+	}
+	for _, e := range idle {
+		if total <= p.options.MemoryBudget {
+			return
+		}
+		if p.evictLocked(e.c) {
+			total -= e.Size
+		}
+	}
+}
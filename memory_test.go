@@ -0,0 +1,97 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{
+		filepath.Join(dir, "a.db"),
+		filepath.Join(dir, "b.db"),
+		filepath.Join(dir, "c.db"),
+	}
+
+	pool := New(nil)
+	c, err := pool.Get(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := fileSize(t, paths[0])
+	c.Close()
+	pool.Close()
+
+	pool = New(&Options{
+		ConnectionExpires: time.Hour,
+		MemoryBudget:      2*size + size/2,
+	})
+	defer pool.Close()
+
+	for _, path := range paths[:2] {
+		c, err := pool.Get(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+	}
+	if got := pool.MemoryUsage(); got != 2*size {
+		t.Errorf("got memory usage %d, expected %d", got, 2*size)
+	}
+
+	c, err = pool.Get(paths[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if pool.Has(paths[0]) {
+		t.Error("least recently released database is not evicted")
+	}
+	for _, path := range paths[1:] {
+		if !pool.Has(path) {
+			t.Errorf("database %s is evicted", path)
+		}
+	}
+}
+
+func TestMemoryBudgetInUse(t *testing.T) {
+	dir := t.TempDir()
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+		MemoryBudget:      1,
+	})
+	defer pool.Close()
+
+	a, err := pool.Get(filepath.Join(dir, "a.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := pool.Get(filepath.Join(dir, "b.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if !pool.Has(a.path) {
+		t.Error("database in use is evicted")
+	}
+
+	// released database is evicted in the background
+	a.Close()
+	timeout := time.After(5 * time.Second)
+	for pool.Has(a.path) {
+		select {
+		case <-timeout:
+			t.Fatal("released database is not evicted")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	if !pool.Has(b.path) {
+		t.Error("database in use is evicted")
+	}
+}
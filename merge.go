@@ -0,0 +1,61 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"fmt"
+)
+
+// ConflictResolver returns the value that is stored for the key in the
+// bucket on the slash-separated bucket path when the key exists in the
+// destination database with the value dst and is copied from the source
+// database with the value src. Returning an error stops the copy. Values
+// are valid only during the call.
+type ConflictResolver func(bucket, key, dst, src []byte) ([]byte, error)
+
+// KeepDestination is a ConflictResolver that keeps existing values in the
+// destination database.
+func KeepDestination(bucket, key, dst, src []byte) ([]byte, error) {
+	return dst, nil
+}
+
+// ReplaceDestination is a ConflictResolver that replaces existing values in
+// the destination database with copied values.
+func ReplaceDestination(bucket, key, dst, src []byte) ([]byte, error) {
+	return src, nil
+}
+
+// MergeInto copies all buckets from databases on srcPaths to the database on
+// dstPath, in the order of srcPaths, as CopyBucket does for each bucket. Key
+// conflicts are resolved with Options.ConflictResolver, and values from
+// later databases replace earlier ones if it is not set. Source databases
+// must exist and are not changed. The destination database is created if
+// needed. The merge is not atomic, and data from source databases that are
+// merged before an error remain in the destination.
+func (p *Pool) MergeInto(dstPath string, srcPaths ...string) error {
+	if p.options.ReadOnly {
+		return ErrReadOnly
+	}
+	for _, srcPath := range srcPaths {
+		if err := p.mergeOne(dstPath, srcPath); err != nil {
+			return fmt.Errorf("boltdbpool: merge %s into %s: %w", srcPath, dstPath, err)
+		}
+	}
+	return nil
+}
+
+// mergeOne copies all buckets from the database on srcPath to the database
+// on dstPath.
+func (p *Pool) mergeOne(dstPath, srcPath string) error {
+	src, dst, err := p.getCopyDatabases(srcPath, dstPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	defer dst.Close()
+
+	return p.copyBuckets(src, dst, nil)
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestMergeInto(t *testing.T) {
+	var conflicts []string
+	pool := New(&Options{
+		ConflictResolver: func(bucket, key, dst, src []byte) ([]byte, error) {
+			conflicts = append(conflicts, fmt.Sprintf("%s:%s", bucket, key))
+			a, _ := strconv.Atoi(string(dst))
+			b, _ := strconv.Atoi(string(src))
+			return []byte(strconv.Itoa(a + b)), nil
+		},
+	})
+	defer pool.Close()
+
+	dir := t.TempDir()
+	dstPath := filepath.Join(dir, "archive.db")
+	var srcPaths []string
+	for i := 1; i <= 3; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("day%d.db", i))
+		if err := pool.With(path, func(db *bolt.DB) error {
+			return db.Update(func(tx *bolt.Tx) error {
+				b, err := createBucket(tx, []byte("counts/pages"))
+				if err != nil {
+					return err
+				}
+				if err := b.Put([]byte("index"), []byte(strconv.Itoa(i))); err != nil {
+					return err
+				}
+				day, err := tx.CreateBucket([]byte(fmt.Sprintf("day/%d", i)))
+				if err != nil {
+					return err
+				}
+				return day.Put([]byte("k"), []byte("v"))
+			})
+		}); err != nil {
+			t.Fatal(err)
+		}
+		srcPaths = append(srcPaths, path)
+	}
+
+	if err := pool.MergeInto(dstPath, srcPaths...); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pool.With(dstPath, func(db *bolt.DB) error {
+		return db.View(func(tx *bolt.Tx) error {
+			if v := string(bucket(tx, []byte("counts/pages")).Get([]byte("index"))); v != "6" {
+				t.Errorf("got value %q, expected 6", v)
+			}
+			for i := 1; i <= 3; i++ {
+				if tx.Bucket([]byte(fmt.Sprintf("day/%d", i))) == nil {
+					t.Errorf("root bucket day/%d not merged", i)
+				}
+			}
+			return nil
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := "[counts/pages:index counts/pages:index]"
+	if got := fmt.Sprint(conflicts); got != want {
+		t.Errorf("got conflicts %s, expected %s", got, want)
+	}
+}
+
+func TestMergeIntoResolvers(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		resolver ConflictResolver
+		want     string
+	}{
+		{name: "default", want: "b"},
+		{name: "keep", resolver: KeepDestination, want: "a"},
+		{name: "replace", resolver: ReplaceDestination, want: "b"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pool := New(&Options{ConflictResolver: tc.resolver})
+			defer pool.Close()
+
+			dir := t.TempDir()
+			dstPath := filepath.Join(dir, "dst.db")
+			srcPath := filepath.Join(dir, "src.db")
+			for path, value := range map[string]string{dstPath: "a", srcPath: "b"} {
+				c, err := pool.GetWithBuckets(path, []byte("bucket"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := c.Update(func(tx *bolt.Tx) error {
+					return tx.Bucket([]byte("bucket")).Put([]byte("key"), []byte(value))
+				}); err != nil {
+					t.Fatal(err)
+				}
+				c.Close()
+			}
+
+			if err := pool.MergeInto(dstPath, srcPath); err != nil {
+				t.Fatal(err)
+			}
+			if err := pool.With(dstPath, func(db *bolt.DB) error {
+				return db.View(func(tx *bolt.Tx) error {
+					if v := string(tx.Bucket([]byte("bucket")).Get([]byte("key"))); v != tc.want {
+						t.Errorf("got value %q, expected %q", v, tc.want)
+					}
+					return nil
+				})
+			}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestMergeIntoResolverError(t *testing.T) {
+	errConflict := errors.New("conflict")
+	pool := New(&Options{
+		ConflictResolver: func(bucket, key, dst, src []byte) ([]byte, error) {
+			return nil, errConflict
+		},
+	})
+	defer pool.Close()
+
+	dir := t.TempDir()
+	dstPath := filepath.Join(dir, "dst.db")
+	srcPath := filepath.Join(dir, "src.db")
+	for _, path := range []string{dstPath, srcPath} {
+		c, err := pool.GetWithBuckets(path, []byte("bucket"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket([]byte("bucket")).Put([]byte("key"), []byte(path))
+		}); err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+	}
+
+	if err := pool.MergeInto(dstPath, srcPath); !errors.Is(err, errConflict) {
+		t.Errorf("got error %v, expected %v", err, errConflict)
+	}
+	if err := pool.MergeInto(dstPath, filepath.Join(dir, "missing.db")); err == nil {
+		t.Error("merged missing database")
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package migrate applies ordered schema migrations to BoltDB databases.
+// A Migrator can be set as boltdbpool.Options.OnOpen to migrate every
+// database when it is opened by the pool.
+//
+// Example:
+//
+//	m, err := migrate.New(
+//		migrate.Migration{Name: "create users", Up: createUsers},
+//		migrate.Migration{Name: "index emails", Up: indexEmails},
+//	)
+//	if err != nil {
+//		...
+//	}
+//	pool := boltdbpool.New(&boltdbpool.Options{
+//		OnOpen: m.OnOpen,
+//	})
+package migrate // import "resenje.org/boltdbpool/migrate"
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// ErrInvalidMigration is returned by New for migrations without the Up
+	// function.
+	ErrInvalidMigration = errors.New("invalid migration")
+	// ErrUnknownVersion is returned when the database has a schema version
+	// greater than the number of registered migrations, which means that it
+	// was migrated by a newer version of the program.
+	ErrUnknownVersion = errors.New("unknown schema version")
+)
+
+// Bucket is the name of the bucket that holds the schema version.
+var Bucket = []byte("migrations")
+
+var versionKey = []byte("version")
+
+// Migration changes the database schema.
+type Migration struct {
+	// Name describes the migration in errors.
+	Name string
+	// Up applies the migration.
+	Up func(tx *bolt.Tx) error
+}
+
+// MigrationError is returned when a migration fails.
+type MigrationError struct {
+	Version uint64
+	Name    string
+	Err     error
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("migrate: migration %d %q: %v", e.Version, e.Name, e.Err)
+}
+
+// Unwrap returns the error returned by the migration.
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+// Migrator applies migrations in order. The schema version of a database
+// is the number of migrations applied to it.
+type Migrator struct {
+	migrations []Migration
+}
+
+// New returns a Migrator for the ordered migrations. Migrations must only be
+// appended in new program versions, never reordered or removed.
+func New(migrations ...Migration) (*Migrator, error) {
+	for _, m := range migrations {
+		if m.Up == nil {
+			return nil, ErrInvalidMigration
+		}
+	}
+	return &Migrator{
+		migrations: migrations,
+	}, nil
+}
+
+// Latest returns the schema version after all migrations are applied.
+func (m *Migrator) Latest() uint64 {
+	return uint64(len(m.migrations))
+}
+
+// Version returns the schema version of the database.
+func (m *Migrator) Version(db *bolt.DB) (version uint64, err error) {
+	err = db.View(func(tx *bolt.Tx) error {
+		version, err = readVersion(tx)
+		return err
+	})
+	return version, err
+}
+
+// Apply applies pending migrations to the database. Every migration is
+// executed in a separate transaction together with the update of the schema
+// version, so that a failed migration leaves the database at the previous
+// version.
+func (m *Migrator) Apply(db *bolt.DB) error {
+	version, err := m.Version(db)
+	if err != nil {
+		return err
+	}
+	if version > m.Latest() {
+		return fmt.Errorf("%w %d", ErrUnknownVersion, version)
+	}
+	for ; version < m.Latest(); version++ {
+		migration := m.migrations[version]
+		if err := db.Update(func(tx *bolt.Tx) error {
+			if err := migration.Up(tx); err != nil {
+				return &MigrationError{Version: version + 1, Name: migration.Name, Err: err}
+			}
+			return writeVersion(tx, version+1)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OnOpen applies pending migrations. It has the signature of
+// boltdbpool.Options.OnOpen.
+func (m *Migrator) OnOpen(path string, db *bolt.DB) error {
+	if err := m.Apply(db); err != nil {
+		return fmt.Errorf("migrate %s: %w", path, err)
+	}
+	return nil
+}
+
+func readVersion(tx *bolt.Tx) (uint64, error) {
+	b := tx.Bucket(Bucket)
+	if b == nil {
+		return 0, nil
+	}
+	v := b.Get(versionKey)
+	if v == nil {
+		return 0, nil
+	}
+	if len(v) != 8 {
+		return 0, fmt.Errorf("migrate: invalid version length %d", len(v))
+	}
+	return binary.BigEndian.Uint64(v), nil
+}
+
+func writeVersion(tx *bolt.Tx, version uint64) error {
+	b, err := tx.CreateBucketIfNotExists(Bucket)
+	if err != nil {
+		return err
+	}
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, version)
+	return b.Put(versionKey, v)
+}
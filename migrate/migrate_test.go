@@ -0,0 +1,128 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package migrate
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+
+	"resenje.org/boltdbpool"
+)
+
+func TestMigrator(t *testing.T) {
+	calls := 0
+	migrations := []Migration{
+		{
+			Name: "create users",
+			Up: func(tx *bolt.Tx) error {
+				calls++
+				_, err := tx.CreateBucket([]byte("users"))
+				return err
+			},
+		},
+		{
+			Name: "create settings",
+			Up: func(tx *bolt.Tx) error {
+				calls++
+				_, err := tx.CreateBucket([]byte("settings"))
+				return err
+			},
+		},
+	}
+	m, err := New(migrations...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := boltdbpool.New(&boltdbpool.Options{
+		OnOpen: m.OnOpen,
+	})
+	defer pool.Close()
+
+	dir := t.TempDir()
+	for _, name := range []string{"a.db", "b.db", "a.db"} {
+		c, err := pool.Get(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, err := m.Version(c.DB); err != nil || v != 2 {
+			t.Errorf("%s: got version %d (%v), expected 2", name, v, err)
+		}
+		c.Close()
+	}
+	if calls != 4 {
+		t.Errorf("got %d migration calls, expected 4", calls)
+	}
+
+	// a newer program version appends a migration
+	m2, err := New(append(migrations, Migration{
+		Name: "broken",
+		Up: func(tx *bolt.Tx) error {
+			if _, err := tx.CreateBucket([]byte("partial")); err != nil {
+				return err
+			}
+			return errors.New("broken")
+		},
+	})...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool2 := boltdbpool.New(&boltdbpool.Options{
+		OnOpen: m2.OnOpen,
+	})
+	defer pool2.Close()
+
+	path := filepath.Join(dir, "a.db")
+	_, err = pool2.Get(path)
+	var merr *MigrationError
+	if !errors.As(err, &merr) || merr.Version != 3 || merr.Name != "broken" {
+		t.Fatalf("got error %v", err)
+	}
+	if pool2.Has(path) {
+		t.Error("database that failed migration is in the pool")
+	}
+
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.View(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte("partial")) != nil {
+			t.Error("failed migration is not rolled back")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigratorUnknownVersion(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "test.db"), 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		return writeVersion(tx, 5)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(Migration{Up: func(*bolt.Tx) error { return nil }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Apply(db); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("got error %v, expected %v", err, ErrUnknownVersion)
+	}
+	if _, err := New(Migration{Name: "nil"}); !errors.Is(err, ErrInvalidMigration) {
+		t.Errorf("got error %v, expected %v", err, ErrInvalidMigration)
+	}
+}
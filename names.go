@@ -0,0 +1,79 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"sort"
+)
+
+var (
+	// ErrUnknownName is returned by Pool.GetNamed when no database is
+	// registered under the name.
+	ErrUnknownName = errors.New("boltdbpool: unknown database name")
+	// ErrInvalidName is returned by Pool.Register for an empty name.
+	ErrInvalidName = errors.New("boltdbpool: invalid database name")
+)
+
+// Register maps the logical database name to the database file path. If
+// the name is already registered, its path is replaced, so that locations
+// can be remapped by configuration. Connections that are already open on
+// the previous path are not affected.
+func (p *Pool) Register(name, path string) error {
+	if name == "" {
+		return ErrInvalidName
+	}
+	path, err := p.normalizePath(path)
+	if err != nil {
+		return err
+	}
+
+	p.namesMu.Lock()
+	defer p.namesMu.Unlock()
+
+	p.names[name] = path
+	return nil
+}
+
+// Unregister removes the mapping for the logical database name.
+func (p *Pool) Unregister(name string) {
+	p.namesMu.Lock()
+	defer p.namesMu.Unlock()
+
+	delete(p.names, name)
+}
+
+// NamedPath returns the database file path registered under the name.
+func (p *Pool) NamedPath(name string) (path string, ok bool) {
+	p.namesMu.RLock()
+	defer p.namesMu.RUnlock()
+
+	path, ok = p.names[name]
+	return path, ok
+}
+
+// Names returns sorted names of all registered databases.
+func (p *Pool) Names() []string {
+	p.namesMu.RLock()
+	defer p.namesMu.RUnlock()
+
+	names := make([]string, 0, len(p.names))
+	for name := range p.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetNamed returns a connection for the database registered under the
+// name. It behaves the same as Get for the registered path.
+func (p *Pool) GetNamed(name string) (*Connection, error) {
+	path, ok := p.NamedPath(name)
+	if !ok {
+		return nil, ErrUnknownName
+	}
+	return p.Get(path)
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNames(t *testing.T) {
+	dir := t.TempDir()
+	sessions := filepath.Join(dir, "sessions.db")
+	pool := New(&Options{
+		Names: map[string]string{
+			"sessions": sessions,
+		},
+	})
+	defer pool.Close()
+
+	c, err := pool.GetNamed("sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.DB.Path() != sessions {
+		t.Errorf("got path %s, expected %s", c.DB.Path(), sessions)
+	}
+	c.Close()
+
+	if _, err := pool.GetNamed("users"); !errors.Is(err, ErrUnknownName) {
+		t.Errorf("got error %v, expected %v", err, ErrUnknownName)
+	}
+	if err := pool.Register("", sessions); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("got error %v, expected %v", err, ErrInvalidName)
+	}
+
+	users := filepath.Join(dir, "users.db")
+	if err := pool.Register("users", users); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := pool.Names(), []string{"sessions", "users"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got names %v, expected %v", got, want)
+	}
+
+	// remap
+	moved := filepath.Join(dir, "moved", "sessions.db")
+	if err := pool.Register("sessions", moved); err != nil {
+		t.Fatal(err)
+	}
+	if path, ok := pool.NamedPath("sessions"); !ok || path != moved {
+		t.Errorf("got path %s, expected %s", path, moved)
+	}
+
+	pool.Unregister("users")
+	if _, ok := pool.NamedPath("users"); ok {
+		t.Error("unregistered name found")
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// ErrNetworkFilesystem is returned by Pool.Get, or passed to
+// Options.ErrorHandler, for databases on network filesystems, as
+// configured by Options.NetworkFilesystem.
+var ErrNetworkFilesystem = errors.New("boltdbpool: database on network filesystem")
+
+// NetworkFilesystemPolicy defines what the pool does when a database is
+// opened on a network filesystem, like NFS or CIFS, where file locks and
+// memory mapping are not reliable and bolt databases may be corrupted.
+type NetworkFilesystemPolicy int
+
+// Policies for databases on network filesystems.
+const (
+	// NetworkFilesystemAllow opens databases without checking the
+	// filesystem.
+	NetworkFilesystemAllow NetworkFilesystemPolicy = iota
+	// NetworkFilesystemWarn opens databases and passes
+	// ErrNetworkFilesystem to Options.ErrorHandler for those on network
+	// filesystems.
+	NetworkFilesystemWarn
+	// NetworkFilesystemRefuse returns ErrNetworkFilesystem from Pool.Get
+	// for databases on network filesystems.
+	NetworkFilesystemRefuse
+)
+
+// String returns the lowercase name of the policy.
+func (n NetworkFilesystemPolicy) String() string {
+	switch n {
+	case NetworkFilesystemAllow:
+		return "allow"
+	case NetworkFilesystemWarn:
+		return "warn"
+	case NetworkFilesystemRefuse:
+		return "refuse"
+	}
+	return fmt.Sprintf("NetworkFilesystemPolicy(%d)", int(n))
+}
+
+// networkFilesystem returns the name of the network filesystem of the
+// directory, or an empty string if it is a local filesystem or if it can
+// not be determined on the platform.
+var networkFilesystem = detectNetworkFilesystem
+
+// checkNetworkFilesystem applies Options.NetworkFilesystem to the database
+// on path. Filesystems that can not be inspected are assumed to be local.
+func (p *Pool) checkNetworkFilesystem(path string) error {
+	if p.options.NetworkFilesystem == NetworkFilesystemAllow {
+		return nil
+	}
+	name, err := networkFilesystem(filepath.Dir(path))
+	if err != nil || name == "" {
+		return nil
+	}
+	err = fmt.Errorf("%w: %s is on %s", ErrNetworkFilesystem, path, name)
+	if p.options.NetworkFilesystem == NetworkFilesystemRefuse {
+		return err
+	}
+	p.handleError(err)
+	return nil
+}
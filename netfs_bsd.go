@@ -0,0 +1,38 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin || freebsd
+// +build darwin freebsd
+
+package boltdbpool
+
+import "syscall"
+
+// networkFilesystemTypes are statfs filesystem type names of network
+// filesystems.
+var networkFilesystemTypes = map[string]struct{}{
+	"nfs":    {},
+	"smbfs":  {},
+	"afpfs":  {},
+	"webdav": {},
+}
+
+func detectNetworkFilesystem(dir string) (string, error) {
+	var s syscall.Statfs_t
+	if err := syscall.Statfs(dir, &s); err != nil {
+		return "", err
+	}
+	b := make([]byte, 0, len(s.Fstypename))
+	for _, c := range s.Fstypename {
+		if c == 0 {
+			break
+		}
+		b = append(b, byte(c))
+	}
+	if _, ok := networkFilesystemTypes[string(b)]; ok {
+		return string(b), nil
+	}
+	return "", nil
+}
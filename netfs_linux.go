@@ -0,0 +1,33 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package boltdbpool
+
+import "syscall"
+
+// networkFilesystemTypes are statfs magic numbers of network filesystems.
+// The type field has different sizes and signedness across architectures,
+// so it is compared as uint32.
+var networkFilesystemTypes = map[uint32]string{
+	0x6969:     "nfs",
+	0x517b:     "smb",
+	0xff534d42: "cifs",
+	0xfe534d42: "smb2",
+	0x5346414f: "afs",
+	0x73757245: "coda",
+	0x564c:     "ncp",
+	0x01021997: "9p",
+}
+
+func detectNetworkFilesystem(dir string) (string, error) {
+	var s syscall.Statfs_t
+	if err := syscall.Statfs(dir, &s); err != nil {
+		return "", err
+	}
+	return networkFilesystemTypes[uint32(s.Type)], nil
+}
@@ -0,0 +1,15 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package boltdbpool
+
+// detectNetworkFilesystem reports local filesystems on platforms where
+// filesystem types can not be determined.
+func detectNetworkFilesystem(dir string) (string, error) {
+	return "", nil
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestNetworkFilesystem(t *testing.T) {
+	defer func(f func(string) (string, error)) { networkFilesystem = f }(networkFilesystem)
+	networkFilesystem = func(string) (string, error) {
+		return "nfs", nil
+	}
+
+	dir := t.TempDir()
+
+	for _, policy := range []NetworkFilesystemPolicy{NetworkFilesystemAllow, NetworkFilesystemWarn} {
+		t.Run(policy.String(), func(t *testing.T) {
+			var errs []error
+			pool := New(&Options{
+				NetworkFilesystem: policy,
+				ErrorHandler: func(err error) {
+					errs = append(errs, err)
+				},
+			})
+			defer pool.Close()
+
+			c, err := pool.Get(filepath.Join(dir, policy.String()+".db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.Close()
+
+			warned := len(errs) == 1 && errors.Is(errs[0], ErrNetworkFilesystem)
+			if warned != (policy == NetworkFilesystemWarn) {
+				t.Errorf("got errors %v", errs)
+			}
+		})
+	}
+
+	pool := New(&Options{
+		NetworkFilesystem: NetworkFilesystemRefuse,
+	})
+	defer pool.Close()
+
+	if _, err := pool.Get(filepath.Join(dir, "refuse.db")); !errors.Is(err, ErrNetworkFilesystem) {
+		t.Errorf("got error %v, expected %v", err, ErrNetworkFilesystem)
+	}
+}
+
+func TestDetectNetworkFilesystem(t *testing.T) {
+	name, err := detectNetworkFilesystem(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "" {
+		t.Skipf("temporary directory is on %s", name)
+	}
+}
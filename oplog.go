@@ -0,0 +1,228 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"encoding/binary"
+	"errors"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// OplogBucket is the name of the bucket that holds recorded changes if
+	// Options.Oplog is set.
+	OplogBucket = []byte("boltdbpool.oplog")
+	// ReplicaBucket is the name of the bucket that holds the sequence of
+	// the last change applied with Connection.ApplyOplog.
+	ReplicaBucket = []byte("boltdbpool.replica")
+
+	replicaSequenceKey = []byte("sequence")
+)
+
+// ErrInvalidOplogEntry is returned when a recorded change can not be
+// decoded.
+var ErrInvalidOplogEntry = errors.New("boltdbpool: invalid oplog entry")
+
+// OplogEntry is a change recorded in the oplog.
+type OplogEntry struct {
+	// Sequence orders entries in the oplog of a single database.
+	Sequence uint64
+	Type     ChangeType
+	Bucket   []byte
+	Key      []byte
+	// Value is the stored value, encoded according to the pool options.
+	Value []byte
+}
+
+// MarshalBinary encodes the entry without its sequence.
+func (e OplogEntry) MarshalBinary() ([]byte, error) {
+	var l [binary.MaxVarintLen64]byte
+	b := make([]byte, 0, 1+2*len(l)+len(e.Bucket)+len(e.Key)+len(e.Value))
+	b = append(b, byte(e.Type))
+	b = append(b, l[:binary.PutUvarint(l[:], uint64(len(e.Bucket)))]...)
+	b = append(b, e.Bucket...)
+	b = append(b, l[:binary.PutUvarint(l[:], uint64(len(e.Key)))]...)
+	b = append(b, e.Key...)
+	return append(b, e.Value...), nil
+}
+
+// UnmarshalBinary decodes the entry encoded by MarshalBinary. The sequence
+// is not changed.
+func (e *OplogEntry) UnmarshalBinary(data []byte) error {
+	if len(data) < 1 {
+		return ErrInvalidOplogEntry
+	}
+	e.Type = ChangeType(data[0])
+	data = data[1:]
+	l, n := binary.Uvarint(data)
+	if n <= 0 || uint64(len(data)-n) < l {
+		return ErrInvalidOplogEntry
+	}
+	e.Bucket = append([]byte{}, data[n:n+int(l)]...)
+	data = data[n+int(l):]
+	l, n = binary.Uvarint(data)
+	if n <= 0 || uint64(len(data)-n) < l {
+		return ErrInvalidOplogEntry
+	}
+	e.Key = append([]byte{}, data[n:n+int(l)]...)
+	e.Value = nil
+	if v := data[n+int(l):]; e.Type == ChangePut {
+		e.Value = append([]byte{}, v...)
+	}
+	return nil
+}
+
+// logChange records the change in the oplog if it is enabled.
+func (p *Pool) logChange(tx *bolt.Tx, t ChangeType, bucket, key, value []byte) error {
+	if !p.options.Oplog {
+		return nil
+	}
+	b, err := tx.CreateBucketIfNotExists(OplogBucket)
+	if err != nil {
+		return err
+	}
+	seq, err := b.NextSequence()
+	if err != nil {
+		return err
+	}
+	data, err := OplogEntry{Type: t, Bucket: bucket, Key: key, Value: value}.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return b.Put(u64tob(seq), data)
+}
+
+// Oplog returns at most limit recorded changes with sequences greater than
+// after. If limit is not positive, all changes are returned.
+func (c *Connection) Oplog(after uint64, limit int) (entries []OplogEntry, err error) {
+	err = c.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(OplogBucket)
+		if b == nil {
+			return nil
+		}
+		cur := b.Cursor()
+		for k, v := cur.Seek(u64tob(after + 1)); k != nil; k, v = cur.Next() {
+			if limit > 0 && len(entries) >= limit {
+				break
+			}
+			e := OplogEntry{Sequence: binary.BigEndian.Uint64(k)}
+			if err := e.UnmarshalBinary(v); err != nil {
+				return err
+			}
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// TrimOplog removes recorded changes with sequences up to and including
+// the sequence, after they are replicated. The oplog is never trimmed by
+// the pool, so it grows without bound unless TrimOplog is called.
+func (c *Connection) TrimOplog(sequence uint64) error {
+	return c.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(OplogBucket)
+		if b == nil {
+			return nil
+		}
+		cur := b.Cursor()
+		for k, _ := cur.First(); k != nil && binary.BigEndian.Uint64(k) <= sequence; k, _ = cur.First() {
+			if err := cur.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ApplyOplog applies changes recorded in another database in a single
+// transaction and records the sequence of the last applied change, which
+// is returned by AppliedOplog. Entries with sequences that are already
+// applied are skipped. Values are stored as they are recorded, so both
+// pools must have the same encryption and compression options to read
+// them.
+func (c *Connection) ApplyOplog(entries []OplogEntry) error {
+	var applied []OplogEntry
+	err := c.Update(func(tx *bolt.Tx) error {
+		applied = applied[:0]
+		rb, err := tx.CreateBucketIfNotExists(ReplicaBucket)
+		if err != nil {
+			return err
+		}
+		var last uint64
+		if v := rb.Get(replicaSequenceKey); len(v) == 8 {
+			last = binary.BigEndian.Uint64(v)
+		}
+		for _, e := range entries {
+			if e.Sequence <= last {
+				continue
+			}
+			switch e.Type {
+			case ChangePut:
+				b, err := tx.CreateBucketIfNotExists(e.Bucket)
+				if err != nil {
+					return err
+				}
+				if err := b.Put(e.Key, e.Value); err != nil {
+					return err
+				}
+			case ChangeDelete:
+				if b := tx.Bucket(e.Bucket); b != nil {
+					if err := b.Delete(e.Key); err != nil {
+						return err
+					}
+				}
+			default:
+				return ErrInvalidOplogEntry
+			}
+			if err := c.pool.logChange(tx, e.Type, e.Bucket, e.Key, e.Value); err != nil {
+				return err
+			}
+			last = e.Sequence
+			applied = append(applied, e)
+		}
+		return rb.Put(replicaSequenceKey, u64tob(last))
+	})
+	if err != nil {
+		return err
+	}
+	for _, e := range applied {
+		c.invalidateValue(e.Bucket, e.Key)
+		var value []byte
+		if e.Type == ChangePut {
+			v, err := c.pool.DecodeValue(e.Bucket, e.Key, e.Value)
+			if err != nil {
+				// the value is applied, but it can not be reported
+				c.pool.handleError(err)
+				continue
+			}
+			value = v
+		}
+		c.notifyChange(nil, e.Type, e.Bucket, e.Key, value)
+	}
+	return nil
+}
+
+// AppliedOplog returns the sequence of the last change applied with
+// ApplyOplog.
+func (c *Connection) AppliedOplog() (sequence uint64, err error) {
+	err = c.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(ReplicaBucket); b != nil {
+			if v := b.Get(replicaSequenceKey); len(v) == 8 {
+				sequence = binary.BigEndian.Uint64(v)
+			}
+		}
+		return nil
+	})
+	return sequence, err
+}
+
+func u64tob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
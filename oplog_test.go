@@ -0,0 +1,81 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOplog(t *testing.T) {
+	pool := New(&Options{
+		Oplog: true,
+	})
+	defer pool.Close()
+
+	dir := t.TempDir()
+	c, err := pool.Get(filepath.Join(dir, "primary.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.PutValue([]byte("b"), []byte("k1"), []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PutValue([]byte("b"), []byte("k2"), []byte("v2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteValue([]byte("b"), []byte("k1")); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := c.Oplog(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []OplogEntry{
+		{Sequence: 1, Type: ChangePut, Bucket: []byte("b"), Key: []byte("k1"), Value: []byte("v1")},
+		{Sequence: 2, Type: ChangePut, Bucket: []byte("b"), Key: []byte("k2"), Value: []byte("v2")},
+		{Sequence: 3, Type: ChangeDelete, Bucket: []byte("b"), Key: []byte("k1")},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("got entries %+v, expected %+v", entries, want)
+	}
+	if entries, _ := c.Oplog(1, 1); len(entries) != 1 || entries[0].Sequence != 2 {
+		t.Errorf("got entries %+v", entries)
+	}
+
+	replica, err := pool.Get(filepath.Join(dir, "replica.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replica.Close()
+
+	// apply twice, the second time is ignored
+	for i := 0; i < 2; i++ {
+		if err := replica.ApplyOplog(entries); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if seq, err := replica.AppliedOplog(); err != nil || seq != 3 {
+		t.Errorf("got applied sequence %d (%v)", seq, err)
+	}
+	if v, _ := replica.GetValue([]byte("b"), []byte("k2")); string(v) != "v2" {
+		t.Errorf("got value %q", v)
+	}
+	if v, _ := replica.GetValue([]byte("b"), []byte("k1")); v != nil {
+		t.Errorf("got deleted value %q", v)
+	}
+
+	if err := c.TrimOplog(2); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := c.Oplog(0, 0); len(entries) != 1 || entries[0].Sequence != 3 {
+		t.Errorf("got entries %+v after trim", entries)
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidOptions is returned by Options.Validate and NewWithError for
+// options that have invalid values or that contradict each other.
+var ErrInvalidOptions = errors.New("boltdbpool: invalid options")
+
+// Validate returns an error wrapping ErrInvalidOptions that describes the
+// first invalid value or combination of options. Nil options are valid.
+func (o *Options) Validate() error {
+	if o == nil {
+		return nil
+	}
+	invalid := func(format string, a ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidOptions, fmt.Sprintf(format, a...))
+	}
+	if o.ConnectionExpires < 0 {
+		return invalid("negative ConnectionExpires %v", o.ConnectionExpires)
+	}
+	if o.ExpirySweepInterval < 0 {
+		return invalid("negative ExpirySweepInterval %v", o.ExpirySweepInterval)
+	}
+	if o.SyncInterval < 0 {
+		return invalid("negative SyncInterval %v", o.SyncInterval)
+	}
+	if o.CompactOnClose < 0 || o.CompactOnClose > 1 {
+		return invalid("CompactOnClose %v is not between 0 and 1", o.CompactOnClose)
+	}
+	if o.ErrorsBufferSize < 0 {
+		return invalid("negative ErrorsBufferSize %v", o.ErrorsBufferSize)
+	}
+	if o.MaxBackgroundWorkers < 0 {
+		return invalid("negative MaxBackgroundWorkers %v", o.MaxBackgroundWorkers)
+	}
+	if o.MaxParked < 0 {
+		return invalid("negative MaxParked %v", o.MaxParked)
+	}
+	if o.MemoryBudget < 0 {
+		return invalid("negative MemoryBudget %v", o.MemoryBudget)
+	}
+	if o.OpenDeadline < 0 {
+		return invalid("negative OpenDeadline %v", o.OpenDeadline)
+	}
+	if o.EventsBufferSize < 0 {
+		return invalid("negative EventsBufferSize %v", o.EventsBufferSize)
+	}
+	if o.CacheSize < 0 {
+		return invalid("negative CacheSize %v", o.CacheSize)
+	}
+	if o.LargeFileSize < 0 {
+		return invalid("negative LargeFileSize %v", o.LargeFileSize)
+	}
+	if o.RotateSize < 0 {
+		return invalid("negative RotateSize %v", o.RotateSize)
+	}
+	if o.LowDiskSpaceReadOnly && o.MinFreeSpace == 0 {
+		return invalid("LowDiskSpaceReadOnly requires MinFreeSpace")
+	}
+	if o.ResolveSymlinks && o.LiteralPaths {
+		return invalid("ResolveSymlinks has no effect with LiteralPaths")
+	}
+	if o.NetworkFilesystem < NetworkFilesystemAllow || o.NetworkFilesystem > NetworkFilesystemRefuse {
+		return invalid("unknown NetworkFilesystem %v", o.NetworkFilesystem)
+	}
+	if o.Permissions != nil {
+		if err := o.Permissions.validate(); err != nil {
+			return invalid("%v", err)
+		}
+	}
+	if o.Root != "" && o.LiteralPaths {
+		return invalid("Root can not be used with LiteralPaths")
+	}
+	if m := o.Maintenance; m != nil {
+		if m.Interval <= 0 {
+			return invalid("Maintenance Interval %v is not positive", m.Interval)
+		}
+		for i, t := range m.Tasks {
+			if t == nil {
+				return invalid("Maintenance task %d is nil", i)
+			}
+		}
+	}
+	if o.Throttle != nil && o.Throttle.target <= 0 {
+		return invalid("Throttle target %v is not positive", o.Throttle.target)
+	}
+	if s := o.StatsSampling; s != nil {
+		if s.Interval <= 0 {
+			return invalid("StatsSampling Interval %v is not positive", s.Interval)
+		}
+		if s.Size < 0 {
+			return invalid("negative StatsSampling Size %d", s.Size)
+		}
+	}
+	if j := o.Journal; j != nil {
+		if j.Interval <= 0 {
+			return invalid("Journal Interval %v is not positive", j.Interval)
+		}
+		if j.MaxEntries < 0 {
+			return invalid("negative Journal MaxEntries %d", j.MaxEntries)
+		}
+	}
+	if e := o.Encryption; e != nil && e.AEAD == nil {
+		if l := len(e.Key); l != 16 && l != 24 && l != 32 {
+			return invalid("Encryption Key length %d is not 16, 24 or 32", l)
+		}
+	}
+	for _, b := range o.EnsureBuckets {
+		if hasEmptyName(b) {
+			return invalid("EnsureBuckets path %q has an empty bucket name", b)
+		}
+	}
+	if o.BoltOptions != nil && o.BoltOptions.ReadOnly && !o.ReadOnly {
+		return invalid("BoltOptions ReadOnly is set without ReadOnly")
+	}
+	if o.ReadOnly {
+		switch {
+		case o.CompactOnClose > 0:
+			return invalid("CompactOnClose with ReadOnly")
+		case len(o.EnsureBuckets) > 0:
+			return invalid("EnsureBuckets with ReadOnly")
+		case o.Oplog:
+			return invalid("Oplog with ReadOnly")
+		case o.Journal != nil:
+			return invalid("Journal with ReadOnly")
+		case o.OnCorrupt != nil:
+			return invalid("OnCorrupt with ReadOnly")
+		case o.LowDiskSpaceReadOnly:
+			return invalid("LowDiskSpaceReadOnly with ReadOnly")
+		}
+	}
+	return nil
+}
+
+// hasEmptyName returns true if a slash-separated bucket path has an empty
+// bucket name.
+func hasEmptyName(path []byte) bool {
+	for _, name := range bytes.Split(path, bucketPathSeparator) {
+		if len(name) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// NewWithError creates a new pool, like New, but returns an error if the
+// options are not valid.
+func NewWithError(options *Options) (*Pool, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	return New(options), nil
+}
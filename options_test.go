@@ -0,0 +1,100 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	for name, o := range map[string]*Options{
+		"nil":   nil,
+		"empty": {},
+		"full": {
+			ConnectionExpires: time.Minute,
+			CompactOnClose:    0.5,
+			MinFreeSpace:      1024,
+			EnsureBuckets:     [][]byte{[]byte("users/by-email")},
+			Encryption:        &Encryption{Key: make([]byte, 32)},
+			Maintenance:       &Maintenance{Interval: time.Minute, Tasks: []MaintenanceTask{CheckTask()}},
+			StatsSampling:     &StatsSampling{Interval: time.Minute},
+		},
+		"read only": {ReadOnly: true, BoltOptions: &bolt.Options{ReadOnly: true}},
+	} {
+		if err := o.Validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	for name, o := range map[string]*Options{
+		"negative expires":       {ConnectionExpires: -time.Second},
+		"negative sync":          {SyncInterval: -time.Second},
+		"negative sweep":         {ExpirySweepInterval: -time.Second},
+		"compact threshold":      {CompactOnClose: 1.5},
+		"negative errors buffer": {ErrorsBufferSize: -1},
+		"negative events buffer": {EventsBufferSize: -1},
+		"negative open deadline": {OpenDeadline: -1},
+		"negative memory budget": {MemoryBudget: -1},
+		"negative max parked":    {MaxParked: -1},
+		"negative max workers":   {MaxBackgroundWorkers: -1},
+		"negative cache":         {CacheSize: -1},
+		"negative large file":    {LargeFileSize: -1},
+		"negative rotate":        {RotateSize: -1},
+		"low disk without min":   {LowDiskSpaceReadOnly: true},
+		"symlinks with literal":  {ResolveSymlinks: true, LiteralPaths: true},
+		"root with literal":      {Root: "/data", LiteralPaths: true},
+		"network filesystem":     {NetworkFilesystem: 3},
+		"file mode type bits":    {Permissions: &Permissions{FileMode: os.ModeDir | 0755}},
+		"maintenance interval":   {Maintenance: &Maintenance{Tasks: []MaintenanceTask{CheckTask()}}},
+		"maintenance nil task":   {Maintenance: &Maintenance{Interval: time.Minute, Tasks: []MaintenanceTask{nil}}},
+		"sampling interval":      {StatsSampling: &StatsSampling{Size: 10}},
+		"negative sampling":      {StatsSampling: &StatsSampling{Interval: time.Minute, Size: -1}},
+		"throttle target":        {Throttle: NewThrottle(0)},
+		"encryption key":         {Encryption: &Encryption{Key: []byte("short")}},
+		"empty bucket":           {EnsureBuckets: [][]byte{[]byte("users//by-email")}},
+		"bolt read only":         {BoltOptions: &bolt.Options{ReadOnly: true}},
+		"read only compact":      {ReadOnly: true, CompactOnClose: 0.5},
+		"read only buckets":      {ReadOnly: true, EnsureBuckets: [][]byte{[]byte("users")}},
+		"journal interval":       {Journal: &Journal{}},
+		"negative journal size":  {Journal: &Journal{Interval: time.Second, MaxEntries: -1}},
+		"read only journal":      {ReadOnly: true, Journal: &Journal{Interval: time.Second}},
+		"read only oplog":        {ReadOnly: true, Oplog: true},
+		"read only on corrupt":   {ReadOnly: true, OnCorrupt: func(Recovery) {}},
+		"read only low disk ro":  {ReadOnly: true, MinFreeSpace: 1, LowDiskSpaceReadOnly: true},
+	} {
+		if err := o.Validate(); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("%s: got error %v, expected %v", name, err, ErrInvalidOptions)
+		}
+	}
+}
+
+func TestNewWithError(t *testing.T) {
+	if _, err := NewWithError(&Options{ConnectionExpires: -1}); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("got error %v, expected %v", err, ErrInvalidOptions)
+	}
+	pool, err := NewWithError(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Close()
+
+	var handled error
+	pool = New(&Options{
+		ConnectionExpires: -1,
+		ErrorHandler: func(err error) {
+			handled = err
+		},
+	})
+	pool.Close()
+	if !errors.Is(handled, ErrInvalidOptions) {
+		t.Errorf("got handled error %v, expected %v", handled, ErrInvalidOptions)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrInvalidPageToken is returned by Pool.Page for tokens that are not
+// returned by it.
+var ErrInvalidPageToken = errors.New("boltdbpool: invalid page token")
+
+// ErrInvalidPageLimit is returned by Pool.Page when the limit is not
+// positive.
+var ErrInvalidPageLimit = errors.New("boltdbpool: invalid page limit")
+
+// pageTokenVersion is the first byte of encoded page tokens, so that their
+// format can be changed.
+const pageTokenVersion = 1
+
+// KeyValue is a key with its value returned by Pool.Page.
+type KeyValue struct {
+	Key   []byte
+	Value []byte
+}
+
+// Page returns at most limit keys with their values from the bucket of the
+// database on path, in the key order, starting after the key encoded in the
+// token, or from the first key if the token is empty. The returned token
+// resumes the iteration in a later call, in a separate read transaction,
+// and it is empty if there are no more keys. Keys that are added or removed
+// between calls are returned or skipped according to their position
+// relative to the last returned key. Values are decoded as by
+// Connection.GetValue and nested buckets are skipped. Tokens are opaque
+// strings safe for use in URLs.
+func (p *Pool) Page(path string, bucket []byte, token string, limit int) (page []KeyValue, next string, err error) {
+	if limit <= 0 {
+		return nil, "", ErrInvalidPageLimit
+	}
+	after, err := decodePageToken(token)
+	if err != nil {
+		return nil, "", err
+	}
+	more := false
+	err = p.scan(path, bucket, func(c *bolt.Cursor) ([]byte, []byte) {
+		if after == nil {
+			return c.First()
+		}
+		k, v := c.Seek(after)
+		if bytes.Equal(k, after) {
+			return c.Next()
+		}
+		return k, v
+	}, func(key []byte) bool {
+		return true
+	}, func(key, value []byte) error {
+		if len(page) == limit {
+			more = true
+			return ErrStopScan
+		}
+		page = append(page, KeyValue{
+			Key:   append([]byte{}, key...),
+			Value: append([]byte{}, value...),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if more {
+		next = encodePageToken(page[len(page)-1].Key)
+	}
+	return page, next, nil
+}
+
+// encodePageToken returns the token that resumes the iteration after the
+// key.
+func encodePageToken(key []byte) string {
+	return base64.RawURLEncoding.EncodeToString(append([]byte{pageTokenVersion}, key...))
+}
+
+// decodePageToken returns the key encoded in the token, or nil if the token
+// is empty.
+func decodePageToken(token string) ([]byte, error) {
+	if token == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(b) < 2 || b[0] != pageTokenVersion {
+		return nil, ErrInvalidPageToken
+	}
+	return b[1:], nil
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPage(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.GetWithBuckets(path, []byte("b/nested"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	var want []string
+	for i := 0; i < 7; i++ {
+		k := fmt.Sprintf("k%d", i)
+		if err := c.PutValue([]byte("b"), []byte(k), []byte("v"+k)); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, k)
+	}
+
+	var (
+		got   []string
+		token string
+		pages int
+	)
+	for {
+		page, next, err := pool.Page(path, []byte("b"), token, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, kv := range page {
+			if string(kv.Value) != "v"+string(kv.Key) {
+				t.Errorf("got value %q for key %q", kv.Value, kv.Key)
+			}
+			got = append(got, string(kv.Key))
+		}
+		if next == "" {
+			break
+		}
+		token = next
+		if pages == 1 {
+			// keys removed before the token position do not change the
+			// next page
+			if err := c.DeleteValue([]byte("b"), []byte("k1")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got keys %v, expected %v", got, want)
+	}
+	if pages != 3 {
+		t.Errorf("got %d pages, expected 3", pages)
+	}
+
+	// exactly full last page has no token
+	page, next, err := pool.Page(path, []byte("b"), "", 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 6 || next != "" {
+		t.Errorf("got %d keys and token %q, expected 6 keys and no token", len(page), next)
+	}
+
+	page, next, err = pool.Page(path, []byte("missing"), "", 10)
+	if err != nil || len(page) != 0 || next != "" {
+		t.Errorf("got %v %q (%v) for missing bucket", page, next, err)
+	}
+}
+
+func TestPageErrors(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	if _, _, err := pool.Page(path, []byte("b"), "", 0); err != ErrInvalidPageLimit {
+		t.Errorf("got error %v, expected %v", err, ErrInvalidPageLimit)
+	}
+	for _, token := range []string{"!", encodePageToken(nil), "AmFi"} {
+		if _, _, err := pool.Page(path, []byte("b"), token, 1); err != ErrInvalidPageToken {
+			t.Errorf("got error %v for token %q, expected %v", err, token, ErrInvalidPageToken)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is a panic recovered from a function provided in Options, like
+// ErrorHandler, OnOpen, OnCorrupt, OnPrefault or a maintenance task.
+type PanicError struct {
+	// Hook is the name of the function that panicked.
+	Hook  string
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("boltdbpool: panic in %s: %v", e.Hook, e.Value)
+}
+
+// protect calls fn and converts its panic to PanicError, which is passed to
+// Options.PanicHandler and returned.
+func (p *Pool) protect(hook string, fn func() error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			perr := &PanicError{
+				Hook:  hook,
+				Value: v,
+				Stack: debug.Stack(),
+			}
+			p.handlePanic(perr)
+			err = perr
+		}
+	}()
+	return fn()
+}
+
+// handlePanic calls Options.PanicHandler, ignoring its panics.
+func (p *Pool) handlePanic(err *PanicError) {
+	if p.options.PanicHandler == nil {
+		return
+	}
+	defer func() {
+		_ = recover()
+	}()
+	p.options.PanicHandler(err)
+}
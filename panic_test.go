@@ -0,0 +1,111 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestPanicErrorHandler(t *testing.T) {
+	var (
+		panics []*PanicError
+		mu     sync.Mutex
+	)
+	pool := New(&Options{
+		ErrorHandler: func(err error) {
+			panic("handler")
+		},
+		PanicHandler: func(err *PanicError) {
+			mu.Lock()
+			panics = append(panics, err)
+			mu.Unlock()
+		},
+	})
+	defer pool.Close()
+
+	pool.handleError(errors.New("test error"))
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(panics) != 1 || panics[0].Hook != "ErrorHandler" || panics[0].Value != "handler" || len(panics[0].Stack) == 0 {
+		t.Fatalf("got panics %v", panics)
+	}
+	if errs := pool.RecentErrors(); len(errs) != 2 || errs[1].Error != panics[0].Error() {
+		t.Errorf("got recent errors %v", errs)
+	}
+}
+
+func TestPanicOnOpen(t *testing.T) {
+	var perr *PanicError
+	pool := New(&Options{
+		OnOpen: func(string, *bolt.DB) error {
+			panic("open")
+		},
+		OnCorrupt: func(Recovery) {
+			t.Error("panic is handled as corruption")
+		},
+		PanicHandler: func(err *PanicError) {
+			perr = err
+		},
+	})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	_, err := pool.Get(path)
+	if !errors.As(err, &perr) || perr.Hook != "OnOpen" {
+		t.Fatalf("got error %v, expected OnOpen panic", err)
+	}
+	if pool.Has(path) {
+		t.Error("database is in the pool")
+	}
+}
+
+func TestPanicMaintenance(t *testing.T) {
+	errs := make(chan error, 10)
+	pool := New(&Options{
+		ErrorHandler: func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		},
+		Maintenance: &Maintenance{
+			Interval: time.Millisecond,
+			Tasks: []MaintenanceTask{maintenanceTask{
+				name: "panic",
+				run: func(*Pool, string) error {
+					panic("task")
+				},
+			}},
+		},
+	})
+	defer pool.Close()
+
+	c, err := pool.Get(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// the maintenance goroutine keeps running after panics
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			var perr *PanicError
+			if !errors.As(err, &perr) || perr.Hook != "maintenance task panic" {
+				t.Fatalf("got error %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("maintenance panic is not handled")
+		}
+	}
+}
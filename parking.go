@@ -0,0 +1,213 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// parkedDB is the state of a database whose connection expired, kept for
+// its revival.
+type parkedDB struct {
+	// c is the detached connection with the open database, or nil if the
+	// database is closed
+	c       *Connection
+	file    os.FileInfo
+	labels  Labels
+	usage   Usage
+	limiter *rateLimiter
+	time    time.Time
+}
+
+// Parked returns sorted paths of databases that are parked, as configured
+// by Options.MaxParked.
+func (p *Pool) Parked() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	paths := make([]string, 0, len(p.parked))
+	for path := range p.parked {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// flushParked applies pending journal entries of the detached connection
+// and syncs its database to disk, so that it can be parked open. If that
+// fails, the database is closed and false is returned.
+func (p *Pool) flushParked(c *Connection) bool {
+	var err error
+	if c.journal != nil {
+		err = c.journal.close(c)
+	}
+	if err == nil && !c.DB.IsReadOnly() {
+		if err = c.DB.Sync(); err != nil {
+			err = fmt.Errorf("boltdbpool: sync %s: %w", c.path, err)
+		}
+	}
+	if err != nil {
+		p.handleError(err)
+		p.handleError(p.closeDB(c))
+		return false
+	}
+	return true
+}
+
+// park remembers the state of the detached connection, keeping its
+// database open if open is true, and removes the least recently parked
+// databases over Options.MaxParked. Pool lock must be held.
+func (p *Pool) park(c *Connection, open bool) {
+	if p.options.MaxParked <= 0 || p.isClosed() {
+		if open {
+			p.handleError(p.closeDB(c))
+		}
+		return
+	}
+	if _, ok := p.connections[c.path]; ok {
+		if open {
+			p.handleError(p.closeDB(c))
+		}
+		return
+	}
+	info, err := p.fs.Stat(c.path)
+	if err != nil {
+		if open {
+			p.handleError(p.closeDB(c))
+		}
+		return
+	}
+	p.closeParked(c.path)
+	if len(p.parked) >= p.options.MaxParked {
+		var oldest string
+		for path, d := range p.parked {
+			if oldest == "" || d.time.Before(p.parked[oldest].time) {
+				oldest = path
+			}
+		}
+		p.closeParked(oldest)
+		delete(p.parked, oldest)
+	}
+	d := &parkedDB{
+		file:    info,
+		labels:  c.Labels(),
+		usage:   c.Usage(),
+		limiter: c.limiter,
+		time:    p.now(),
+	}
+	if open {
+		d.c = c
+	}
+	p.parked[c.path] = d
+	c.emit(EventParked, nil)
+}
+
+// unpark returns the connection of the database on path that is parked
+// open, if its file is not changed since, so that it can be revived
+// without opening the database again. A parked database whose file is
+// changed is closed and nil is returned. Pool lock must be held.
+func (p *Pool) unpark(path string) *Connection {
+	d, ok := p.parked[path]
+	if !ok || d.c == nil {
+		return nil
+	}
+	info, err := p.fs.Stat(path)
+	if err != nil || !os.SameFile(d.file, info) || info.Size() != d.file.Size() || !info.ModTime().Equal(d.file.ModTime()) {
+		p.closeParked(path)
+		return nil
+	}
+	c := d.c
+	delete(p.parked, path)
+	return c
+}
+
+// closeParked closes the database on path if it is parked open, keeping
+// its parked state. It must be called before the database file is changed
+// by the pool. Pool lock must be held.
+func (p *Pool) closeParked(path string) {
+	d, ok := p.parked[path]
+	if !ok || d.c == nil {
+		return
+	}
+	p.handleError(p.closeDB(d.c))
+	d.c = nil
+}
+
+// parkedSize returns the sum of sizes of database files that are parked
+// open. Pool lock must be held.
+func (p *Pool) parkedSize() int64 {
+	var total int64
+	for _, d := range p.parked {
+		if d.c != nil {
+			total += d.file.Size()
+		}
+	}
+	return total
+}
+
+// closeParkedOverBudget closes least recently parked databases that are
+// parked open while the total size of open database files is above
+// Options.MemoryBudget and returns the remaining total. Pool lock must be
+// held.
+func (p *Pool) closeParkedOverBudget(total int64) int64 {
+	if total <= p.options.MemoryBudget {
+		return total
+	}
+	paths := make([]string, 0, len(p.parked))
+	for path, d := range p.parked {
+		if d.c != nil {
+			paths = append(paths, path)
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return p.parked[paths[i]].time.Before(p.parked[paths[j]].time)
+	})
+	for _, path := range paths {
+		if total <= p.options.MemoryBudget {
+			break
+		}
+		total -= p.parked[path].file.Size()
+		p.closeParked(path)
+	}
+	return total
+}
+
+// detachParked returns connections of all databases that are parked open
+// and forgets all parked databases. Pool lock must be held.
+func (p *Pool) detachParked() []*Connection {
+	var connections []*Connection
+	for path, d := range p.parked {
+		if d.c != nil {
+			connections = append(connections, d.c)
+		}
+		delete(p.parked, path)
+	}
+	sort.Slice(connections, func(i, j int) bool {
+		return connections[i].path < connections[j].path
+	})
+	return connections
+}
+
+// revive restores the parked state of the database to its new connection
+// and returns true if the database was parked. Pool lock must be held.
+func (p *Pool) revive(c *Connection) bool {
+	d, ok := p.parked[c.path]
+	if !ok {
+		return false
+	}
+	delete(p.parked, c.path)
+	c.addLabels(d.labels)
+	c.usage.mu.Lock()
+	c.usage.Usage = d.usage
+	c.usage.mu.Unlock()
+	if d.limiter != nil {
+		c.limiter = d.limiter
+	}
+	return true
+}
@@ -0,0 +1,297 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestParking(t *testing.T) {
+	now := time.Now()
+	var prefaults int32
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+		MaxParked:         1,
+		EventsBufferSize:  100,
+		Prefault:          true,
+		OnPrefault: func(path string, err error) {
+			atomic.AddInt32(&prefaults, 1)
+		},
+		Now: func() time.Time {
+			return now
+		},
+	})
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+	c, err := pool.GetWithLabels(path, Labels{"tenant": "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := c.DB
+	c.Close()
+
+	now = now.Add(2 * time.Hour)
+	pool.CloseExpired()
+	if pool.Has(path) {
+		t.Fatal("expired database is in the pool")
+	}
+	if err := db.View(func(*bolt.Tx) error { return nil }); err != nil {
+		t.Fatalf("parked database is closed: %v", err)
+	}
+	if got := fmt.Sprint(pool.Parked()); got != fmt.Sprint([]string{path}) {
+		t.Errorf("got parked %s, expected [%s]", got, path)
+	}
+
+	c, err = pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.DB != db {
+		t.Error("parked database is opened again")
+	}
+	if got := c.Labels()["tenant"]; got != "a" {
+		t.Errorf("got revived label %q, expected %q", got, "a")
+	}
+	if got := c.Usage().Gets; got != 2 {
+		t.Errorf("got revived gets %d, expected 2", got)
+	}
+	if l := len(pool.Parked()); l != 0 {
+		t.Errorf("got %d parked databases after revival, expected 0", l)
+	}
+	c.Close()
+	pool.Close()
+
+	if got := atomic.LoadInt32(&prefaults); got != 1 {
+		t.Errorf("got %d prefaults, expected 1", got)
+	}
+	var got []EventType
+	for e := range pool.Events() {
+		got = append(got, e.Type)
+	}
+	want := []EventType{
+		EventOpened,
+		EventExpiryScheduled,
+		EventExpired,
+		EventParked,
+		EventRevived,
+		EventExpiryScheduled,
+		EventClosed,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got events %v, expected %v", got, want)
+	}
+}
+
+func TestParkingChanged(t *testing.T) {
+	now := time.Now()
+	prefaulted := make(chan struct{}, 2)
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+		MaxParked:         2,
+		Prefault:          true,
+		OnPrefault: func(path string, err error) {
+			prefaulted <- struct{}{}
+		},
+		Now: func() time.Time {
+			return now
+		},
+	})
+	defer pool.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	<-prefaulted
+
+	now = now.Add(2 * time.Hour)
+	pool.CloseExpired()
+
+	// the file is modified by another process
+	mtime := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	select {
+	case <-prefaulted:
+	case <-time.After(5 * time.Second):
+		t.Error("changed parked database is not prefaulted")
+	}
+}
+
+func TestParkingLimit(t *testing.T) {
+	now := time.Now()
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+		MaxParked:         2,
+		Now: func() time.Time {
+			return now
+		},
+	})
+	defer pool.Close()
+
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b", "c"} {
+		c, err := pool.Get(filepath.Join(dir, name+".db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+		now = now.Add(2 * time.Hour)
+		pool.CloseExpired()
+	}
+	want := []string{filepath.Join(dir, "b.db"), filepath.Join(dir, "c.db")}
+	if got := pool.Parked(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got parked %v, expected %v", got, want)
+	}
+}
+
+func TestParkingClose(t *testing.T) {
+	now := time.Now()
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+		MaxParked:         2,
+		Now: func() time.Time {
+			return now
+		},
+	})
+
+	dir := t.TempDir()
+	var dbs []*bolt.DB
+	for _, name := range []string{"a", "b", "c"} {
+		c, err := pool.Get(filepath.Join(dir, name+".db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dbs = append(dbs, c.DB)
+		c.Close()
+		now = now.Add(2 * time.Hour)
+		pool.CloseExpired()
+	}
+
+	// parked databases are closed when they are removed
+	if err := pool.Remove(filepath.Join(dir, "c.db")); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.CloseAll(); err != nil {
+		t.Fatal(err)
+	}
+	for i, db := range dbs {
+		if err := db.View(func(*bolt.Tx) error { return nil }); err != bolt.ErrDatabaseNotOpen {
+			t.Errorf("database %d: got error %v, expected %v", i, err, bolt.ErrDatabaseNotOpen)
+		}
+	}
+}
+
+func TestParkingShutdown(t *testing.T) {
+	now := time.Now()
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+		MaxParked:         4,
+		Now: func() time.Time {
+			return now
+		},
+	})
+
+	path := filepath.Join(t.TempDir(), "a.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := c.DB
+	c.Close()
+	now = now.Add(2 * time.Hour)
+	pool.CloseExpired()
+
+	if err := pool.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.View(func(*bolt.Tx) error { return nil }); err != bolt.ErrDatabaseNotOpen {
+		t.Errorf("got error %v, expected %v", err, bolt.ErrDatabaseNotOpen)
+	}
+	db, err = bolt.Open(path, 0666, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+}
+
+func TestParkingMemoryBudget(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{
+		filepath.Join(dir, "a.db"),
+		filepath.Join(dir, "b.db"),
+		filepath.Join(dir, "c.db"),
+	}
+
+	pool := New(nil)
+	c, err := pool.Get(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := fileSize(t, paths[0])
+	c.Close()
+	pool.Close()
+
+	now := time.Now()
+	pool = New(&Options{
+		ConnectionExpires: time.Hour,
+		MemoryBudget:      2*size + size/2,
+		MaxParked:         4,
+		Now: func() time.Time {
+			return now
+		},
+	})
+	defer pool.Close()
+
+	var dbs []*bolt.DB
+	for _, path := range paths[:2] {
+		c, err := pool.Get(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dbs = append(dbs, c.DB)
+		c.Close()
+		now = now.Add(2 * time.Hour)
+		pool.CloseExpired()
+	}
+	if got := pool.MemoryUsage(); got != 2*size {
+		t.Errorf("got memory usage %d, expected %d", got, 2*size)
+	}
+
+	c, err = pool.Get(paths[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := dbs[0].View(func(*bolt.Tx) error { return nil }); err != bolt.ErrDatabaseNotOpen {
+		t.Errorf("got error %v, expected least recently parked database to be closed", err)
+	}
+	if err := dbs[1].View(func(*bolt.Tx) error { return nil }); err != nil {
+		t.Errorf("got error %v, expected parked database to be open", err)
+	}
+	if got := pool.MemoryUsage(); got != 2*size {
+		t.Errorf("got memory usage %d, expected %d", got, 2*size)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// normalizePath returns the path that is used as the pool key for the
+// database file, according to Options.LiteralPaths,
+// Options.ResolveSymlinks and Options.Root.
+func (p *Pool) normalizePath(path string) (string, error) {
+	if p.options.LiteralPaths {
+		return path, nil
+	}
+	if p.options.Root != "" {
+		return p.rootPath(path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if !p.options.ResolveSymlinks {
+		return path, nil
+	}
+	return resolveSymlinks(path)
+}
+
+// rootPath returns the absolute path that is confined to Options.Root,
+// resolving relative paths against it.
+func (p *Pool) rootPath(path string) (string, error) {
+	root, err := filepath.Abs(p.options.Root)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	path = filepath.Clean(path)
+	if !inDir(root, path) {
+		return "", fmt.Errorf("%w: %s", ErrPathOutsideRoot, path)
+	}
+	resolvedRoot, err := resolveSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := resolveSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	if !inDir(resolvedRoot, resolved) {
+		return "", fmt.Errorf("%w: %s resolves to %s", ErrPathOutsideRoot, path, resolved)
+	}
+	if p.options.ResolveSymlinks {
+		return resolved, nil
+	}
+	return path, nil
+}
+
+// inDir returns true if the clean absolute path is the directory or if it
+// is in the directory or in one of its subdirectories.
+func inDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolveSymlinks evaluates symbolic links in the longest existing part of
+// the absolute path, as the database file and its directories may not be
+// created yet.
+func resolveSymlinks(path string) (string, error) {
+	dir, rest := path, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return path, nil
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = parent
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPathNormalization(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+
+	pool := New(nil)
+	defer pool.Close()
+
+	c1, err := pool.Get("./test.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c1.Close()
+	c2, err := pool.Get(filepath.Join(dir, "sub", "..", "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+
+	if c1 != c2 {
+		t.Error("different paths to the same file opened different connections")
+	}
+	if !pool.Has("test.db") {
+		t.Error("relative path is not in the pool")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sub")); !os.IsNotExist(err) {
+		t.Errorf("directory from unclean path created: %v", err)
+	}
+}
+
+func TestPathNormalizationSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "data"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "data"), filepath.Join(dir, "link")); err != nil {
+		t.Skip(err)
+	}
+
+	pool := New(&Options{
+		ResolveSymlinks: true,
+	})
+	defer pool.Close()
+
+	c1, err := pool.Get(filepath.Join(dir, "data", "new", "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c1.Close()
+	c2, err := pool.Get(filepath.Join(dir, "link", "new", "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+
+	if c1 != c2 {
+		t.Error("paths through a symlink opened different connections")
+	}
+}
+
+func TestLiteralPaths(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+
+	pool := New(&Options{
+		LiteralPaths: true,
+	})
+	defer pool.Close()
+
+	c, err := pool.Get("test.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if pool.Has(filepath.Join(dir, "test.db")) {
+		t.Error("literal path is normalized")
+	}
+	if !pool.Has("test.db") {
+		t.Error("literal path is not in the pool")
+	}
+}
+
+// chdir changes the working directory for the duration of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestRoot(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	if err := os.Mkdir(root, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	pool := New(&Options{
+		Root: root,
+	})
+	defer pool.Close()
+
+	c, err := pool.Get("tenants/a.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if !pool.Has(filepath.Join(root, "tenants", "a.db")) {
+		t.Error("relative path is not resolved against the root")
+	}
+
+	for _, path := range []string{
+		"../outside.db",
+		"tenants/../../outside.db",
+		filepath.Join(dir, "outside.db"),
+		root + "-sibling/a.db",
+	} {
+		if _, err := pool.Get(path); !errors.Is(err, ErrPathOutsideRoot) {
+			t.Errorf("%s: got error %v, expected %v", path, err, ErrPathOutsideRoot)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "outside.db")); !os.IsNotExist(err) {
+		t.Error("database created outside of the root")
+	}
+
+	if err := os.Symlink(dir, filepath.Join(root, "escape")); err != nil {
+		t.Skip(err)
+	}
+	for _, path := range []string{
+		"escape/outside.db",
+		"escape/new/outside.db",
+	} {
+		if _, err := pool.Get(path); !errors.Is(err, ErrPathOutsideRoot) {
+			t.Errorf("%s: got error %v, expected %v", path, err, ErrPathOutsideRoot)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "tenants"), filepath.Join(root, "inside")); err != nil {
+		t.Fatal(err)
+	}
+	c2, err := pool.Get("inside/b.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2.Close()
+}
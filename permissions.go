@@ -0,0 +1,137 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+)
+
+// ErrPermissionsUnsupported is returned when Options.Permissions is set and
+// the pool FS does not implement PermissionsFS.
+var ErrPermissionsUnsupported = errors.New("boltdbpool: filesystem does not support permissions")
+
+// Permissions are modes and the ownership that are set on database files and
+// directories created by the pool, so that they are accessible to other
+// users in deployments where processes run as different users.
+type Permissions struct {
+	// FileMode, if not 0, is set on created database files, regardless of
+	// the process umask.
+	FileMode os.FileMode
+	// DirMode, if not 0, is set on created directories, regardless of the
+	// process umask.
+	DirMode os.FileMode
+	// Owner and Group are names or numeric ids of the user and the group
+	// that are set as owners of created files and directories. Changing the
+	// owner requires privileges, like running as root or with the
+	// CAP_CHOWN capability on Linux. If empty, the owner or the group is not
+	// changed.
+	Owner string
+	Group string
+}
+
+// validate returns an error if modes have bits other than permission bits.
+func (p *Permissions) validate() error {
+	const valid = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+	if p.FileMode&^valid != 0 {
+		return fmt.Errorf("invalid Permissions.FileMode %v", p.FileMode)
+	}
+	if p.DirMode&^valid != 0 {
+		return fmt.Errorf("invalid Permissions.DirMode %v", p.DirMode)
+	}
+	return nil
+}
+
+// ids returns numeric user and group ids of Owner and Group, or -1 for
+// those that are not set.
+func (p *Permissions) ids() (uid, gid int, err error) {
+	uid, gid = -1, -1
+	if p.Owner != "" {
+		u, err := user.Lookup(p.Owner)
+		if err != nil {
+			if u, err = user.LookupId(p.Owner); err != nil {
+				return 0, 0, err
+			}
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return 0, 0, fmt.Errorf("user %s id %s is not numeric", p.Owner, u.Uid)
+		}
+	}
+	if p.Group != "" {
+		g, err := user.LookupGroup(p.Group)
+		if err != nil {
+			if g, err = user.LookupGroupId(p.Group); err != nil {
+				return 0, 0, err
+			}
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return 0, 0, fmt.Errorf("group %s id %s is not numeric", p.Group, g.Gid)
+		}
+	}
+	return uid, gid, nil
+}
+
+// PermissionsFS is an FS that can change modes and owners of files. OSFS
+// implements it. The pool FS must implement it if Options.Permissions is
+// set.
+type PermissionsFS interface {
+	FS
+	// Chmod changes the mode of the named file, like os.Chmod.
+	Chmod(name string, mode os.FileMode) error
+	// Chown changes the user and group ids of the named file, like
+	// os.Chown.
+	Chown(name string, uid, gid int) error
+}
+
+// applyFilePermissions sets the mode and the owner from Options.Permissions
+// on the database file created by the pool.
+func (p *Pool) applyFilePermissions(path string) error {
+	if p.options.Permissions == nil {
+		return nil
+	}
+	return p.applyPermissions(path, p.options.Permissions.FileMode)
+}
+
+// applyDirPermissions sets the mode and the owner from Options.Permissions
+// on directories created by the pool, ordered from the top one.
+func (p *Pool) applyDirPermissions(dirs []string) error {
+	if p.options.Permissions == nil {
+		return nil
+	}
+	for _, dir := range dirs {
+		if err := p.applyPermissions(dir, p.options.Permissions.DirMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Pool) applyPermissions(name string, mode os.FileMode) error {
+	fs, ok := p.fs.(PermissionsFS)
+	if !ok {
+		return ErrPermissionsUnsupported
+	}
+	perm := p.options.Permissions
+	if perm.Owner != "" || perm.Group != "" {
+		uid, gid, err := perm.ids()
+		if err != nil {
+			return fmt.Errorf("boltdbpool: permissions of %s: %w", name, err)
+		}
+		if err := fs.Chown(name, uid, gid); err != nil {
+			return err
+		}
+	}
+	if mode != 0 {
+		// chmod after chown, as chown may clear setuid and setgid bits
+		if err := fs.Chmod(name, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
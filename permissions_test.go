@@ -0,0 +1,80 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes and owners are not supported")
+	}
+	pool := New(&Options{
+		Permissions: &Permissions{
+			// modes that the usual umask 022 would restrict
+			FileMode: 0666,
+			DirMode:  0777,
+			// changing to the current owner does not require privileges
+			Owner: strconv.Itoa(os.Getuid()),
+			Group: strconv.Itoa(os.Getgid()),
+		},
+	})
+	defer pool.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a", "b", "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	for name, want := range map[string]os.FileMode{
+		path:                         0666,
+		filepath.Join(dir, "a"):      os.ModeDir | 0777,
+		filepath.Join(dir, "a", "b"): os.ModeDir | 0777,
+	} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode() != want {
+			t.Errorf("%s: got mode %v, expected %v", name, info.Mode(), want)
+		}
+	}
+
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Compact(path); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode() != 0666 {
+		t.Errorf("got mode %v after compaction, expected %v", info.Mode(), os.FileMode(0666))
+	}
+}
+
+func TestPermissionsUnsupported(t *testing.T) {
+	pool := New(&Options{
+		FS:          struct{ FS }{OSFS{}},
+		Permissions: &Permissions{FileMode: 0600},
+	})
+	defer pool.Close()
+
+	if _, err := pool.Get(filepath.Join(t.TempDir(), "test.db")); !errors.Is(err, ErrPermissionsUnsupported) {
+		t.Errorf("got error %v, expected %v", err, ErrPermissionsUnsupported)
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package pooltest provides helpers for testing code that uses
+// boltdbpool and timed pools. Every pool and directory created by this
+// package is closed and removed when the test finishes.
+package pooltest // import "resenje.org/boltdbpool/pooltest"
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"resenje.org/boltdbpool"
+	"resenje.org/boltdbpool/timed"
+)
+
+// Pool is a boltdbpool.Pool with databases in a temporary directory and a
+// fake clock that controls connection expiration.
+type Pool struct {
+	*boltdbpool.Pool
+
+	// Dir is the temporary directory that holds database files.
+	Dir string
+	// Clock is the fake clock used by the pool.
+	Clock *Clock
+}
+
+// Path returns the path of a database file with name in the pool
+// directory.
+func (p *Pool) Path(name string) string {
+	return filepath.Join(p.Dir, name)
+}
+
+// NewTempPool creates a new pool over a temporary directory. Errors from the
+// pool are reported as test errors. The pool is closed on test end.
+func NewTempPool(t testing.TB) *Pool {
+	t.Helper()
+
+	return NewTempPoolWithOptions(t, nil)
+}
+
+// NewTempPoolWithOptions creates a new pool over a temporary directory with
+// provided options. If ErrorHandler is not set, errors from the pool are
+// reported as test errors. Option Now is always replaced by the fake clock.
+// The pool is closed on test end.
+func NewTempPoolWithOptions(t testing.TB, options *boltdbpool.Options) *Pool {
+	t.Helper()
+
+	var o boltdbpool.Options
+	if options != nil {
+		o = *options
+	}
+	if o.ErrorHandler == nil {
+		o.ErrorHandler = errorHandler(t)
+	}
+	clock := NewClock(time.Now())
+	o.Now = clock.Now
+
+	dir := t.TempDir()
+	pool := boltdbpool.New(&o)
+	t.Cleanup(pool.Close)
+	return &Pool{
+		Pool:  pool,
+		Dir:   dir,
+		Clock: clock,
+	}
+}
+
+// Expire advances the fake clock by d and closes all databases that have
+// expired, without waiting for the background expiration.
+func (p *Pool) Expire(d time.Duration) {
+	p.Clock.Advance(d)
+	p.CloseExpired()
+}
+
+// AssertOpen reports a test error if the number of open databases is not n.
+func (p *Pool) AssertOpen(t testing.TB, n int) {
+	t.Helper()
+
+	if got := len(p.Stats().Databases); got != n {
+		t.Errorf("pooltest: got %d open databases, expected %d", got, n)
+	}
+}
+
+// AssertReferences reports a test error if the database on path is not open
+// or if its reference count is not n.
+func (p *Pool) AssertReferences(t testing.TB, path string, n int64) {
+	t.Helper()
+
+	for _, s := range p.Stats().Databases {
+		if s.Path == path {
+			if s.References != n {
+				t.Errorf("pooltest: got %d references to %s, expected %d", s.References, path, n)
+			}
+			return
+		}
+	}
+	t.Errorf("pooltest: database %s is not open", path)
+}
+
+// Clock is a fake clock that changes only when it is advanced.
+type Clock struct {
+	t  time.Time
+	mu sync.Mutex
+}
+
+// NewClock returns a new Clock set to t.
+func NewClock(t time.Time) *Clock {
+	return &Clock{t: t}
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.t
+}
+
+// Advance moves the clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.t = c.t.Add(d)
+}
+
+// TimedPool is a timed.Pool with databases in a temporary directory.
+type TimedPool struct {
+	*timed.Pool
+
+	// Dir is the temporary directory that holds database files.
+	Dir string
+}
+
+// NewTempTimedPool creates a new timed pool partitioned by period over a
+// temporary directory. The pool is closed on test end.
+func NewTempTimedPool(t testing.TB, period timed.Period) *TimedPool {
+	t.Helper()
+
+	dir := t.TempDir()
+	pool, err := timed.New(dir, period, &boltdbpool.Options{
+		ErrorHandler: errorHandler(t),
+	})
+	if err != nil {
+		t.Fatalf("pooltest: new timed pool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	return &TimedPool{
+		Pool: pool,
+		Dir:  dir,
+	}
+}
+
+// Fixture holds data that is loaded into a database. Keys are bucket names
+// that may be slash-separated paths of nested buckets, and values are maps
+// of keys and values stored in that bucket.
+type Fixture map[string]map[string]string
+
+// LoadFixture stores all fixture data in the database in a single update
+// transaction. Buckets are created if they do not exist.
+func LoadFixture(t testing.TB, db *bolt.DB, f Fixture) {
+	t.Helper()
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for name, data := range f {
+			b, err := createBucket(tx, name)
+			if err != nil {
+				return err
+			}
+			for k, v := range data {
+				if err := b.Put([]byte(k), []byte(v)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("pooltest: load fixture: %v", err)
+	}
+}
+
+// LoadFixtureFile loads a fixture encoded as a JSON object from a file.
+func LoadFixtureFile(t testing.TB, db *bolt.DB, filename string) {
+	t.Helper()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("pooltest: read fixture: %v", err)
+	}
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatalf("pooltest: decode fixture %s: %v", filename, err)
+	}
+	LoadFixture(t, db, f)
+}
+
+func createBucket(tx *bolt.Tx, name string) (b *bolt.Bucket, err error) {
+	for i, n := range strings.Split(name, "/") {
+		if i == 0 {
+			b, err = tx.CreateBucketIfNotExists([]byte(n))
+		} else {
+			b, err = b.CreateBucketIfNotExists([]byte(n))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func errorHandler(t testing.TB) func(error) {
+	return func(err error) {
+		t.Errorf("pooltest: pool error: %v", err)
+	}
+}
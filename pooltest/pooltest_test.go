@@ -0,0 +1,92 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pooltest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"resenje.org/boltdbpool"
+	"resenje.org/boltdbpool/timed"
+)
+
+func TestNewTempPool(t *testing.T) {
+	pool := NewTempPool(t)
+
+	c, err := pool.Get(pool.Path("test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	LoadFixture(t, c.DB, Fixture{
+		"users":         {"1": "alice"},
+		"users/archive": {"2": "bob"},
+	})
+
+	filename := filepath.Join(t.TempDir(), "fixture.json")
+	if err := os.WriteFile(filename, []byte(`{"items": {"a": "b"}}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	LoadFixtureFile(t, c.DB, filename)
+
+	if err := c.DB.View(func(tx *bolt.Tx) error {
+		users := tx.Bucket([]byte("users"))
+		if v := string(users.Get([]byte("1"))); v != "alice" {
+			t.Errorf("got %q, expected %q", v, "alice")
+		}
+		if v := string(users.Bucket([]byte("archive")).Get([]byte("2"))); v != "bob" {
+			t.Errorf("got %q, expected %q", v, "bob")
+		}
+		if v := string(tx.Bucket([]byte("items")).Get([]byte("a"))); v != "b" {
+			t.Errorf("got %q, expected %q", v, "b")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewTempTimedPool(t *testing.T) {
+	pool := NewTempTimedPool(t, timed.Monthly)
+
+	c, err := pool.NewConnection(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err := os.Stat(filepath.Join(pool.Dir, time.Now().Format("200601")+".db")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	pool := NewTempPoolWithOptions(t, &boltdbpool.Options{
+		ConnectionExpires: time.Hour,
+	})
+	path := pool.Path("test.db")
+
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.AssertOpen(t, 1)
+	pool.AssertReferences(t, path, 1)
+
+	c.Close()
+	pool.AssertReferences(t, path, 0)
+
+	pool.Expire(time.Minute)
+	pool.AssertOpen(t, 1)
+
+	pool.Expire(time.Hour)
+	pool.AssertOpen(t, 0)
+}
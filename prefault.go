@@ -0,0 +1,59 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// prefaultBufferSize is the size of reads when a database file is
+// pre-faulted.
+const prefaultBufferSize = 1024 * 1024
+
+// prefault reads the database file on path in the background, so that its
+// pages are in the page cache before they are accessed through the memory
+// map. Options.OnPrefault is called when the file is read.
+func (p *Pool) prefault(path string) {
+	p.goBackground(func() {
+		err := p.readFile(path)
+		if err != nil {
+			err = fmt.Errorf("boltdbpool: prefault %s: %w", path, err)
+		}
+		if p.options.OnPrefault != nil {
+			p.handleError(p.protect("OnPrefault", func() error {
+				p.options.OnPrefault(path, err)
+				return nil
+			}))
+		} else if err != nil {
+			p.handleError(err)
+		}
+	})
+}
+
+// readFile sequentially reads the file on path until its end or until the
+// pool is closed.
+func (p *Pool) readFile(path string) error {
+	f, err := p.fs.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := make([]byte, prefaultBufferSize)
+	for {
+		if p.isClosed() {
+			return ErrClosed
+		}
+		if _, err := f.Read(buf); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
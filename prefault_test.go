@@ -0,0 +1,96 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPrefault(t *testing.T) {
+	type result struct {
+		path string
+		err  error
+	}
+	results := make(chan result, 1)
+
+	fs := newRecordingFS()
+	pool := New(&Options{
+		FS:       fs,
+		Prefault: true,
+		OnPrefault: func(path string, err error) {
+			results <- result{path: path, err: err}
+		},
+	})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	select {
+	case r := <-results:
+		if r.path != path || r.err != nil {
+			t.Errorf("got prefault of %s with error %v", r.path, r.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("database is not prefaulted")
+	}
+	fs.mu.Lock()
+	opens := len(fs.calls["open"])
+	fs.mu.Unlock()
+	if opens != 2 {
+		t.Errorf("got %d file opens, expected 2", opens)
+	}
+}
+
+// secondOpenFailFS fails all file opens after the first one.
+type secondOpenFailFS struct {
+	OSFS
+	opened int32
+	err    error
+}
+
+func (fs *secondOpenFailFS) OpenFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	if atomic.AddInt32(&fs.opened, 1) > 1 {
+		return nil, fs.err
+	}
+	return fs.OSFS.OpenFile(name, flag, perm)
+}
+
+func TestPrefaultError(t *testing.T) {
+	errTest := errors.New("test error")
+	errs := make(chan error, 1)
+
+	pool := New(&Options{
+		FS:           &secondOpenFailFS{err: errTest},
+		Prefault:     true,
+		ErrorHandler: func(err error) { errs <- err },
+	})
+	defer pool.Close()
+
+	c, err := pool.Get(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, errTest) {
+			t.Errorf("got error %v, expected %v", err, errTest)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("prefault error is not handled")
+	}
+}
@@ -0,0 +1,395 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package queue implements a persistent priority queue with at-least-once
+// delivery in a database managed by boltdbpool.Pool.
+package queue // import "resenje.org/boltdbpool/queue"
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"resenje.org/boltdbpool"
+)
+
+var (
+	// ErrEmpty is returned by Queue.Dequeue when there are no messages ready
+	// for delivery.
+	ErrEmpty = errors.New("queue empty")
+	// ErrUnknownMessage is returned by Queue.Ack and Queue.Nack for messages
+	// that are not delivered and waiting for acknowledgement.
+	ErrUnknownMessage = errors.New("unknown message")
+	// ErrInvalidMessage is returned when a stored message can not be decoded.
+	ErrInvalidMessage = errors.New("invalid message")
+)
+
+// DefaultVisibilityTimeout is used when Options.VisibilityTimeout is not
+// set.
+var DefaultVisibilityTimeout = 30 * time.Second
+
+// Options configure a Queue.
+type Options struct {
+	// VisibilityTimeout is the duration after which a dequeued message that
+	// is not acknowledged is delivered again.
+	VisibilityTimeout time.Duration
+	// MaxAttempts is the number of deliveries after which a message that is
+	// not acknowledged is moved to the dead-letter bucket. If the value is 0
+	// (default), messages are delivered until they are acknowledged.
+	MaxAttempts int
+	// Now returns the current time. If nil, time.Now is used.
+	Now func() time.Time
+}
+
+// Message is a queued message.
+type Message struct {
+	ID       uint64
+	Body     []byte
+	Priority uint8
+	// Attempts is the number of times the message has been delivered.
+	Attempts int
+}
+
+// Queue is a persistent FIFO queue with priorities. Messages with higher
+// priority are delivered first and messages with the same priority in the
+// order they are enqueued.
+type Queue struct {
+	pool    *boltdbpool.Pool
+	path    string
+	options Options
+
+	messagesBucket []byte
+	readyBucket    []byte
+	inflightBucket []byte
+	deadBucket     []byte
+}
+
+// New returns a Queue with the name that keeps messages in the database on
+// path. Multiple queues may be stored in the same database.
+func New(pool *boltdbpool.Pool, path, name string, options *Options) *Queue {
+	if options == nil {
+		options = &Options{}
+	}
+	q := &Queue{
+		pool:           pool,
+		path:           path,
+		options:        *options,
+		messagesBucket: []byte(name + ".messages"),
+		readyBucket:    []byte(name + ".ready"),
+		inflightBucket: []byte(name + ".inflight"),
+		deadBucket:     []byte(name + ".dead"),
+	}
+	if q.options.VisibilityTimeout <= 0 {
+		q.options.VisibilityTimeout = DefaultVisibilityTimeout
+	}
+	if q.options.Now == nil {
+		q.options.Now = time.Now
+	}
+	return q
+}
+
+// Enqueue adds the message body to the queue with the default priority 0
+// and returns its ID.
+func (q *Queue) Enqueue(body []byte) (id uint64, err error) {
+	return q.EnqueuePriority(body, 0)
+}
+
+// EnqueuePriority adds the message body to the queue with the priority and
+// returns its ID.
+func (q *Queue) EnqueuePriority(body []byte, priority uint8) (id uint64, err error) {
+	err = q.update(func(b *buckets) error {
+		id, err = b.messages.NextSequence()
+		if err != nil {
+			return err
+		}
+		m := record{priority: priority, body: body}
+		if err := b.messages.Put(itob(id), m.encode()); err != nil {
+			return err
+		}
+		return b.ready.Put(readyKey(priority, id), nil)
+	})
+	return id, err
+}
+
+// Dequeue returns the next message ready for delivery or ErrEmpty. The
+// message is delivered again after the visibility timeout if it is not
+// acknowledged with Ack.
+func (q *Queue) Dequeue() (m *Message, err error) {
+	now := q.options.Now()
+	err = q.update(func(b *buckets) error {
+		if err := q.reclaim(b, now); err != nil {
+			return err
+		}
+		k, _ := b.ready.Cursor().First()
+		if k == nil {
+			return ErrEmpty
+		}
+		id := binary.BigEndian.Uint64(k[1:])
+		if err := b.ready.Delete(k); err != nil {
+			return err
+		}
+		r, err := b.record(id)
+		if err != nil {
+			return err
+		}
+		r.attempts++
+		r.deadline = now.Add(q.options.VisibilityTimeout).UnixNano()
+		if err := b.messages.Put(itob(id), r.encode()); err != nil {
+			return err
+		}
+		if err := b.inflight.Put(inflightKey(r.deadline, id), nil); err != nil {
+			return err
+		}
+		m = r.message(id)
+		return nil
+	})
+	return m, err
+}
+
+// Ack acknowledges that the delivered message is processed and removes it
+// from the queue.
+func (q *Queue) Ack(id uint64) error {
+	return q.update(func(b *buckets) error {
+		r, err := b.inflightRecord(id)
+		if err != nil {
+			return err
+		}
+		if err := b.inflight.Delete(inflightKey(r.deadline, id)); err != nil {
+			return err
+		}
+		return b.messages.Delete(itob(id))
+	})
+}
+
+// Nack returns the delivered message to the queue so that it is delivered
+// again without waiting for the visibility timeout. The message is moved to
+// the dead-letter bucket if it reached Options.MaxAttempts.
+func (q *Queue) Nack(id uint64) error {
+	return q.update(func(b *buckets) error {
+		r, err := b.inflightRecord(id)
+		if err != nil {
+			return err
+		}
+		if err := b.inflight.Delete(inflightKey(r.deadline, id)); err != nil {
+			return err
+		}
+		return q.requeue(b, id, r)
+	})
+}
+
+// Len returns the number of messages ready for delivery and the number of
+// delivered messages waiting for acknowledgement.
+func (q *Queue) Len() (ready, inflight int, err error) {
+	err = q.view(func(b *buckets) error {
+		ready = b.ready.Stats().KeyN
+		inflight = b.inflight.Stats().KeyN
+		return nil
+	})
+	return ready, inflight, err
+}
+
+// DeadLetters returns messages that reached Options.MaxAttempts without
+// being acknowledged.
+func (q *Queue) DeadLetters() (messages []Message, err error) {
+	err = q.view(func(b *buckets) error {
+		return b.dead.ForEach(func(k, v []byte) error {
+			r, err := decodeRecord(v)
+			if err != nil {
+				return err
+			}
+			messages = append(messages, *r.message(binary.BigEndian.Uint64(k)))
+			return nil
+		})
+	})
+	return messages, err
+}
+
+// DeleteDeadLetter removes the message from the dead-letter bucket.
+func (q *Queue) DeleteDeadLetter(id uint64) error {
+	return q.update(func(b *buckets) error {
+		return b.dead.Delete(itob(id))
+	})
+}
+
+// reclaim returns messages whose visibility timeout expired to the queue.
+func (q *Queue) reclaim(b *buckets, now time.Time) error {
+	var keys [][]byte
+	c := b.inflight.Cursor()
+	for k, _ := c.First(); k != nil && int64(binary.BigEndian.Uint64(k)) <= now.UnixNano(); k, _ = c.Next() {
+		keys = append(keys, append([]byte{}, k...))
+	}
+	for _, k := range keys {
+		if err := b.inflight.Delete(k); err != nil {
+			return err
+		}
+		id := binary.BigEndian.Uint64(k[8:])
+		r, err := b.record(id)
+		if err != nil {
+			return err
+		}
+		if err := q.requeue(b, id, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requeue makes the message ready for delivery or moves it to the
+// dead-letter bucket.
+func (q *Queue) requeue(b *buckets, id uint64, r record) error {
+	r.deadline = 0
+	if max := q.options.MaxAttempts; max > 0 && r.attempts >= max {
+		if err := b.messages.Delete(itob(id)); err != nil {
+			return err
+		}
+		return b.dead.Put(itob(id), r.encode())
+	}
+	if err := b.messages.Put(itob(id), r.encode()); err != nil {
+		return err
+	}
+	return b.ready.Put(readyKey(r.priority, id), nil)
+}
+
+type buckets struct {
+	messages *bolt.Bucket
+	ready    *bolt.Bucket
+	inflight *bolt.Bucket
+	dead     *bolt.Bucket
+}
+
+func (b *buckets) record(id uint64) (record, error) {
+	v := b.messages.Get(itob(id))
+	if v == nil {
+		return record{}, ErrUnknownMessage
+	}
+	return decodeRecord(v)
+}
+
+func (b *buckets) inflightRecord(id uint64) (record, error) {
+	r, err := b.record(id)
+	if err != nil {
+		return r, err
+	}
+	if r.deadline == 0 {
+		return r, ErrUnknownMessage
+	}
+	return r, nil
+}
+
+func (q *Queue) update(fn func(*buckets) error) error {
+	c, err := q.pool.Get(q.path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.Update(func(tx *bolt.Tx) error {
+		var b buckets
+		for _, e := range []struct {
+			bucket **bolt.Bucket
+			name   []byte
+		}{
+			{&b.messages, q.messagesBucket},
+			{&b.ready, q.readyBucket},
+			{&b.inflight, q.inflightBucket},
+			{&b.dead, q.deadBucket},
+		} {
+			bucket, err := tx.CreateBucketIfNotExists(e.name)
+			if err != nil {
+				return err
+			}
+			*e.bucket = bucket
+		}
+		return fn(&b)
+	})
+}
+
+func (q *Queue) view(fn func(*buckets) error) error {
+	c, err := q.pool.Get(q.path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.View(func(tx *bolt.Tx) error {
+		b := buckets{
+			messages: tx.Bucket(q.messagesBucket),
+			ready:    tx.Bucket(q.readyBucket),
+			inflight: tx.Bucket(q.inflightBucket),
+			dead:     tx.Bucket(q.deadBucket),
+		}
+		if b.messages == nil || b.ready == nil || b.inflight == nil || b.dead == nil {
+			// queue is empty until the first message is enqueued
+			return nil
+		}
+		return fn(&b)
+	})
+}
+
+// record is the stored message with its delivery state.
+type record struct {
+	priority uint8
+	attempts int
+	// deadline is the visibility timeout of a delivered message in unix
+	// nanoseconds, or 0 if the message is not delivered.
+	deadline int64
+	body     []byte
+}
+
+const recordHeaderSize = 1 + 4 + 8
+
+func (r record) encode() []byte {
+	b := make([]byte, recordHeaderSize+len(r.body))
+	b[0] = r.priority
+	binary.BigEndian.PutUint32(b[1:], uint32(r.attempts))
+	binary.BigEndian.PutUint64(b[5:], uint64(r.deadline))
+	copy(b[recordHeaderSize:], r.body)
+	return b
+}
+
+func decodeRecord(b []byte) (record, error) {
+	if len(b) < recordHeaderSize {
+		return record{}, ErrInvalidMessage
+	}
+	return record{
+		priority: b[0],
+		attempts: int(binary.BigEndian.Uint32(b[1:])),
+		deadline: int64(binary.BigEndian.Uint64(b[5:])),
+		body:     append([]byte{}, b[recordHeaderSize:]...),
+	}, nil
+}
+
+func (r record) message(id uint64) *Message {
+	return &Message{
+		ID:       id,
+		Body:     r.body,
+		Priority: r.priority,
+		Attempts: r.attempts,
+	}
+}
+
+// readyKey orders messages by descending priority and ascending ID.
+func readyKey(priority uint8, id uint64) []byte {
+	k := make([]byte, 9)
+	k[0] = 255 - priority
+	binary.BigEndian.PutUint64(k[1:], id)
+	return k
+}
+
+// inflightKey orders delivered messages by their visibility deadline.
+func inflightKey(deadline int64, id uint64) []byte {
+	k := make([]byte, 16)
+	binary.BigEndian.PutUint64(k, uint64(deadline))
+	binary.BigEndian.PutUint64(k[8:], id)
+	return k
+}
+
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"resenje.org/boltdbpool"
+)
+
+func newTestQueue(t *testing.T, options *Options) *Queue {
+	t.Helper()
+
+	pool := boltdbpool.New(nil)
+	t.Cleanup(pool.Close)
+	return New(pool, filepath.Join(t.TempDir(), "queue.db"), "jobs", options)
+}
+
+func TestQueueOrder(t *testing.T) {
+	q := newTestQueue(t, nil)
+
+	if _, err := q.Dequeue(); !errors.Is(err, ErrEmpty) {
+		t.Fatalf("got error %v, expected %v", err, ErrEmpty)
+	}
+	for _, m := range []struct {
+		body     string
+		priority uint8
+	}{
+		{"low-1", 0},
+		{"high", 9},
+		{"low-2", 0},
+		{"mid", 5},
+	} {
+		if _, err := q.EnqueuePriority([]byte(m.body), m.priority); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range []string{"high", "mid", "low-1", "low-2"} {
+		m, err := q.Dequeue()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(m.Body) != want {
+			t.Errorf("got message %q, expected %q", m.Body, want)
+		}
+		if err := q.Ack(m.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := q.Ack(m.ID); !errors.Is(err, ErrUnknownMessage) {
+			t.Errorf("got error %v, expected %v", err, ErrUnknownMessage)
+		}
+	}
+	if ready, inflight, err := q.Len(); err != nil || ready != 0 || inflight != 0 {
+		t.Errorf("got len %d, %d (%v)", ready, inflight, err)
+	}
+}
+
+func TestQueueVisibilityTimeout(t *testing.T) {
+	now := time.Now()
+	q := newTestQueue(t, &Options{
+		VisibilityTimeout: time.Minute,
+		MaxAttempts:       2,
+		Now:               func() time.Time { return now },
+	})
+
+	id, err := q.Enqueue([]byte("job"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := q.Dequeue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != id || m.Attempts != 1 {
+		t.Errorf("got message %+v", m)
+	}
+	if _, err := q.Dequeue(); !errors.Is(err, ErrEmpty) {
+		t.Errorf("got error %v, expected %v", err, ErrEmpty)
+	}
+	if ready, inflight, _ := q.Len(); ready != 0 || inflight != 1 {
+		t.Errorf("got len %d, %d", ready, inflight)
+	}
+
+	// not acknowledged message is delivered again
+	now = now.Add(2 * time.Minute)
+	m, err = q.Dequeue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != id || m.Attempts != 2 {
+		t.Errorf("got message %+v", m)
+	}
+
+	// the message reached max attempts
+	if err := q.Nack(m.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Dequeue(); !errors.Is(err, ErrEmpty) {
+		t.Errorf("got error %v, expected %v", err, ErrEmpty)
+	}
+	dead, err := q.DeadLetters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 || dead[0].ID != id || string(dead[0].Body) != "job" {
+		t.Fatalf("got dead letters %+v", dead)
+	}
+	if err := q.DeleteDeadLetter(id); err != nil {
+		t.Fatal(err)
+	}
+	if dead, _ := q.DeadLetters(); len(dead) != 0 {
+		t.Errorf("got dead letters %+v", dead)
+	}
+}
+
+func TestQueueNack(t *testing.T) {
+	q := newTestQueue(t, nil)
+
+	if _, err := q.Enqueue([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Enqueue([]byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	m, err := q.Dequeue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Nack(m.ID); err != nil {
+		t.Fatal(err)
+	}
+	m, err = q.Dequeue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(m.Body) != "a" || m.Attempts != 2 {
+		t.Errorf("got message %+v", m)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when a write is rejected by the limit from
+// Options.WriteRateLimit. Returned errors are of the RateLimitError type.
+var ErrRateLimited = errors.New("boltdbpool: write rate limited")
+
+// RateLimit is the limit of write transactions for a single database.
+type RateLimit struct {
+	// Rate is the number of writes per second. If the value is 0, writes
+	// are not limited.
+	Rate float64
+	// Burst is the number of writes that can be done at once, before they
+	// are limited by the rate. If the value is less than 1, it is 1.
+	Burst int
+}
+
+// RateLimitError is returned by Connection Update, Batch and value helper
+// methods when a write exceeds the database rate limit.
+type RateLimitError struct {
+	Path string
+	// RetryAfter is the duration after which the write is allowed.
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("boltdbpool: write rate limited %s: retry after %v", e.Path, e.RetryAfter)
+}
+
+// Unwrap returns ErrRateLimited.
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// rateLimiter is a token bucket.
+type rateLimiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	mu     sync.Mutex
+}
+
+func newRateLimiter(l RateLimit, now time.Time) *rateLimiter {
+	if l.Rate <= 0 {
+		return nil
+	}
+	burst := float64(l.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   l.Rate,
+		burst:  burst,
+		tokens: burst,
+		last:   now,
+	}
+}
+
+// allow takes a token from the bucket, or returns the duration after which
+// a token is available.
+func (l *rateLimiter) allow(now time.Time) (ok bool, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens = math.Min(l.burst, l.tokens+elapsed.Seconds()*l.rate)
+		l.last = now
+	}
+	if l.tokens >= 1 {
+		l.tokens--
+		return true, 0
+	}
+	return false, time.Duration(math.Ceil((1 - l.tokens) / l.rate * float64(time.Second)))
+}
+
+// checkRateLimit returns RateLimitError if the write is not allowed by the
+// connection database rate limit.
+func (c *Connection) checkRateLimit() error {
+	if c.limiter == nil {
+		return nil
+	}
+	if ok, retryAfter := c.limiter.allow(c.pool.now()); !ok {
+		return &RateLimitError{Path: c.path, RetryAfter: retryAfter}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestWriteRateLimit(t *testing.T) {
+	dir := t.TempDir()
+	limited := filepath.Join(dir, "limited.db")
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	pool := New(&Options{
+		Now: func() time.Time { return now },
+		WriteRateLimit: func(path string) RateLimit {
+			if path == limited {
+				return RateLimit{Rate: 2, Burst: 3}
+			}
+			return RateLimit{}
+		},
+	})
+	defer pool.Close()
+
+	c, err := pool.Get(limited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := c.PutValue([]byte("bucket"), []byte("key"), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = c.Update(func(*bolt.Tx) error { return nil })
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("got error %v, expected %v", err, ErrRateLimited)
+	}
+	var rerr *RateLimitError
+	if !errors.As(err, &rerr) || rerr.Path != limited || rerr.RetryAfter != 500*time.Millisecond {
+		t.Errorf("got error %#v", err)
+	}
+	if _, err := c.GetValue([]byte("bucket"), []byte("key")); err != nil {
+		t.Errorf("reads are limited: %v", err)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if err := c.Batch(func(*bolt.Tx) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Batch(func(*bolt.Tx) error { return nil }); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("got error %v, expected %v", err, ErrRateLimited)
+	}
+
+	// tokens do not accumulate above the burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if err := c.Update(func(*bolt.Tx) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Update(func(*bolt.Tx) error { return nil }); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("got error %v, expected %v", err, ErrRateLimited)
+	}
+
+	other, err := pool.Get(filepath.Join(dir, "other.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	for i := 0; i < 10; i++ {
+		if err := other.Update(func(*bolt.Tx) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
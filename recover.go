@@ -0,0 +1,153 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrCorrupted is returned when the database content is not valid.
+var ErrCorrupted = errors.New("boltdbpool: database corrupted")
+
+// CorruptExtension is appended to the file name of a corrupted database
+// when it is quarantined.
+const CorruptExtension = ".corrupt"
+
+// Recovery is the report of a corrupted database recovery that is passed
+// to the Options.OnCorrupt function.
+type Recovery struct {
+	// Path is the database path.
+	Path string
+	// CorruptPath is the path of the quarantined corrupted file.
+	CorruptPath string
+	// Err is the error that detected the corruption.
+	Err error
+	// Salvaged are names of top-level buckets that are copied to the new
+	// database.
+	Salvaged []string
+	// Lost are names of top-level buckets that could not be copied.
+	Lost []string
+	// SalvageErr is the error that prevented reading the corrupted file,
+	// if any.
+	SalvageErr error
+}
+
+// IsCorruption returns true if the error reports invalid database content.
+// Errors from reading the database file, like permission or I/O errors,
+// are not corruption, even if they are returned in a VerifyError.
+func IsCorruption(err error) bool {
+	return errors.Is(err, ErrCorrupted) ||
+		errors.Is(err, ErrInvalidHeader) ||
+		errors.Is(err, bolt.ErrInvalid) ||
+		errors.Is(err, bolt.ErrChecksum) ||
+		errors.Is(err, bolt.ErrVersionMismatch)
+}
+
+// recoverFile quarantines the corrupted database file on path and salvages
+// its readable buckets into a new file on the same path. The database must
+// not be open. Pool lock must be held.
+func (p *Pool) recoverFile(path string, cause error) error {
+	corruptPath := path + CorruptExtension
+	if _, err := p.fs.Stat(corruptPath); err == nil {
+		corruptPath = fmt.Sprintf("%s.%d%s", path, time.Now().UnixNano(), CorruptExtension)
+	}
+	if err := p.fs.Rename(path, corruptPath); err != nil {
+		return fmt.Errorf("boltdbpool: quarantine %s: %w", path, err)
+	}
+	r := Recovery{
+		Path:        path,
+		CorruptPath: corruptPath,
+		Err:         cause,
+	}
+	r.Salvaged, r.Lost, r.SalvageErr = p.salvage(corruptPath, path)
+	p.handleError(p.protect("OnCorrupt", func() error {
+		p.options.OnCorrupt(r)
+		return nil
+	}))
+	return nil
+}
+
+// salvage copies every top-level bucket that can be read from the src
+// database to a new database dst.
+func (p *Pool) salvage(src, dst string) (salvaged, lost []string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", ErrCorrupted, r)
+		}
+	}()
+
+	srcDB, err := bolt.Open(src, 0, &bolt.Options{ReadOnly: true, Timeout: time.Second, OpenFile: p.fs.OpenFile})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer srcDB.Close()
+
+	options, mode := p.boltOptions(dst)
+	dstDB, err := bolt.Open(dst, mode, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer dstDB.Close()
+	if err := p.applyFilePermissions(dst); err != nil {
+		return nil, nil, err
+	}
+
+	var names [][]byte
+	if err := srcDB.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			names = append(names, append([]byte(nil), name...))
+			return nil
+		})
+	}); err != nil {
+		return nil, nil, err
+	}
+	for _, name := range names {
+		if err := salvageBucket(dstDB, srcDB, name); err != nil {
+			lost = append(lost, string(name))
+			continue
+		}
+		salvaged = append(salvaged, string(name))
+	}
+	return salvaged, lost, nil
+}
+
+// salvageBucket copies a top-level bucket in a single transaction, which
+// is rolled back if the bucket can not be read.
+func salvageBucket(dst, src *bolt.DB, name []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", ErrCorrupted, r)
+		}
+	}()
+
+	return src.View(func(srcTx *bolt.Tx) error {
+		return dst.Update(func(dstTx *bolt.Tx) error {
+			b, err := dstTx.CreateBucketIfNotExists(name)
+			if err != nil {
+				return err
+			}
+			return copyBucket(b, srcTx.Bucket(name))
+		})
+	})
+}
+
+// copyBucket copies all keys and nested buckets from src to dst.
+func copyBucket(dst, src *bolt.Bucket) error {
+	return src.ForEach(func(k, v []byte) error {
+		if v == nil {
+			b, err := dst.CreateBucketIfNotExists(k)
+			if err != nil {
+				return err
+			}
+			return copyBucket(b, src.Bucket(k))
+		}
+		return dst.Put(k, v)
+	})
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestRecoverOnOpen(t *testing.T) {
+	var recoveries []Recovery
+	pool := New(&Options{
+		OnCorrupt: func(r Recovery) {
+			recoveries = append(recoveries, r)
+		},
+	})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	if err := os.WriteFile(path, bytes.Repeat([]byte{0xff}, 8192), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	if len(recoveries) != 1 {
+		t.Fatalf("got %d recoveries, expected 1", len(recoveries))
+	}
+	r := recoveries[0]
+	if r.Path != path || r.CorruptPath != path+CorruptExtension {
+		t.Errorf("unexpected recovery %+v", r)
+	}
+	if !IsCorruption(r.Err) {
+		t.Errorf("error %v is not corruption", r.Err)
+	}
+	if r.SalvageErr == nil {
+		t.Error("expected salvage error")
+	}
+	if _, err := os.Stat(r.CorruptPath); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRecoverOnCheck(t *testing.T) {
+	var recoveries []Recovery
+	pool := New(&Options{
+		OnCorrupt: func(r Recovery) {
+			recoveries = append(recoveries, r)
+		},
+	})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var root uint64
+	if err := c.Update(func(tx *bolt.Tx) error {
+		value := make([]byte, 100)
+		for _, name := range []string{"good", "bad"} {
+			b, err := tx.CreateBucket([]byte(name))
+			if err != nil {
+				return err
+			}
+			for i := 0; i < 100; i++ {
+				if err := b.Put([]byte{byte(i)}, value); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.View(func(tx *bolt.Tx) error {
+		root = uint64(tx.Bucket([]byte("bad")).Root())
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	pageSize := c.DB.Info().PageSize
+	c.Close()
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt(bytes.Repeat([]byte{0xff}, pageSize), int64(root)*int64(pageSize)); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pool.Check(path); err == nil {
+		t.Fatal("expected check error")
+	}
+	if len(recoveries) != 1 {
+		t.Fatalf("got %d recoveries, expected 1", len(recoveries))
+	}
+	r := recoveries[0]
+	if r.SalvageErr != nil {
+		t.Fatal(r.SalvageErr)
+	}
+	if len(r.Salvaged) != 1 || r.Salvaged[0] != "good" {
+		t.Errorf("got salvaged buckets %v, expected [good]", r.Salvaged)
+	}
+	if len(r.Lost) != 1 || r.Lost[0] != "bad" {
+		t.Errorf("got lost buckets %v, expected [bad]", r.Lost)
+	}
+
+	if err := pool.Check(path); err != nil {
+		t.Errorf("recovered database check: %v", err)
+	}
+}
+
+func TestRecoverUnreadable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := bolt.Open(path, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := newRecordingFS()
+	fs.fail[path] = &os.PathError{Op: "open", Path: path, Err: os.ErrPermission}
+	var recoveries []Recovery
+	pool := New(&Options{
+		FS: fs,
+		VerifyOnOpen: func(string) VerifyLevel {
+			return VerifyHeader
+		},
+		OnCorrupt: func(r Recovery) {
+			recoveries = append(recoveries, r)
+		},
+	})
+	defer pool.Close()
+
+	if _, err := pool.Get(path); !errors.Is(err, os.ErrPermission) {
+		t.Fatalf("got error %v, expected %v", err, os.ErrPermission)
+	}
+	if len(recoveries) != 0 {
+		t.Errorf("got %d recoveries, expected 0", len(recoveries))
+	}
+	if fs.called("rename", path) {
+		t.Error("unreadable database quarantined")
+	}
+	if _, err := os.Stat(path + CorruptExtension); !os.IsNotExist(err) {
+		t.Errorf("got error %v, expected not exist", err)
+	}
+}
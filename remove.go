@@ -0,0 +1,117 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ErrInUse is returned when an operation requires a database that is
+// referenced by connections.
+var ErrInUse = errors.New("boltdbpool: database in use")
+
+// Remove closes the database on path if it has no references, removes it
+// from the pool and deletes its file. Parent directories that were created
+// by the pool and that are left empty are deleted, too. ErrInUse is
+// returned if there are connections that reference the database.
+func (p *Pool) Remove(path string) error {
+	if p.options.ReadOnly {
+		return ErrReadOnly
+	}
+	path, err := p.normalizePath(path)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.waitCompaction(path)
+	if c, ok := p.connections[path]; ok {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if c.count > 0 {
+			return ErrInUse
+		}
+		if err := c.remove(); err != nil {
+			return err
+		}
+	}
+	delete(p.verified, path)
+	p.closeParked(path)
+	delete(p.parked, path)
+	delete(p.known, path)
+	p.invalidateCache(path)
+	if err := p.fs.Remove(path); err != nil {
+		return err
+	}
+	p.removeEmptyDirs(filepath.Dir(path))
+	return nil
+}
+
+// mkdirAll creates the directory and all its missing parents, recording
+// which directories are created by the pool. Pool lock must be held.
+func (p *Pool) mkdirAll(dir string) error {
+	created, err := makeDirs(p.fs, dir)
+	p.addCreatedDirs(created)
+	if err != nil {
+		return err
+	}
+	return p.applyDirPermissions(created)
+}
+
+// addCreatedDirs records directories that are created by the pool. Pool
+// lock must be held.
+func (p *Pool) addCreatedDirs(dirs []string) {
+	for _, d := range dirs {
+		p.createdDirs[d] = struct{}{}
+	}
+}
+
+// makeDirs creates the directory and all its missing parents and returns
+// the directories that it created, also when it fails.
+func makeDirs(fs FS, dir string) (created []string, err error) {
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := fs.Stat(d); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+		missing = append(missing, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	// missing directories are ordered from the deepest one
+	for i := len(missing) - 1; i >= 0; i-- {
+		d := missing[i]
+		if err := fs.Mkdir(d, 0777); err != nil && !os.IsExist(err) {
+			return created, err
+		}
+		created = append(created, d)
+	}
+	return created, nil
+}
+
+// removeEmptyDirs deletes the directory and its parents while they are
+// empty and created by the pool. Pool lock must be held.
+func (p *Pool) removeEmptyDirs(dir string) {
+	for {
+		if _, ok := p.createdDirs[dir]; !ok {
+			return
+		}
+		// removal fails on directories that are not empty
+		if err := p.fs.Remove(dir); err != nil {
+			return
+		}
+		delete(p.createdDirs, dir)
+		dir = filepath.Dir(dir)
+	}
+}
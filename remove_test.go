@@ -0,0 +1,63 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRemove(t *testing.T) {
+	dir := t.TempDir()
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+	})
+	defer pool.Close()
+
+	path := filepath.Join(dir, "a", "b", "test.db")
+	sibling := filepath.Join(dir, "a", "sibling.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := pool.Get(sibling)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	if err := pool.Remove(path); !errors.Is(err, ErrInUse) {
+		t.Errorf("got error %v, expected %v", err, ErrInUse)
+	}
+	c.Close()
+
+	if err := pool.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if pool.Has(path) {
+		t.Error("removed database is in the pool")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a", "b")); !os.IsNotExist(err) {
+		t.Errorf("empty directory not removed: %v", err)
+	}
+
+	if err := pool.Remove(sibling); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a")); !os.IsNotExist(err) {
+		t.Errorf("empty directory not removed: %v", err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("directory not created by the pool removed: %v", err)
+	}
+
+	if err := pool.Remove(path); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ErrExists is returned when a database file already exists on a path where
+// a new one should be placed.
+var ErrExists = errors.New("boltdbpool: database already exists")
+
+// Rename moves the database file from oldPath to newPath. If the database
+// is open in the pool, it is closed before the file is renamed and its
+// connection is moved to newPath. If the connection has a reference, the
+// database is opened again on the new path and the Connection DB field is
+// replaced, so the connection must not be used while the database is
+// renamed. ErrInUse is returned if the connection has more than one
+// reference. If the database can not be opened on the new path, the file
+// is moved back to oldPath and opened there. Parent directories of newPath
+// are created if needed and directories of oldPath created by the pool
+// that are left empty are deleted.
+func (p *Pool) Rename(oldPath, newPath string) (err error) {
+	if p.options.ReadOnly {
+		return ErrReadOnly
+	}
+	if oldPath, err = p.normalizePath(oldPath); err != nil {
+		return err
+	}
+	if newPath, err = p.normalizePath(newPath); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.isClosed() {
+		return ErrClosed
+	}
+	p.waitCompaction(oldPath)
+	p.waitCompaction(newPath)
+	p.closeParked(oldPath)
+	p.invalidateCache(oldPath)
+	p.invalidateCache(newPath)
+	if _, ok := p.connections[newPath]; ok {
+		return ErrExists
+	}
+	if _, err := p.fs.Stat(newPath); err == nil {
+		return ErrExists
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if err := p.mkdirAll(filepath.Dir(newPath)); err != nil {
+		return err
+	}
+
+	c, ok := p.connections[oldPath]
+	if !ok {
+		if err := p.fs.Rename(oldPath, newPath); err != nil {
+			return err
+		}
+		p.moveVerified(oldPath, newPath)
+		p.moveKnown(oldPath, newPath)
+		p.removeEmptyDirs(filepath.Dir(oldPath))
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.count > 1 {
+		return ErrInUse
+	}
+	if err := p.remove(oldPath); err != nil {
+		return err
+	}
+	if err := p.fs.Rename(oldPath, newPath); err != nil {
+		// keep the database on the old path
+		if c.count > 0 {
+			p.handleError(p.reopen(c))
+		}
+		return err
+	}
+	if c.count > 0 {
+		c.path = newPath
+		if err := p.reopen(c); err != nil {
+			// move the database back to the old path
+			c.path = oldPath
+			if rerr := p.fs.Rename(newPath, oldPath); rerr != nil {
+				p.handleError(rerr)
+			} else {
+				p.handleError(p.reopen(c))
+			}
+			p.removeEmptyDirs(filepath.Dir(newPath))
+			return err
+		}
+	}
+	p.moveVerified(oldPath, newPath)
+	p.moveKnown(oldPath, newPath)
+	p.removeEmptyDirs(filepath.Dir(oldPath))
+	c.path = newPath
+	return nil
+}
+
+// reopen opens the database for the connection on its path and adds the
+// connection back to the pool. The connection must not have more than one
+// reference, as its DB field is replaced. Pool and connection locks must
+// be held.
+func (p *Pool) reopen(c *Connection) error {
+	db, err := p.open(c.path, p.openDeadline())
+	if err != nil {
+		return err
+	}
+	c.DB = db
+	p.connections[c.path] = c
+	p.index.Store(c.path, c)
+	c.emit(EventOpened, nil)
+	return nil
+}
+
+// moveVerified transfers the verification state of a database file to its
+// new path. Pool lock must be held.
+func (p *Pool) moveVerified(oldPath, newPath string) {
+	if _, ok := p.verified[oldPath]; ok {
+		delete(p.verified, oldPath)
+		p.verified[newPath] = struct{}{}
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestRename(t *testing.T) {
+	dir := t.TempDir()
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+	})
+	defer pool.Close()
+
+	oldPath := filepath.Join(dir, "old", "tenant.db")
+	newPath := filepath.Join(dir, "new", "tenant.db")
+
+	c, err := pool.Get(oldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("bucket"))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("key"), []byte("value"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pool.Rename(oldPath, newPath); err != nil {
+		t.Fatal(err)
+	}
+	if pool.Has(oldPath) {
+		t.Error("old path is in the pool")
+	}
+	if !pool.Has(newPath) {
+		t.Error("new path is not in the pool")
+	}
+	if c.DB.Path() != newPath {
+		t.Errorf("got database path %s, expected %s", c.DB.Path(), newPath)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old")); !os.IsNotExist(err) {
+		t.Errorf("empty directory not removed: %v", err)
+	}
+
+	// the connection is usable after the rename
+	if err := c.DB.View(func(tx *bolt.Tx) error {
+		if v := string(tx.Bucket([]byte("bucket")).Get([]byte("key"))); v != "value" {
+			t.Errorf("got value %q, expected %q", v, "value")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	c2, err := pool.Get(newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c2 != c {
+		t.Error("renamed database is not reused")
+	}
+	c2.Close()
+
+	other := filepath.Join(dir, "other.db")
+	o, err := pool.Get(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Close()
+	if err := pool.Rename(other, newPath); !errors.Is(err, ErrExists) {
+		t.Errorf("got error %v, expected %v", err, ErrExists)
+	}
+}
+
+func TestRenameClosed(t *testing.T) {
+	dir := t.TempDir()
+	pool := New(nil)
+	defer pool.Close()
+
+	oldPath := filepath.Join(dir, "old.db")
+	newPath := filepath.Join(dir, "new.db")
+
+	c, err := pool.Get(oldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	if pool.Has(oldPath) {
+		t.Fatal("database is not closed")
+	}
+
+	if err := pool.Rename(oldPath, newPath); err != nil {
+		t.Fatal(err)
+	}
+	if pool.Has(newPath) {
+		t.Error("closed database is opened by rename")
+	}
+	if _, err := os.Stat(newPath); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Errorf("old file exists: %v", err)
+	}
+}
+
+func TestRenameInUse(t *testing.T) {
+	dir := t.TempDir()
+	pool := New(nil)
+	defer pool.Close()
+
+	oldPath := filepath.Join(dir, "old.db")
+	newPath := filepath.Join(dir, "new.db")
+
+	for i := 0; i < 2; i++ {
+		c, err := pool.Get(oldPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+	}
+	if err := pool.Rename(oldPath, newPath); err != ErrInUse {
+		t.Errorf("got error %v, expected %v", err, ErrInUse)
+	}
+	if !pool.Has(oldPath) {
+		t.Error("database in use is closed")
+	}
+}
+
+func TestRenameOpenFailed(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.db")
+	newPath := filepath.Join(dir, "new", "new.db")
+	errOpen := errors.New("open refused")
+	pool := New(&Options{
+		OnOpen: func(path string, db *bolt.DB) error {
+			if path == newPath {
+				return errOpen
+			}
+			return nil
+		},
+	})
+	defer pool.Close()
+
+	c, err := pool.Get(oldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := pool.Rename(oldPath, newPath); !errors.Is(err, errOpen) {
+		t.Fatalf("got error %v, expected %v", err, errOpen)
+	}
+	if !pool.Has(oldPath) {
+		t.Error("database is not moved back to the old path")
+	}
+	if _, err := os.Stat(filepath.Dir(newPath)); !os.IsNotExist(err) {
+		t.Errorf("new directory is not removed: %v", err)
+	}
+	if err := c.PutValue([]byte("b"), []byte("k"), []byte("v")); err != nil {
+		t.Errorf("connection is not usable: %v", err)
+	}
+}
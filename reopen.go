@@ -0,0 +1,55 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+// Reopen closes the database on path and opens it again, so that the pool
+// uses a file that was replaced by an external tool, like a restore from a
+// backup. The Connection DB field is replaced, so the connection must not
+// be used while the database is reopened, and ErrInUse is returned if it
+// has more than one reference. If the connection has a reference, the
+// header of the new file is validated before the database is closed, so
+// that the connection remains usable if the file is not a valid database.
+// The new file is validated according to Options.VerifyOnOpen when it is
+// opened. If the database is not open in the pool, Reopen only resets its
+// verification state.
+func (p *Pool) Reopen(path string) error {
+	path, err := p.normalizePath(path)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.isClosed() {
+		return ErrClosed
+	}
+	p.waitCompaction(path)
+	p.closeParked(path)
+	delete(p.verified, path)
+	p.invalidateCache(path)
+
+	c, ok := p.connections[path]
+	if !ok {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.count > 1 {
+		return ErrInUse
+	}
+	if c.count > 0 {
+		if err := verifyHeader(p.fs, path); err != nil {
+			return &VerifyError{Path: path, Level: VerifyHeader, Err: err}
+		}
+	}
+	if err := p.remove(path); err != nil {
+		return err
+	}
+	return p.reopen(c)
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+	})
+	defer pool.Close()
+
+	path := filepath.Join(dir, "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// prepare a replacement database file with different content
+	replacement := filepath.Join(dir, "replacement.db")
+	db, err := bolt.Open(replacement, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("restored"))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("key"), []byte("value"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(replacement, path); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pool.Reopen(path); err != nil {
+		t.Fatal(err)
+	}
+	if !pool.Has(path) {
+		t.Fatal("reopened database is not in the pool")
+	}
+	if err := c.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("restored"))
+		if b == nil {
+			t.Fatal("replaced database is not opened")
+		}
+		if v := string(b.Get([]byte("key"))); v != "value" {
+			t.Errorf("got value %q, expected %q", v, "value")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pool.Reopen(filepath.Join(dir, "missing.db")); err != nil {
+		t.Errorf("reopen of a database that is not open: %v", err)
+	}
+}
+
+func TestReopenVerify(t *testing.T) {
+	dir := t.TempDir()
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+		VerifyOnOpen: func(string) VerifyLevel {
+			return VerifyHeader
+		},
+	})
+	defer pool.Close()
+
+	path := filepath.Join(dir, "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	if err := os.WriteFile(path+".new", make([]byte, 4096), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path+".new", path); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Reopen(path); err == nil {
+		t.Error("invalid replacement database reopened")
+	}
+	if pool.Has(path) {
+		t.Error("invalid database is in the pool")
+	}
+}
+
+func TestReopenInvalidInUse(t *testing.T) {
+	dir := t.TempDir()
+	pool := New(nil)
+	defer pool.Close()
+
+	path := filepath.Join(dir, "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := os.WriteFile(path+".new", make([]byte, 4096), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path+".new", path); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Reopen(path); !errors.Is(err, ErrInvalidHeader) {
+		t.Fatalf("got error %v, expected %v", err, ErrInvalidHeader)
+	}
+	if !pool.Has(path) {
+		t.Error("database is not in the pool")
+	}
+	if err := c.PutValue([]byte("b"), []byte("k"), []byte("v")); err != nil {
+		t.Errorf("connection is not usable: %v", err)
+	}
+
+	c2, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	if err := pool.Reopen(path); err != ErrInUse {
+		t.Errorf("got error %v, expected %v", err, ErrInUse)
+	}
+}
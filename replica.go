@@ -0,0 +1,190 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNoReplica is returned by Pool.GetReplica for databases that have no
+// read replicas configured by Options.ReadReplicas.
+var ErrNoReplica = errors.New("boltdbpool: no read replica")
+
+// replicaSuffix is appended to the database path to get the path of its
+// read replica file.
+const replicaSuffix = ".snapshot"
+
+// GetReplica returns the read replica of the existing database on path,
+// which is a read-only snapshot in the file with the ".snapshot" suffix
+// next to it. The replica is created on the first call and refreshed in the
+// background with the interval returned by Options.ReadReplicas, while
+// writes continue on the live database. The returned snapshot is not
+// changed by refreshes and it must be released with Close. Replica files
+// are removed when the pool is closed.
+func (p *Pool) GetReplica(path string) (*Snapshot, error) {
+	path, err := p.normalizePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if p.options.ReadReplicas == nil {
+		return nil, ErrNoReplica
+	}
+	if p.isClosed() {
+		return nil, ErrClosed
+	}
+	r, err := p.replica(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	s := r.current
+	r.mu.Unlock()
+	if s == nil {
+		if err := p.refreshReplica(r); err != nil {
+			return nil, err
+		}
+		r.mu.Lock()
+		s = r.current
+		r.mu.Unlock()
+	}
+	return s.Acquire(), nil
+}
+
+type replicas struct {
+	replicas map[string]*replica
+	mu       sync.Mutex
+}
+
+// replica is the current snapshot of a database that is refreshed
+// periodically.
+type replica struct {
+	path     string
+	interval time.Duration
+
+	// current holds one reference to the snapshot, which is released when
+	// it is replaced
+	current *Snapshot
+	mu      sync.Mutex
+
+	// refreshMu serializes refreshes
+	refreshMu sync.Mutex
+}
+
+// replica returns the replica of the database on path, starting its
+// periodic refresh if it is created.
+func (p *Pool) replica(path string) (*replica, error) {
+	p.replicas.mu.Lock()
+	defer p.replicas.mu.Unlock()
+
+	if r, ok := p.replicas.replicas[path]; ok {
+		return r, nil
+	}
+	interval := p.options.ReadReplicas(path)
+	if interval <= 0 {
+		return nil, ErrNoReplica
+	}
+	r := &replica{
+		path:     path,
+		interval: interval,
+	}
+
+	// the pool lock is held while the refresh is started, so that it is
+	// waited for if the pool is closed concurrently
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.isClosed() {
+		return nil, ErrClosed
+	}
+	if p.replicas.replicas == nil {
+		p.replicas.replicas = make(map[string]*replica)
+	}
+	p.replicas.replicas[path] = r
+	p.background.Add(1)
+	go p.refreshReplicaPeriodically(r)
+	return r, nil
+}
+
+// refreshReplicaPeriodically refreshes the replica until the pool is
+// closed.
+func (p *Pool) refreshReplicaPeriodically(r *replica) {
+	defer p.background.Done()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.quit:
+			return
+		}
+		p.handleError(p.refreshReplica(r))
+	}
+}
+
+// refreshReplica creates a new snapshot of the database and moves it to the
+// replica file, replacing the previous snapshot. The previous snapshot file
+// is moved to a temporary path and it is removed when all its references are
+// released.
+func (p *Pool) refreshReplica(r *replica) (err error) {
+	r.refreshMu.Lock()
+	defer r.refreshMu.Unlock()
+
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("boltdbpool: refresh replica %s: %w", r.path, err)
+		}
+	}()
+
+	c, err := p.getExisting(r.path)
+	if err != nil {
+		return err
+	}
+	s, err := c.Snapshot()
+	c.Close()
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	old := r.current
+	r.mu.Unlock()
+
+	replicaPath := r.path + replicaSuffix
+	if old != nil {
+		old.mu.Lock()
+		retired := snapshotTempPath(r.path)
+		err = p.fs.Rename(old.path, retired)
+		if err == nil {
+			old.path = retired
+		}
+		old.mu.Unlock()
+		if err != nil {
+			s.Close()
+			return err
+		}
+	}
+	if err := p.fs.Rename(s.path, replicaPath); err != nil {
+		s.Close()
+		return err
+	}
+	s.mu.Lock()
+	s.path = replicaPath
+	s.mu.Unlock()
+
+	r.mu.Lock()
+	r.current = s
+	r.mu.Unlock()
+	if old != nil {
+		return old.Close()
+	}
+	return nil
+}
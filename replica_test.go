@@ -0,0 +1,139 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestGetReplica(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "reports.db")
+	pool := New(&Options{
+		ReadReplicas: func(p string) time.Duration {
+			if p == path {
+				return 10 * time.Millisecond
+			}
+			return 0
+		},
+	})
+	defer pool.Close()
+
+	if _, err := pool.GetReplica(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("got error %v, expected %v", err, os.ErrNotExist)
+	}
+
+	c, err := pool.GetWithBuckets(path, []byte("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	put := func(v string) {
+		t.Helper()
+
+		if err := c.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket([]byte("b")).Put([]byte("k"), []byte(v))
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	get := func(s *Snapshot) (v string) {
+		t.Helper()
+
+		if err := s.View(func(tx *bolt.Tx) error {
+			v = string(tx.Bucket([]byte("b")).Get([]byte("k")))
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	put("1")
+
+	s1, err := pool.GetReplica(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s1.Path() != path+".snapshot" {
+		t.Errorf("got replica path %q", s1.Path())
+	}
+	if !s1.DB.IsReadOnly() {
+		t.Error("replica is not read-only")
+	}
+	if v := get(s1); v != "1" {
+		t.Errorf("got value %q, expected 1", v)
+	}
+
+	// writes continue on the live database and are visible in the
+	// replica after it is refreshed
+	put("2")
+	timeout := time.After(5 * time.Second)
+	for {
+		s2, err := pool.GetReplica(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v := get(s2)
+		s2.Close()
+		if v == "2" {
+			break
+		}
+		select {
+		case <-timeout:
+			t.Fatal("replica not refreshed")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	// the acquired replica is not changed by refreshes
+	if v := get(s1); v != "1" {
+		t.Errorf("got value %q, expected 1", v)
+	}
+	retired := s1.Path()
+	if retired == path+".snapshot" {
+		t.Error("replaced replica file is not moved")
+	}
+	if err := s1.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(retired); !os.IsNotExist(err) {
+		t.Errorf("replaced replica file not removed: %v", err)
+	}
+
+	if err := pool.CloseAll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".snapshot"); !os.IsNotExist(err) {
+		t.Errorf("replica file not removed on close: %v", err)
+	}
+	if _, err := pool.GetReplica(path); err != ErrClosed {
+		t.Errorf("got error %v, expected %v", err, ErrClosed)
+	}
+}
+
+func TestGetReplicaNotConfigured(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	pool := New(nil)
+	defer pool.Close()
+	if _, err := pool.GetReplica(path); err != ErrNoReplica {
+		t.Errorf("got error %v, expected %v", err, ErrNoReplica)
+	}
+
+	pool = New(&Options{
+		ReadReplicas: func(string) time.Duration { return 0 },
+	})
+	defer pool.Close()
+	if _, err := pool.GetReplica(path); err != ErrNoReplica {
+		t.Errorf("got error %v, expected %v", err, ErrNoReplica)
+	}
+}
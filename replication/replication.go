@@ -0,0 +1,295 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package replication streams changes recorded in the oplog of databases
+// in a primary boltdbpool.Pool to a follower pool over TCP. The primary
+// pool must have boltdbpool.Options.Oplog set and changes must be made with
+// the pool value helpers to be replicated.
+//
+// The protocol is a stream of JSON messages: the follower sends a request
+// with the database path relative to the primary root directory and the
+// sequence of the last applied change, and the primary responds with
+// batches of newer changes as they are committed.
+//
+// Changes must be streamed without gaps in oplog sequences. Changes
+// removed with boltdbpool.Connection.TrimOplog before the follower applied
+// them can not be replicated, and Follow returns ErrOplogGap, after which
+// the follower database has to be seeded again, for example with
+// boltdbpool.Pool.Restore. Changes made before Options.Oplog was enabled
+// are not recorded, so the follower database has to be seeded with them,
+// too. The oplog grows without bound unless it is trimmed with TrimOplog.
+package replication // import "resenje.org/boltdbpool/replication"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"resenje.org/boltdbpool"
+)
+
+// ErrInvalidPath is returned for database paths that are not relative to
+// the root directory.
+var ErrInvalidPath = errors.New("invalid database path")
+
+// ErrOplogGap is returned by Follower.Follow when changes that the follower
+// has not applied are not in the primary oplog anymore.
+var ErrOplogGap = errors.New("oplog sequence gap")
+
+// DefaultPollInterval is used when Primary.PollInterval is not set.
+var DefaultPollInterval = time.Second
+
+// batchSize is the maximal number of changes sent in a single message.
+const batchSize = 1000
+
+type request struct {
+	Path  string `json:"path"`
+	After uint64 `json:"after"`
+}
+
+type batch struct {
+	Entries []boltdbpool.OplogEntry `json:"entries,omitempty"`
+	Error   string                  `json:"error,omitempty"`
+	Gap     bool                    `json:"gap,omitempty"`
+}
+
+// Primary serves oplog changes of databases under the root directory.
+type Primary struct {
+	pool *boltdbpool.Pool
+	root string
+
+	// PollInterval is the maximal duration between two reads of the oplog.
+	// Changes made with the pool value helpers are sent immediately.
+	PollInterval time.Duration
+	// ErrorHandler receives errors of individual streams. If nil,
+	// boltdbpool.DefaultErrorHandler is used.
+	ErrorHandler func(error)
+}
+
+// NewPrimary returns a Primary for databases under the root directory
+// opened through the pool.
+func NewPrimary(pool *boltdbpool.Pool, root string) *Primary {
+	return &Primary{
+		pool: pool,
+		root: root,
+	}
+}
+
+// Serve accepts follower connections on the listener until it is closed.
+func (p *Primary) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			if err := p.handle(conn); err != nil {
+				p.handleError(err)
+			}
+		}()
+	}
+}
+
+func (p *Primary) handle(conn net.Conn) error {
+	defer conn.Close()
+
+	var req request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(conn)
+	path, err := resolve(p.root, req.Path)
+	if err == nil {
+		_, err = os.Stat(path)
+	}
+	if err != nil {
+		return enc.Encode(batch{Error: err.Error()})
+	}
+
+	// the follower does not send anything after the request, so reading
+	// detects a closed connection
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		defer cancel()
+		var b [1]byte
+		_, _ = conn.Read(b[:])
+	}()
+
+	changes, stop := p.pool.Watch(path, "")
+	defer stop()
+
+	interval := p.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	after := req.After
+	for {
+		entries, err := p.oplog(path, after)
+		if err != nil {
+			return enc.Encode(batch{Error: err.Error()})
+		}
+		if err := checkSequences(entries, after); err != nil {
+			if eerr := enc.Encode(batch{Error: err.Error(), Gap: true}); eerr != nil {
+				return eerr
+			}
+			return fmt.Errorf("replication: %s: %w", req.Path, err)
+		}
+		if len(entries) > 0 {
+			if err := enc.Encode(batch{Entries: entries}); err != nil {
+				return err
+			}
+			after = entries[len(entries)-1].Sequence
+			if len(entries) == batchSize {
+				continue
+			}
+		}
+		select {
+		case <-changes:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (p *Primary) oplog(path string, after uint64) ([]boltdbpool.OplogEntry, error) {
+	c, err := p.pool.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	return c.Oplog(after, batchSize)
+}
+
+func (p *Primary) handleError(err error) {
+	if p.ErrorHandler != nil {
+		p.ErrorHandler(err)
+		return
+	}
+	boltdbpool.DefaultErrorHandler(err)
+}
+
+// Follower applies changes streamed from a Primary to databases under the
+// root directory opened through the pool.
+type Follower struct {
+	pool *boltdbpool.Pool
+	root string
+}
+
+// NewFollower returns a Follower for databases under the root directory.
+func NewFollower(pool *boltdbpool.Pool, root string) *Follower {
+	return &Follower{
+		pool: pool,
+		root: root,
+	}
+}
+
+// Follow connects to the primary on addr and applies changes of the
+// database on the path relative to both root directories until the
+// context is done or the connection fails. Replication continues from the
+// last applied change, so Follow can be called again after an error, except
+// for ErrOplogGap, which requires the database to be seeded again.
+func (f *Follower) Follow(ctx context.Context, addr, path string) error {
+	local, err := resolve(f.root, path)
+	if err != nil {
+		return err
+	}
+	c, err := f.pool.Get(local)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	after, err := c.AppliedOplog()
+	if err != nil {
+		return err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if err := json.NewEncoder(conn).Encode(request{Path: filepath.ToSlash(path), After: after}); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(conn)
+	for {
+		var b batch
+		if err := dec.Decode(&b); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if b.Gap {
+			return fmt.Errorf("replication: primary: %w", ErrOplogGap)
+		}
+		if b.Error != "" {
+			return fmt.Errorf("replication: primary: %s", b.Error)
+		}
+		if err := checkSequences(b.Entries, after); err != nil {
+			return fmt.Errorf("replication: %w", err)
+		}
+		if err := c.ApplyOplog(b.Entries); err != nil {
+			return err
+		}
+		if len(b.Entries) > 0 {
+			after = b.Entries[len(b.Entries)-1].Sequence
+		}
+	}
+}
+
+// checkSequences returns ErrOplogGap if sequences of entries do not
+// directly follow the sequence after.
+func checkSequences(entries []boltdbpool.OplogEntry, after uint64) error {
+	for _, e := range entries {
+		if e.Sequence != after+1 {
+			return fmt.Errorf("%w: expected sequence %d, got %d", ErrOplogGap, after+1, e.Sequence)
+		}
+		after = e.Sequence
+	}
+	return nil
+}
+
+// resolve returns the path under the root directory, rejecting paths that
+// are absolute or that reference parent directories.
+func resolve(root, path string) (string, error) {
+	path = filepath.FromSlash(path)
+	if path == "" || filepath.IsAbs(path) {
+		return "", ErrInvalidPath
+	}
+	clean := filepath.Clean(path)
+	if clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", ErrInvalidPath
+	}
+	return filepath.Join(root, clean), nil
+}
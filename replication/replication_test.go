@@ -0,0 +1,189 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"resenje.org/boltdbpool"
+)
+
+func TestReplication(t *testing.T) {
+	primaryDir, followerDir := t.TempDir(), t.TempDir()
+	primaryPool := boltdbpool.New(&boltdbpool.Options{
+		Oplog: true,
+	})
+	defer primaryPool.Close()
+	followerPool := boltdbpool.New(nil)
+	defer followerPool.Close()
+
+	pc, err := primaryPool.Get(filepath.Join(primaryDir, "data", "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	for i := 0; i < 5; i++ {
+		if err := pc.PutValue([]byte("users"), []byte(fmt.Sprint(i)), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary := NewPrimary(primaryPool, primaryDir)
+	primary.ErrorHandler = func(err error) {
+		t.Error(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- primary.Serve(l)
+	}()
+	defer func() {
+		l.Close()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	follower := NewFollower(followerPool, followerDir)
+	followDone := make(chan error, 1)
+	go func() {
+		followDone <- follower.Follow(ctx, l.Addr().String(), "data/app.db")
+	}()
+
+	// changes after the follower connected
+	if err := pc.DeleteValue([]byte("users"), []byte("0")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pc.Increment([]byte("counters"), []byte("n"), 7); err != nil {
+		t.Fatal(err)
+	}
+
+	fc, err := followerPool.Get(filepath.Join(followerDir, "data", "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fc.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		seq, err := fc.AppliedOplog()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seq == 7 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("applied sequence %d, expected 7", seq)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-followDone; !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, expected %v", err, context.Canceled)
+	}
+
+	if v, err := fc.GetValue([]byte("users"), []byte("0")); err != nil || v != nil {
+		t.Errorf("got deleted value %q (%v)", v, err)
+	}
+	if v, err := fc.GetValue([]byte("users"), []byte("4")); err != nil || string(v) != "v" {
+		t.Errorf("got value %q (%v)", v, err)
+	}
+	if v, err := fc.Increment([]byte("counters"), []byte("n"), 0); err != nil || v != 7 {
+		t.Errorf("got counter %d (%v)", v, err)
+	}
+
+	if err := pc.TrimOplog(7); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := pc.Oplog(0, 0); err != nil || len(entries) != 0 {
+		t.Errorf("got %d entries after trim (%v)", len(entries), err)
+	}
+}
+
+func TestFollowInvalidPath(t *testing.T) {
+	follower := NewFollower(boltdbpool.New(nil), t.TempDir())
+	for _, path := range []string{"", "/etc/passwd", "../x.db", "a/../../x.db"} {
+		if err := follower.Follow(context.Background(), "127.0.0.1:0", path); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("%q: got error %v, expected %v", path, err, ErrInvalidPath)
+		}
+	}
+}
+
+func TestFollowOplogGap(t *testing.T) {
+	primaryDir := t.TempDir()
+	primaryPool := boltdbpool.New(&boltdbpool.Options{
+		Oplog: true,
+	})
+	defer primaryPool.Close()
+	followerPool := boltdbpool.New(nil)
+	defer followerPool.Close()
+
+	pc, err := primaryPool.Get(filepath.Join(primaryDir, "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	for i := 0; i < 3; i++ {
+		if err := pc.PutValue([]byte("users"), []byte(fmt.Sprint(i)), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pc.TrimOplog(1); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary := NewPrimary(primaryPool, primaryDir)
+	primaryErrors := make(chan error, 1)
+	primary.ErrorHandler = func(err error) {
+		primaryErrors <- err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- primary.Serve(l)
+	}()
+	defer func() {
+		l.Close()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	follower := NewFollower(followerPool, t.TempDir())
+	if err := follower.Follow(context.Background(), l.Addr().String(), "app.db"); !errors.Is(err, ErrOplogGap) {
+		t.Errorf("got error %v, expected %v", err, ErrOplogGap)
+	}
+	if err := <-primaryErrors; !errors.Is(err, ErrOplogGap) {
+		t.Errorf("got primary error %v, expected %v", err, ErrOplogGap)
+	}
+}
+
+func TestCheckSequences(t *testing.T) {
+	entries := []boltdbpool.OplogEntry{{Sequence: 3}, {Sequence: 4}}
+	if err := checkSequences(entries, 2); err != nil {
+		t.Error(err)
+	}
+	if err := checkSequences(entries, 1); !errors.Is(err, ErrOplogGap) {
+		t.Errorf("got error %v, expected %v", err, ErrOplogGap)
+	}
+	if err := checkSequences(append(entries, boltdbpool.OplogEntry{Sequence: 6}), 2); !errors.Is(err, ErrOplogGap) {
+		t.Errorf("got error %v, expected %v", err, ErrOplogGap)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GetRotated returns a connection to the latest generation of the database
+// with the base path. Generations are database files named by appending a
+// dot and a generation number, starting from 1, to the base path, like
+// "events.db.1". When the latest generation file size exceeds
+// Options.RotateSize, a new generation is created.
+func (p *Pool) GetRotated(basePath string) (*Connection, error) {
+	p.rotateMu.Lock()
+	defer p.rotateMu.Unlock()
+
+	generations, err := p.Generations(basePath)
+	if err != nil {
+		return nil, err
+	}
+	n := 1
+	if l := len(generations); l > 0 {
+		n = generations[l-1]
+		if max := p.options.RotateSize; max > 0 {
+			info, err := p.fs.Stat(GenerationPath(basePath, n))
+			if err != nil {
+				return nil, err
+			}
+			if info.Size() > max {
+				n++
+			}
+		}
+	}
+	return p.Get(GenerationPath(basePath, n))
+}
+
+// Generations returns sorted generation numbers of existing database files
+// with the base path, as created by GetRotated.
+func (p *Pool) Generations(basePath string) ([]int, error) {
+	basePath, err := p.normalizePath(basePath)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := Glob(p.fs, escapeGlob(basePath)+".*")
+	if err != nil {
+		return nil, err
+	}
+	generations := make([]int, 0, len(matches))
+	for _, m := range matches {
+		n, err := strconv.Atoi(strings.TrimPrefix(m, basePath+"."))
+		if err != nil || n < 1 {
+			continue
+		}
+		generations = append(generations, n)
+	}
+	sort.Ints(generations)
+	return generations, nil
+}
+
+// GenerationPath returns the path of the database file for the generation
+// of the database with the base path.
+func GenerationPath(basePath string, generation int) string {
+	return basePath + "." + strconv.Itoa(generation)
+}
+
+// escapeGlob escapes characters that have a special meaning in
+// filepath.Match patterns. Escaping is not supported on Windows.
+func escapeGlob(path string) string {
+	var b strings.Builder
+	for _, r := range path {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			if os.PathSeparator != '\\' {
+				b.WriteRune('\\')
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGetRotated(t *testing.T) {
+	pool := New(&Options{
+		RotateSize: 64 * 1024,
+	})
+	defer pool.Close()
+
+	basePath := filepath.Join(t.TempDir(), "events[1].db")
+
+	c, err := pool.GetRotated(basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := c.DB.Path(); p != GenerationPath(basePath, 1) {
+		t.Errorf("got path %s, expected %s", p, GenerationPath(basePath, 1))
+	}
+	fragment(t, c.DB)
+	c.Close()
+
+	c, err = pool.GetRotated(basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := c.DB.Path(); p != GenerationPath(basePath, 2) {
+		t.Errorf("got path %s, expected %s", p, GenerationPath(basePath, 2))
+	}
+	c.Close()
+
+	if err := os.WriteFile(basePath+".backup", nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	generations, err := pool.Generations(basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(generations, []int{1, 2}) {
+		t.Errorf("got generations %v, expected [1 2]", generations)
+	}
+
+	c, err = pool.GetRotated(basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := c.DB.Path(); p != GenerationPath(basePath, 2) {
+		t.Errorf("got path %s, expected %s", p, GenerationPath(basePath, 2))
+	}
+	c.Close()
+}
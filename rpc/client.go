@@ -0,0 +1,109 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"io"
+	"net"
+	netrpc "net/rpc"
+	"net/rpc/jsonrpc"
+
+	"resenje.org/boltdbpool"
+)
+
+// Client calls pool methods on a remote Server.
+type Client struct {
+	client *netrpc.Client
+}
+
+// Dial connects to the Server on the TCP address.
+func Dial(addr string) (*Client, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient returns a Client that communicates over the connection.
+func NewClient(conn io.ReadWriteCloser) *Client {
+	return &Client{
+		client: jsonrpc.NewClient(conn),
+	}
+}
+
+// Get returns the value of the key in the bucket of the database on the
+// path, or nil if the key does not exist.
+func (c *Client) Get(path string, bucket, key []byte) ([]byte, error) {
+	var reply ValueReply
+	if err := c.call("Get", &KeyArgs{Path: path, Bucket: bucket, Key: key}, &reply); err != nil {
+		return nil, err
+	}
+	if !reply.Found {
+		return nil, nil
+	}
+	if reply.Value == nil {
+		return []byte{}, nil
+	}
+	return reply.Value, nil
+}
+
+// Put stores the value under the key in the bucket of the database on the
+// path.
+func (c *Client) Put(path string, bucket, key, value []byte) error {
+	return c.call("Put", &PutArgs{Path: path, Bucket: bucket, Key: key, Value: value}, &Empty{})
+}
+
+// Delete removes the key from the bucket of the database on the path.
+func (c *Client) Delete(path string, bucket, key []byte) error {
+	return c.call("Delete", &KeyArgs{Path: path, Bucket: bucket, Key: key}, &Empty{})
+}
+
+// Scan returns keys and values from the bucket of the database on the path
+// as described by ScanArgs.
+func (c *Client) Scan(args ScanArgs) (*ScanReply, error) {
+	var reply ScanReply
+	if err := c.call("Scan", &args, &reply); err != nil {
+		return nil, err
+	}
+	return &reply, nil
+}
+
+// Backup writes a consistent copy of the database on the path to w.
+func (c *Client) Backup(path string, w io.Writer) (int64, error) {
+	var reply BackupReply
+	if err := c.call("Backup", &PathArgs{Path: path}, &reply); err != nil {
+		return 0, err
+	}
+	n, err := w.Write(reply.Data)
+	return int64(n), err
+}
+
+// Stats returns information about databases open in the remote pool.
+func (c *Client) Stats() (boltdbpool.Stats, error) {
+	var reply boltdbpool.Stats
+	err := c.call("Stats", &Empty{}, &reply)
+	return reply, err
+}
+
+// Close closes the connection to the Server.
+func (c *Client) Close() error {
+	return c.client.Close()
+}
+
+// call invokes the service method and maps errors returned by the server
+// to package errors.
+func (c *Client) call(method string, args, reply interface{}) error {
+	err := c.client.Call(ServiceName+"."+method, args, reply)
+	if e, ok := err.(netrpc.ServerError); ok {
+		for _, known := range []error{ErrInvalidPath, ErrUnknownDB} {
+			if string(e) == known.Error() {
+				return known
+			}
+		}
+	}
+	return err
+}
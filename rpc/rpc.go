@@ -0,0 +1,295 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package rpc exposes databases of a boltdbpool.Pool to remote clients.
+//
+// The service is served with the standard library net/rpc package and the
+// JSON-RPC 1.0 codec from net/rpc/jsonrpc, so that it can be used from any
+// language that has a JSON-RPC client, without linking bolt. Methods are
+// registered under the "Pool" service name: Pool.Get, Pool.Put,
+// Pool.Delete, Pool.Scan, Pool.Backup and Pool.Stats.
+//
+// Database paths in requests are relative to the server root directory.
+package rpc // import "resenje.org/boltdbpool/rpc"
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	netrpc "net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"path/filepath"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+
+	"resenje.org/boltdbpool"
+)
+
+var (
+	// ErrInvalidPath is returned for database paths that are not relative
+	// to the root directory.
+	ErrInvalidPath = errors.New("invalid database path")
+	// ErrUnknownDB is returned by read operations on database files that do
+	// not exist.
+	ErrUnknownDB = errors.New("unknown database")
+)
+
+// ServiceName is the name under which the pool methods are registered.
+const ServiceName = "Pool"
+
+// KeyArgs identifies a single key in a bucket of a database.
+type KeyArgs struct {
+	Path   string `json:"path"`
+	Bucket []byte `json:"bucket"`
+	Key    []byte `json:"key"`
+}
+
+// PutArgs are arguments for the Pool.Put method.
+type PutArgs struct {
+	Path   string `json:"path"`
+	Bucket []byte `json:"bucket"`
+	Key    []byte `json:"key"`
+	Value  []byte `json:"value"`
+}
+
+// ValueReply is the reply of the Pool.Get method. Found is false if the key
+// does not exist.
+type ValueReply struct {
+	Value []byte `json:"value"`
+	Found bool   `json:"found"`
+}
+
+// ScanArgs are arguments for the Pool.Scan method. Only keys with the
+// prefix that are greater than After are returned, at most Limit of them if
+// Limit is greater than zero.
+type ScanArgs struct {
+	Path   string `json:"path"`
+	Bucket []byte `json:"bucket"`
+	Prefix []byte `json:"prefix,omitempty"`
+	After  []byte `json:"after,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+// Item is a key and value pair returned by the Pool.Scan method.
+type Item struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// ScanReply is the reply of the Pool.Scan method. More is true if the
+// limit was reached before all keys were returned.
+type ScanReply struct {
+	Items []Item `json:"items"`
+	More  bool   `json:"more"`
+}
+
+// PathArgs identifies a database.
+type PathArgs struct {
+	Path string `json:"path"`
+}
+
+// BackupReply is the reply of the Pool.Backup method that holds a
+// consistent copy of the database.
+type BackupReply struct {
+	Data []byte `json:"data"`
+}
+
+// Empty is the argument or the reply of methods that do not have one.
+type Empty struct{}
+
+// Service implements the remote pool methods.
+type Service struct {
+	pool *boltdbpool.Pool
+	root string
+}
+
+// Get returns the value of the key, decoded by the pool.
+func (s *Service) Get(args *KeyArgs, reply *ValueReply) error {
+	c, err := s.getExisting(args.Path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	v, err := c.GetValue(args.Bucket, args.Key)
+	if err != nil {
+		return err
+	}
+	reply.Value, reply.Found = v, v != nil
+	return nil
+}
+
+// Put stores the value under the key, creating the database and the bucket
+// if they do not exist.
+func (s *Service) Put(args *PutArgs, _ *Empty) error {
+	path, err := resolve(s.root, args.Path)
+	if err != nil {
+		return err
+	}
+	c, err := s.pool.Get(path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.PutValue(args.Bucket, args.Key, args.Value)
+}
+
+// Delete removes the key.
+func (s *Service) Delete(args *KeyArgs, _ *Empty) error {
+	c, err := s.getExisting(args.Path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.DeleteValue(args.Bucket, args.Key)
+}
+
+// Scan returns keys and decoded values from the bucket in key order.
+func (s *Service) Scan(args *ScanArgs, reply *ScanReply) error {
+	c, err := s.getExisting(args.Path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	reply.Items = []Item{}
+	return c.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(args.Bucket)
+		if b == nil {
+			return nil
+		}
+		cur := b.Cursor()
+		k, v := cur.Seek(args.Prefix)
+		if args.After != nil && bytes.Compare(args.After, args.Prefix) >= 0 {
+			k, v = cur.Seek(args.After)
+			if bytes.Equal(k, args.After) {
+				k, v = cur.Next()
+			}
+		}
+		for ; k != nil && bytes.HasPrefix(k, args.Prefix); k, v = cur.Next() {
+			if v == nil {
+				// nested bucket
+				continue
+			}
+			if args.Limit > 0 && len(reply.Items) == args.Limit {
+				reply.More = true
+				return nil
+			}
+			value, err := s.pool.DecodeValue(args.Bucket, k, v)
+			if err != nil {
+				return err
+			}
+			reply.Items = append(reply.Items, Item{
+				Key:   append([]byte(nil), k...),
+				Value: value,
+			})
+		}
+		return nil
+	})
+}
+
+// Backup returns a consistent copy of the database.
+func (s *Service) Backup(args *PathArgs, reply *BackupReply) error {
+	c, err := s.getExisting(args.Path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	var buf bytes.Buffer
+	if _, err := c.Backup(&buf); err != nil {
+		return err
+	}
+	reply.Data = buf.Bytes()
+	return nil
+}
+
+// Stats returns information about databases open in the pool. Paths are
+// relative to the root directory.
+func (s *Service) Stats(_ *Empty, reply *boltdbpool.Stats) error {
+	*reply = s.pool.Stats()
+	databases := reply.Databases[:0]
+	for _, d := range reply.Databases {
+		rel, err := filepath.Rel(s.root, d.Path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		d.Path = filepath.ToSlash(rel)
+		databases = append(databases, d)
+	}
+	reply.Databases = databases
+	return nil
+}
+
+// getExisting returns a connection to the database on the path relative to
+// the root directory without creating a new database file.
+func (s *Service) getExisting(path string) (*boltdbpool.Connection, error) {
+	path, err := resolve(s.root, path)
+	if err != nil {
+		return nil, err
+	}
+	if !s.pool.Has(path) {
+		if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+			return nil, ErrUnknownDB
+		}
+	}
+	return s.pool.Get(path)
+}
+
+// Server serves the pool methods to remote clients.
+type Server struct {
+	server *netrpc.Server
+}
+
+// NewServer returns a Server for databases under the root directory opened
+// through the pool.
+func NewServer(pool *boltdbpool.Pool, root string) (*Server, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	server := netrpc.NewServer()
+	if err := server.RegisterName(ServiceName, &Service{
+		pool: pool,
+		root: root,
+	}); err != nil {
+		return nil, err
+	}
+	return &Server{
+		server: server,
+	}, nil
+}
+
+// Serve accepts connections on the listener until it is closed.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+// resolve returns the path under the root directory, rejecting paths that
+// are absolute or that reference parent directories.
+func resolve(root, path string) (string, error) {
+	path = filepath.FromSlash(path)
+	if path == "" || filepath.IsAbs(path) {
+		return "", ErrInvalidPath
+	}
+	clean := filepath.Clean(path)
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", ErrInvalidPath
+	}
+	return filepath.Join(root, clean), nil
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"resenje.org/boltdbpool"
+)
+
+func newTestClient(t *testing.T, root string) *Client {
+	t.Helper()
+
+	pool := boltdbpool.New(&boltdbpool.Options{
+		ConnectionExpires: time.Minute,
+	})
+	t.Cleanup(pool.Close)
+
+	server, err := NewServer(pool, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve(l)
+	}()
+	t.Cleanup(func() {
+		l.Close()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+
+	client, err := Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+	})
+	return client
+}
+
+func TestClient(t *testing.T) {
+	root := t.TempDir()
+	client := newTestClient(t, root)
+
+	bucket := []byte("users")
+
+	if _, err := client.Get("app.db", bucket, []byte("a")); err != ErrUnknownDB {
+		t.Errorf("got error %v, expected %v", err, ErrUnknownDB)
+	}
+	if err := client.Put("../app.db", bucket, []byte("a"), []byte("1")); err != ErrInvalidPath {
+		t.Errorf("got error %v, expected %v", err, ErrInvalidPath)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := client.Put("data/app.db", bucket, []byte(fmt.Sprint("k", i)), []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := client.Put("data/app.db", bucket, []byte("empty"), []byte{}); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := client.Get("data/app.db", bucket, []byte("k2"))
+	if err != nil || string(v) != "2" {
+		t.Errorf("got value %q (%v)", v, err)
+	}
+	v, err = client.Get("data/app.db", bucket, []byte("empty"))
+	if err != nil || v == nil || len(v) != 0 {
+		t.Errorf("got empty value %q (%v)", v, err)
+	}
+
+	if err := client.Delete("data/app.db", bucket, []byte("k2")); err != nil {
+		t.Fatal(err)
+	}
+	v, err = client.Get("data/app.db", bucket, []byte("k2"))
+	if err != nil || v != nil {
+		t.Errorf("got deleted value %q (%v)", v, err)
+	}
+
+	r, err := client.Scan(ScanArgs{Path: "data/app.db", Bucket: bucket, Prefix: []byte("k"), After: []byte("k0"), Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Items) != 2 || string(r.Items[0].Key) != "k1" || string(r.Items[1].Key) != "k3" || !r.More {
+		t.Errorf("got scan reply %+v", r)
+	}
+	r, err = client.Scan(ScanArgs{Path: "data/app.db", Bucket: bucket, Prefix: []byte("k"), After: []byte("k3")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Items) != 1 || string(r.Items[0].Key) != "k4" || string(r.Items[0].Value) != "4" || r.More {
+		t.Errorf("got scan reply %+v", r)
+	}
+
+	var buf bytes.Buffer
+	n, err := client.Backup("data/app.db", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) || n == 0 {
+		t.Errorf("got backup size %d", n)
+	}
+	backup := filepath.Join(t.TempDir(), "backup.db")
+	if err := os.WriteFile(backup, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	db, err := bolt.Open(backup, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(bucket).Get([]byte("k4")); string(v) != "4" {
+			t.Errorf("got backup value %q", v)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := client.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stats.Healthy || len(stats.Databases) != 1 || stats.Databases[0].Path != "data/app.db" {
+		t.Errorf("got stats %+v", stats)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Scan finds existing database files under the directory and its
+// subdirectories, without opening them, and returns their sorted paths.
+// File names are matched against the pattern with the filepath.Match
+// syntax, like "*.db". Hidden files and directories, including temporary
+// files of the pool, are skipped. Found databases are reported by
+// Pool.Known and in Stats, and registered under their paths relative to
+// the directory, with forward slashes and without the file extension, if
+// those names are not already registered.
+func (p *Pool) Scan(dir, pattern string) ([]string, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	dir, err := p.normalizePath(dir)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := p.scanDir(dir, pattern, nil)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	p.mu.Lock()
+	for _, path := range paths {
+		p.known[path] = struct{}{}
+	}
+	p.mu.Unlock()
+
+	p.namesMu.Lock()
+	defer p.namesMu.Unlock()
+
+	for _, path := range paths {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			continue
+		}
+		name := filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel)))
+		if _, ok := p.names[name]; !ok {
+			p.names[name] = path
+		}
+	}
+	return paths, nil
+}
+
+// scanDir appends paths of files under the directory with names that
+// match the pattern.
+func (p *Pool) scanDir(dir, pattern string, paths []string) ([]string, error) {
+	entries, err := p.fs.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if e.IsDir() {
+			if paths, err = p.scanDir(path, pattern, paths); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if !e.Type().IsRegular() {
+			continue
+		}
+		if matched, _ := filepath.Match(pattern, name); matched {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// Known returns sorted paths of databases found by Scan that are not
+// removed by the pool.
+func (p *Pool) Known() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	paths := make([]string, 0, len(p.known))
+	for path := range p.known {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// moveKnown transfers the database found by Scan to its new path. Pool
+// lock must be held.
+func (p *Pool) moveKnown(oldPath, newPath string) {
+	if _, ok := p.known[oldPath]; ok {
+		delete(p.known, oldPath)
+		p.known[newPath] = struct{}{}
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"a.db",
+		"b.txt",
+		".hidden.db",
+		filepath.Join("tenants", "c.db"),
+		filepath.Join(".tmp", "d.db"),
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pool := New(nil)
+	defer pool.Close()
+
+	if err := pool.Register("a", "/other/a.db"); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := pool.Scan(dir, "*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(dir, "a.db"),
+		filepath.Join(dir, "tenants", "c.db"),
+	}
+	if fmt.Sprint(paths) != fmt.Sprint(want) {
+		t.Errorf("got paths %v, expected %v", paths, want)
+	}
+	if got := pool.Known(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got known %v, expected %v", got, want)
+	}
+	if got := pool.Stats().Known; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got stats known %v, expected %v", got, want)
+	}
+	for _, path := range want {
+		if pool.Has(path) {
+			t.Errorf("scanned database %s is open", path)
+		}
+	}
+
+	// registered names are not replaced
+	if path, _ := pool.NamedPath("a"); path != "/other/a.db" {
+		t.Errorf("got path %q for name a, expected /other/a.db", path)
+	}
+	if path, _ := pool.NamedPath("tenants/c"); path != want[1] {
+		t.Errorf("got path %q for name tenants/c, expected %q", path, want[1])
+	}
+
+	if err := pool.Remove(want[1]); err != nil {
+		t.Fatal(err)
+	}
+	if got := pool.Known(); fmt.Sprint(got) != fmt.Sprint(want[:1]) {
+		t.Errorf("got known %v after remove, expected %v", got, want[:1])
+	}
+}
+
+func TestScanBadPattern(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	if _, err := pool.Scan(t.TempDir(), "["); err != filepath.ErrBadPattern {
+		t.Errorf("got error %v, expected %v", err, filepath.ErrBadPattern)
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sessions provides HTTP sessions stored in databases managed by
+// boltdbpool.Pool. The Store has the same methods as the Store interface of
+// github.com/gorilla/sessions, and Session the same fields, so that it can
+// be used through a thin adapter without adding the dependency to the pool.
+package sessions // import "resenje.org/boltdbpool/sessions"
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"resenje.org/boltdbpool"
+	"resenje.org/boltdbpool/timed"
+	"resenje.org/boltdbpool/ttlstore"
+)
+
+// ErrInvalidID is returned when a session ID from a cookie is malformed.
+var ErrInvalidID = errors.New("invalid session id")
+
+// Bucket is the name of the bucket that holds sessions.
+const Bucket = "sessions"
+
+// Options are cookie options of a session.
+type Options struct {
+	Path   string
+	Domain string
+	// MaxAge is the session lifetime in seconds. A session with MaxAge < 0
+	// is deleted when it is saved.
+	MaxAge   int
+	Secure   bool
+	HttpOnly bool
+	SameSite http.SameSite
+}
+
+// Session holds values of a single session.
+type Session struct {
+	// ID is generated when a new session is saved.
+	ID      string
+	Values  map[interface{}]interface{}
+	Options *Options
+	IsNew   bool
+
+	name  string
+	store *Store
+}
+
+// NewSession returns a new session with the name.
+func NewSession(store *Store, name string) *Session {
+	opts := *store.Options
+	return &Session{
+		Values:  map[interface{}]interface{}{},
+		Options: &opts,
+		IsNew:   true,
+		name:    name,
+		store:   store,
+	}
+}
+
+// Name returns the name of the session and its cookie.
+func (s *Session) Name() string {
+	return s.name
+}
+
+// Save stores the session and writes its cookie to the response.
+func (s *Session) Save(r *http.Request, w http.ResponseWriter) error {
+	return s.store.Save(r, w, s)
+}
+
+// Store keeps sessions with expiration in pooled databases. Expired
+// sessions are removed by the ttlstore sweeper.
+type Store struct {
+	// Options are the default options of new sessions.
+	Options *Options
+
+	values *ttlstore.Store
+	path   func(created time.Time) string
+	now    func() time.Time
+}
+
+// New returns a Store that keeps sessions in the database on path and
+// removes expired sessions every sweepInterval.
+func New(pool *boltdbpool.Pool, path string, sweepInterval time.Duration) *Store {
+	return newStore(pool, func(time.Time) string { return path }, sweepInterval)
+}
+
+// NewTimed returns a Store that keeps every session in the database of the
+// timed pool for the time when the session was created, so that databases
+// with sessions from past periods can be removed as a whole.
+func NewTimed(pool *timed.Pool, sweepInterval time.Duration) *Store {
+	return newStore(pool.Pool(), pool.Path, sweepInterval)
+}
+
+func newStore(pool *boltdbpool.Pool, path func(time.Time) string, sweepInterval time.Duration) *Store {
+	return &Store{
+		Options: &Options{
+			Path:     "/",
+			MaxAge:   86400 * 30,
+			HttpOnly: true,
+		},
+		values: ttlstore.New(pool, &ttlstore.Options{
+			Bucket:        Bucket,
+			SweepInterval: sweepInterval,
+		}),
+		path: path,
+		now:  time.Now,
+	}
+}
+
+// Get returns the session with the name from the request cookie, or a new
+// session if the cookie is not present or the session has expired.
+func (s *Store) Get(r *http.Request, name string) (*Session, error) {
+	return s.New(r, name)
+}
+
+// New returns the session with the name from the request cookie, or a new
+// session. As with gorilla/sessions, a new session is returned together
+// with the error if the stored session can not be loaded.
+func (s *Store) New(r *http.Request, name string) (*Session, error) {
+	session := NewSession(s, name)
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	path, err := s.pathForID(cookie.Value)
+	if err != nil {
+		return session, err
+	}
+	data, err := s.values.Get(path, []byte(cookie.Value))
+	if err != nil {
+		return session, err
+	}
+	if data == nil {
+		return session, nil
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&session.Values); err != nil {
+		return session, err
+	}
+	session.ID = cookie.Value
+	session.IsNew = false
+	return session, nil
+}
+
+// Save stores the session values and sets the session cookie. Sessions
+// with negative MaxAge are deleted and their cookies removed.
+func (s *Store) Save(r *http.Request, w http.ResponseWriter, session *Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			path, err := s.pathForID(session.ID)
+			if err != nil {
+				return err
+			}
+			if err := s.values.Delete(path, []byte(session.ID)); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, s.cookie(session, ""))
+		return nil
+	}
+	if session.ID == "" {
+		id, err := s.newID()
+		if err != nil {
+			return err
+		}
+		session.ID = id
+	}
+	path, err := s.pathForID(session.ID)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(session.Values); err != nil {
+		return err
+	}
+	ttl := time.Duration(session.Options.MaxAge) * time.Second
+	if err := s.values.Put(path, []byte(session.ID), buf.Bytes(), ttl); err != nil {
+		return err
+	}
+	http.SetCookie(w, s.cookie(session, session.ID))
+	return nil
+}
+
+// Close stops the background removal of expired sessions.
+func (s *Store) Close() {
+	s.values.Close()
+}
+
+func (s *Store) cookie(session *Session, value string) *http.Cookie {
+	o := session.Options
+	c := &http.Cookie{
+		Name:     session.name,
+		Value:    value,
+		Path:     o.Path,
+		Domain:   o.Domain,
+		MaxAge:   o.MaxAge,
+		Secure:   o.Secure,
+		HttpOnly: o.HttpOnly,
+		SameSite: o.SameSite,
+	}
+	if o.MaxAge > 0 {
+		c.Expires = s.now().Add(time.Duration(o.MaxAge) * time.Second)
+	} else if o.MaxAge < 0 {
+		c.Expires = time.Unix(1, 0)
+	}
+	return c
+}
+
+// newID returns a random session ID prefixed by the creation time, which
+// selects the database that holds the session.
+func (s *Store) newID() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(s.now().Unix(), 16) + "-" + hex.EncodeToString(b), nil
+}
+
+// pathForID returns the database path for the session ID.
+func (s *Store) pathForID(id string) (string, error) {
+	created, random, ok := strings.Cut(id, "-")
+	if !ok || len(random) != 48 {
+		return "", ErrInvalidID
+	}
+	if _, err := hex.DecodeString(random); err != nil {
+		return "", ErrInvalidID
+	}
+	sec, err := strconv.ParseInt(created, 16, 64)
+	if err != nil {
+		return "", ErrInvalidID
+	}
+	return s.path(time.Unix(sec, 0)), nil
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sessions
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"resenje.org/boltdbpool"
+	"resenje.org/boltdbpool/timed"
+)
+
+func TestStore(t *testing.T) {
+	pool := boltdbpool.New(nil)
+	defer pool.Close()
+
+	store := New(pool, filepath.Join(t.TempDir(), "sessions.db"), 0)
+	defer store.Close()
+
+	testStore(t, store)
+}
+
+func TestStoreTimed(t *testing.T) {
+	pool, err := timed.New(t.TempDir(), timed.Daily, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	store := NewTimed(pool, 0)
+	defer store.Close()
+
+	testStore(t, store)
+
+	if _, err := os.Stat(pool.Path(time.Now())); err != nil {
+		t.Errorf("session not stored in the current period database: %v", err)
+	}
+}
+
+func testStore(t *testing.T, store *Store) {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	s, err := store.Get(r, "sid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.IsNew {
+		t.Error("session without cookie is not new")
+	}
+	s.Values["user"] = "ana"
+	s.Values[42] = 3.5
+	w := httptest.NewRecorder()
+	if err := s.Save(r, w); err != nil {
+		t.Fatal(err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "sid" || cookies[0].Value != s.ID {
+		t.Fatalf("got cookies %v", cookies)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookies[0])
+	s, err = store.Get(r, "sid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.IsNew {
+		t.Error("stored session is new")
+	}
+	if s.Values["user"] != "ana" || s.Values[42] != 3.5 {
+		t.Errorf("got values %v", s.Values)
+	}
+
+	// delete
+	s.Options.MaxAge = -1
+	w = httptest.NewRecorder()
+	if err := s.Save(r, w); err != nil {
+		t.Fatal(err)
+	}
+	if c := w.Result().Cookies(); len(c) != 1 || c[0].MaxAge >= 0 {
+		t.Errorf("got cookies %v", c)
+	}
+	s, err = store.Get(r, "sid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.IsNew {
+		t.Error("deleted session is loaded")
+	}
+}
+
+func TestStoreInvalidCookie(t *testing.T) {
+	pool := boltdbpool.New(nil)
+	defer pool.Close()
+
+	store := New(pool, filepath.Join(t.TempDir(), "sessions.db"), 0)
+	defer store.Close()
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "sid", Value: "../../etc"})
+	s, err := store.Get(r, "sid")
+	if !errors.Is(err, ErrInvalidID) {
+		t.Errorf("got error %v, expected %v", err, ErrInvalidID)
+	}
+	if s == nil || !s.IsNew {
+		t.Error("new session not returned with the error")
+	}
+}
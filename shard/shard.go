@@ -0,0 +1,149 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package shard distributes keys over a fixed set of BoltDB databases by
+// consistent hashing, to spread write load over multiple files.
+package shard // import "resenje.org/boltdbpool/shard"
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	bolt "go.etcd.io/bbolt"
+
+	"resenje.org/boltdbpool"
+)
+
+// ErrInvalidCount is returned by New when the number of shards is not
+// positive.
+var ErrInvalidCount = errors.New("invalid number of shards")
+
+// replicas is the number of points on the hash ring for every shard.
+const replicas = 64
+
+// Set is a fixed set of databases with keys routed to them by consistent
+// hashing.
+type Set struct {
+	pool  *boltdbpool.Pool
+	paths []string
+	ring  []point
+}
+
+type point struct {
+	hash  uint32
+	shard int
+}
+
+// New creates n database files in dir, if they do not exist, and returns
+// a Set that routes keys to them. Databases are opened through the pool.
+func New(pool *boltdbpool.Pool, dir string, n int) (*Set, error) {
+	if n <= 0 {
+		return nil, ErrInvalidCount
+	}
+	s := &Set{
+		pool:  pool,
+		paths: make([]string, n),
+		ring:  make([]point, 0, n*replicas),
+	}
+	for i := 0; i < n; i++ {
+		s.paths[i] = filepath.Join(dir, fmt.Sprintf("shard-%04d.db", i))
+		c, err := pool.Get(s.paths[i])
+		if err != nil {
+			return nil, err
+		}
+		c.Close()
+		for r := 0; r < replicas; r++ {
+			s.ring = append(s.ring, point{
+				hash:  crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + "-" + strconv.Itoa(r))),
+				shard: i,
+			})
+		}
+	}
+	sort.Slice(s.ring, func(i, j int) bool {
+		return s.ring[i].hash < s.ring[j].hash
+	})
+	return s, nil
+}
+
+// Len returns the number of shards.
+func (s *Set) Len() int {
+	return len(s.paths)
+}
+
+// Path returns the database file path of the shard.
+func (s *Set) Path(shard int) string {
+	return s.paths[shard]
+}
+
+// Shard returns the index of the shard that holds the key.
+func (s *Set) Shard(key []byte) int {
+	h := crc32.ChecksumIEEE(key)
+	i := sort.Search(len(s.ring), func(i int) bool {
+		return s.ring[i].hash >= h
+	})
+	if i == len(s.ring) {
+		i = 0
+	}
+	return s.ring[i].shard
+}
+
+// View executes the function in a read-only transaction of the shard that
+// holds the key.
+func (s *Set) View(key []byte, fn func(*bolt.Tx) error) error {
+	c, err := s.pool.Get(s.paths[s.Shard(key)])
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.View(fn)
+}
+
+// Update executes the function in a read-write transaction of the shard
+// that holds the key.
+func (s *Set) Update(key []byte, fn func(*bolt.Tx) error) error {
+	c, err := s.pool.Get(s.paths[s.Shard(key)])
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.Update(fn)
+}
+
+// Batch executes the function in a batched read-write transaction of the
+// shard that holds the key.
+func (s *Set) Batch(key []byte, fn func(*bolt.Tx) error) error {
+	c, err := s.pool.Get(s.paths[s.Shard(key)])
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.Batch(fn)
+}
+
+// ViewAll executes the function in read-only transactions of all shards,
+// in shard order, stopping on the first error.
+func (s *Set) ViewAll(fn func(shard int, tx *bolt.Tx) error) error {
+	for i, path := range s.paths {
+		c, err := s.pool.Get(path)
+		if err != nil {
+			return err
+		}
+		err = c.View(func(tx *bolt.Tx) error {
+			return fn(i, tx)
+		})
+		c.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
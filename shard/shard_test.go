@@ -0,0 +1,108 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package shard
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+
+	"resenje.org/boltdbpool"
+)
+
+func TestSet(t *testing.T) {
+	pool := boltdbpool.New(nil)
+	defer pool.Close()
+
+	s, err := New(pool, t.TempDir(), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < s.Len(); i++ {
+		if _, err := os.Stat(s.Path(i)); err != nil {
+			t.Errorf("shard %d: %v", i, err)
+		}
+	}
+
+	counts := make([]int, s.Len())
+	for i := 0; i < 1000; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		counts[s.Shard(key)]++
+		if err := s.Update(key, func(tx *bolt.Tx) error {
+			b, err := tx.CreateBucketIfNotExists([]byte("data"))
+			if err != nil {
+				return err
+			}
+			return b.Put(key, key)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, c := range counts {
+		if c < 100 {
+			t.Errorf("shard %d has only %d keys", i, c)
+		}
+	}
+
+	key := []byte("key-42")
+	if err := s.View(key, func(tx *bolt.Tx) error {
+		if v := string(tx.Bucket([]byte("data")).Get(key)); v != string(key) {
+			t.Errorf("got value %q, expected %q", v, key)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	total := 0
+	if err := s.ViewAll(func(shard int, tx *bolt.Tx) error {
+		n := tx.Bucket([]byte("data")).Stats().KeyN
+		if n != counts[shard] {
+			t.Errorf("shard %d: got %d keys, expected %d", shard, n, counts[shard])
+		}
+		total += n
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if total != 1000 {
+		t.Errorf("got %d keys, expected 1000", total)
+	}
+}
+
+func TestSetConsistent(t *testing.T) {
+	pool := boltdbpool.New(nil)
+	defer pool.Close()
+
+	s4, err := New(pool, t.TempDir(), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s5, err := New(pool, t.TempDir(), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved := 0
+	for i := 0; i < 1000; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		if s4.Shard(key) != s5.Shard(key) {
+			moved++
+		}
+	}
+	// adding a shard should move roughly a fifth of the keys
+	if moved > 400 {
+		t.Errorf("%d of 1000 keys moved to a different shard", moved)
+	}
+}
+
+func TestNewInvalidCount(t *testing.T) {
+	if _, err := New(nil, t.TempDir(), 0); !errors.Is(err, ErrInvalidCount) {
+		t.Errorf("got error %v, expected %v", err, ErrInvalidCount)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// shutdownPollInterval is the interval between checks of connection
+// references while the pool is shutting down.
+const shutdownPollInterval = 10 * time.Millisecond
+
+// Shutdown gracefully closes the pool. Get returns ErrClosed and the pool
+// is reported as not healthy from the moment Shutdown is called. Shutdown
+// waits until all connections are closed by their users or the context is
+// done, closes all databases in parallel and waits for background work to
+// finish. If the context is done before all connections are closed, the
+// databases are closed regardless and the context error is returned, joined
+// with errors from closing databases. Bolt waits for transactions in
+// progress before the database is closed. Close and CloseAll called while
+// Shutdown is in progress wait for it to finish.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if p.isClosed() || p.isDraining() {
+		p.mu.Unlock()
+		return ErrClosed
+	}
+	atomic.StoreInt32(&p.draining, 1)
+	p.mu.Unlock()
+
+	err := p.drain(ctx)
+
+	p.mu.Lock()
+	connections := p.detachAll()
+	if !p.isClosed() {
+		close(p.quit)
+	}
+	p.mu.Unlock()
+
+	errs := p.closeDetached(connections)
+	if len(errs) == 0 {
+		return err
+	}
+	return errors.Join(append([]error{err}, errs...)...)
+}
+
+// isDraining returns true if the pool is shutting down.
+func (p *Pool) isDraining() bool {
+	return atomic.LoadInt32(&p.draining) == 1
+}
+
+// drain blocks until no connection has references or the context is done.
+func (p *Pool) drain(ctx context.Context) error {
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for p.referenced() {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// referenced returns true if any connection in the pool has references.
+func (p *Pool) referenced() bool {
+	p.mu.RLock()
+	connections := make([]*Connection, 0, len(p.connections))
+	for _, c := range p.connections {
+		connections = append(connections, c)
+	}
+	p.mu.RUnlock()
+
+	// connection locks are not acquired under the pool lock, as
+	// Connection.Close acquires them in the reverse order
+	for _, c := range connections {
+		c.mu.RLock()
+		count := c.count
+		c.mu.RUnlock()
+		if count > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ShutdownOnSignal calls Shutdown with the timeout when the process
+// receives one of the signals, or os.Interrupt and SIGTERM if no signals
+// are provided. The returned channel receives the Shutdown result and it
+// is closed without a value if the pool is closed before a signal is
+// received.
+//
+//	done := pool.ShutdownOnSignal(10 * time.Second)
+//	// serve requests
+//	if err := <-done; err != nil {
+//		log.Print(err)
+//	}
+func (p *Pool) ShutdownOnSignal(timeout time.Duration, signals ...os.Signal) <-chan error {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, signals...)
+
+	done := make(chan error, 1)
+	go func() {
+		defer close(done)
+		defer signal.Stop(c)
+
+		select {
+		case <-c:
+		case <-p.quit:
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		done <- p.Shutdown(ctx)
+	}()
+	return done
+}
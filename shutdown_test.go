@@ -0,0 +1,128 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestShutdown(t *testing.T) {
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+	})
+	defer pool.Close()
+
+	dir := t.TempDir()
+	idle, err := pool.Get(filepath.Join(dir, "idle.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idle.Close()
+	c, err := pool.Get(filepath.Join(dir, "busy.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- pool.Shutdown(context.Background())
+	}()
+
+	// wait until the pool stops returning connections
+	for deadline := time.Now().Add(5 * time.Second); pool.Stats().Healthy; {
+		if time.Now().After(deadline) {
+			t.Fatal("pool is healthy while shutting down")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := pool.Get(filepath.Join(dir, "new.db")); err != ErrClosed {
+		t.Errorf("got error %v, expected %v", err, ErrClosed)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("shutdown returned %v while a connection has references", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := c.View(func(*bolt.Tx) error { return nil }); err != nil {
+		t.Error(err)
+	}
+	c.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if len(pool.Stats().Databases) != 0 {
+		t.Error("databases open after shutdown")
+	}
+	if err := pool.Shutdown(context.Background()); err != ErrClosed {
+		t.Errorf("got error %v, expected %v", err, ErrClosed)
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	pool := New(&Options{
+		// the connection is closed after the pool
+		ErrorHandler: func(error) {},
+	})
+	defer pool.Close()
+
+	c, err := pool.Get(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := pool.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("got error %v, expected %v", err, context.DeadlineExceeded)
+	}
+	if pool.Has(c.path) {
+		t.Error("database open after shutdown")
+	}
+}
+
+func TestShutdownClose(t *testing.T) {
+	pool := New(nil)
+
+	c, err := pool.Get(filepath.Join(t.TempDir(), "a.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- pool.Shutdown(context.Background())
+	}()
+	for deadline := time.Now().Add(5 * time.Second); pool.Stats().Healthy; {
+		if time.Now().After(deadline) {
+			t.Fatal("pool is healthy while shutting down")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		pool.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("close returned while shutdown is in progress")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	c.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	<-closed
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package boltdbpool
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestShutdownOnSignal(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	done := pool.ShutdownOnSignal(time.Second, syscall.SIGUSR1)
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Signal(syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pool not shut down")
+	}
+
+	pool = New(nil)
+	done = pool.ShutdownOnSignal(time.Second, syscall.SIGUSR1)
+	pool.Close()
+	if _, ok := <-done; ok {
+		t.Error("got shutdown result after close")
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Snapshot is a read-only copy of a database in a temporary file, which
+// gives long-running readers a stable view of the data without keeping a
+// read transaction open on the live database, which would prevent reuse of
+// its free pages and its compaction. Snapshots are reference counted and
+// their files are removed when all references are released or when the
+// pool is closed.
+type Snapshot struct {
+	DB *bolt.DB
+
+	pool   *Pool
+	path   string
+	count  int64
+	closed bool
+	mu     sync.Mutex
+}
+
+// Snapshot copies the connection database to a temporary file next to it
+// within a single read transaction and opens the copy read-only. The
+// returned snapshot has one reference that must be released with Close.
+func (c *Connection) Snapshot() (s *Snapshot, err error) {
+	p := c.pool
+	if p.isClosed() {
+		return nil, ErrClosed
+	}
+	path := snapshotTempPath(c.path)
+	defer func() {
+		if err != nil {
+			p.fs.Remove(path)
+			err = fmt.Errorf("boltdbpool: snapshot %s: %w", c.path, err)
+		}
+	}()
+
+	options, mode := p.boltOptions(c.path)
+	f, err := p.fs.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.View(func(tx *bolt.Tx) error {
+		_, err := tx.WriteTo(f)
+		return err
+	}); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	o := *options
+	o.ReadOnly = true
+	db, err := bolt.Open(path, mode, &o)
+	if err != nil {
+		return nil, err
+	}
+	s = &Snapshot{
+		DB:    db,
+		pool:  p,
+		path:  path,
+		count: 1,
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.isClosed() {
+		p.handleError(db.Close())
+		return nil, ErrClosed
+	}
+	p.snapshots[s] = struct{}{}
+	return s, nil
+}
+
+// snapshotTempPath returns a new path of a temporary snapshot file of the
+// database on path.
+func snapshotTempPath(path string) string {
+	return filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.snapshot-%d", filepath.Base(path), time.Now().UnixNano()))
+}
+
+// Path returns the path of the snapshot file.
+func (s *Snapshot) Path() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.path
+}
+
+// View executes a function within a read-only transaction on the snapshot
+// database.
+func (s *Snapshot) View(fn func(*bolt.Tx) error) error {
+	return s.DB.View(fn)
+}
+
+// Acquire adds a reference to the snapshot, which must be released with
+// Close.
+func (s *Snapshot) Acquire() *Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.count++
+	return s
+}
+
+// Close releases a reference to the snapshot. When all references are
+// released, the snapshot database is closed and its file is removed.
+func (s *Snapshot) Close() error {
+	s.mu.Lock()
+	s.count--
+	if s.count > 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	err := s.close()
+	s.mu.Unlock()
+
+	s.pool.mu.Lock()
+	delete(s.pool.snapshots, s)
+	s.pool.mu.Unlock()
+	return err
+}
+
+// close closes the snapshot database and removes its file. Snapshot lock
+// must be held.
+func (s *Snapshot) close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	err := s.DB.Close()
+	if rerr := s.pool.fs.Remove(s.path); err == nil {
+		err = rerr
+	}
+	if err != nil {
+		return fmt.Errorf("boltdbpool: close snapshot %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// closeSnapshots closes all snapshots regardless of their references and
+// returns errors that are also passed to the ErrorHandler.
+func (p *Pool) closeSnapshots() (errs []error) {
+	p.mu.Lock()
+	snapshots := make([]*Snapshot, 0, len(p.snapshots))
+	for s := range p.snapshots {
+		snapshots = append(snapshots, s)
+	}
+	p.snapshots = map[*Snapshot]struct{}{}
+	p.mu.Unlock()
+
+	for _, s := range snapshots {
+		s.mu.Lock()
+		if err := s.close(); err != nil {
+			p.handleError(err)
+			errs = append(errs, err)
+		}
+		s.mu.Unlock()
+	}
+	return errs
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestSnapshot(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.PutValue([]byte("bucket"), []byte("key"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := c.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.DB.IsReadOnly() {
+		t.Error("snapshot is not read-only")
+	}
+
+	if err := c.PutValue([]byte("bucket"), []byte("key"), []byte("changed")); err != nil {
+		t.Fatal(err)
+	}
+	// the live database can be compacted while the snapshot is open
+	c.Close()
+	if err := pool.Compact(path); err != nil {
+		t.Fatal(err)
+	}
+
+	view := func(s *Snapshot) (value string) {
+		if err := s.View(func(tx *bolt.Tx) error {
+			value = string(tx.Bucket([]byte("bucket")).Get([]byte("key")))
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+	if v := view(s); v != "value" {
+		t.Errorf("got value %q, expected %q", v, "value")
+	}
+
+	s2 := s.Acquire()
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if v := view(s2); v != "value" {
+		t.Errorf("got value %q after release, expected %q", v, "value")
+	}
+	if err := s2.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(s.Path()); !os.IsNotExist(err) {
+		t.Errorf("got error %v, expected snapshot file to be removed", err)
+	}
+}
+
+func TestSnapshotPoolClose(t *testing.T) {
+	pool := New(nil)
+
+	c, err := pool.Get(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := c.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	pool.Close()
+
+	if _, err := os.Stat(s.Path()); !os.IsNotExist(err) {
+		t.Errorf("got error %v, expected snapshot file to be removed", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Error(err)
+	}
+	if _, err := c.Snapshot(); err != ErrClosed {
+		t.Errorf("got error %v, expected %v", err, ErrClosed)
+	}
+}
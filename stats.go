@@ -0,0 +1,152 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// recentErrorsSize is the number of recent errors that the pool keeps.
+const recentErrorsSize = 20
+
+// Stats holds information about the pool and its databases.
+type Stats struct {
+	Healthy   bool            `json:"healthy"`
+	Databases []DatabaseStats `json:"databases"`
+	// Known are sorted paths of databases found by Pool.Scan, open or not.
+	Known []string `json:"known,omitempty"`
+}
+
+// DatabaseStats holds information about a single database in the pool.
+type DatabaseStats struct {
+	Path         string     `json:"path"`
+	References   int64      `json:"references"`
+	Size         int64      `json:"size"`
+	Expires      *time.Time `json:"expires,omitempty"`
+	TxN          int        `json:"txN"`
+	OpenTxN      int        `json:"openTxN"`
+	FreePageN    int        `json:"freePageN"`
+	PendingPageN int        `json:"pendingPageN"`
+	ReadOnly     bool       `json:"readOnly,omitempty"`
+	Usage        Usage      `json:"usage"`
+	Labels       Labels     `json:"labels,omitempty"`
+}
+
+// ErrorRecord is an error that was handled by the pool.
+type ErrorRecord struct {
+	Time  time.Time `json:"time"`
+	Error string    `json:"error"`
+}
+
+// Stats returns information about all databases in the pool sorted by their
+// paths.
+func (p *Pool) Stats() Stats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	s := Stats{
+		Healthy:   !p.isClosed() && !p.isDraining(),
+		Databases: make([]DatabaseStats, 0, len(p.connections)),
+	}
+	for _, c := range p.connections {
+		s.Databases = append(s.Databases, c.stats())
+	}
+	sort.Slice(s.Databases, func(i, j int) bool {
+		return s.Databases[i].Path < s.Databases[j].Path
+	})
+	for path := range p.known {
+		s.Known = append(s.Known, path)
+	}
+	sort.Strings(s.Known)
+	return s
+}
+
+// stats returns information about the connection database.
+func (c *Connection) stats() DatabaseStats {
+	c.mu.RLock()
+	s := DatabaseStats{
+		Path:       c.path,
+		References: c.count,
+	}
+	if !c.closeTime.IsZero() {
+		t := c.closeTime
+		s.Expires = &t
+	}
+	c.mu.RUnlock()
+	if info, err := c.pool.fs.Stat(c.path); err == nil {
+		s.Size = info.Size()
+	}
+	bs := c.DB.Stats()
+	s.TxN = bs.TxN
+	s.OpenTxN = bs.OpenTxN
+	s.FreePageN = bs.FreePageN
+	s.PendingPageN = bs.PendingPageN
+	s.ReadOnly = c.DB.IsReadOnly()
+	s.Usage = c.Usage()
+	s.Labels = c.Labels()
+	return s
+}
+
+// RecentErrors returns the most recent errors handled by the pool, oldest
+// first.
+func (p *Pool) RecentErrors() []ErrorRecord {
+	p.errorsMu.Lock()
+	defer p.errorsMu.Unlock()
+
+	return append([]ErrorRecord(nil), p.recentErrors...)
+}
+
+func (p *Pool) recordError(err error) {
+	p.errorsMu.Lock()
+	defer p.errorsMu.Unlock()
+
+	if len(p.recentErrors) == recentErrorsSize {
+		copy(p.recentErrors, p.recentErrors[1:])
+		p.recentErrors = p.recentErrors[:recentErrorsSize-1]
+	}
+	p.recentErrors = append(p.recentErrors, ErrorRecord{
+		Time:  time.Now(),
+		Error: err.Error(),
+	})
+}
+
+func (p *Pool) isClosed() bool {
+	select {
+	case <-p.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// StatsHandler returns an HTTP handler that responds with pool stats and
+// recent errors encoded as JSON. If Options.StatsAuthorize is set, requests
+// that are not authorized get the Unauthorized response. Response status
+// is Service Unavailable if the pool is closed or shutting down.
+func (p *Pool) StatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a := p.options.StatsAuthorize; a != nil && !a(r) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		s := p.Stats()
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		if !s.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(struct {
+			Stats
+			Errors []ErrorRecord `json:"errors"`
+		}{
+			Stats:  s,
+			Errors: p.RecentErrors(),
+		})
+	})
+}
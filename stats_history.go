@@ -0,0 +1,104 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultStatsHistorySize is the number of samples kept by the stats
+// sampler if StatsSampling.Size is not set.
+const DefaultStatsHistorySize = 60
+
+// StatsSampling configures the sampler that periodically records pool
+// stats, so that growth of databases can be followed over time with
+// Pool.StatsHistory.
+type StatsSampling struct {
+	// Interval is the duration between two samples.
+	Interval time.Duration
+	// Size is the number of the most recent samples that are kept. If the
+	// value is 0, DefaultStatsHistorySize is used.
+	Size int
+}
+
+// StatsSample holds pool stats recorded by the stats sampler.
+type StatsSample struct {
+	Time time.Time `json:"time"`
+	Stats
+}
+
+// statsHistory is a ring buffer of stats samples.
+type statsHistory struct {
+	samples []StatsSample
+	// next is the index of the sample that is overwritten next
+	next int
+	full bool
+	mu   sync.Mutex
+}
+
+func newStatsHistory(size int) *statsHistory {
+	if size <= 0 {
+		size = DefaultStatsHistorySize
+	}
+	return &statsHistory{
+		samples: make([]StatsSample, size),
+	}
+}
+
+func (h *statsHistory) add(s StatsSample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.samples[h.next] = s
+	h.next++
+	if h.next == len(h.samples) {
+		h.next = 0
+		h.full = true
+	}
+}
+
+func (h *statsHistory) list() []StatsSample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.full {
+		return append([]StatsSample(nil), h.samples[:h.next]...)
+	}
+	s := make([]StatsSample, 0, len(h.samples))
+	s = append(s, h.samples[h.next:]...)
+	return append(s, h.samples[:h.next]...)
+}
+
+// StatsHistory returns stats recorded by the sampler configured with
+// Options.StatsSampling, oldest first. It returns nil if sampling is not
+// configured.
+func (p *Pool) StatsHistory() []StatsSample {
+	if p.statsHistory == nil {
+		return nil
+	}
+	return p.statsHistory.list()
+}
+
+// sampleStats records pool stats at the interval until the pool is closed.
+func (p *Pool) sampleStats(interval time.Duration) {
+	defer p.background.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.quit:
+			return
+		}
+		p.statsHistory.add(StatsSample{
+			Time:  p.now(),
+			Stats: p.Stats(),
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStatsHistory(t *testing.T) {
+	pool := New(&Options{
+		StatsSampling: &StatsSampling{
+			Interval: time.Millisecond,
+			Size:     3,
+		},
+	})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(pool.StatsHistory()) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("stats are not sampled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// wait for the ring buffer to overwrite the oldest samples
+	time.Sleep(10 * time.Millisecond)
+
+	h := pool.StatsHistory()
+	if len(h) != 3 {
+		t.Fatalf("got %d samples, expected 3", len(h))
+	}
+	for i, s := range h {
+		if i > 0 && s.Time.Before(h[i-1].Time) {
+			t.Errorf("sample %d is older than the previous one", i)
+		}
+		if !s.Healthy || len(s.Databases) != 1 || s.Databases[0].Path != path {
+			t.Errorf("got sample %+v", s)
+		}
+	}
+}
+
+func TestStatsHistoryDisabled(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	if h := pool.StatsHistory(); h != nil {
+		t.Errorf("got history %v, expected nil", h)
+	}
+}
+
+func TestStatsHistoryRing(t *testing.T) {
+	h := newStatsHistory(3)
+	for i := 0; i < 5; i++ {
+		h.add(StatsSample{Time: time.Unix(int64(i), 0)})
+		l := h.list()
+		want := i + 1
+		if want > 3 {
+			want = 3
+		}
+		if len(l) != want {
+			t.Fatalf("got %d samples, expected %d", len(l), want)
+		}
+		if got := l[len(l)-1].Time.Unix(); got != int64(i) {
+			t.Errorf("got newest sample %d, expected %d", got, i)
+		}
+		if got, want := l[0].Time.Unix(), int64(i+1-want); got != want {
+			t.Errorf("got oldest sample %d, expected %d", got, want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatsHandler(t *testing.T) {
+	pool := New(&Options{
+		ErrorHandler: func(error) {},
+		StatsAuthorize: func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == "secret"
+		},
+	})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	pool.handleError(errors.New("test error"))
+
+	handler := pool.StatsHandler()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("got status %d, expected %d", w.Code, http.StatusUnauthorized)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "secret")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, expected %d", w.Code, http.StatusOK)
+	}
+	var got struct {
+		Stats
+		Errors []ErrorRecord `json:"errors"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Healthy {
+		t.Error("pool not healthy")
+	}
+	if len(got.Databases) != 1 || got.Databases[0].Path != path || got.Databases[0].References != 1 {
+		t.Errorf("unexpected databases %+v", got.Databases)
+	}
+	if got.Databases[0].Size == 0 {
+		t.Error("database size is 0")
+	}
+	if len(got.Errors) != 1 || got.Errors[0].Error != "test error" {
+		t.Errorf("unexpected errors %+v", got.Errors)
+	}
+}
+
+func TestRecentErrors(t *testing.T) {
+	pool := New(&Options{
+		ErrorHandler: func(error) {},
+	})
+	defer pool.Close()
+
+	for i := 0; i < recentErrorsSize+5; i++ {
+		pool.handleError(errors.New("error"))
+	}
+	if l := len(pool.RecentErrors()); l != recentErrorsSize {
+		t.Errorf("got %d recent errors, expected %d", l, recentErrorsSize)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"encoding/binary"
+
+	bolt "go.etcd.io/bbolt"
+
+	"resenje.org/boltdbpool"
+)
+
+// getChunked returns the reassembled chunked value stored under the key, or
+// nil if it does not exist.
+func (s *Store[K, V]) getChunked(c *boltdbpool.Connection, key []byte) (data []byte, err error) {
+	err = c.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		if b == nil {
+			return nil
+		}
+		chunks := b.Bucket(key)
+		if chunks == nil {
+			return nil
+		}
+		data, err = s.readChunks(chunks, key)
+		return err
+	})
+	return data, err
+}
+
+// readChunks returns the copy of the value reassembled from decoded chunks
+// in the nested bucket.
+func (s *Store[K, V]) readChunks(chunks *bolt.Bucket, key []byte) (data []byte, err error) {
+	data = []byte{}
+	err = chunks.ForEach(func(k, v []byte) error {
+		d, err := s.pool.DecodeValue(s.bucket, chunkKey(key, k), v)
+		if err != nil {
+			return err
+		}
+		data = append(data, d...)
+		return nil
+	})
+	return data, err
+}
+
+// putChunked stores the value split into chunks under sequential keys in a
+// nested bucket under the key, replacing the previous value.
+func (s *Store[K, V]) putChunked(c *boltdbpool.Connection, key, data []byte) error {
+	defer c.InvalidateValue(s.bucket, key)
+
+	return c.Update(func(tx *bolt.Tx) error {
+		b, err := s.clear(tx, key)
+		if err != nil {
+			return err
+		}
+		chunks, err := b.CreateBucket(key)
+		if err != nil {
+			return err
+		}
+		// chunks are written in key order, so that bolt fills pages
+		// sequentially
+		chunks.FillPercent = 1
+		for i := uint32(0); len(data) > 0; i++ {
+			n := s.chunkSize
+			if n > len(data) {
+				n = len(data)
+			}
+			k := make([]byte, 4)
+			binary.BigEndian.PutUint32(k, i)
+			v, err := s.pool.EncodeValue(s.bucket, chunkKey(key, k), data[:n])
+			if err != nil {
+				return err
+			}
+			if err := chunks.Put(k, v); err != nil {
+				return err
+			}
+			data = data[n:]
+		}
+		return nil
+	})
+}
+
+// replaceChunked replaces the chunked value stored under the key with the
+// value that is not chunked.
+func (s *Store[K, V]) replaceChunked(c *boltdbpool.Connection, key, data []byte) error {
+	v, err := s.pool.EncodeValue(s.bucket, key, data)
+	if err != nil {
+		return err
+	}
+	defer c.InvalidateValue(s.bucket, key)
+
+	return c.Update(func(tx *bolt.Tx) error {
+		b, err := s.clear(tx, key)
+		if err != nil {
+			return err
+		}
+		return b.Put(key, v)
+	})
+}
+
+// deleteChunked removes the chunked value stored under the key.
+func (s *Store[K, V]) deleteChunked(c *boltdbpool.Connection, key []byte) error {
+	defer c.InvalidateValue(s.bucket, key)
+
+	return c.Update(func(tx *bolt.Tx) error {
+		_, err := s.clear(tx, key)
+		return err
+	})
+}
+
+// clear removes the value or the chunked value stored under the key and
+// returns the store bucket, creating it if needed.
+func (s *Store[K, V]) clear(tx *bolt.Tx, key []byte) (*bolt.Bucket, error) {
+	b, err := tx.CreateBucketIfNotExists(s.bucket)
+	if err != nil {
+		return nil, err
+	}
+	if b.Bucket(key) != nil {
+		return b, b.DeleteBucket(key)
+	}
+	return b, b.Delete(key)
+}
+
+// chunkKey returns the key under which the chunk with the index is encoded,
+// so that encrypted chunks can not be reordered or moved to other values.
+func chunkKey(key, index []byte) []byte {
+	k := make([]byte, 0, len(key)+len(index))
+	k = append(k, key...)
+	return append(k, index...)
+}
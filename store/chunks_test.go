@@ -0,0 +1,115 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+
+	"resenje.org/boltdbpool"
+)
+
+func TestChunks(t *testing.T) {
+	pool := boltdbpool.New(&boltdbpool.Options{
+		CacheSize:   1 << 20,
+		Compression: &boltdbpool.Compression{},
+	})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "files.db")
+	files := NewWithOptions[string, []byte](pool, path, "files", &Options{
+		ChunkSize: 16,
+	})
+
+	small := []byte("small")
+	large := bytes.Repeat([]byte("0123456789"), 10)
+	for name, data := range map[string][]byte{"a": small, "b": large, "c": small} {
+		if err := files.Put(name, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// large value is stored in a nested bucket in chunks
+	if err := pool.With(path, func(db *bolt.DB) error {
+		return db.View(func(tx *bolt.Tx) error {
+			chunks := tx.Bucket([]byte("files")).Bucket([]byte("b"))
+			if chunks == nil {
+				t.Fatal("value is not chunked")
+			}
+			if n := chunks.Stats().KeyN; n < 2 {
+				t.Errorf("got %d chunks", n)
+			}
+			return nil
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(name string, want []byte) {
+		t.Helper()
+
+		got, ok, err := files.Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want == nil {
+			if ok {
+				t.Errorf("got value %q for %s, expected none", got, name)
+			}
+			return
+		}
+		if !ok || !bytes.Equal(got, want) {
+			t.Errorf("got value %q (%v) for %s, expected %q", got, ok, name, want)
+		}
+	}
+	get("a", small)
+	get("b", large)
+
+	var names []string
+	if err := files.Iterate(func(name string, data []byte) error {
+		names = append(names, name)
+		if name == "b" && !bytes.Equal(data, large) {
+			t.Errorf("got iterated value %q", data)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got keys %v, expected %v", names, want)
+	}
+
+	// cached small value is replaced by the chunked one and back
+	if err := files.Put("a", large); err != nil {
+		t.Fatal(err)
+	}
+	get("a", large)
+	if err := files.Put("a", small); err != nil {
+		t.Fatal(err)
+	}
+	get("a", small)
+
+	// chunked values are read by stores without chunking
+	plain := New[string, []byte](pool, path, "files", nil)
+	if got, ok, err := plain.Get("b"); err != nil || !ok || !bytes.Equal(got, large) {
+		t.Errorf("got value %q (%v, %v)", got, ok, err)
+	}
+	if err := plain.Put("b", small); err != nil {
+		t.Fatal(err)
+	}
+	get("b", small)
+	if err := files.Put("b", large); err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	get("b", nil)
+}
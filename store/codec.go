@@ -0,0 +1,51 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// Codec encodes and decodes store values. Every Store can use a different
+// Codec, so that values in different buckets can have different formats.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Codecs that are provided by the package.
+var (
+	// JSON encodes values with encoding/json.
+	JSON Codec = jsonCodec{}
+	// Gob encodes values with encoding/gob. Every value is encoded with its
+	// type information, so it is larger than with a shared gob stream.
+	Gob Codec = gobCodec{}
+	// MsgPack encodes values in the MessagePack format. Struct fields are
+	// encoded as map entries named by the field name or the "msgpack" tag,
+	// and types that implement encoding.TextMarshaler as strings.
+	MsgPack Codec = msgPackCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"resenje.org/boltdbpool"
+)
+
+type record struct {
+	ID       uint64
+	Name     string `msgpack:"name"`
+	Score    float64
+	Delta    int32
+	Tags     []string
+	Attrs    map[string]int
+	Data     []byte
+	Parent   *record
+	Created  time.Time
+	Active   bool
+	Optional string `msgpack:",omitempty"`
+	Ignored  string `msgpack:"-"`
+}
+
+func testRecord() record {
+	return record{
+		ID:      1 << 40,
+		Name:    "root",
+		Score:   3.5,
+		Delta:   -70000,
+		Tags:    []string{"a", "b"},
+		Attrs:   map[string]int{"x": -1, "y": 300},
+		Data:    []byte{0, 1, 2},
+		Parent:  &record{ID: 7, Name: "parent", Created: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		Created: time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC),
+		Active:  true,
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	for name, codec := range map[string]Codec{
+		"json":    JSON,
+		"gob":     Gob,
+		"msgpack": MsgPack,
+	} {
+		t.Run(name, func(t *testing.T) {
+			want := testRecord()
+			data, err := codec.Marshal(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got record
+			if err := codec.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, expected %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestCodecPerStore(t *testing.T) {
+	pool := boltdbpool.New(nil)
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "mixed.db")
+	j := New[string, record](pool, path, "json", JSON)
+	m := New[string, record](pool, path, "msgpack", MsgPack)
+	for _, s := range []*Store[string, record]{j, m} {
+		if err := s.Put("r", testRecord()); err != nil {
+			t.Fatal(err)
+		}
+		if r, ok, err := s.Get("r"); err != nil || !ok || !reflect.DeepEqual(r, testRecord()) {
+			t.Errorf("got %+v (%v, %v)", r, ok, err)
+		}
+	}
+
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket([]byte("json")).Get([]byte("r")); v[0] != '{' {
+			t.Errorf("json value %q", v)
+		}
+		if v := tx.Bucket([]byte("msgpack")).Get([]byte("r")); v[0]&0xf0 != 0x80 {
+			t.Errorf("msgpack value %x", v)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMsgPackFormat(t *testing.T) {
+	for _, tc := range []struct {
+		value interface{}
+		data  []byte
+	}{
+		{nil, []byte{0xc0}},
+		{true, []byte{0xc3}},
+		{5, []byte{0x05}},
+		{-5, []byte{0xfb}},
+		{200, []byte{0xcc, 0xc8}},
+		{-200, []byte{0xd1, 0xff, 0x38}},
+		{uint32(70000), []byte{0xce, 0x00, 0x01, 0x11, 0x70}},
+		{1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"hi", []byte{0xa2, 'h', 'i'}},
+		{[]byte{1}, []byte{0xc4, 0x01, 0x01}},
+		{[]int{1, 2}, []byte{0x92, 0x01, 0x02}},
+		{map[string]bool{"a": false}, []byte{0x81, 0xa1, 'a', 0xc2}},
+	} {
+		data, err := MsgPack.Marshal(tc.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, tc.data) {
+			t.Errorf("%#v: got %x, expected %x", tc.value, data, tc.data)
+		}
+	}
+}
+
+func TestMsgPackGeneric(t *testing.T) {
+	data, err := MsgPack.Marshal(map[string]interface{}{
+		"n": -1,
+		"l": []interface{}{"x", uint64(1 << 63)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got interface{}
+	if err := MsgPack.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"n": int64(-1),
+		"l": []interface{}{"x", uint64(1 << 63)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, expected %#v", got, want)
+	}
+}
+
+func TestMsgPackInvalid(t *testing.T) {
+	var v record
+	for _, data := range [][]byte{
+		{},
+		{0xa5, 'a'},
+		{0xc1},
+		{0x01, 0x02},
+		{0x92, 0x01},
+	} {
+		if err := MsgPack.Unmarshal(data, &v); !errors.Is(err, ErrInvalidMsgPack) {
+			t.Errorf("%x: got error %v, expected %v", data, err, ErrInvalidMsgPack)
+		}
+	}
+	var i int8
+	if err := MsgPack.Unmarshal([]byte{0xcc, 0xc8}, &i); !errors.Is(err, ErrInvalidMsgPack) {
+		t.Errorf("overflow: got error %v, expected %v", err, ErrInvalidMsgPack)
+	}
+}
@@ -0,0 +1,635 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// ErrInvalidMsgPack is returned when MessagePack data can not be decoded.
+var ErrInvalidMsgPack = errors.New("store: invalid msgpack data")
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+type msgPackCodec struct{}
+
+func (msgPackCodec) Marshal(v interface{}) ([]byte, error) {
+	var e msgPackEncoder
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+func (msgPackCodec) Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("store: msgpack unmarshal into non-pointer %T", v)
+	}
+	d := msgPackDecoder{data: data}
+	if err := d.decode(rv.Elem()); err != nil {
+		return err
+	}
+	if d.pos != len(d.data) {
+		return fmt.Errorf("%w: %d trailing bytes", ErrInvalidMsgPack, len(d.data)-d.pos)
+	}
+	return nil
+}
+
+type msgPackEncoder struct {
+	buf []byte
+}
+
+func (e *msgPackEncoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.buf = append(e.buf, 0xc0)
+		return nil
+	}
+	if v.Type().Implements(textMarshalerType) && !(v.Kind() == reflect.Ptr && v.IsNil()) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return err
+		}
+		e.encodeString(string(text))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		return e.encode(v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.encodeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.encodeUint(v.Uint())
+	case reflect.Float32:
+		e.buf = append(e.buf, 0xca)
+		e.append32(math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		e.buf = append(e.buf, 0xcb)
+		e.append64(math.Float64bits(v.Float()))
+	case reflect.String:
+		e.encodeString(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.encodeBinary(v.Bytes())
+			return nil
+		}
+		fallthrough
+	case reflect.Array:
+		n := v.Len()
+		e.encodeLength(n, 0x90, 16, 0xdc, 0xdd)
+		for i := 0; i < n; i++ {
+			if err := e.encode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		e.encodeLength(v.Len(), 0x80, 16, 0xde, 0xdf)
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := e.encode(iter.Key()); err != nil {
+				return err
+			}
+			if err := e.encode(iter.Value()); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		fields := structFields(v.Type())
+		values := make([]reflect.Value, 0, len(fields))
+		names := make([]string, 0, len(fields))
+		for _, f := range fields {
+			fv := v.FieldByIndex(f.index)
+			if f.omitEmpty && fv.IsZero() {
+				continue
+			}
+			names = append(names, f.name)
+			values = append(values, fv)
+		}
+		e.encodeLength(len(values), 0x80, 16, 0xde, 0xdf)
+		for i, fv := range values {
+			e.encodeString(names[i])
+			if err := e.encode(fv); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("store: msgpack unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func (e *msgPackEncoder) encodeInt(i int64) {
+	switch {
+	case i >= 0:
+		e.encodeUint(uint64(i))
+	case i >= -32:
+		e.buf = append(e.buf, byte(i))
+	case i >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		e.buf = append(e.buf, 0xd1)
+		e.append16(uint16(i))
+	case i >= math.MinInt32:
+		e.buf = append(e.buf, 0xd2)
+		e.append32(uint32(i))
+	default:
+		e.buf = append(e.buf, 0xd3)
+		e.append64(uint64(i))
+	}
+}
+
+func (e *msgPackEncoder) encodeUint(u uint64) {
+	switch {
+	case u <= 0x7f:
+		e.buf = append(e.buf, byte(u))
+	case u <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		e.buf = append(e.buf, 0xcd)
+		e.append16(uint16(u))
+	case u <= math.MaxUint32:
+		e.buf = append(e.buf, 0xce)
+		e.append32(uint32(u))
+	default:
+		e.buf = append(e.buf, 0xcf)
+		e.append64(u)
+	}
+}
+
+func (e *msgPackEncoder) encodeString(s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		e.buf = append(e.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xda)
+		e.append16(uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdb)
+		e.append32(uint32(n))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *msgPackEncoder) encodeBinary(b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xc5)
+		e.append16(uint16(n))
+	default:
+		e.buf = append(e.buf, 0xc6)
+		e.append32(uint32(n))
+	}
+	e.buf = append(e.buf, b...)
+}
+
+// encodeLength writes the header of an array or a map with n elements in
+// the fix, 16 or 32 bit format.
+func (e *msgPackEncoder) encodeLength(n int, fix byte, fixMax int, b16, b32 byte) {
+	switch {
+	case n < fixMax:
+		e.buf = append(e.buf, fix|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, b16)
+		e.append16(uint16(n))
+	default:
+		e.buf = append(e.buf, b32)
+		e.append32(uint32(n))
+	}
+}
+
+func (e *msgPackEncoder) append16(u uint16) {
+	e.buf = append(e.buf, byte(u>>8), byte(u))
+}
+
+func (e *msgPackEncoder) append32(u uint32) {
+	e.buf = append(e.buf, byte(u>>24), byte(u>>16), byte(u>>8), byte(u))
+}
+
+func (e *msgPackEncoder) append64(u uint64) {
+	e.append32(uint32(u >> 32))
+	e.append32(uint32(u))
+}
+
+type msgPackDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *msgPackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.data) {
+		return nil, fmt.Errorf("%w: unexpected end of data", ErrInvalidMsgPack)
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *msgPackDecoder) uint(size int) (uint64, error) {
+	b, err := d.next(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// value decodes the next value into a generic Go representation: nil, bool,
+// int64, uint64, float64, string, []byte, []interface{} or
+// map[string]interface{}. Maps with non-string keys are decoded as
+// map[interface{}]interface{}.
+func (d *msgPackDecoder) value() (interface{}, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	t := b[0]
+	switch {
+	case t <= 0x7f:
+		return int64(t), nil
+	case t >= 0xe0:
+		return int64(int8(t)), nil
+	case t&0xe0 == 0xa0:
+		return d.str(int(t & 0x1f))
+	case t&0xf0 == 0x90:
+		return d.array(int(t & 0x0f))
+	case t&0xf0 == 0x80:
+		return d.mapValue(int(t & 0x0f))
+	}
+	switch t {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return d.uint(1 << (t - 0xcc))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (t - 0xd0)
+		u, err := d.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		// sign extend
+		shift := 64 - 8*size
+		return int64(u<<shift) >> shift, nil
+	case 0xca:
+		u, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := d.uint(8)
+		return math.Float64frombits(u), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (t - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(n))
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (t - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.next(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{}, b...), nil
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (t - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(int(n))
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (t - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.mapValue(int(n))
+	}
+	return nil, fmt.Errorf("%w: unsupported type 0x%x", ErrInvalidMsgPack, t)
+}
+
+func (d *msgPackDecoder) str(n int) (string, error) {
+	b, err := d.next(n)
+	return string(b), err
+}
+
+func (d *msgPackDecoder) array(n int) ([]interface{}, error) {
+	if n > len(d.data)-d.pos {
+		return nil, fmt.Errorf("%w: array too long", ErrInvalidMsgPack)
+	}
+	a := make([]interface{}, n)
+	for i := range a {
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		a[i] = v
+	}
+	return a, nil
+}
+
+func (d *msgPackDecoder) mapValue(n int) (interface{}, error) {
+	if n > len(d.data)-d.pos {
+		return nil, fmt.Errorf("%w: map too long", ErrInvalidMsgPack)
+	}
+	keys := make([]interface{}, n)
+	values := make([]interface{}, n)
+	stringKeys := true
+	for i := 0; i < n; i++ {
+		k, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := k.(string); !ok {
+			stringKeys = false
+		}
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		keys[i], values[i] = k, v
+	}
+	if stringKeys {
+		m := make(map[string]interface{}, n)
+		for i, k := range keys {
+			m[k.(string)] = values[i]
+		}
+		return m, nil
+	}
+	m := make(map[interface{}]interface{}, n)
+	for i, k := range keys {
+		if k != nil && !reflect.TypeOf(k).Comparable() {
+			return nil, fmt.Errorf("%w: map key %T is not comparable", ErrInvalidMsgPack, k)
+		}
+		m[k] = values[i]
+	}
+	return m, nil
+}
+
+// decode decodes the next value into the settable reflect value.
+func (d *msgPackDecoder) decode(v reflect.Value) error {
+	x, err := d.value()
+	if err != nil {
+		return err
+	}
+	return assign(v, x)
+}
+
+// assign sets the generic decoded value x to v, converting it to the type
+// of v.
+func assign(v reflect.Value, x interface{}) error {
+	if x == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if v.Kind() != reflect.Ptr && v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		s, ok := x.(string)
+		if !ok {
+			return typeError(x, v.Type())
+		}
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		if err := assign(p.Elem(), x); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return typeError(x, v.Type())
+		}
+		v.Set(reflect.ValueOf(x))
+		return nil
+	case reflect.Bool:
+		b, ok := x.(bool)
+		if !ok {
+			return typeError(x, v.Type())
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		switch n := x.(type) {
+		case int64:
+			i = n
+		case uint64:
+			if n > math.MaxInt64 {
+				return typeError(x, v.Type())
+			}
+			i = int64(n)
+		default:
+			return typeError(x, v.Type())
+		}
+		if v.OverflowInt(i) {
+			return typeError(x, v.Type())
+		}
+		v.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		switch n := x.(type) {
+		case uint64:
+			u = n
+		case int64:
+			if n < 0 {
+				return typeError(x, v.Type())
+			}
+			u = uint64(n)
+		default:
+			return typeError(x, v.Type())
+		}
+		if v.OverflowUint(u) {
+			return typeError(x, v.Type())
+		}
+		v.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		switch n := x.(type) {
+		case float64:
+			v.SetFloat(n)
+		case int64:
+			v.SetFloat(float64(n))
+		case uint64:
+			v.SetFloat(float64(n))
+		default:
+			return typeError(x, v.Type())
+		}
+		return nil
+	case reflect.String:
+		switch s := x.(type) {
+		case string:
+			v.SetString(s)
+		case []byte:
+			v.SetString(string(s))
+		default:
+			return typeError(x, v.Type())
+		}
+		return nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			switch b := x.(type) {
+			case []byte:
+				v.SetBytes(b)
+				return nil
+			case string:
+				v.SetBytes([]byte(b))
+				return nil
+			}
+		}
+		a, ok := x.([]interface{})
+		if !ok {
+			return typeError(x, v.Type())
+		}
+		s := reflect.MakeSlice(v.Type(), len(a), len(a))
+		for i, e := range a {
+			if err := assign(s.Index(i), e); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	case reflect.Array:
+		a, ok := x.([]interface{})
+		if !ok || len(a) > v.Len() {
+			return typeError(x, v.Type())
+		}
+		for i, e := range a {
+			if err := assign(v.Index(i), e); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		set := func(k, e interface{}) error {
+			kv := reflect.New(v.Type().Key()).Elem()
+			if err := assign(kv, k); err != nil {
+				return err
+			}
+			ev := reflect.New(v.Type().Elem()).Elem()
+			if err := assign(ev, e); err != nil {
+				return err
+			}
+			m.SetMapIndex(kv, ev)
+			return nil
+		}
+		switch mx := x.(type) {
+		case map[string]interface{}:
+			for k, e := range mx {
+				if err := set(k, e); err != nil {
+					return err
+				}
+			}
+		case map[interface{}]interface{}:
+			for k, e := range mx {
+				if err := set(k, e); err != nil {
+					return err
+				}
+			}
+		default:
+			return typeError(x, v.Type())
+		}
+		v.Set(m)
+		return nil
+	case reflect.Struct:
+		mx, ok := x.(map[string]interface{})
+		if !ok {
+			return typeError(x, v.Type())
+		}
+		for _, f := range structFields(v.Type()) {
+			e, ok := mx[f.name]
+			if !ok {
+				continue
+			}
+			if err := assign(v.FieldByIndex(f.index), e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("store: msgpack unsupported type %s", v.Type())
+}
+
+func typeError(x interface{}, t reflect.Type) error {
+	return fmt.Errorf("%w: can not decode %T into %s", ErrInvalidMsgPack, x, t)
+}
+
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// structFields returns exported fields of the struct type, named by the
+// "msgpack" tag if it is set. Fields tagged with "-" are skipped.
+func structFields(t reflect.Type) []structField {
+	fields := make([]structField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("msgpack"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, structField{
+			name:      name,
+			index:     f.Index,
+			omitEmpty: opts == "omitempty",
+		})
+	}
+	return fields
+}
@@ -0,0 +1,253 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package store provides a typed key-value store in a bucket of a database
+// managed by boltdbpool.Pool.
+//
+// Example:
+//
+//	users := store.New[string, User](pool, "/var/lib/app/users.db", "users", store.JSON)
+//	if err := users.Put("ana", User{Name: "Ana"}); err != nil {
+//		...
+//	}
+//	u, ok, err := users.Get("ana")
+package store // import "resenje.org/boltdbpool/store"
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+
+	bolt "go.etcd.io/bbolt"
+
+	"resenje.org/boltdbpool"
+)
+
+// Key is the set of types that can be used as store keys. Integer keys are
+// encoded so that their byte order is the same as their numeric order.
+type Key interface {
+	~string | ~[]byte | ~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+// Options configure a Store.
+type Options struct {
+	// Codec encodes and decodes values. If nil, JSON is used.
+	Codec Codec
+	// ChunkSize, if positive, is the size in bytes of marshaled values above
+	// which they are split into chunks, stored under sequential keys in a
+	// nested bucket under the value key, so that large values do not
+	// require large contiguous overflow pages. Chunked values are read
+	// regardless of this option. They are not cached, recorded in the
+	// oplog nor reported by Pool.Watch.
+	ChunkSize int
+}
+
+// Store keeps values of type V under keys of type K in a bucket. Values are
+// stored with boltdbpool.Connection value helpers, so they are compressed,
+// encrypted and cached according to the pool options. Connections are
+// obtained from the pool for every operation. Nested buckets in the store
+// bucket are reserved for chunked values.
+type Store[K Key, V any] struct {
+	pool      *boltdbpool.Pool
+	path      string
+	bucket    []byte
+	codec     Codec
+	chunkSize int
+}
+
+// New returns a Store for values in the bucket of the database on path.
+// If codec is nil, JSON is used.
+func New[K Key, V any](pool *boltdbpool.Pool, path, bucket string, codec Codec) *Store[K, V] {
+	return NewWithOptions[K, V](pool, path, bucket, &Options{Codec: codec})
+}
+
+// NewWithOptions returns a Store for values in the bucket of the database
+// on path, configured with options.
+func NewWithOptions[K Key, V any](pool *boltdbpool.Pool, path, bucket string, options *Options) *Store[K, V] {
+	if options == nil {
+		options = &Options{}
+	}
+	codec := options.Codec
+	if codec == nil {
+		codec = JSON
+	}
+	return &Store[K, V]{
+		pool:      pool,
+		path:      path,
+		bucket:    []byte(bucket),
+		codec:     codec,
+		chunkSize: options.ChunkSize,
+	}
+}
+
+// Get returns the value stored under the key. The returned boolean is false
+// if the value does not exist.
+func (s *Store[K, V]) Get(key K) (value V, ok bool, err error) {
+	c, err := s.pool.Get(s.path)
+	if err != nil {
+		return value, false, err
+	}
+	defer c.Close()
+
+	k := encodeKey(key)
+	data, err := c.GetValue(s.bucket, k)
+	if err != nil {
+		return value, false, err
+	}
+	if data == nil {
+		if data, err = s.getChunked(c, k); err != nil || data == nil {
+			return value, false, err
+		}
+	}
+	if err := s.codec.Unmarshal(data, &value); err != nil {
+		return value, false, err
+	}
+	return value, true, nil
+}
+
+// Put stores the value under the key, creating the bucket if needed.
+func (s *Store[K, V]) Put(key K, value V) error {
+	data, err := s.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	c, err := s.pool.Get(s.path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	k := encodeKey(key)
+	if s.chunkSize > 0 && len(data) > s.chunkSize {
+		return s.putChunked(c, k, data)
+	}
+	err = c.PutValue(s.bucket, k, data)
+	if errors.Is(err, bolt.ErrIncompatibleValue) {
+		// the previous value is chunked
+		return s.replaceChunked(c, k, data)
+	}
+	return err
+}
+
+// Delete removes the value stored under the key.
+func (s *Store[K, V]) Delete(key K) error {
+	c, err := s.pool.Get(s.path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	k := encodeKey(key)
+	err = c.DeleteValue(s.bucket, k)
+	if errors.Is(err, bolt.ErrIncompatibleValue) {
+		// the value is chunked
+		return s.deleteChunked(c, k)
+	}
+	return err
+}
+
+// CompareAndSwap stores the new value under the key only if the current
+// value is equal to old, within a single read-write transaction, and
+// reports whether the value is swapped. Values are compared in their
+// marshaled form, so the codec must marshal equal values to the same
+// bytes. Chunked values are not supported.
+func (s *Store[K, V]) CompareAndSwap(key K, old, new V) (swapped bool, err error) {
+	o, err := s.codec.Marshal(old)
+	if err != nil {
+		return false, err
+	}
+	n, err := s.codec.Marshal(new)
+	if err != nil {
+		return false, err
+	}
+	c, err := s.pool.Get(s.path)
+	if err != nil {
+		return false, err
+	}
+	defer c.Close()
+
+	return c.CompareAndSwap(s.bucket, encodeKey(key), o, n)
+}
+
+// Iterate calls the function for every key and value in the key order, in
+// a single read-only transaction. Chunked values are reassembled. Iteration
+// stops on the first error returned by the function.
+func (s *Store[K, V]) Iterate(fn func(key K, value V) error) error {
+	c, err := s.pool.Get(s.path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			key, err := decodeKey[K](k)
+			if err != nil {
+				return err
+			}
+			var data []byte
+			if v == nil {
+				data, err = s.readChunks(b.Bucket(k), k)
+			} else {
+				data, err = s.pool.DecodeValue(s.bucket, k, v)
+			}
+			if err != nil {
+				return err
+			}
+			var value V
+			if err := s.codec.Unmarshal(data, &value); err != nil {
+				return err
+			}
+			return fn(key, value)
+		})
+	})
+}
+
+// encodeKey returns the byte representation of the key. Signed integers
+// have the sign bit flipped so that negative numbers sort first.
+func encodeKey[K Key](key K) []byte {
+	v := reflect.ValueOf(key)
+	switch v.Kind() {
+	case reflect.String:
+		return []byte(v.String())
+	case reflect.Slice:
+		return v.Bytes()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, uint64(v.Int())^(1<<63))
+		return b
+	default:
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, v.Uint())
+		return b
+	}
+}
+
+// decodeKey reverses the encoding done by encodeKey.
+func decodeKey[K Key](b []byte) (key K, err error) {
+	v := reflect.ValueOf(&key).Elem()
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(string(b))
+	case reflect.Slice:
+		v.SetBytes(append([]byte{}, b...))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if len(b) != 8 {
+			return key, fmt.Errorf("store: invalid key length %d", len(b))
+		}
+		v.SetInt(int64(binary.BigEndian.Uint64(b) ^ (1 << 63)))
+	default:
+		if len(b) != 8 {
+			return key, fmt.Errorf("store: invalid key length %d", len(b))
+		}
+		v.SetUint(binary.BigEndian.Uint64(b))
+	}
+	return key, nil
+}
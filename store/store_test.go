@@ -0,0 +1,130 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"resenje.org/boltdbpool"
+)
+
+type user struct {
+	Name string
+	Age  int
+}
+
+func TestStore(t *testing.T) {
+	pool := boltdbpool.New(&boltdbpool.Options{
+		CacheSize: 1 << 20,
+	})
+	defer pool.Close()
+
+	users := New[string, user](pool, filepath.Join(t.TempDir(), "users.db"), "users", nil)
+
+	if _, ok, err := users.Get("ana"); err != nil || ok {
+		t.Fatalf("got ok %v, error %v for missing value", ok, err)
+	}
+	for _, u := range []user{{"ana", 31}, {"bob", 25}} {
+		if err := users.Put(u.Name, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	u, ok, err := users.Get("ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || u != (user{"ana", 31}) {
+		t.Errorf("got user %v (%v)", u, ok)
+	}
+
+	if err := users.Delete("ana"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := users.Get("ana"); ok {
+		t.Error("deleted value found")
+	}
+
+	var got []user
+	if err := users.Iterate(func(key string, u user) error {
+		got = append(got, u)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []user{{"bob", 25}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got users %v, expected %v", got, want)
+	}
+}
+
+func TestStoreIntegerKeys(t *testing.T) {
+	pool := boltdbpool.New(nil)
+	defer pool.Close()
+
+	s := New[int64, string](pool, filepath.Join(t.TempDir(), "numbers.db"), "numbers", JSON)
+	for _, k := range []int64{5, -3, 0, 1 << 40, -1 << 40} {
+		if err := s.Put(k, "v"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var keys []int64
+	if err := s.Iterate(func(k int64, _ string) error {
+		keys = append(keys, k)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{-1 << 40, -3, 0, 5, 1 << 40}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("got keys %v, expected %v", keys, want)
+	}
+}
+
+func TestKeyEncoding(t *testing.T) {
+	type id uint16
+
+	if k, err := decodeKey[id](encodeKey(id(513))); err != nil || k != 513 {
+		t.Errorf("got key %v (%v)", k, err)
+	}
+	if k, err := decodeKey[[]byte](encodeKey([]byte("raw"))); err != nil || !bytes.Equal(k, []byte("raw")) {
+		t.Errorf("got key %v (%v)", k, err)
+	}
+	if _, err := decodeKey[int](nil); err == nil {
+		t.Error("invalid key decoded")
+	}
+}
+
+func TestStoreCompareAndSwap(t *testing.T) {
+	pool := boltdbpool.New(nil)
+	defer pool.Close()
+
+	users := New[string, user](pool, filepath.Join(t.TempDir(), "users.db"), "users", nil)
+	if err := users.Put("ana", user{"ana", 31}); err != nil {
+		t.Fatal(err)
+	}
+
+	swapped, err := users.CompareAndSwap("ana", user{"ana", 30}, user{"ana", 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if swapped {
+		t.Error("swapped with a different old value")
+	}
+	swapped, err = users.CompareAndSwap("ana", user{"ana", 31}, user{"ana", 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !swapped {
+		t.Error("not swapped with the current value")
+	}
+	if u, _, err := users.Get("ana"); err != nil || u != (user{"ana", 32}) {
+		t.Errorf("got user %v (%v)", u, err)
+	}
+	if swapped, err := users.CompareAndSwap("bob", user{}, user{"bob", 1}); err != nil || swapped {
+		t.Errorf("got swapped %v (%v) for missing value", swapped, err)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// SetNoSync sets the NoSync flag of the connection database, which skips
+// fsync calls after every commit. It is useful for bulk loading, which
+// should be followed by a call to Sync after the flag is cleared. The flag
+// is changed while no write transaction is in progress. It is kept until
+// the database is closed by the pool, as databases are opened again with
+// Options.BoltOptions.
+func (c *Connection) SetNoSync(noSync bool) error {
+	return c.setFlags(func(db *bolt.DB) {
+		db.NoSync = noSync
+	})
+}
+
+// SetNoGrowSync sets the NoGrowSync flag of the connection database, which
+// skips fsync calls when the database file grows. The flag is changed
+// while no write transaction is in progress.
+func (c *Connection) SetNoGrowSync(noGrowSync bool) error {
+	return c.setFlags(func(db *bolt.DB) {
+		db.NoGrowSync = noGrowSync
+	})
+}
+
+// NoSync returns the NoSync flag of the connection database.
+func (c *Connection) NoSync() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// the flag is changed within a write transaction
+	tx, err := c.DB.Begin(!c.DB.IsReadOnly())
+	if err != nil {
+		return c.DB.NoSync
+	}
+	defer tx.Rollback()
+
+	return c.DB.NoSync
+}
+
+// Sync flushes the connection database file to disk. It is required after
+// writes done with the NoSync flag set to make them durable.
+func (c *Connection) Sync() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.DB.Sync()
+}
+
+// setFlags calls fn within a write transaction that is rolled back, as bolt
+// reads the sync flags only when write transactions are committed. The
+// connection lock prevents the database from being closed or replaced by
+// the pool.
+func (c *Connection) setFlags(fn func(db *bolt.DB)) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	tx, err := c.DB.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	fn(c.DB)
+	return nil
+}
+
+// syncPeriodically flushes all open databases to disk at the interval
+// configured by Options.SyncInterval until the pool is closed.
+func (p *Pool) syncPeriodically(interval time.Duration) {
+	defer p.background.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.quit:
+			return
+		}
+		p.syncAll()
+	}
+}
+
+// syncAll flushes all open writable databases to disk.
+func (p *Pool) syncAll() {
+	p.mu.RLock()
+	connections := make([]*Connection, 0, len(p.connections))
+	for _, c := range p.connections {
+		connections = append(connections, c)
+	}
+	p.mu.RUnlock()
+	sort.Slice(connections, func(i, j int) bool {
+		return connections[i].path < connections[j].path
+	})
+
+	for _, c := range connections {
+		c.mu.RLock()
+		// skip databases closed after the connections are listed
+		if v, ok := p.index.Load(c.path); ok && v == c && !c.DB.IsReadOnly() {
+			if err := c.DB.Sync(); err != nil {
+				p.handleError(fmt.Errorf("boltdbpool: sync %s: %w", c.path, err))
+			}
+		}
+		c.mu.RUnlock()
+	}
+}
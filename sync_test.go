@@ -0,0 +1,134 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetNoSync(t *testing.T) {
+	pool := New(nil)
+	defer pool.Close()
+
+	c, err := pool.Get(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if c.NoSync() {
+		t.Fatal("NoSync is set by default")
+	}
+
+	if err := c.SetNoSync(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetNoGrowSync(true); err != nil {
+		t.Fatal(err)
+	}
+	if !c.NoSync() || !c.DB.NoGrowSync {
+		t.Fatal("sync flags are not set")
+	}
+
+	// bulk load concurrently with flag changes
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			if err := c.PutValue([]byte("bucket"), []byte(fmt.Sprint(i)), []byte("value")); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	if err := c.SetNoSync(false); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	if err := c.SetNoGrowSync(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if c.NoSync() || c.DB.NoGrowSync {
+		t.Fatal("sync flags are not cleared")
+	}
+}
+
+func TestSyncInterval(t *testing.T) {
+	pool := New(&Options{
+		SyncInterval: time.Millisecond,
+	})
+	defer pool.Close()
+
+	dir := t.TempDir()
+	c, err := pool.Get(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.NoSync() {
+		t.Error("database is not opened with NoSync")
+	}
+	for i := 0; i < 10; i++ {
+		if err := c.PutValue([]byte("bucket"), []byte(fmt.Sprint(i)), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// let the background sync run concurrently with writes and close
+	time.Sleep(10 * time.Millisecond)
+	c.Close()
+
+	if pool.Has(filepath.Join(dir, "test.db")) {
+		t.Fatal("database is not closed")
+	}
+	if errs := pool.RecentErrors(); len(errs) > 0 {
+		t.Errorf("got errors %v", errs)
+	}
+
+	c, err = pool.Get(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if v, err := c.GetValue([]byte("bucket"), []byte("9")); err != nil || string(v) != "value" {
+		t.Errorf("got value %q (%v)", v, err)
+	}
+}
+
+func TestSyncIntervalReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	writer := New(nil)
+	c, err := writer.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	writer.Close()
+
+	pool := New(&Options{
+		ReadOnly:     true,
+		SyncInterval: time.Millisecond,
+	})
+	defer pool.Close()
+
+	c, err = pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	c.Close()
+
+	if errs := pool.RecentErrors(); len(errs) > 0 {
+		t.Errorf("got errors %v", errs)
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package tenant manages per-tenant BoltDB databases in a single directory
+// through a boltdbpool.Pool.
+package tenant // import "resenje.org/boltdbpool/tenant"
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+
+	"resenje.org/boltdbpool"
+)
+
+var (
+	// ErrInvalidID is returned when a tenant ID can not be used as a database
+	// file name.
+	ErrInvalidID = errors.New("invalid tenant id")
+	// ErrUnknownTenant is returned when a database for the tenant does not
+	// exist.
+	ErrUnknownTenant = errors.New("unknown tenant")
+	// ErrQuotaExceeded is returned by Manager.Update when the tenant database
+	// size is above Options.MaxSize.
+	ErrQuotaExceeded = errors.New("tenant quota exceeded")
+	// ErrTooManyTenants is returned by Manager.Get when a new tenant can not
+	// be created because of Options.MaxTenants.
+	ErrTooManyTenants = errors.New("too many tenants")
+)
+
+// Extension is the file name extension of tenant databases.
+const Extension = ".db"
+
+// Options configure tenant databases.
+type Options struct {
+	// Buckets are created in every new tenant database.
+	Buckets []string
+	// Provision is called in the same transaction in which Buckets are
+	// created for a new tenant database.
+	Provision func(id string, tx *bolt.Tx) error
+	// MaxSize is the database size in bytes above which Manager.Update
+	// returns ErrQuotaExceeded. If the value is 0 (default), the size is
+	// not limited.
+	MaxSize int64
+	// MaxTenants limits the number of tenant databases. If the value is 0
+	// (default), the number is not limited.
+	MaxTenants int
+	// ErrorHandler receives errors of removing databases that could not be
+	// provisioned. If nil, boltdbpool.DefaultErrorHandler is used.
+	ErrorHandler func(error)
+}
+
+// Manager maps tenant IDs to database files under a root directory.
+type Manager struct {
+	pool         *boltdbpool.Pool
+	fs           boltdbpool.FS
+	dir          string
+	options      Options
+	errorHandler func(error)
+
+	// provisioning holds channels of tenants whose databases are being
+	// created, that are closed when the databases are provisioned
+	provisioning map[string]chan struct{}
+	mu           sync.Mutex
+}
+
+// New returns a new Manager with tenant databases in dir, opened through
+// the pool.
+func New(pool *boltdbpool.Pool, dir string, options *Options) *Manager {
+	if options == nil {
+		options = &Options{}
+	}
+	m := &Manager{
+		pool:         pool,
+		fs:           pool.FS(),
+		dir:          dir,
+		options:      *options,
+		errorHandler: options.ErrorHandler,
+		provisioning: map[string]chan struct{}{},
+	}
+	if m.errorHandler == nil {
+		m.errorHandler = boltdbpool.DefaultErrorHandler
+	}
+	return m
+}
+
+// Path returns the database file path for the tenant.
+func (m *Manager) Path(id string) (string, error) {
+	if err := validateID(id); err != nil {
+		return "", err
+	}
+	return filepath.Join(m.dir, id+Extension), nil
+}
+
+// Get returns a connection to the tenant database, creating and
+// provisioning it if it does not exist. Connections to a database that is
+// being provisioned are returned after the provisioning is done.
+func (m *Manager) Get(id string) (*boltdbpool.Connection, error) {
+	path, err := m.Path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.waitProvisioned(id)
+	if m.pool.Has(path) {
+		m.mu.Unlock()
+		return m.pool.Get(path)
+	}
+	_, err = m.fs.Stat(path)
+	if err == nil {
+		m.mu.Unlock()
+		return m.pool.Get(path)
+	}
+	if !os.IsNotExist(err) {
+		m.mu.Unlock()
+		return nil, err
+	}
+	if max := m.options.MaxTenants; max > 0 {
+		n, err := m.count()
+		if err != nil {
+			m.mu.Unlock()
+			return nil, err
+		}
+		if n >= max {
+			m.mu.Unlock()
+			return nil, ErrTooManyTenants
+		}
+	}
+	done := make(chan struct{})
+	m.provisioning[id] = done
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		delete(m.provisioning, id)
+		m.mu.Unlock()
+		close(done)
+	}()
+
+	c, err := m.pool.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Update(func(tx *bolt.Tx) error {
+		return m.provision(id, tx)
+	}); err != nil {
+		c.Close()
+		if rerr := m.pool.Remove(path); rerr != nil {
+			m.errorHandler(rerr)
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// waitProvisioned blocks until the database of the tenant is not being
+// provisioned. Manager lock must be held and it is released while waiting.
+func (m *Manager) waitProvisioned(id string) {
+	for {
+		done, ok := m.provisioning[id]
+		if !ok {
+			return
+		}
+		m.mu.Unlock()
+		<-done
+		m.mu.Lock()
+	}
+}
+
+// count returns the number of tenants with databases, including the ones
+// that are being provisioned. Manager lock must be held.
+func (m *Manager) count() (int, error) {
+	ids, err := m.Tenants()
+	if err != nil {
+		return 0, err
+	}
+	n := len(ids)
+	for id := range m.provisioning {
+		if i := sort.SearchStrings(ids, id); i == len(ids) || ids[i] != id {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Open returns a connection to an existing tenant database. It returns
+// ErrUnknownTenant if the database does not exist.
+func (m *Manager) Open(id string) (*boltdbpool.Connection, error) {
+	path, err := m.Path(id)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.waitProvisioned(id)
+	m.mu.Unlock()
+
+	if !m.pool.Has(path) {
+		if _, err := m.fs.Stat(path); os.IsNotExist(err) {
+			return nil, ErrUnknownTenant
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return m.pool.Get(path)
+}
+
+// View executes the function in a read-only transaction on an existing
+// tenant database.
+func (m *Manager) View(id string, fn func(*bolt.Tx) error) error {
+	c, err := m.Open(id)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.View(fn)
+}
+
+// Update executes the function in a read-write transaction on the tenant
+// database, creating it if needed. It returns ErrQuotaExceeded without
+// calling the function if the database is larger than Options.MaxSize.
+func (m *Manager) Update(id string, fn func(*bolt.Tx) error) error {
+	c, err := m.Get(id)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.Update(func(tx *bolt.Tx) error {
+		if max := m.options.MaxSize; max > 0 && tx.Size() > max {
+			return ErrQuotaExceeded
+		}
+		return fn(tx)
+	})
+}
+
+// Tenants returns sorted IDs of all tenants with databases.
+func (m *Manager) Tenants() ([]string, error) {
+	entries, err := m.fs.ReadDir(m.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var ids []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, Extension) {
+			continue
+		}
+		id := strings.TrimSuffix(name, Extension)
+		if validateID(id) != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Delete closes and removes the tenant database. It returns
+// boltdbpool.ErrInUse if the database is referenced and ErrUnknownTenant
+// if it does not exist.
+func (m *Manager) Delete(id string) error {
+	path, err := m.Path(id)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.waitProvisioned(id)
+	if err := m.pool.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return ErrUnknownTenant
+		}
+		return err
+	}
+	return nil
+}
+
+func (m *Manager) provision(id string, tx *bolt.Tx) error {
+	for _, name := range m.options.Buckets {
+		if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+			return err
+		}
+	}
+	if m.options.Provision != nil {
+		return m.options.Provision(id, tx)
+	}
+	return nil
+}
+
+// validateID allows only letters, digits, dots, dashes and underscores in
+// tenant IDs, so that they are safe to use as file names.
+func validateID(id string) error {
+	if id == "" || id == "." || id == ".." || strings.HasPrefix(id, ".") {
+		return ErrInvalidID
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '.', r == '-', r == '_':
+		default:
+			return ErrInvalidID
+		}
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tenant
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"resenje.org/boltdbpool"
+)
+
+func TestManager(t *testing.T) {
+	pool := boltdbpool.New(nil)
+	defer pool.Close()
+
+	provisioned := map[string]int{}
+	m := New(pool, t.TempDir(), &Options{
+		Buckets: []string{"users", "settings"},
+		Provision: func(id string, tx *bolt.Tx) error {
+			provisioned[id]++
+			return tx.Bucket([]byte("settings")).Put([]byte("tenant"), []byte(id))
+		},
+		MaxTenants: 2,
+	})
+
+	for _, id := range []string{"acme", "globex", "acme"} {
+		if err := m.Update(id, func(tx *bolt.Tx) error {
+			return tx.Bucket([]byte("users")).Put([]byte("admin"), []byte(id))
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if provisioned["acme"] != 1 || provisioned["globex"] != 1 {
+		t.Errorf("got provisioning counts %v", provisioned)
+	}
+	if err := m.View("globex", func(tx *bolt.Tx) error {
+		if v := string(tx.Bucket([]byte("settings")).Get([]byte("tenant"))); v != "globex" {
+			t.Errorf("got tenant %q, expected %q", v, "globex")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Get("initech"); !errors.Is(err, ErrTooManyTenants) {
+		t.Errorf("got error %v, expected %v", err, ErrTooManyTenants)
+	}
+	if err := m.View("initech", func(*bolt.Tx) error { return nil }); !errors.Is(err, ErrUnknownTenant) {
+		t.Errorf("got error %v, expected %v", err, ErrUnknownTenant)
+	}
+
+	ids, err := m.Tenants()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"acme", "globex"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("got tenants %v, expected %v", ids, want)
+	}
+
+	c, err := m.Open("acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Delete("acme"); !errors.Is(err, boltdbpool.ErrInUse) {
+		t.Errorf("got error %v, expected %v", err, boltdbpool.ErrInUse)
+	}
+	c.Close()
+	if err := m.Delete("acme"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Delete("acme"); !errors.Is(err, ErrUnknownTenant) {
+		t.Errorf("got error %v, expected %v", err, ErrUnknownTenant)
+	}
+	if ids, _ := m.Tenants(); !reflect.DeepEqual(ids, []string{"globex"}) {
+		t.Errorf("got tenants %v after delete", ids)
+	}
+}
+
+func TestManagerQuota(t *testing.T) {
+	pool := boltdbpool.New(nil)
+	defer pool.Close()
+
+	m := New(pool, t.TempDir(), &Options{
+		Buckets: []string{"data"},
+		MaxSize: 64 * 1024,
+	})
+
+	value := make([]byte, 1024)
+	var err error
+	for i := 0; i < 1000 && err == nil; i++ {
+		err = m.Update("acme", func(tx *bolt.Tx) error {
+			return tx.Bucket([]byte("data")).Put([]byte{byte(i >> 8), byte(i)}, value)
+		})
+	}
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("got error %v, expected %v", err, ErrQuotaExceeded)
+	}
+}
+
+func TestValidateID(t *testing.T) {
+	for _, id := range []string{"", ".", "..", ".hidden", "a/b", "a\\b", "a b"} {
+		if err := validateID(id); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("id %q: got error %v, expected %v", id, err, ErrInvalidID)
+		}
+	}
+	for _, id := range []string{"acme", "tenant-1", "tenant_2.eu"} {
+		if err := validateID(id); err != nil {
+			t.Errorf("id %q: %v", id, err)
+		}
+	}
+}
+
+func TestManagerProvisioning(t *testing.T) {
+	pool := boltdbpool.New(nil)
+	defer pool.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	m := New(pool, t.TempDir(), &Options{
+		Provision: func(id string, tx *bolt.Tx) error {
+			close(started)
+			<-release
+			_, err := tx.CreateBucket([]byte("provisioned"))
+			return err
+		},
+	})
+
+	errs := make(chan error, 1)
+	go func() {
+		c, err := m.Get("acme")
+		if err == nil {
+			c.Close()
+		}
+		errs <- err
+	}()
+	<-started
+
+	got := make(chan *boltdbpool.Connection)
+	go func() {
+		c, err := m.Get("acme")
+		if err != nil {
+			t.Error(err)
+		}
+		got <- c
+	}()
+	select {
+	case <-got:
+		t.Fatal("database returned before it is provisioned")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+
+	c := <-got
+	if c == nil {
+		t.FailNow()
+	}
+	defer c.Close()
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if err := c.View(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte("provisioned")) == nil {
+			t.Error("database is not provisioned")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// failingRemoveFS fails to remove files.
+type failingRemoveFS struct {
+	boltdbpool.OSFS
+}
+
+var errRemove = errors.New("remove failed")
+
+func (failingRemoveFS) Remove(name string) error {
+	return errRemove
+}
+
+func TestManagerProvisioningError(t *testing.T) {
+	pool := boltdbpool.New(&boltdbpool.Options{
+		FS: failingRemoveFS{},
+	})
+	defer pool.Close()
+
+	var handled []error
+	errProvision := errors.New("provision failed")
+	m := New(pool, t.TempDir(), &Options{
+		Provision: func(id string, tx *bolt.Tx) error {
+			return errProvision
+		},
+		ErrorHandler: func(err error) {
+			handled = append(handled, err)
+		},
+	})
+
+	if _, err := m.Get("acme"); !errors.Is(err, errProvision) {
+		t.Errorf("got error %v, expected %v", err, errProvision)
+	}
+	if len(handled) != 1 || !errors.Is(handled[0], errRemove) {
+		t.Errorf("got handled errors %v, expected %v", handled, errRemove)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Throttle pauses background maintenance work when latency of foreground
+// transactions degrades. Latencies of transactions executed by Connection
+// View, Update and Batch methods are observed and their exponentially
+// weighted moving average is compared to the target latency.
+type Throttle struct {
+	target   time.Duration
+	interval time.Duration
+	idle     time.Duration
+
+	avg  time.Duration
+	last time.Time
+	mu   sync.Mutex
+}
+
+// NewThrottle creates a new Throttle that considers the serving path
+// degraded when the average transaction latency is above target. The target
+// must be positive, otherwise Options.Validate reports the Throttle as
+// invalid.
+func NewThrottle(target time.Duration) *Throttle {
+	return &Throttle{
+		target:   target,
+		interval: 100 * time.Millisecond,
+		idle:     10 * time.Second,
+	}
+}
+
+// Observe records a latency of a foreground transaction.
+func (t *Throttle) Observe(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.avg == 0 {
+		t.avg = d
+	} else {
+		// alpha of 0.2
+		t.avg = (4*t.avg + d) / 5
+	}
+	t.last = time.Now()
+}
+
+// Latency returns the average observed latency. If there were no
+// observations for a while, the serving path is considered idle and zero is
+// returned.
+func (t *Throttle) Latency() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if time.Since(t.last) > t.idle {
+		return 0
+	}
+	return t.avg
+}
+
+// Degraded returns true if the average observed latency is above the target.
+func (t *Throttle) Degraded() bool {
+	return t.Latency() > t.target
+}
+
+// Wait blocks until the serving path is not degraded or the context is done.
+// The time between checks grows proportionally to the observed latency.
+func (t *Throttle) Wait(ctx context.Context) error {
+	for {
+		latency := t.Latency()
+		if latency <= t.target {
+			return nil
+		}
+		d := t.interval
+		if t.target > 0 {
+			d *= time.Duration(latency / t.target)
+		}
+		if d > t.idle {
+			d = t.idle
+		}
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (p *Pool) observe(start time.Time) {
+	if p.options.Throttle != nil {
+		p.options.Throttle.Observe(time.Since(start))
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestThrottle(t *testing.T) {
+	throttle := NewThrottle(10 * time.Millisecond)
+	throttle.interval = time.Millisecond
+
+	if throttle.Degraded() {
+		t.Error("throttle degraded without observations")
+	}
+
+	throttle.Observe(50 * time.Millisecond)
+	if !throttle.Degraded() {
+		t.Error("throttle not degraded after slow observation")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := throttle.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("got error %v, expected %v", err, context.DeadlineExceeded)
+	}
+
+	for i := 0; i < 20; i++ {
+		throttle.Observe(time.Millisecond)
+	}
+	if throttle.Degraded() {
+		t.Errorf("throttle degraded with latency %s", throttle.Latency())
+	}
+	if err := throttle.Wait(context.Background()); err != nil {
+		t.Error(err)
+	}
+
+	throttle.Observe(time.Second)
+	throttle.idle = time.Millisecond
+	time.Sleep(2 * time.Millisecond)
+	if throttle.Degraded() {
+		t.Error("throttle degraded while idle")
+	}
+}
+
+func TestThrottleObservesTransactions(t *testing.T) {
+	throttle := NewThrottle(time.Hour)
+	pool := New(&Options{
+		Throttle: throttle,
+	})
+	defer pool.Close()
+
+	c, err := pool.Get(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Update(func(tx *bolt.Tx) error {
+		time.Sleep(time.Millisecond)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if throttle.Latency() < time.Millisecond {
+		t.Errorf("transaction latency %s not observed", throttle.Latency())
+	}
+}
+
+func TestThrottleZeroTarget(t *testing.T) {
+	throttle := NewThrottle(0)
+	throttle.interval = time.Millisecond
+
+	throttle.Observe(time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := throttle.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("got error %v, expected %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,362 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package timed
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"resenje.org/boltdbpool"
+)
+
+// ErrDatabaseInUse is returned when a database that should be removed is
+// open in the pool.
+var ErrDatabaseInUse = boltdbpool.ErrInUse
+
+// RollupBucket is the bucket in which databases of pipeline stages record
+// paths of databases that are rolled up into them until the rolled up
+// databases are removed, so that their data is not added again if the
+// removal fails.
+var RollupBucket = []byte("boltdbpool.rollups")
+
+// Stage is a single step in a data lifecycle Pipeline. Databases of the
+// stage pool whose period ended more than Retention ago are rolled up into
+// the pool of the next stage and removed. Databases of the last stage are
+// only removed.
+type Stage struct {
+	// Name identifies the stage in the status report.
+	Name string
+	// Pool holds databases of this stage.
+	Pool *Pool
+	// Retention is the duration after the end of the database period for
+	// which the database is kept in this stage.
+	Retention time.Duration
+	// Rollup merges data from a database of this stage into a database of
+	// the next stage. If nil, all buckets are copied and existing keys are
+	// overwritten.
+	Rollup func(dst, src *bolt.Tx) error
+}
+
+// StageStatus holds information about the last execution of a Stage.
+type StageStatus struct {
+	Name       string
+	Databases  int
+	Oldest     string
+	Newest     string
+	RolledUp   int
+	Removed    int
+	LastRun    time.Time
+	LastErrors []error
+}
+
+// Pipeline executes stages that roll up, archive and remove time
+// partitioned databases, for example: keep raw hourly data for 7 days, roll
+// it up to daily databases kept for 90 days, archive them to monthly
+// databases kept for 2 years and then remove them.
+type Pipeline struct {
+	stages []Stage
+	status []StageStatus
+	mu     sync.Mutex
+}
+
+// NewPipeline returns a new Pipeline with provided stages. Every period of
+// a stage pool must be within a single period of the next stage pool, so
+// that databases are rolled up into a single database. Weekly stages can
+// therefore be followed only by weekly stages.
+func NewPipeline(stages ...Stage) (*Pipeline, error) {
+	for i, s := range stages {
+		if s.Pool == nil {
+			return nil, fmt.Errorf("stage %d: nil pool", i)
+		}
+		if s.Retention < 0 {
+			return nil, fmt.Errorf("stage %d: negative retention", i)
+		}
+		if i > 0 {
+			prev := stages[i-1].Pool.period
+			if s.Pool.period.rank() < prev.rank() {
+				return nil, fmt.Errorf("stage %d: period shorter than in the previous stage", i)
+			}
+			if !prev.nests(s.Pool.period) {
+				return nil, fmt.Errorf("stage %d: %s periods of the previous stage are not within %s periods", i, prev, s.Pool.period)
+			}
+		}
+	}
+	status := make([]StageStatus, len(stages))
+	for i, s := range stages {
+		status[i].Name = s.Name
+	}
+	return &Pipeline{
+		stages: stages,
+		status: status,
+	}, nil
+}
+
+// Run executes the pipeline every interval until the context is done.
+func (pl *Pipeline) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		pl.RunOnce(time.Now())
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// RunOnce executes all stages for the provided current time. Errors are
+// reported in the Status. Databases that are open in the pool are skipped
+// and processed on the next run. Databases that are past their retention
+// should not be written to, as writes during the rollup may be lost.
+func (pl *Pipeline) RunOnce(now time.Time) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	for i, s := range pl.stages {
+		var next *Stage
+		if i < len(pl.stages)-1 {
+			next = &pl.stages[i+1]
+		}
+		status := StageStatus{
+			Name:    s.Name,
+			LastRun: now,
+		}
+		for _, series := range s.Pool.seriesList() {
+			start, err := s.Pool.timeFromSeries(series)
+			if err != nil {
+				continue
+			}
+			_, end, _ := s.Pool.periodBounds(start)
+			if end.Add(s.Retention).After(now) {
+				continue
+			}
+			if next != nil {
+				if err := rollup(next, s, start); err != nil {
+					status.LastErrors = append(status.LastErrors, fmt.Errorf("%s: rollup %s: %w", s.Name, series, err))
+					continue
+				}
+				status.RolledUp++
+			}
+			if err := s.Pool.removeSeries(series); err != nil {
+				status.LastErrors = append(status.LastErrors, fmt.Errorf("%s: remove %s: %w", s.Name, series, err))
+				continue
+			}
+			status.Removed++
+			if next != nil {
+				if err := forgetRollup(next, s, start); err != nil {
+					status.LastErrors = append(status.LastErrors, fmt.Errorf("%s: forget rollup %s: %w", s.Name, series, err))
+				}
+			}
+		}
+		series := s.Pool.seriesList()
+		status.Databases = len(series)
+		if len(series) > 0 {
+			status.Oldest = series[0]
+			status.Newest = series[len(series)-1]
+		}
+		pl.status[i] = status
+	}
+}
+
+// Status returns the status of every stage after the last run.
+func (pl *Pipeline) Status() []StageStatus {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	return append([]StageStatus(nil), pl.status...)
+}
+
+// rollup merges data from the database of stage s that holds data for time
+// t into the database of the next stage, unless it is already rolled up
+// into it. The source database is opened through the stage pool and it is
+// skipped if it is already open, as it may be written to.
+func rollup(next *Stage, s Stage, t time.Time) error {
+	path := s.Pool.pathFromSeries(s.Pool.seriesFromTime(t))
+	if s.Pool.pool.Has(path) {
+		return ErrDatabaseInUse
+	}
+	if _, err := s.Pool.fs.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	src, err := s.Pool.pool.Get(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := next.Pool.NewConnection(t)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	fn := s.Rollup
+	if fn == nil {
+		fn = mergeTx
+	}
+	return src.View(func(srcTx *bolt.Tx) error {
+		return dst.Update(func(dstTx *bolt.Tx) error {
+			b, err := dstTx.CreateBucketIfNotExists(RollupBucket)
+			if err != nil {
+				return err
+			}
+			if b.Get([]byte(path)) != nil {
+				return nil
+			}
+			if err := fn(dstTx, srcTx); err != nil {
+				return err
+			}
+			return b.Put([]byte(path), []byte(t.Format(time.RFC3339)))
+		})
+	})
+}
+
+// forgetRollup deletes the record of the rollup of the database of stage s
+// that holds data for time t, after it is removed.
+func forgetRollup(next *Stage, s Stage, t time.Time) error {
+	path := s.Pool.pathFromSeries(s.Pool.seriesFromTime(t))
+	dst, err := next.Pool.NewConnection(t)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	return dst.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(RollupBucket)
+		if b == nil {
+			return nil
+		}
+		if err := b.Delete([]byte(path)); err != nil {
+			return err
+		}
+		if k, _ := b.Cursor().First(); k == nil {
+			return tx.DeleteBucket(RollupBucket)
+		}
+		return nil
+	})
+}
+
+// mergeTx copies all buckets from src to dst, except the RollupBucket.
+func mergeTx(dst, src *bolt.Tx) error {
+	return src.ForEach(func(name []byte, b *bolt.Bucket) error {
+		if bytes.Equal(name, RollupBucket) {
+			return nil
+		}
+		db, err := dst.CreateBucketIfNotExists(name)
+		if err != nil {
+			return err
+		}
+		return mergeBucket(db, b)
+	})
+}
+
+func mergeBucket(dst, src *bolt.Bucket) error {
+	return src.ForEach(func(k, v []byte) error {
+		if v == nil {
+			db, err := dst.CreateBucketIfNotExists(k)
+			if err != nil {
+				return err
+			}
+			return mergeBucket(db, src.Bucket(k))
+		}
+		return dst.Put(k, v)
+	})
+}
+
+// seriesList returns a copy of known series.
+func (p *Pool) seriesList() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]string(nil), p.series...)
+}
+
+// timeFromSeries returns the start of the period of the series in the pool
+// location.
+func (p *Pool) timeFromSeries(series string) (time.Time, error) {
+	var layout string
+	switch p.period {
+	case Hourly:
+		layout = "2006010215"
+	case Daily:
+		layout = "20060102"
+	case Monthly:
+		layout = "200601"
+	case Yearly:
+		layout = "2006"
+	case Weekly:
+		return timeFromWeek(series, p.location())
+	default:
+		if p.period.minutes() == 0 {
+			return time.Time{}, ErrUnknownPeriod
+		}
+		layout = "200601021504"
+	}
+	return time.ParseInLocation(layout, series, p.location())
+}
+
+// timeFromWeek returns the start of the ISO week of the weekly series in
+// the location.
+func timeFromWeek(series string, loc *time.Location) (time.Time, error) {
+	var year, week int
+	if _, err := fmt.Sscanf(series, "%4dW%2d", &year, &week); err != nil || len(series) != 7 {
+		return time.Time{}, fmt.Errorf("invalid weekly series %q", series)
+	}
+	// January 4th is always in the first ISO week
+	jan4 := time.Date(year, 1, 4, 0, 0, 0, 0, loc)
+	t := jan4.AddDate(0, 0, -(int(jan4.Weekday())+6)%7+(week-1)*7)
+	if y, w := t.ISOWeek(); y != year || w != week {
+		return time.Time{}, fmt.Errorf("invalid weekly series %q", series)
+	}
+	return t, nil
+}
+
+// removeSeries deletes the database file of the series if it is not
+// referenced in the pool, and its directory if it is left empty.
+func (p *Pool) removeSeries(series string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	path := p.pathFromSeries(series)
+	if err := p.pool.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	p.removeEmptyDir(filepath.Dir(path))
+	for i, s := range p.series {
+		if s == series {
+			p.series = append(p.series[:i], p.series[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// removeEmptyDir deletes the directory of the database and its parents in
+// the pool directory while they are empty, like month directories of
+// hourly and daily databases. Directories in the pool layout are removed
+// even if they are not created by this process, so that they do not
+// accumulate over restarts.
+func (p *Pool) removeEmptyDir(dir string) {
+	root := filepath.Clean(p.dir)
+	for dir = filepath.Clean(dir); dir != root && filepath.Dir(dir) != dir; dir = filepath.Dir(dir) {
+		// removal fails on directories that are not empty
+		if err := p.fs.Remove(dir); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,370 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package timed
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"resenje.org/boltdbpool"
+)
+
+func TestPipeline(t *testing.T) {
+	hourly, err := New(t.TempDir(), Hourly, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hourly.Close()
+	daily, err := New(t.TempDir(), Daily, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer daily.Close()
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)
+	for h := 0; h < 48; h++ {
+		tm := start.Add(time.Duration(h) * time.Hour)
+		c, err := hourly.NewConnection(tm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Update(func(tx *bolt.Tx) error {
+			b, err := tx.CreateBucketIfNotExists([]byte("events"))
+			if err != nil {
+				return err
+			}
+			return b.Put([]byte(tm.Format(time.RFC3339)), []byte("event"))
+		}); err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+	}
+
+	pl, err := NewPipeline(
+		Stage{Name: "raw", Pool: hourly, Retention: 24 * time.Hour},
+		Stage{Name: "daily", Pool: daily, Retention: 48 * time.Hour},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// hours of the first day and the first hour of the second day are past
+	// retention
+	pl.RunOnce(start.Add(49 * time.Hour))
+
+	status := pl.Status()
+	if status[0].RolledUp != 25 || status[0].Removed != 25 || status[0].Databases != 23 {
+		t.Errorf("unexpected raw stage status %+v", status[0])
+	}
+	if len(status[0].LastErrors) != 0 {
+		t.Errorf("unexpected errors %v", status[0].LastErrors)
+	}
+	if status[1].Databases != 2 || status[1].Removed != 0 {
+		t.Errorf("unexpected daily stage status %+v", status[1])
+	}
+
+	c, err := daily.GetConnection(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.View(func(tx *bolt.Tx) error {
+		if n := tx.Bucket([]byte("events")).Stats().KeyN; n != 24 {
+			t.Errorf("got %d events in the first day, expected 24", n)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	pl.RunOnce(start.Add(5 * 24 * time.Hour))
+	status = pl.Status()
+	if status[0].Databases != 0 || status[1].Databases != 0 || status[1].Removed != 2 {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if _, err := daily.GetConnection(start); !errors.Is(err, ErrUnknownDB) {
+		t.Errorf("got error %v, expected %v", err, ErrUnknownDB)
+	}
+}
+
+func TestNewPipelineValidation(t *testing.T) {
+	hourly, err := New(t.TempDir(), Hourly, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hourly.Close()
+	daily, err := New(t.TempDir(), Daily, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer daily.Close()
+
+	if _, err := NewPipeline(Stage{Pool: daily}, Stage{Pool: hourly}); err == nil {
+		t.Error("expected error for decreasing periods")
+	}
+	if _, err := NewPipeline(Stage{}); err == nil {
+		t.Error("expected error for nil pool")
+	}
+
+	weekly, err := New(t.TempDir(), Weekly, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer weekly.Close()
+	monthly, err := New(t.TempDir(), Monthly, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer monthly.Close()
+	if _, err := NewPipeline(Stage{Pool: daily}, Stage{Pool: weekly}, Stage{Pool: weekly}); err != nil {
+		t.Error(err)
+	}
+	if _, err := NewPipeline(Stage{Pool: daily}, Stage{Pool: weekly}, Stage{Pool: monthly}); err == nil {
+		t.Error("expected error for weeks that are not within months")
+	}
+	if _, err := NewPipeline(Stage{Pool: daily}, Stage{Pool: monthly}); err != nil {
+		t.Error(err)
+	}
+
+	tenMinutes, err := New(t.TempDir(), Minutes(10), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tenMinutes.Close()
+	fifteenMinutes, err := New(t.TempDir(), Minutes(15), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fifteenMinutes.Close()
+	if _, err := NewPipeline(Stage{Pool: tenMinutes}, Stage{Pool: fifteenMinutes}); err == nil {
+		t.Error("expected error for 10 minute periods that are not within 15 minute periods")
+	}
+	if _, err := NewPipeline(Stage{Pool: tenMinutes}, Stage{Pool: hourly}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPipelineRemovesNestedEmptyDirs(t *testing.T) {
+	dir := t.TempDir()
+	pool, err := New(dir, Minutely, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	start := time.Date(2020, 1, 31, 23, 59, 0, 0, time.Local)
+	c, err := pool.NewConnection(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	pl, err := NewPipeline(Stage{Name: "minutely", Pool: pool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pl.RunOnce(start.Add(time.Minute))
+
+	if status := pl.Status()[0]; status.Removed != 1 || len(status.LastErrors) != 0 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "202001")); !os.IsNotExist(err) {
+		t.Errorf("empty month directory is not removed: %v", err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("pool directory: %v", err)
+	}
+}
+
+func TestPipelineRemovesEmptyDirs(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2020, 1, 31, 0, 0, 0, 0, time.Local)
+
+	daily, err := New(dir, Daily, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for d := 0; d < 2; d++ {
+		c, err := daily.NewConnection(start.AddDate(0, 0, d))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+	}
+	if err := daily.CloseAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new pool does not know which directories were created before
+	daily, err = New(dir, Daily, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer daily.Close()
+
+	pl, err := NewPipeline(Stage{Name: "daily", Pool: daily})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pl.RunOnce(start.AddDate(0, 0, 1))
+
+	if status := pl.Status()[0]; status.Removed != 1 || len(status.LastErrors) != 0 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "202001")); !os.IsNotExist(err) {
+		t.Errorf("empty month directory is not removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "202002")); err != nil {
+		t.Errorf("month directory with a database: %v", err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("pool directory: %v", err)
+	}
+}
+
+// toggleRemoveFS fails to remove database files while fail is set.
+type toggleRemoveFS struct {
+	boltdbpool.OSFS
+	fail *int32
+}
+
+var errRemove = errors.New("remove failed")
+
+func (fs toggleRemoveFS) Remove(name string) error {
+	if atomic.LoadInt32(fs.fail) == 1 && filepath.Ext(name) == ".db" {
+		return errRemove
+	}
+	return fs.OSFS.Remove(name)
+}
+
+func TestPipelineRollupOnce(t *testing.T) {
+	fail := int32(1)
+	hourly, err := New(t.TempDir(), Hourly, &boltdbpool.Options{
+		FS: toggleRemoveFS{fail: &fail},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hourly.Close()
+	daily, err := New(t.TempDir(), Daily, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer daily.Close()
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)
+	c, err := hourly.NewConnection(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("counts"))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("events"), []byte{1})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	// counts are summed, so repeated rollups would change them
+	pl, err := NewPipeline(
+		Stage{Name: "raw", Pool: hourly, Retention: time.Hour, Rollup: func(dst, src *bolt.Tx) error {
+			b, err := dst.CreateBucketIfNotExists([]byte("counts"))
+			if err != nil {
+				return err
+			}
+			v := b.Get([]byte("events"))
+			n := src.Bucket([]byte("counts")).Get([]byte("events"))[0]
+			if len(v) > 0 {
+				n += v[0]
+			}
+			return b.Put([]byte("events"), []byte{n})
+		}},
+		Stage{Name: "daily", Pool: daily, Retention: 24 * time.Hour},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := start.Add(3 * time.Hour)
+	for i := 0; i < 2; i++ {
+		pl.RunOnce(now)
+		if status := pl.Status()[0]; status.Removed != 0 || len(status.LastErrors) != 1 || !errors.Is(status.LastErrors[0], errRemove) {
+			t.Fatalf("unexpected status %+v", status)
+		}
+	}
+	atomic.StoreInt32(&fail, 0)
+	pl.RunOnce(now)
+	if status := pl.Status()[0]; status.Removed != 1 || len(status.LastErrors) != 0 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	c, err = daily.GetConnection(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket([]byte("counts")).Get([]byte("events")); len(v) != 1 || v[0] != 1 {
+			t.Errorf("got count %v, expected 1", v)
+		}
+		if tx.Bucket(RollupBucket) != nil {
+			t.Error("rollup records are not deleted")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPipelineLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+5", 5*60*60)
+	hourly, err := NewInLocation(t.TempDir(), Hourly, loc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hourly.Close()
+
+	// the time is converted to the pool location
+	tm := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c, err := hourly.NewConnection(tm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	if got, want := filepath.Base(hourly.Path(tm)), "2020010105.db"; got != want {
+		t.Errorf("got database %s, expected %s", got, want)
+	}
+
+	start, err := hourly.timeFromSeries("2020010105")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !start.Equal(tm) {
+		t.Errorf("got series start %v, expected %v", start, tm)
+	}
+
+	pl, err := NewPipeline(Stage{Name: "raw", Pool: hourly, Retention: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pl.RunOnce(tm.Add(90 * time.Minute))
+	if status := pl.Status()[0]; status.Removed != 0 {
+		t.Fatalf("database removed before its retention: %+v", status)
+	}
+	pl.RunOnce(tm.Add(2 * time.Hour))
+	if status := pl.Status()[0]; status.Removed != 1 {
+		t.Fatalf("database not removed after its retention: %+v", status)
+	}
+}
@@ -7,10 +7,15 @@ package timed // import "resenje.org/boltdbpool/timed"
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"resenje.org/boltdbpool"
@@ -23,33 +28,170 @@ var (
 	ErrUnknownPeriod = errors.New("unknown period")
 )
 
-type period int
+// Period defines the time span of data that a single database holds.
+type Period int
 
 // Periods for database partitioning.
 const (
 	_             = iota
-	Hourly period = iota
+	Hourly Period = iota
 	Daily
 	Monthly
 	Yearly
+	// Weekly databases hold data for ISO 8601 weeks, starting on Monday,
+	// and are named by the ISO year and week, like 2024W07.db.
+	Weekly
 )
 
+// minutesPeriod is the base of sub-hour periods returned by Minutes.
+const minutesPeriod Period = 100
+
+// Minutely databases hold data for a single minute. They are named by the
+// minute, like 200601021504.db, in directories of the month and the hour,
+// like 200601/2006010215.
+const Minutely = minutesPeriod + 1
+
+// Minutes returns the sub-hour period of n minutes. Databases are named and
+// placed as Minutely databases, by the first minute of the period. The
+// value of n must divide the hour evenly, like 5, 10, 15 or 30, and pools
+// with other periods are not created.
+func Minutes(n int) Period {
+	return minutesPeriod + Period(n)
+}
+
+// minutes returns the number of minutes of a valid sub-hour period, or 0
+// for other periods.
+func (p Period) minutes() int {
+	n := int(p - minutesPeriod)
+	if n < 1 || n > 30 || 60%n != 0 {
+		return 0
+	}
+	return n
+}
+
+// String returns the lowercase name of the period. Sub-hour periods other
+// than Minutely are named by their number of minutes, like 15m.
+func (p Period) String() string {
+	if n := p.minutes(); n > 1 {
+		return strconv.Itoa(n) + "m"
+	}
+	switch p {
+	case Minutely:
+		return "minutely"
+	case Hourly:
+		return "hourly"
+	case Daily:
+		return "daily"
+	case Monthly:
+		return "monthly"
+	case Yearly:
+		return "yearly"
+	case Weekly:
+		return "weekly"
+	}
+	return "unknown"
+}
+
+// rank orders periods by their nominal duration in minutes.
+func (p Period) rank() int {
+	if n := p.minutes(); n > 0 {
+		return n
+	}
+	switch p {
+	case Hourly:
+		return 60
+	case Daily:
+		return 24 * 60
+	case Weekly:
+		return 7 * 24 * 60
+	case Monthly:
+		return 30 * 24 * 60
+	case Yearly:
+		return 365 * 24 * 60
+	}
+	return 0
+}
+
+// nests returns true if every period of p is within a single period of
+// next, so that data of a p database can be rolled up into a single next
+// database. ISO weeks do not nest in months and years, and sub-hour
+// periods nest only in periods of a multiple of their minutes.
+func (p Period) nests(next Period) bool {
+	if p.rank() > next.rank() {
+		return false
+	}
+	if n, m := p.minutes(), next.minutes(); n > 0 && m > 0 {
+		return m%n == 0
+	}
+	if p == Weekly {
+		return next == Weekly
+	}
+	return true
+}
+
+// ParsePeriod returns the period with the name as returned by
+// Period.String.
+func ParsePeriod(name string) (Period, error) {
+	for _, p := range []Period{Minutely, Hourly, Daily, Weekly, Monthly, Yearly} {
+		if p.String() == name {
+			return p, nil
+		}
+	}
+	if n, err := strconv.Atoi(strings.TrimSuffix(name, "m")); err == nil && strings.HasSuffix(name, "m") {
+		if p := Minutes(n); p.minutes() > 1 && p.String() == name {
+			return p, nil
+		}
+	}
+	return 0, ErrUnknownPeriod
+}
+
 // Pool holds database connections and database information.
 type Pool struct {
 	pool   *boltdbpool.Pool
+	fs     boltdbpool.FS
 	series []string
 	dir    string
-	period period
-	mu     sync.Mutex
+	period Period
+	// loc is the location of series names, or nil if they are in the
+	// location of provided times
+	loc *time.Location
+	mu  sync.Mutex
+
+	// cache holds *seriesCache for the most recently requested time
+	cache atomic.Value
+}
+
+// seriesCache holds the series and database path for a single period, so
+// that the time is not formatted on every call for the same period.
+type seriesCache struct {
+	start  time.Time
+	end    time.Time
+	loc    *time.Location
+	series string
+	path   string
 }
 
 // New returns a new instance of Pool with database files in dir,
 // partitioned by period and each database connection created with options.
-func New(dir string, p period, options *boltdbpool.Options) (*Pool, error) {
+// Databases are selected by times in their own locations, and series names
+// of existing databases are interpreted in the local time zone.
+func New(dir string, p Period, options *boltdbpool.Options) (*Pool, error) {
+	return NewInLocation(dir, p, nil, options)
+}
+
+// NewInLocation returns a new Pool as New does, with times converted to
+// the location before databases are selected and series names interpreted
+// in it, so that periods are aligned to the same time zone regardless of
+// the locations of provided times. If loc is nil, New behavior is used.
+func NewInLocation(dir string, p Period, loc *time.Location, options *boltdbpool.Options) (*Pool, error) {
+	var fs boltdbpool.FS = boltdbpool.OSFS{}
+	if options != nil && options.FS != nil {
+		fs = options.FS
+	}
 	series := []string{}
 	switch p {
 	case Hourly:
-		matches, err := filepath.Glob(filepath.Join(dir, "??????", "??????????.db"))
+		matches, err := boltdbpool.Glob(fs, filepath.Join(dir, "??????", "??????????.db"))
 		if err != nil {
 			return nil, err
 		}
@@ -60,7 +202,7 @@ func New(dir string, p period, options *boltdbpool.Options) (*Pool, error) {
 			}
 		}
 	case Daily:
-		matches, err := filepath.Glob(filepath.Join(dir, "??????", "????????.db"))
+		matches, err := boltdbpool.Glob(fs, filepath.Join(dir, "??????", "????????.db"))
 		if err != nil {
 			return nil, err
 		}
@@ -71,7 +213,7 @@ func New(dir string, p period, options *boltdbpool.Options) (*Pool, error) {
 			}
 		}
 	case Monthly:
-		matches, err := filepath.Glob(filepath.Join(dir, "??????.db"))
+		matches, err := boltdbpool.Glob(fs, filepath.Join(dir, "??????.db"))
 		if err != nil {
 			return nil, err
 		}
@@ -82,7 +224,7 @@ func New(dir string, p period, options *boltdbpool.Options) (*Pool, error) {
 			}
 		}
 	case Yearly:
-		matches, err := filepath.Glob(filepath.Join(dir, "????.db"))
+		matches, err := boltdbpool.Glob(fs, filepath.Join(dir, "????.db"))
 		if err != nil {
 			return nil, err
 		}
@@ -92,18 +234,60 @@ func New(dir string, p period, options *boltdbpool.Options) (*Pool, error) {
 				series = append(series, match[:4])
 			}
 		}
+	case Weekly:
+		matches, err := boltdbpool.Glob(fs, filepath.Join(dir, "????W??.db"))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			match = filepath.Base(match)
+			if len(match) >= 7 {
+				series = append(series, match[:7])
+			}
+		}
 	default:
-		return nil, ErrUnknownPeriod
+		if p.minutes() == 0 {
+			return nil, ErrUnknownPeriod
+		}
+		matches, err := boltdbpool.Glob(fs, filepath.Join(dir, "??????", "??????????", "????????????.db"))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			match = filepath.Base(match)
+			if len(match) >= 12 {
+				series = append(series, match[:12])
+			}
+		}
 	}
 	return &Pool{
 		pool:   boltdbpool.New(options),
+		fs:     fs,
 		series: series,
 		dir:    dir,
 		period: p,
+		loc:    loc,
 	}, nil
 }
 
+// in returns the time in the pool location, if it is set.
+func (p *Pool) in(t time.Time) time.Time {
+	if p.loc == nil {
+		return t
+	}
+	return t.In(p.loc)
+}
+
+// location returns the location of series names.
+func (p *Pool) location() *time.Location {
+	if p.loc == nil {
+		return time.Local
+	}
+	return p.loc
+}
+
 func (p *Pool) seriesFromTime(t time.Time) string {
+	t = p.in(t)
 	if p.period == Hourly {
 		return t.Format("2006010215")
 	}
@@ -116,9 +300,65 @@ func (p *Pool) seriesFromTime(t time.Time) string {
 	if p.period == Yearly {
 		return t.Format("2006")
 	}
+	if p.period == Weekly {
+		y, w := t.ISOWeek()
+		return fmt.Sprintf("%04dW%02d", y, w)
+	}
+	if n := p.period.minutes(); n > 0 {
+		return t.Add(-time.Duration(t.Minute()%n) * time.Minute).Format("200601021504")
+	}
 	return ""
 }
 
+// seriesAndPath returns the series and database path for the time, using the
+// cached values if the time is in the same period as the previous call.
+func (p *Pool) seriesAndPath(t time.Time) (series, path string) {
+	t = p.in(t)
+	if c, ok := p.cache.Load().(*seriesCache); ok && c.loc == t.Location() && !t.Before(c.start) && t.Before(c.end) {
+		return c.series, c.path
+	}
+	series = p.seriesFromTime(t)
+	path = p.pathFromSeries(series)
+	if start, end, ok := p.periodBounds(t); ok {
+		p.cache.Store(&seriesCache{
+			start:  start,
+			end:    end,
+			loc:    t.Location(),
+			series: series,
+			path:   path,
+		})
+	}
+	return series, path
+}
+
+// periodBounds returns the start and the end of the period that contains
+// the time.
+func (p *Pool) periodBounds(t time.Time) (start, end time.Time, ok bool) {
+	t = p.in(t)
+	y, m, d := t.Date()
+	loc := t.Location()
+	switch p.period {
+	case Hourly:
+		h := t.Hour()
+		return time.Date(y, m, d, h, 0, 0, 0, loc), time.Date(y, m, d, h+1, 0, 0, 0, loc), true
+	case Daily:
+		return time.Date(y, m, d, 0, 0, 0, 0, loc), time.Date(y, m, d+1, 0, 0, 0, 0, loc), true
+	case Monthly:
+		return time.Date(y, m, 1, 0, 0, 0, 0, loc), time.Date(y, m+1, 1, 0, 0, 0, 0, loc), true
+	case Yearly:
+		return time.Date(y, 1, 1, 0, 0, 0, 0, loc), time.Date(y+1, 1, 1, 0, 0, 0, 0, loc), true
+	case Weekly:
+		// days since Monday
+		d -= (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, d, 0, 0, 0, 0, loc), time.Date(y, m, d+7, 0, 0, 0, 0, loc), true
+	}
+	if n := p.period.minutes(); n > 0 {
+		h, min := t.Hour(), t.Minute()-t.Minute()%n
+		return time.Date(y, m, d, h, min, 0, 0, loc), time.Date(y, m, d, h, min+n, 0, 0, loc), true
+	}
+	return
+}
+
 func (p *Pool) pathFromSeries(series string) (path string) {
 	if p.period == Hourly && len(series) == 10 {
 		return filepath.Join(p.dir, series[:6], series+".db")
@@ -132,6 +372,12 @@ func (p *Pool) pathFromSeries(series string) (path string) {
 	if p.period == Yearly && len(series) == 4 {
 		return filepath.Join(p.dir, series+".db")
 	}
+	if p.period == Weekly && len(series) == 7 {
+		return filepath.Join(p.dir, series+".db")
+	}
+	if p.period.minutes() > 0 && len(series) == 12 {
+		return filepath.Join(p.dir, series[:6], series[:10], series+".db")
+	}
 	return
 }
 
@@ -147,8 +393,7 @@ func (p *Pool) connFromPath(path string) (c *boltdbpool.Connection, err error) {
 // creates a new one for a database that should hold or holds
 // data for a provided time.
 func (p *Pool) NewConnection(t time.Time) (conn *Connection, err error) {
-	series := p.seriesFromTime(t)
-	path := p.pathFromSeries(series)
+	series, path := p.seriesAndPath(t)
 	c, err := p.pool.Get(path)
 	if err != nil {
 		return nil, err
@@ -178,13 +423,15 @@ func (p *Pool) NewConnection(t time.Time) (conn *Connection, err error) {
 // GetConnection returns a Connection if the database for the provided
 // time exists.
 func (p *Pool) GetConnection(t time.Time) (conn *Connection, err error) {
-	series := p.seriesFromTime(t)
-	path := p.pathFromSeries(series)
-	if _, err = os.Stat(path); os.IsNotExist(err) {
-		err = ErrUnknownDB
-		return
-	} else if err != nil {
-		return
+	series, path := p.seriesAndPath(t)
+	// database files that are open in the pool exist
+	if !p.pool.Has(path) {
+		if _, err = p.fs.Stat(path); os.IsNotExist(err) {
+			err = ErrUnknownDB
+			return
+		} else if err != nil {
+			return
+		}
 	}
 	c, err := p.pool.Get(path)
 	if err != nil {
@@ -206,7 +453,7 @@ func (p *Pool) NextConnection(t time.Time) (conn *Connection, err error) {
 		s := p.series[i]
 		if s > series {
 			path = p.pathFromSeries(s)
-			if _, err = os.Stat(path); os.IsNotExist(err) {
+			if _, err = p.fs.Stat(path); os.IsNotExist(err) {
 				continue
 			} else if err != nil {
 				return
@@ -239,7 +486,7 @@ func (p *Pool) PrevConnection(t time.Time) (conn *Connection, err error) {
 		s := p.series[i]
 		if s < series {
 			path = p.pathFromSeries(s)
-			if _, err = os.Stat(path); os.IsNotExist(err) {
+			if _, err = p.fs.Stat(path); os.IsNotExist(err) {
 				continue
 			} else if err != nil {
 				return
@@ -263,11 +510,57 @@ func (p *Pool) PrevConnection(t time.Time) (conn *Connection, err error) {
 	}, nil
 }
 
+// Get returns a connection from the underlying boltdbpool.Pool for the
+// database on path. It allows the timed pool to be used through the
+// boltdbpool.Interface. Databases are not registered as time series, so
+// NewConnection should be used to create new ones.
+func (p *Pool) Get(path string) (*boltdbpool.Connection, error) {
+	return p.pool.Get(path)
+}
+
+// Has returns true if a database with a file path is open.
+func (p *Pool) Has(path string) bool {
+	return p.pool.Has(path)
+}
+
+// Path returns the database file path that holds data for the time.
+func (p *Pool) Path(t time.Time) string {
+	_, path := p.seriesAndPath(t)
+	return path
+}
+
+// Paths returns file paths of all known databases ordered by time.
+func (p *Pool) Paths() []string {
+	series := p.seriesList()
+	paths := make([]string, 0, len(series))
+	for _, s := range series {
+		paths = append(paths, p.pathFromSeries(s))
+	}
+	return paths
+}
+
+// Pool returns the underlying boltdbpool.Pool, for layers that operate on
+// database paths, like stores built on top of the pool.
+func (p *Pool) Pool() *boltdbpool.Pool {
+	return p.pool
+}
+
+// Backup writes a consistent copy of the database on path to w.
+func (p *Pool) Backup(path string, w io.Writer) (int64, error) {
+	return p.pool.Backup(path, w)
+}
+
 // Close closes underlying boltdbpool.Pool.
 func (p *Pool) Close() {
 	p.pool.Close()
 }
 
+// CloseAll closes underlying boltdbpool.Pool and returns errors from
+// closing its databases.
+func (p *Pool) CloseAll() error {
+	return p.pool.CloseAll()
+}
+
 // Connection represents a boltdbpool.Connection for a particular
 // time partition.
 type Connection struct {
@@ -276,6 +569,11 @@ type Connection struct {
 	series string
 }
 
+var (
+	_ boltdbpool.Interface = (*Pool)(nil)
+	_ boltdbpool.Conn      = (*Connection)(nil)
+)
+
 // Next returns a connection that holds newer data relative to the
 // data partition of the current connection.
 func (c *Connection) Next() (*Connection, error) {
@@ -6,10 +6,15 @@
 package timed
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sync"
 	"testing"
 	"time"
+
+	"resenje.org/boltdbpool"
 )
 
 func TestUnknownPeriod(t *testing.T) {
@@ -486,3 +491,366 @@ func TestYearlyPeriod(t *testing.T) {
 		}
 	})
 }
+
+func TestWeeklyPeriod(t *testing.T) {
+	dir := t.TempDir()
+
+	times := map[string]time.Time{
+		"2020W01": time.Date(2019, 12, 30, 0, 0, 0, 0, time.Local),
+		"2020W53": time.Date(2020, 12, 31, 12, 0, 0, 0, time.Local),
+		"2021W01": time.Date(2021, 1, 10, 23, 59, 0, 0, time.Local),
+	}
+	setupPool, err := New(dir, Weekly, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for series, tm := range times {
+		c, err := setupPool.NewConnection(tm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+		if _, err := os.Stat(filepath.Join(dir, series+".db")); err != nil {
+			t.Error(err)
+		}
+	}
+	setupPool.Close()
+
+	pool, err := New(dir, Weekly, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	want := []string{
+		filepath.Join(dir, "2020W01.db"),
+		filepath.Join(dir, "2020W53.db"),
+		filepath.Join(dir, "2021W01.db"),
+	}
+	if got := pool.Paths(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got paths %v, expected %v", got, want)
+	}
+
+	c, err := pool.GetConnection(times["2020W53"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	next, err := c.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer next.Close()
+	if next.series != "2021W01" {
+		t.Errorf("got next series %s, expected 2021W01", next.series)
+	}
+	prev, err := c.Prev()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prev.Close()
+	if prev.series != "2020W01" {
+		t.Errorf("got previous series %s, expected 2020W01", prev.series)
+	}
+
+	for series, tm := range times {
+		start, end, _ := pool.periodBounds(tm)
+		if start.Weekday() != time.Monday || end.Sub(start) < 6*24*time.Hour || tm.Before(start) || !tm.Before(end) {
+			t.Errorf("%s: got bounds %s, %s", series, start, end)
+		}
+		got, err := pool.timeFromSeries(series)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(start) {
+			t.Errorf("%s: got time %s, expected %s", series, got, start)
+		}
+	}
+	for _, series := range []string{"2021W53", "2021W00", "2021-01", "2021W1"} {
+		if _, err := pool.timeFromSeries(series); err == nil {
+			t.Errorf("%s: parsed invalid series", series)
+		}
+	}
+
+	daily, err := New(t.TempDir(), Daily, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer daily.Close()
+	if _, err := NewPipeline(Stage{Pool: daily}, Stage{Pool: pool}); err != nil {
+		t.Error(err)
+	}
+	if _, err := NewPipeline(Stage{Pool: pool}, Stage{Pool: daily}); err == nil {
+		t.Error("weekly stage is followed by a daily stage")
+	}
+}
+
+func TestMinutesPeriod(t *testing.T) {
+	dir := t.TempDir()
+
+	setupPool, err := New(dir, Minutes(15), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tm := range []time.Time{
+		time.Date(2020, 1, 2, 3, 14, 59, 0, time.Local),
+		time.Date(2020, 1, 2, 3, 15, 0, 0, time.Local),
+		time.Date(2020, 1, 2, 3, 29, 0, 0, time.Local),
+		time.Date(2020, 1, 2, 3, 59, 0, 0, time.Local),
+	} {
+		c, err := setupPool.NewConnection(tm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+	}
+	setupPool.Close()
+
+	pool, err := New(dir, Minutes(15), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	want := []string{
+		filepath.Join(dir, "202001", "2020010203", "202001020300.db"),
+		filepath.Join(dir, "202001", "2020010203", "202001020315.db"),
+		filepath.Join(dir, "202001", "2020010203", "202001020345.db"),
+	}
+	if got := pool.Paths(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got paths %v, expected %v", got, want)
+	}
+
+	tm := time.Date(2020, 1, 2, 3, 20, 0, 0, time.Local)
+	c, err := pool.GetConnection(tm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	next, err := c.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer next.Close()
+	if next.series != "202001020345" {
+		t.Errorf("got next series %s, expected 202001020345", next.series)
+	}
+
+	start, end, _ := pool.periodBounds(tm)
+	if want := time.Date(2020, 1, 2, 3, 15, 0, 0, time.Local); !start.Equal(want) || !end.Equal(want.Add(15*time.Minute)) {
+		t.Errorf("got bounds %s, %s", start, end)
+	}
+	if got, err := pool.timeFromSeries("202001020315"); err != nil || !got.Equal(start) {
+		t.Errorf("got time %s (%v), expected %s", got, err, start)
+	}
+
+	for _, period := range []Period{Minutes(0), Minutes(7), Minutes(60)} {
+		if _, err := New(t.TempDir(), period, nil); err != ErrUnknownPeriod {
+			t.Errorf("%d: got error %v, expected %v", int(period), err, ErrUnknownPeriod)
+		}
+	}
+
+	minutely, err := New(t.TempDir(), Minutely, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer minutely.Close()
+	hourly, err := New(t.TempDir(), Hourly, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hourly.Close()
+	if _, err := NewPipeline(Stage{Pool: minutely}, Stage{Pool: pool}, Stage{Pool: hourly}); err != nil {
+		t.Error(err)
+	}
+	if _, err := NewPipeline(Stage{Pool: pool}, Stage{Pool: minutely}); err == nil {
+		t.Error("15 minutes stage is followed by a minutely stage")
+	}
+}
+
+func TestPoolInterface(t *testing.T) {
+	dir := t.TempDir()
+	pool, err := New(dir, Daily, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	now := time.Now()
+	c, err := pool.NewConnection(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var i boltdbpool.Interface = pool
+	path := pool.pathFromSeries(pool.seriesFromTime(now))
+	if !i.Has(path) {
+		t.Errorf("database %s not in pool", path)
+	}
+	var buf bytes.Buffer
+	n, err := i.Backup(path, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n == 0 || n != int64(buf.Len()) {
+		t.Errorf("got %d bytes written, buffer has %d", n, buf.Len())
+	}
+}
+
+func BenchmarkGetConnection(b *testing.B) {
+	pool, err := New(b.TempDir(), Hourly, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer pool.Close()
+
+	now := time.Now()
+	c, err := pool.NewConnection(now)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer c.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c, err := pool.GetConnection(now)
+		if err != nil {
+			b.Fatal(err)
+		}
+		c.Close()
+	}
+}
+
+func TestSeriesCache(t *testing.T) {
+	for _, period := range []Period{Minutely, Minutes(15), Hourly, Daily, Weekly, Monthly, Yearly} {
+		pool, err := New(t.TempDir(), period, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := time.Date(2019, 12, 31, 22, 59, 0, 0, time.UTC)
+		for i := 0; i < 60*26; i++ {
+			tm := start.Add(time.Duration(i) * time.Minute)
+			series, path := pool.seriesAndPath(tm)
+			if want := pool.seriesFromTime(tm); series != want {
+				t.Fatalf("period %v: time %s: got series %s, expected %s", period, tm, series, want)
+			}
+			if want := pool.pathFromSeries(series); path != want {
+				t.Fatalf("period %v: time %s: got path %s, expected %s", period, tm, path, want)
+			}
+		}
+		pool.Close()
+	}
+}
+
+func TestPath(t *testing.T) {
+	dir := t.TempDir()
+	pool, err := New(dir, Daily, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	tm := time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+	if got, want := pool.Path(tm), filepath.Join(dir, "202003", "20200304.db"); got != want {
+		t.Errorf("got path %s, expected %s", got, want)
+	}
+	c, err := pool.NewConnection(tm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if !pool.Pool().Has(pool.Path(tm)) {
+		t.Error("database is not in the underlying pool")
+	}
+}
+
+func TestPaths(t *testing.T) {
+	dir := t.TempDir()
+	pool, err := New(dir, Monthly, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []time.Month{3, 1, 2} {
+		c, err := pool.NewConnection(time.Date(2020, m, 1, 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+	}
+	pool.Close()
+
+	pool, err = New(dir, Monthly, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	want := []string{
+		filepath.Join(dir, "202001.db"),
+		filepath.Join(dir, "202002.db"),
+		filepath.Join(dir, "202003.db"),
+	}
+	if got := pool.Paths(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got paths %v, expected %v", got, want)
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	for _, p := range []Period{Minutely, Minutes(5), Minutes(30), Hourly, Daily, Weekly, Monthly, Yearly} {
+		got, err := ParsePeriod(p.String())
+		if err != nil || got != p {
+			t.Errorf("got period %v (%v), expected %v", got, err, p)
+		}
+	}
+	if p, err := ParsePeriod("1m"); err != ErrUnknownPeriod {
+		t.Errorf("got period %v (%v), expected %v", p, err, ErrUnknownPeriod)
+	}
+	for _, name := range []string{"fortnightly", "7m", "60m", "05m", "m"} {
+		if _, err := ParsePeriod(name); err != ErrUnknownPeriod {
+			t.Errorf("%s: got error %v, expected %v", name, err, ErrUnknownPeriod)
+		}
+	}
+}
+
+type countingFS struct {
+	boltdbpool.OSFS
+	mu   sync.Mutex
+	dirs int
+}
+
+func (fs *countingFS) ReadDir(name string) ([]os.DirEntry, error) {
+	fs.mu.Lock()
+	fs.dirs++
+	fs.mu.Unlock()
+	return fs.OSFS.ReadDir(name)
+}
+
+func TestFS(t *testing.T) {
+	dir := t.TempDir()
+	pool, err := New(dir, Daily, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := pool.NewConnection(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	pool.Close()
+
+	fs := &countingFS{}
+	pool, err = New(dir, Daily, &boltdbpool.Options{FS: fs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	if fs.dirs == 0 {
+		t.Error("databases are not listed on the filesystem")
+	}
+	want := []string{filepath.Join(dir, "202001", "20200101.db")}
+	if got := pool.Paths(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got paths %v, expected %v", got, want)
+	}
+}
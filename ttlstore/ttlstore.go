@@ -0,0 +1,346 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package ttlstore provides a key-value store with expiring values in
+// databases managed by boltdbpool.Pool.
+package ttlstore // import "resenje.org/boltdbpool/ttlstore"
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"resenje.org/boltdbpool"
+)
+
+// ErrInvalidValue is returned when a stored value is too short to contain
+// the expiration time.
+var ErrInvalidValue = errors.New("invalid value")
+
+// DefaultBucket is the name of the bucket that holds values if
+// Options.Bucket is not set.
+const DefaultBucket = "ttl"
+
+// Options configure the Store.
+type Options struct {
+	// Bucket is the name of the bucket that holds values. Expiration index
+	// is kept in a bucket with the same name and the ".expires" suffix.
+	Bucket string
+	// SweepInterval is the duration between two removals of expired values
+	// from every database that the store has written to. If the value is 0
+	// (default), expired values are removed only by Store.Sweep. Expired
+	// values are never returned by Store.Get.
+	SweepInterval time.Duration
+	// Now returns the current time. If nil, time.Now is used.
+	Now func() time.Time
+	// ErrorHandler receives errors from the background sweeper. If nil,
+	// boltdbpool.DefaultErrorHandler is used.
+	ErrorHandler func(error)
+}
+
+// Store keeps values with expiration times.
+type Store struct {
+	pool         *boltdbpool.Pool
+	bucket       []byte
+	expiryBucket []byte
+	now          func() time.Time
+	errorHandler func(error)
+
+	paths map[string]struct{}
+	mu    sync.Mutex
+	quit  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+}
+
+// New returns a new Store that keeps values in databases from the pool
+// and starts the background sweeper if Options.SweepInterval is set.
+func New(pool *boltdbpool.Pool, options *Options) *Store {
+	if options == nil {
+		options = &Options{}
+	}
+	bucket := options.Bucket
+	if bucket == "" {
+		bucket = DefaultBucket
+	}
+	s := &Store{
+		pool:         pool,
+		bucket:       []byte(bucket),
+		expiryBucket: []byte(bucket + ".expires"),
+		now:          options.Now,
+		errorHandler: options.ErrorHandler,
+		paths:        map[string]struct{}{},
+		quit:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	if s.now == nil {
+		s.now = time.Now
+	}
+	if s.errorHandler == nil {
+		s.errorHandler = boltdbpool.DefaultErrorHandler
+	}
+	if options.SweepInterval > 0 {
+		go s.sweep(options.SweepInterval)
+	} else {
+		close(s.done)
+	}
+	return s
+}
+
+// Put stores the value under the key in the database on path. The value
+// expires after ttl. If ttl is not positive, the value never expires.
+func (s *Store) Put(path string, key, value []byte, ttl time.Duration) error {
+	v, err := s.pool.EncodeValue(s.bucket, key, value)
+	if err != nil {
+		return err
+	}
+	var expires int64
+	if ttl > 0 {
+		expires = s.now().Add(ttl).UnixNano()
+	}
+
+	c, err := s.pool.Get(path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	s.mu.Lock()
+	s.paths[path] = struct{}{}
+	s.mu.Unlock()
+
+	return c.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(s.bucket)
+		if err != nil {
+			return err
+		}
+		eb, err := tx.CreateBucketIfNotExists(s.expiryBucket)
+		if err != nil {
+			return err
+		}
+		if err := deleteExpiry(b, eb, key); err != nil {
+			return err
+		}
+		if expires > 0 {
+			if err := eb.Put(expiryKey(expires, key), nil); err != nil {
+				return err
+			}
+		}
+		data := make([]byte, 8+len(v))
+		binary.BigEndian.PutUint64(data, uint64(expires))
+		copy(data[8:], v)
+		return b.Put(key, data)
+	})
+}
+
+// Get returns the value stored under the key in the database on path, or
+// nil if the value does not exist or it has expired.
+func (s *Store) Get(path string, key []byte) (value []byte, err error) {
+	c, err := s.pool.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	now := s.now().UnixNano()
+	err = c.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		if b == nil {
+			return nil
+		}
+		data := b.Get(key)
+		if data == nil {
+			return nil
+		}
+		if len(data) < 8 {
+			return ErrInvalidValue
+		}
+		if expires := int64(binary.BigEndian.Uint64(data)); expires > 0 && expires <= now {
+			return nil
+		}
+		v, err := s.pool.DecodeValue(s.bucket, key, data[8:])
+		if err != nil {
+			return err
+		}
+		value = append([]byte{}, v...)
+		return nil
+	})
+	return value, err
+}
+
+// TTL returns the remaining time until the value expires. It returns 0 if
+// the value does not exist or it has expired and a negative duration if
+// the value never expires.
+func (s *Store) TTL(path string, key []byte) (ttl time.Duration, err error) {
+	c, err := s.pool.Get(path)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+
+	now := s.now()
+	err = c.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		if b == nil {
+			return nil
+		}
+		data := b.Get(key)
+		if data == nil {
+			return nil
+		}
+		if len(data) < 8 {
+			return ErrInvalidValue
+		}
+		expires := int64(binary.BigEndian.Uint64(data))
+		if expires == 0 {
+			ttl = -1
+			return nil
+		}
+		if d := time.Unix(0, expires).Sub(now); d > 0 {
+			ttl = d
+		}
+		return nil
+	})
+	return ttl, err
+}
+
+// Delete removes the value stored under the key in the database on path.
+func (s *Store) Delete(path string, key []byte) error {
+	c, err := s.pool.Get(path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		if b == nil {
+			return nil
+		}
+		eb := tx.Bucket(s.expiryBucket)
+		if eb != nil {
+			if err := deleteExpiry(b, eb, key); err != nil {
+				return err
+			}
+		}
+		return b.Delete(key)
+	})
+}
+
+// Sweep removes expired values from the database on path and returns the
+// number of removed values.
+func (s *Store) Sweep(path string) (n int, err error) {
+	c, err := s.pool.Get(path)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+
+	now := uint64(s.now().UnixNano())
+	err = c.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		eb := tx.Bucket(s.expiryBucket)
+		if b == nil || eb == nil {
+			return nil
+		}
+		var keys [][]byte
+		cur := eb.Cursor()
+		for k, _ := cur.First(); k != nil && binary.BigEndian.Uint64(k) <= now; k, _ = cur.Next() {
+			keys = append(keys, append([]byte{}, k...))
+		}
+		for _, k := range keys {
+			if err := eb.Delete(k); err != nil {
+				return err
+			}
+			key := k[8:]
+			// the value may be stored again with a different expiration
+			data := b.Get(key)
+			if len(data) >= 8 && bytes.Equal(data[:8], k[:8]) {
+				if err := b.Delete(key); err != nil {
+					return err
+				}
+				n++
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+// Paths returns sorted paths of databases that the store has written to
+// and that are swept by the background sweeper.
+func (s *Store) Paths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	paths := make([]string, 0, len(s.paths))
+	for path := range s.paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Close stops the background sweeper. It does not close the pool.
+func (s *Store) Close() {
+	s.once.Do(func() {
+		close(s.quit)
+	})
+	<-s.done
+}
+
+func (s *Store) sweep(interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.quit:
+			return
+		}
+		for _, path := range s.Paths() {
+			select {
+			case <-s.quit:
+				return
+			default:
+			}
+			if _, err := s.Sweep(path); err != nil {
+				s.errorHandler(fmt.Errorf("ttlstore: sweep %s: %w", path, err))
+			}
+		}
+	}
+}
+
+// deleteExpiry removes the expiration index entry of the value stored
+// under the key.
+func deleteExpiry(b, eb *bolt.Bucket, key []byte) error {
+	data := b.Get(key)
+	if len(data) < 8 {
+		return nil
+	}
+	expires := int64(binary.BigEndian.Uint64(data))
+	if expires == 0 {
+		return nil
+	}
+	return eb.Delete(expiryKey(expires, key))
+}
+
+// expiryKey returns the expiration index key which orders keys by their
+// expiration time.
+func expiryKey(expires int64, key []byte) []byte {
+	k := make([]byte, 8+len(key))
+	binary.BigEndian.PutUint64(k, uint64(expires))
+	copy(k[8:], key)
+	return k
+}
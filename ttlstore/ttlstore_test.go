@@ -0,0 +1,152 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ttlstore
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"resenje.org/boltdbpool"
+)
+
+func TestStore(t *testing.T) {
+	pool := boltdbpool.New(nil)
+	defer pool.Close()
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := New(pool, &Options{
+		Now: func() time.Time { return now },
+	})
+	defer s.Close()
+
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	if err := s.Put(path, []byte("short"), []byte("1"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(path, []byte("long"), []byte("2"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(path, []byte("forever"), []byte("3"), 0); err != nil {
+		t.Fatal(err)
+	}
+	assertGet(t, s, path, "short", "1")
+
+	if ttl, err := s.TTL(path, []byte("long")); err != nil || ttl != time.Hour {
+		t.Errorf("got ttl %v (%v), expected %v", ttl, err, time.Hour)
+	}
+	if ttl, err := s.TTL(path, []byte("forever")); err != nil || ttl >= 0 {
+		t.Errorf("got ttl %v (%v), expected negative", ttl, err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	assertGet(t, s, path, "short", "")
+	assertGet(t, s, path, "long", "2")
+
+	// extend the expiration of the long value
+	if err := s.Put(path, []byte("long"), []byte("2"), 2*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(time.Hour)
+	n, err := s.Sweep(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("swept %d values, expected 1", n)
+	}
+	assertGet(t, s, path, "long", "2")
+	assertGet(t, s, path, "forever", "3")
+
+	if err := s.Delete(path, []byte("long")); err != nil {
+		t.Fatal(err)
+	}
+	assertGet(t, s, path, "long", "")
+
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.View(func(tx *bolt.Tx) error {
+		if n := tx.Bucket([]byte(DefaultBucket)).Stats().KeyN; n != 1 {
+			t.Errorf("got %d values, expected 1", n)
+		}
+		if n := tx.Bucket([]byte(DefaultBucket + ".expires")).Stats().KeyN; n != 0 {
+			t.Errorf("got %d expiration index entries, expected 0", n)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreSweeper(t *testing.T) {
+	pool := boltdbpool.New(nil)
+	defer pool.Close()
+
+	var mu sync.Mutex
+	now := time.Now()
+	s := New(pool, &Options{
+		SweepInterval: 10 * time.Millisecond,
+		Now: func() time.Time {
+			mu.Lock()
+			defer mu.Unlock()
+			return now
+		},
+		ErrorHandler: func(err error) {
+			t.Error(err)
+		},
+	})
+	defer s.Close()
+
+	path := filepath.Join(t.TempDir(), "cache.db")
+	if err := s.Put(path, []byte("key"), []byte("value"), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	now = now.Add(time.Minute)
+	mu.Unlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c, err := pool.Get(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n int
+		if err := c.View(func(tx *bolt.Tx) error {
+			n = tx.Bucket([]byte(DefaultBucket)).Stats().KeyN
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expired value not swept")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func assertGet(t *testing.T, s *Store, path, key, want string) {
+	t.Helper()
+
+	v, err := s.Get(path, []byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(v) != want {
+		t.Errorf("key %s: got value %q, expected %q", key, v, want)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// View executes a function within a managed read-only transaction on the
+// connection database.
+func (c *Connection) View(fn func(*bolt.Tx) error) error {
+	c.countView()
+	defer c.pool.observe(time.Now())
+
+	return c.DB.View(fn)
+}
+
+// Update executes a function within a managed read-write transaction on the
+// connection database.
+func (c *Connection) Update(fn func(*bolt.Tx) error) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	if err := c.checkRateLimit(); err != nil {
+		return err
+	}
+	c.countUpdate()
+	defer c.pool.observe(time.Now())
+
+	return c.DB.Update(func(tx *bolt.Tx) error {
+		c.countWrite(tx)
+		return fn(tx)
+	})
+}
+
+// Batch calls a function as a part of a batch on the connection database.
+func (c *Connection) Batch(fn func(*bolt.Tx) error) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	if err := c.checkRateLimit(); err != nil {
+		return err
+	}
+	c.countUpdate()
+	defer c.pool.observe(time.Now())
+
+	return c.DB.Batch(func(tx *bolt.Tx) error {
+		c.countWrite(tx)
+		return fn(tx)
+	})
+}
+
+// With gets a connection to the database on path, calls fn with its
+// database and releases the connection when fn returns or panics. The
+// database must not be used after fn returns.
+func (p *Pool) With(path string, fn func(db *bolt.DB) error) error {
+	c, err := p.Get(path)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return fn(c.DB)
+}
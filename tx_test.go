@@ -0,0 +1,67 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestWith(t *testing.T) {
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+	})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	references := func() int64 {
+		t.Helper()
+
+		s := pool.Stats()
+		if len(s.Databases) != 1 {
+			t.Fatalf("got %d databases", len(s.Databases))
+		}
+		return s.Databases[0].References
+	}
+
+	if err := pool.With(path, func(db *bolt.DB) error {
+		if n := references(); n != 1 {
+			t.Errorf("got %d references", n)
+		}
+		return db.Update(func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucket([]byte("bucket"))
+			return err
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if n := references(); n != 0 {
+		t.Errorf("got %d references after With", n)
+	}
+
+	errTest := errors.New("test")
+	if err := pool.With(path, func(*bolt.DB) error { return errTest }); err != errTest {
+		t.Errorf("got error %v, expected %v", err, errTest)
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != "test panic" {
+				t.Errorf("got panic %v", r)
+			}
+		}()
+		_ = pool.With(path, func(*bolt.DB) error {
+			panic("test panic")
+		})
+	}()
+	if n := references(); n != 0 {
+		t.Errorf("got %d references after panic", n)
+	}
+}
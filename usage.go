@@ -0,0 +1,86 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Usage holds counters of connection use since its database is opened in
+// the pool.
+type Usage struct {
+	// Gets is the number of times the connection is returned by the pool.
+	Gets int64 `json:"gets"`
+	// Views is the number of View calls, including ones from pool helpers.
+	Views int64 `json:"views"`
+	// Updates is the number of Update and Batch calls, including ones from
+	// pool helpers.
+	Updates int64 `json:"updates"`
+	// BytesWritten is an estimate of bytes written by committed Update and
+	// Batch transactions, based on the size of pages that they allocated.
+	BytesWritten int64 `json:"bytesWritten"`
+	// LastUsed is the time of the last Get, View, Update or Batch call.
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+type connectionUsage struct {
+	Usage
+	// tx is the last write transaction whose size is counted, so that
+	// functions batched in the same transaction are counted once
+	tx *bolt.Tx
+	mu sync.Mutex
+}
+
+// Usage returns counters of the connection use.
+func (c *Connection) Usage() Usage {
+	c.usage.mu.Lock()
+	defer c.usage.mu.Unlock()
+
+	return c.usage.Usage
+}
+
+func (c *Connection) countGet() {
+	c.usage.mu.Lock()
+	c.usage.Gets++
+	c.usage.LastUsed = c.pool.now()
+	c.usage.mu.Unlock()
+}
+
+func (c *Connection) countView() {
+	c.usage.mu.Lock()
+	c.usage.Views++
+	c.usage.LastUsed = c.pool.now()
+	c.usage.mu.Unlock()
+}
+
+func (c *Connection) countUpdate() {
+	c.usage.mu.Lock()
+	c.usage.Updates++
+	c.usage.LastUsed = c.pool.now()
+	c.usage.mu.Unlock()
+}
+
+// countWrite adds the size of pages allocated by the write transaction to
+// the bytes written when it is committed.
+func (c *Connection) countWrite(tx *bolt.Tx) {
+	c.usage.mu.Lock()
+	defer c.usage.mu.Unlock()
+
+	if c.usage.tx == tx {
+		return
+	}
+	c.usage.tx = tx
+	tx.OnCommit(func() {
+		s := tx.Stats()
+		c.usage.mu.Lock()
+		c.usage.BytesWritten += s.GetPageAlloc()
+		c.usage.tx = nil
+		c.usage.mu.Unlock()
+	})
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestUsage(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	pool := New(&Options{
+		ConnectionExpires: time.Minute,
+		Now:               func() time.Time { return now },
+	})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if u := c.Usage(); u.Gets != 1 || !u.LastUsed.Equal(now) {
+		t.Errorf("got usage %+v", u)
+	}
+
+	now = now.Add(time.Second)
+	c2, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2.Close()
+
+	if err := c.PutValue([]byte("bucket"), []byte("key"), make([]byte, 10000)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetValue([]byte("bucket"), []byte("key")); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			if err := c.Batch(func(tx *bolt.Tx) error {
+				return tx.Bucket([]byte("bucket")).Put([]byte(fmt.Sprint(i)), []byte("value"))
+			}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := c.Update(func(*bolt.Tx) error { return fmt.Errorf("rollback") }); err == nil {
+		t.Fatal("expected error")
+	}
+
+	u := c.Usage()
+	if u.Gets != 2 || u.Views != 1 || u.Updates != 12 {
+		t.Errorf("got usage %+v", u)
+	}
+	if !u.LastUsed.Equal(now) {
+		t.Errorf("got last used %v, expected %v", u.LastUsed, now)
+	}
+	if u.BytesWritten < 10000 {
+		t.Errorf("got bytes written %v, expected at least the value size", u.BytesWritten)
+	}
+
+	if s := pool.Stats(); len(s.Databases) != 1 || s.Databases[0].Usage != u {
+		t.Errorf("got stats %+v, expected usage %+v", s.Databases, u)
+	}
+}
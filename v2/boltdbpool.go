@@ -0,0 +1,398 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package boltdbpool implements a pool container for BoltDB go.etcd.io/bbolt databases.
+
+This is the second major version of resenje.org/boltdbpool. Compared to the
+first version:
+
+  - Pool and Connection are interfaces, so that wrappers for metrics,
+    tracing or testing can be used in place of the default implementation.
+  - Get accepts a context that bounds the time spent opening the database.
+  - Close methods return errors instead of passing them only to the
+    ErrorHandler.
+  - Every opening of a database file gets a new generation number, so that
+    callers can detect that the database was reopened.
+  - Errors are typed and can be inspected with errors.Is and errors.As.
+
+Existing v1 callers can migrate incrementally with NewV1 that wraps a v2
+pool in the v1 API.
+
+Example:
+
+    pool, err := boltdbpool.New(&boltdbpool.Options{
+        ConnectionExpires: 5 * time.Second,
+    })
+    if err != nil {
+        panic(err)
+    }
+    defer pool.Close()
+
+    c, err := pool.Get(ctx, "/tmp/db.bolt")
+    if err != nil {
+        panic(err)
+    }
+    defer c.Close()
+
+    c.DB().Update(func(tx *bolt.Tx) error {
+        ...
+    })
+*/
+package boltdbpool // import "resenje.org/boltdbpool/v2"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// ErrClosed is returned when the pool is used after it is closed.
+	ErrClosed = errors.New("boltdbpool: pool closed")
+	// ErrNegativeExpires is returned by New if Options.ConnectionExpires
+	// is negative.
+	ErrNegativeExpires = errors.New("boltdbpool: negative connection expires")
+)
+
+// DefaultErrorHandler is the default function that prints errors from the
+// background closing of databases.
+var DefaultErrorHandler = func(err error) {
+	log.Printf("error: %v", err)
+}
+
+// OpenError is returned when a database can not be opened.
+type OpenError struct {
+	Path string
+	Err  error
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("boltdbpool: open %s: %v", e.Path, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *OpenError) Unwrap() error {
+	return e.Err
+}
+
+// CloseError is returned when a database can not be closed.
+type CloseError struct {
+	Path string
+	Err  error
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("boltdbpool: close %s: %v", e.Path, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *CloseError) Unwrap() error {
+	return e.Err
+}
+
+// Errors holds multiple errors that occurred during a single operation.
+type Errors []error
+
+func (e Errors) Error() string {
+	s := make([]string, 0, len(e))
+	for _, err := range e {
+		s = append(s, err.Error())
+	}
+	return strings.Join(s, "; ")
+}
+
+// Is reports whether any of the errors matches the target.
+func (e Errors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Pool keeps track of database connections.
+type Pool interface {
+	// Get returns a connection to the database on path, opening the
+	// database if it is not already open. The context bounds waiting for
+	// the database to be opened. Databases on different paths are opened
+	// concurrently and Get calls for the same path share a single open.
+	Get(ctx context.Context, path string) (Connection, error)
+	// Has returns true if a database with a file path is in the pool.
+	Has(path string) bool
+	// Close closes all databases. After the execution pool is not usable.
+	Close() error
+}
+
+// Connection is a reference to a pooled database.
+type Connection interface {
+	// DB returns the underlying bolt database.
+	DB() *bolt.DB
+	// Path returns the database file path.
+	Path() string
+	// Generation returns a number that is different every time the
+	// database file is opened by the pool.
+	Generation() uint64
+	// Close releases the reference to the database and closes it if needed.
+	Close() error
+}
+
+// Options are used when a new pool is created.
+type Options struct {
+	// BoltOptions is used on bolt.Open().
+	BoltOptions *bolt.Options
+
+	// ConnectionExpires is a duration between the reference count drops to 0
+	// and the time when the database is closed. If the value is 0 (default),
+	// no caching is done.
+	ConnectionExpires time.Duration
+
+	// ErrorHandler is the function that handles errors from closing
+	// databases in the background.
+	ErrorHandler func(error)
+}
+
+type pool struct {
+	options       Options
+	connections   map[string]*connection
+	opening       map[string]*opening
+	generation    uint64
+	closed        bool
+	mu            sync.Mutex
+	removeTrigger chan struct{}
+	quit          chan struct{}
+}
+
+// New creates a new pool with provided options and starts the database
+// closing goroutine.
+func New(options *Options) (Pool, error) {
+	p := &pool{
+		connections:   map[string]*connection{},
+		opening:       map[string]*opening{},
+		removeTrigger: make(chan struct{}, 1),
+		quit:          make(chan struct{}),
+	}
+	if options != nil {
+		p.options = *options
+	}
+	if p.options.ConnectionExpires < 0 {
+		return nil, ErrNegativeExpires
+	}
+	if p.options.ErrorHandler == nil {
+		p.options.ErrorHandler = DefaultErrorHandler
+	}
+	go p.expire()
+	return p, nil
+}
+
+func (p *pool) expire() {
+	for {
+		select {
+		case <-p.removeTrigger:
+			select {
+			case <-time.After(p.options.ConnectionExpires):
+			case <-p.quit:
+				return
+			}
+			p.mu.Lock()
+			for _, c := range p.connections {
+				if c.count == 0 && !c.closeTime.IsZero() && c.closeTime.Before(time.Now()) {
+					if err := p.remove(c); err != nil {
+						p.options.ErrorHandler(err)
+					}
+				}
+			}
+			p.mu.Unlock()
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+func (p *pool) Get(ctx context.Context, path string) (Connection, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if c, ok := p.connections[path]; ok {
+		c.count++
+		c.closeTime = time.Time{}
+		p.mu.Unlock()
+		return c, nil
+	}
+	o, ok := p.opening[path]
+	if !ok {
+		o = &opening{done: make(chan struct{})}
+		p.opening[path] = o
+		go p.open(path, o)
+	}
+	o.waiters++
+	p.mu.Unlock()
+
+	select {
+	case <-o.done:
+		if o.err != nil {
+			return nil, o.err
+		}
+		return o.c, nil
+	case <-ctx.Done():
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-o.done:
+		// the database is opened with a reference for this call
+		if o.c != nil {
+			if err := p.release(o.c); err != nil {
+				p.options.ErrorHandler(err)
+			}
+		}
+	default:
+		o.waiters--
+	}
+	return nil, &OpenError{Path: path, Err: ctx.Err()}
+}
+
+// opening is an open of a database that Get calls wait for.
+type opening struct {
+	done    chan struct{}
+	waiters int64
+	c       *connection
+	err     error
+}
+
+// open opens the database outside of the pool lock and adds it to the pool
+// with a reference for every Get call that still waits for it.
+func (p *pool) open(path string, o *opening) {
+	db, err := open(path, p.options.BoltOptions)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	defer close(o.done)
+
+	delete(p.opening, path)
+	if err != nil {
+		o.err = &OpenError{Path: path, Err: err}
+		return
+	}
+	if p.closed || o.waiters == 0 {
+		if err := db.Close(); err != nil {
+			p.options.ErrorHandler(&CloseError{Path: path, Err: err})
+		}
+		o.err = ErrClosed
+		return
+	}
+	p.generation++
+	o.c = &connection{
+		db:         db,
+		path:       path,
+		generation: p.generation,
+		count:      o.waiters,
+		pool:       p,
+	}
+	p.connections[path] = o.c
+}
+
+func open(path string, options *bolt.Options) (*bolt.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return nil, err
+	}
+	return bolt.Open(path, 0666, options)
+}
+
+func (p *pool) Has(path string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, ok := p.connections[path]
+	return ok
+}
+
+func (p *pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	close(p.quit)
+
+	var errs Errors
+	for _, c := range p.connections {
+		if err := p.remove(c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// remove closes the database and removes it from the pool. Pool lock must
+// be held.
+func (p *pool) remove(c *connection) error {
+	delete(p.connections, c.path)
+	if err := c.db.Close(); err != nil {
+		return &CloseError{Path: c.path, Err: err}
+	}
+	return nil
+}
+
+type connection struct {
+	db         *bolt.DB
+	path       string
+	generation uint64
+	count      int64
+	closeTime  time.Time
+	pool       *pool
+}
+
+func (c *connection) DB() *bolt.DB       { return c.db }
+func (c *connection) Path() string       { return c.path }
+func (c *connection) Generation() uint64 { return c.generation }
+
+func (c *connection) Close() error {
+	p := c.pool
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.release(c)
+}
+
+// release removes a reference to the connection and closes its database
+// or schedules its expiration when no references are left. Pool lock must
+// be held.
+func (p *pool) release(c *connection) error {
+	if c.count == 0 {
+		return nil
+	}
+	c.count--
+	if c.count > 0 || p.closed {
+		return nil
+	}
+	if p.options.ConnectionExpires == 0 {
+		return p.remove(c)
+	}
+	c.closeTime = time.Now().Add(p.options.ConnectionExpires)
+	select {
+	case p.removeTrigger <- struct{}{}:
+	default:
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestPool(t *testing.T) {
+	pool, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "dir", "test.db")
+	c1, err := pool.Get(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := pool.Get(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c1.DB() != c2.DB() {
+		t.Error("connections do not share the database")
+	}
+	if c1.Path() != path {
+		t.Errorf("got path %q, expected %q", c1.Path(), path)
+	}
+	if err := c1.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !pool.Has(path) {
+		t.Error("database closed while referenced")
+	}
+	if err := c2.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if pool.Has(path) {
+		t.Error("database not closed")
+	}
+
+	c3, err := pool.Get(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c3.Generation() == c1.Generation() {
+		t.Error("reopened database has the same generation")
+	}
+
+	if err := pool.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Get(context.Background(), path); !errors.Is(err, ErrClosed) {
+		t.Errorf("got error %v, expected %v", err, ErrClosed)
+	}
+}
+
+func TestPoolOptions(t *testing.T) {
+	if _, err := New(&Options{ConnectionExpires: -1}); !errors.Is(err, ErrNegativeExpires) {
+		t.Errorf("got error %v, expected %v", err, ErrNegativeExpires)
+	}
+}
+
+func TestPoolExpires(t *testing.T) {
+	pool, err := New(&Options{ConnectionExpires: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.Get(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !pool.Has(path) {
+		t.Error("database closed before expiration")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if pool.Has(path) {
+		t.Error("database not closed after expiration")
+	}
+}
+
+func TestPoolOpenError(t *testing.T) {
+	pool, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	_, err = pool.Get(context.Background(), os.DevNull)
+	var oerr *OpenError
+	if !errors.As(err, &oerr) {
+		t.Fatalf("expected OpenError, got %v", err)
+	}
+	if oerr.Path != os.DevNull {
+		t.Errorf("got path %q, expected %q", oerr.Path, os.DevNull)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := pool.Get(ctx, filepath.Join(t.TempDir(), "test.db")); err != nil && !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, expected %v", err, context.Canceled)
+	}
+}
+
+func TestV1(t *testing.T) {
+	var handled error
+	pool := NewV1(&Options{
+		ErrorHandler: func(err error) {
+			handled = err
+		},
+	})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.DB.Path() != path {
+		t.Errorf("got path %q, expected %q", c.DB.Path(), path)
+	}
+	c.Close()
+	if handled != nil {
+		t.Errorf("unexpected error %v", handled)
+	}
+	if pool.Has(path) {
+		t.Error("database not closed")
+	}
+}
+
+func TestPoolConcurrentOpen(t *testing.T) {
+	dir := t.TempDir()
+	slowPath := filepath.Join(dir, "slow.db")
+
+	// an exclusive lock held on the file makes opening it wait for the
+	// timeout
+	db, err := bolt.Open(slowPath, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	pool, err := New(&Options{
+		BoltOptions: &bolt.Options{Timeout: 2 * time.Second},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	errc := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			_, err := pool.Get(ctx, slowPath)
+			errc <- err
+		}()
+	}
+
+	start := time.Now()
+	c, err := pool.Get(context.Background(), filepath.Join(dir, "fast.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	for i := 0; i < 2; i++ {
+		if err := <-errc; !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("got error %v, expected %v", err, context.DeadlineExceeded)
+		}
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("waited %s for opens of other databases", d)
+	}
+	if pool.Has(slowPath) {
+		t.Error("database opened without references")
+	}
+}
@@ -0,0 +1,7 @@
+module resenje.org/boltdbpool/v2
+
+go 1.17
+
+require go.etcd.io/bbolt v1.3.7
+
+require golang.org/x/sys v0.6.0 // indirect
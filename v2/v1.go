@@ -0,0 +1,84 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"context"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// V1Pool provides the API of the first major version of this package over
+// a v2 Pool. It allows callers to switch the import path first and to
+// migrate to the v2 API incrementally.
+type V1Pool struct {
+	Pool
+
+	errorHandler func(error)
+}
+
+// NewV1 returns a V1Pool with the same semantics as the New function of the
+// first version. Errors returned from Close methods are passed to the
+// ErrorHandler from options. As in the first version, options are not
+// validated.
+func NewV1(options *Options) *V1Pool {
+	var o Options
+	if options != nil {
+		o = *options
+	}
+	// v1 does not validate options
+	if o.ConnectionExpires < 0 {
+		o.ConnectionExpires = 0
+	}
+	if o.ErrorHandler == nil {
+		o.ErrorHandler = DefaultErrorHandler
+	}
+	p, _ := New(&o)
+	return &V1Pool{
+		Pool:         p,
+		errorHandler: o.ErrorHandler,
+	}
+}
+
+// Get returns a connection with the v1 API.
+func (p *V1Pool) Get(path string) (*V1Connection, error) {
+	c, err := p.Pool.Get(context.Background(), path)
+	if err != nil {
+		return nil, err
+	}
+	return &V1Connection{
+		DB:         c.DB(),
+		Connection: c,
+		pool:       p,
+	}, nil
+}
+
+// Close closes all databases and passes any error to the ErrorHandler.
+func (p *V1Pool) Close() {
+	p.handleError(p.Pool.Close())
+}
+
+func (p *V1Pool) handleError(err error) {
+	if err != nil {
+		p.errorHandler(err)
+	}
+}
+
+// V1Connection provides the API of the first major version Connection.
+type V1Connection struct {
+	DB *bolt.DB
+
+	// Connection is the v2 connection, for incremental migration.
+	Connection Connection
+
+	pool *V1Pool
+}
+
+// Close releases the reference to the database and passes any error to the
+// ErrorHandler.
+func (c *V1Connection) Close() {
+	c.pool.handleError(c.Connection.Close())
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	bolt "go.etcd.io/bbolt"
+)
+
+// EncodeValue transforms the value that is stored under the key in the
+// bucket according to the pool options, by compressing and encrypting it.
+// Store layers built on top of the pool should use it before putting values
+// into the database.
+func (p *Pool) EncodeValue(bucket, key, value []byte) (v []byte, err error) {
+	v = value
+	if c := p.options.Compression; c != nil {
+		if v, err = c.compress(v); err != nil {
+			return nil, err
+		}
+	}
+	if e := p.options.Encryption; e != nil {
+		if v, err = e.seal(bucket, key, v); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// DecodeValue reverses the transformation done by EncodeValue. The returned
+// value may reference the provided value, so it is valid only as long as
+// the provided value is.
+func (p *Pool) DecodeValue(bucket, key, value []byte) (v []byte, err error) {
+	v = value
+	if e := p.options.Encryption; e != nil {
+		if v, err = e.open(bucket, key, v); err != nil {
+			return nil, err
+		}
+	}
+	if c := p.options.Compression; c != nil {
+		if v, err = c.decompress(v); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// GetValue returns a copy of the decoded value stored under the key in the
+// bucket, or nil if the bucket or the key do not exist. Values are cached
+// if Options.CacheSize is set.
+func (c *Connection) GetValue(bucket, key []byte) (value []byte, err error) {
+	cache := c.pool.cache
+	var (
+		k       cacheKey
+		version uint64
+	)
+	if cache != nil {
+		k = cacheKey{path: c.path, bucket: string(bucket), key: string(key)}
+		v, ok, ver := cache.get(k)
+		if ok {
+			return append([]byte{}, v...), nil
+		}
+		version = ver
+	}
+	err = c.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+		if b == nil {
+			return nil
+		}
+		v := b.Get(key)
+		if v == nil {
+			return nil
+		}
+		v, err := c.pool.DecodeValue(bucket, key, v)
+		if err != nil {
+			return err
+		}
+		value = append([]byte{}, v...)
+		return nil
+	})
+	if err == nil && value != nil && cache != nil {
+		cache.add(k, append([]byte{}, value...), version)
+	}
+	return value, err
+}
+
+// PutValue encodes and stores the value under the key in the bucket,
+// creating the bucket if it does not exist.
+func (c *Connection) PutValue(bucket, key, value []byte) error {
+	v, err := c.pool.EncodeValue(bucket, key, value)
+	if err != nil {
+		return err
+	}
+	defer c.invalidateValue(bucket, key)
+
+	err = c.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(bucket)
+		if err != nil {
+			return err
+		}
+		if err := b.Put(key, v); err != nil {
+			return err
+		}
+		return c.pool.logChange(tx, ChangePut, bucket, key, v)
+	})
+	c.notifyChange(err, ChangePut, bucket, key, value)
+	return err
+}
+
+// DeleteValue removes the key from the bucket.
+func (c *Connection) DeleteValue(bucket, key []byte) error {
+	defer c.invalidateValue(bucket, key)
+
+	err := c.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+		if b == nil {
+			return nil
+		}
+		if err := b.Delete(key); err != nil {
+			return err
+		}
+		return c.pool.logChange(tx, ChangeDelete, bucket, key, nil)
+	})
+	c.notifyChange(err, ChangeDelete, bucket, key, nil)
+	return err
+}
+
+// InvalidateValue removes the value stored under the key in the bucket
+// from the cache. Store layers that write values directly in transactions
+// should call it after the transaction is done.
+func (c *Connection) InvalidateValue(bucket, key []byte) {
+	c.invalidateValue(bucket, key)
+}
+
+// invalidateValue removes the value from the cache. It is called after the
+// write transaction is done, so that the value read concurrently before the
+// commit is not cached.
+func (c *Connection) invalidateValue(bucket, key []byte) {
+	if c.pool.cache != nil {
+		c.pool.cache.invalidate(cacheKey{path: c.path, bucket: string(bucket), key: string(key)})
+	}
+}
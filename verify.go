@@ -0,0 +1,194 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// VerifyLevel defines how thoroughly a database is validated when it is
+// opened for the first time by the pool.
+type VerifyLevel int
+
+// Verification levels ordered by their cost.
+const (
+	// VerifyNone skips any validation.
+	VerifyNone VerifyLevel = iota
+	// VerifyHeader validates only the meta page header of the database file
+	// before it is opened.
+	VerifyHeader
+	// VerifyQuick validates the header and traverses the first key of every
+	// top-level bucket in a read transaction.
+	VerifyQuick
+	// VerifyFull validates the header and runs bolt's Tx.Check on the whole
+	// database.
+	VerifyFull
+)
+
+func (l VerifyLevel) String() string {
+	switch l {
+	case VerifyNone:
+		return "none"
+	case VerifyHeader:
+		return "header"
+	case VerifyQuick:
+		return "quick"
+	case VerifyFull:
+		return "full"
+	}
+	return fmt.Sprintf("VerifyLevel(%d)", int(l))
+}
+
+// ErrInvalidHeader is returned when the database file meta page does not
+// contain a valid bolt header.
+var ErrInvalidHeader = errors.New("boltdbpool: invalid database header")
+
+// VerifyError is returned by Pool.Get when a database fails the validation
+// configured by Options.VerifyOnOpen.
+type VerifyError struct {
+	Path  string
+	Level VerifyLevel
+	Err   error
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("boltdbpool: verify %s (%s): %v", e.Path, e.Level, e.Err)
+}
+
+// Unwrap returns the underlying validation error.
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
+
+const (
+	boltMagic        uint32 = 0xED0CDAED
+	boltVersion      uint32 = 2
+	boltMetaPageFlag uint16 = 0x04
+	// page header is id (8), flags (2), count (2) and overflow (4) bytes
+	// long and it is followed by meta magic and version.
+	boltPageHeaderSize = 16
+)
+
+// verifyLevel returns the verification level for the path if it has not
+// been verified by the pool already. Pool lock must be held.
+func (p *Pool) verifyLevel(path string) VerifyLevel {
+	if p.options.VerifyOnOpen == nil {
+		return VerifyNone
+	}
+	if _, ok := p.verified[path]; ok {
+		return VerifyNone
+	}
+	return p.options.VerifyOnOpen(path)
+}
+
+// verifyHeader checks the meta page of an existing database file. Files
+// that do not exist or are empty are considered valid as bolt will
+// initialize them.
+func verifyHeader(fs FS, path string) error {
+	f, err := fs.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	b := make([]byte, boltPageHeaderSize+8)
+	if _, err := io.ReadFull(f, b); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			return ErrInvalidHeader
+		}
+		return err
+	}
+	for _, o := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		if o.Uint16(b[8:])&boltMetaPageFlag == 0 {
+			continue
+		}
+		if o.Uint32(b[boltPageHeaderSize:]) != boltMagic {
+			continue
+		}
+		if o.Uint32(b[boltPageHeaderSize+4:]) != boltVersion {
+			return fmt.Errorf("%w: unsupported version", ErrInvalidHeader)
+		}
+		return nil
+	}
+	return ErrInvalidHeader
+}
+
+// verifyDB validates an opened database according to the level.
+func verifyDB(db *bolt.DB, level VerifyLevel) error {
+	switch level {
+	case VerifyQuick:
+		return db.View(func(tx *bolt.Tx) error {
+			return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+				b.Cursor().First()
+				return nil
+			})
+		})
+	case VerifyFull:
+		// bolt's Check panics in its own goroutine on some invalid pages,
+		// so all pages are read before it in a recoverable way
+		if err := walkDB(db); err != nil {
+			return err
+		}
+		// Check is executed in a writable transaction which is rolled back,
+		// as checking in a read-only transaction reports false errors on
+		// pages that are freed by concurrent writes. Databases opened in
+		// read-only mode do not have concurrent writes.
+		tx, err := db.Begin(!db.IsReadOnly())
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		// drain all errors so that the checking goroutine terminates, the
+		// first one is reported as corruption
+		for e := range tx.Check() {
+			if err == nil {
+				err = fmt.Errorf("%w: %v", ErrCorrupted, e)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// walkDB reads all keys and values in the database and returns ErrCorrupted
+// if reading panics.
+func walkDB(db *bolt.DB) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", ErrCorrupted, r)
+		}
+	}()
+
+	return db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(_ []byte, b *bolt.Bucket) error {
+			return walkBucket(b)
+		})
+	})
+}
+
+func walkBucket(b *bolt.Bucket) error {
+	return b.ForEach(func(k, v []byte) error {
+		if v == nil {
+			if nb := b.Bucket(k); nb != nil {
+				return walkBucket(nb)
+			}
+		}
+		return nil
+	})
+}
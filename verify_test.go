@@ -0,0 +1,65 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyOnOpen(t *testing.T) {
+	dir := t.TempDir()
+
+	calls := map[string]int{}
+	pool := New(&Options{
+		VerifyOnOpen: func(path string) VerifyLevel {
+			calls[path]++
+			switch filepath.Base(path) {
+			case "critical.db":
+				return VerifyFull
+			case "quick.db":
+				return VerifyQuick
+			}
+			return VerifyHeader
+		},
+	})
+	defer pool.Close()
+
+	for _, name := range []string{"critical.db", "quick.db", "tenant.db"} {
+		path := filepath.Join(dir, name)
+		for i := 0; i < 2; i++ {
+			c, err := pool.Get(path)
+			if err != nil {
+				t.Fatalf("get %s: %v", name, err)
+			}
+			c.Close()
+		}
+		if calls[path] != 1 {
+			t.Errorf("%s verified %d times, expected 1", name, calls[path])
+		}
+	}
+
+	path := filepath.Join(dir, "corrupt.db")
+	if err := os.WriteFile(path, make([]byte, 4096), 0666); err != nil {
+		t.Fatal(err)
+	}
+	_, err := pool.Get(path)
+	var verr *VerifyError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected VerifyError, got %v", err)
+	}
+	if !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("expected ErrInvalidHeader, got %v", err)
+	}
+	if verr.Level != VerifyHeader {
+		t.Errorf("expected level %s, got %s", VerifyHeader, verr.Level)
+	}
+	if pool.Has(path) {
+		t.Error("invalid database is in the pool")
+	}
+}
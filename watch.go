@@ -0,0 +1,186 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrFileChanged is returned by the WatchTask when a database file is
+// changed by something other than the pool.
+var ErrFileChanged = errors.New("boltdbpool: database file changed")
+
+// FileChange describes how a database file was changed.
+type FileChange int
+
+// Database file changes detected by the WatchTask.
+const (
+	// FileModified is reported when the file modification time or size
+	// changed without a transaction committed through the open database.
+	FileModified FileChange = iota + 1
+	// FileReplaced is reported when a different file is on the database
+	// path.
+	FileReplaced
+	// FileRemoved is reported when the database file does not exist.
+	FileRemoved
+)
+
+func (c FileChange) String() string {
+	switch c {
+	case FileModified:
+		return "modified"
+	case FileReplaced:
+		return "replaced"
+	case FileRemoved:
+		return "removed"
+	}
+	return fmt.Sprintf("FileChange(%d)", int(c))
+}
+
+// FileEvent is reported by the WatchTask for a database file that was
+// changed out from under the pool.
+type FileEvent struct {
+	Path   string
+	Change FileChange
+	// Evicted is true if the database was closed and removed from the pool.
+	Evicted bool
+}
+
+// watchState is the last observed state of a database file.
+type watchState struct {
+	db      *bolt.DB
+	size    int64
+	modTime time.Time
+	file    os.FileInfo
+	txid    int
+}
+
+// WatchTask returns a task that detects database files modified, replaced
+// or removed by other processes while they are open in the pool. Files are
+// polled on every maintenance interval, so only changes between two runs
+// are detected and modifications are not detected if the database was also
+// written to through the pool in the same interval. Changes are passed to
+// the function or, if it is nil, returned as ErrFileChanged errors to the
+// ErrorHandler. If evict is true, databases with no references are closed
+// and removed from the pool, so that the changed file is opened on the next
+// Get.
+func WatchTask(fn func(FileEvent), evict bool) MaintenanceTask {
+	var (
+		states = map[string]watchState{}
+		mu     sync.Mutex
+	)
+	return maintenanceTask{
+		name: "watch",
+		run: func(p *Pool, path string) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			c := p.acquire(path)
+			if c == nil {
+				delete(states, path)
+				return nil
+			}
+			state, err := fileState(c)
+			c.release()
+			if err != nil {
+				return err
+			}
+
+			last, ok := states[path]
+			states[path] = state
+			if !ok || last.db != state.db {
+				return nil
+			}
+
+			var change FileChange
+			switch {
+			case state.file == nil:
+				if last.file == nil {
+					return nil
+				}
+				change = FileRemoved
+			case last.file == nil || !os.SameFile(last.file, state.file):
+				change = FileReplaced
+			case state.txid == last.txid && (state.size != last.size || !state.modTime.Equal(last.modTime)):
+				change = FileModified
+			default:
+				return nil
+			}
+
+			e := FileEvent{
+				Path:   path,
+				Change: change,
+			}
+			if evict {
+				e.Evicted = p.evictIdle(c)
+				if e.Evicted {
+					delete(states, path)
+				}
+			}
+			if fn == nil {
+				return fmt.Errorf("%w: %s", ErrFileChanged, change)
+			}
+			fn(e)
+			return nil
+		},
+	}
+}
+
+// fileState returns the current state of the connection database file.
+func fileState(c *Connection) (s watchState, err error) {
+	s.db = c.DB
+	if err := c.DB.View(func(tx *bolt.Tx) error {
+		s.txid = tx.ID()
+		return nil
+	}); err != nil {
+		return s, err
+	}
+	info, err := c.pool.fs.Stat(c.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return s, err
+	}
+	s.file = info
+	s.size = info.Size()
+	s.modTime = info.ModTime()
+	return s, nil
+}
+
+// evictIdle closes and removes the connection from the pool if it has no
+// references and returns true if it was removed.
+func (p *Pool) evictIdle(c *Connection) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.connections[c.path] != c {
+		return false
+	}
+	return p.evictLocked(c)
+}
+
+// evictLocked closes and removes the connection from the pool if it has no
+// references and returns true if it was removed. Pool lock must be held.
+func (p *Pool) evictLocked(c *Connection) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.count > 0 {
+		return false
+	}
+	delete(p.verified, c.path)
+	p.invalidateCache(c.path)
+	c.emit(EventEvicted, nil)
+	p.handleError(c.remove())
+	return true
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestWatchTask(t *testing.T) {
+	dir := t.TempDir()
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+	})
+	defer pool.Close()
+
+	var events []FileEvent
+	task := WatchTask(func(e FileEvent) {
+		events = append(events, e)
+	}, true)
+
+	path := filepath.Join(dir, "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Run(pool, path); err != nil {
+		t.Fatal(err)
+	}
+
+	// writes through the pool are not reported
+	if err := c.DB.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucket([]byte("bucket"))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Run(pool, path); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("got events %v, expected none", events)
+	}
+
+	replaceFile(t, path)
+	if err := task.Run(pool, path); err != nil {
+		t.Fatal(err)
+	}
+	want := FileEvent{Path: path, Change: FileReplaced}
+	if len(events) != 1 || events[0] != want {
+		t.Fatalf("got events %v, expected %v", events, want)
+	}
+	if !pool.Has(path) {
+		t.Fatal("referenced database evicted")
+	}
+
+	c.Close()
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Run(pool, path); err != nil {
+		t.Fatal(err)
+	}
+	want = FileEvent{Path: path, Change: FileRemoved, Evicted: true}
+	if len(events) != 2 || events[1] != want {
+		t.Fatalf("got events %v, expected %v", events, want)
+	}
+	if pool.Has(path) {
+		t.Error("idle database not evicted")
+	}
+}
+
+func TestWatchTaskError(t *testing.T) {
+	dir := t.TempDir()
+	pool := New(&Options{
+		ConnectionExpires: time.Hour,
+	})
+	defer pool.Close()
+
+	task := WatchTask(nil, false)
+
+	path := filepath.Join(dir, "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	if err := task.Run(pool, path); err != nil {
+		t.Fatal(err)
+	}
+
+	replaceFile(t, path)
+	if err := task.Run(pool, path); !errors.Is(err, ErrFileChanged) {
+		t.Errorf("got error %v, expected %v", err, ErrFileChanged)
+	}
+	if !pool.Has(path) {
+		t.Error("database evicted")
+	}
+}
+
+// replaceFile replaces the file on path with its copy.
+func replaceFile(t *testing.T, path string) {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+".new", data, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path+".new", path); err != nil {
+		t.Fatal(err)
+	}
+}
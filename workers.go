@@ -0,0 +1,111 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"sort"
+	"sync"
+)
+
+// goBackground calls fn in a new goroutine that the pool waits for when
+// it is closed. If Options.MaxBackgroundWorkers is set, fn waits until the
+// number of running background functions is below it.
+func (p *Pool) goBackground(fn func()) {
+	p.background.Add(1)
+	go func() {
+		defer p.background.Done()
+
+		if p.workers != nil {
+			p.workers <- struct{}{}
+			defer func() { <-p.workers }()
+		}
+		fn()
+	}()
+}
+
+// markBusy makes Get and other operations on the database file on path
+// wait until the returned function is called. Pool lock must be held.
+func (p *Pool) markBusy(path string) (unmark func()) {
+	done := make(chan struct{})
+	p.compacting[path] = done
+	return func() {
+		p.mu.Lock()
+		delete(p.compacting, path)
+		p.mu.Unlock()
+		close(done)
+	}
+}
+
+// runParallel calls fn for every path on background workers and waits
+// for all calls to return.
+func (p *Pool) runParallel(paths []string, fn func(path string)) {
+	var wg sync.WaitGroup
+	for _, path := range paths {
+		path := path
+		wg.Add(1)
+		p.goBackground(func() {
+			defer wg.Done()
+
+			fn(path)
+		})
+	}
+	wg.Wait()
+}
+
+// defaultCloseParallelism is the number of databases that are closed at
+// the same time by Pool.Close and Pool.Shutdown if
+// Options.MaxBackgroundWorkers is not set.
+const defaultCloseParallelism = 16
+
+// detachAll removes all connections from the pool and returns them sorted
+// by their paths. Pool lock must be held.
+func (p *Pool) detachAll() []*Connection {
+	connections := make([]*Connection, 0, len(p.connections))
+	for path, c := range p.connections {
+		connections = append(connections, c)
+		p.detach(path)
+	}
+	sort.Slice(connections, func(i, j int) bool {
+		return connections[i].path < connections[j].path
+	})
+	return connections
+}
+
+// closeAll closes databases of detached connections in parallel, with at
+// most Options.MaxBackgroundWorkers or defaultCloseParallelism at the same
+// time, and returns errors, which are also passed to the ErrorHandler, in
+// the order of connections.
+func (p *Pool) closeAll(connections []*Connection) []error {
+	n := p.options.MaxBackgroundWorkers
+	if n <= 0 {
+		n = defaultCloseParallelism
+	}
+	var (
+		results = make([]error, len(connections))
+		sem     = make(chan struct{}, n)
+		wg      sync.WaitGroup
+	)
+	for i, c := range connections {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, c *Connection) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			results[i] = p.closeDB(c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, err := range results {
+		if err != nil {
+			p.handleError(err)
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2015 Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boltdbpool
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestMaxBackgroundWorkers(t *testing.T) {
+	pool := New(&Options{
+		MaxBackgroundWorkers: 2,
+	})
+
+	var running, max int32
+	for i := 0; i < 10; i++ {
+		pool.goBackground(func() {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		})
+	}
+	pool.Close()
+
+	if got := atomic.LoadInt32(&max); got > 2 {
+		t.Errorf("got %d concurrent workers, expected at most 2", got)
+	}
+}
+
+func TestMaxBackgroundWorkersMaintenance(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.db")
+
+	// the task on the first database waits for the task on the second one,
+	// which is possible only if they run in parallel
+	unblock := make(chan struct{})
+	var once sync.Once
+	done := make(chan struct{})
+	pool := New(&Options{
+		ConnectionExpires:    time.Hour,
+		MaxBackgroundWorkers: 2,
+		Maintenance: &Maintenance{
+			Interval: 10 * time.Millisecond,
+			Tasks: []MaintenanceTask{
+				maintenanceTask{
+					name: "test",
+					run: func(p *Pool, path string) error {
+						if path == a {
+							select {
+							case <-unblock:
+								once.Do(func() { close(done) })
+							case <-time.After(5 * time.Second):
+							}
+							return nil
+						}
+						select {
+						case unblock <- struct{}{}:
+						case <-time.After(5 * time.Second):
+						}
+						return nil
+					},
+				},
+			},
+		},
+	})
+	defer pool.Close()
+
+	for _, path := range []string{a, filepath.Join(dir, "b.db")} {
+		c, err := pool.Get(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+	}
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("maintenance tasks did not run in parallel")
+	}
+}
+
+func TestMaxBackgroundWorkersExpired(t *testing.T) {
+	now := time.Now()
+	var nowMu sync.Mutex
+	pool := New(&Options{
+		ConnectionExpires:    time.Hour,
+		CompactOnClose:       0.5,
+		MaxBackgroundWorkers: 1,
+		MaxParked:            1,
+		Now: func() time.Time {
+			nowMu.Lock()
+			defer nowMu.Unlock()
+			return now
+		},
+	})
+	defer pool.Close()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	c, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fragment(t, c.DB)
+	c.Close()
+	before := fileSize(t, path)
+
+	nowMu.Lock()
+	now = now.Add(2 * time.Hour)
+	nowMu.Unlock()
+	pool.CloseExpired()
+	if pool.Has(path) {
+		t.Error("expired database is open")
+	}
+
+	// Get waits for the database to be closed and compacted
+	c, err = pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if after := fileSize(t, path); after >= before {
+		t.Errorf("database not compacted: size before %d, after %d", before, after)
+	}
+	if err := c.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket([]byte("bucket")).Get([]byte("key-0")); v == nil {
+			t.Error("data lost after compaction")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// concurrentRemoveFS records the maximal number of concurrent Remove calls.
+type concurrentRemoveFS struct {
+	OSFS
+	running, max *int32
+}
+
+func (fs concurrentRemoveFS) Remove(name string) error {
+	n := atomic.AddInt32(fs.running, 1)
+	defer atomic.AddInt32(fs.running, -1)
+
+	for {
+		m := atomic.LoadInt32(fs.max)
+		if n <= m || atomic.CompareAndSwapInt32(fs.max, m, n) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	return fs.OSFS.Remove(name)
+}
+
+func TestCloseParallel(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		workers  int
+		min, max int32
+	}{
+		{name: "default", min: 2, max: defaultCloseParallelism},
+		{name: "workers", workers: 2, min: 2, max: 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var running, max int32
+			pool := New(&Options{
+				ConnectionExpires:    time.Hour,
+				Journal:              &Journal{Interval: time.Hour},
+				MaxBackgroundWorkers: tc.workers,
+				FS:                   concurrentRemoveFS{running: &running, max: &max},
+			})
+
+			dir := t.TempDir()
+			for i := 0; i < 8; i++ {
+				// journal files are removed when databases are closed
+				if err := pool.Append(filepath.Join(dir, fmt.Sprintf("%d.db", i)), []JournalEntry{
+					{Type: ChangePut, Bucket: []byte("bucket"), Key: []byte("key"), Value: []byte("value")},
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := pool.CloseAll(); err != nil {
+				t.Fatal(err)
+			}
+			if got := atomic.LoadInt32(&max); got < tc.min || got > tc.max {
+				t.Errorf("got %d concurrent closes, expected between %d and %d", got, tc.min, tc.max)
+			}
+		})
+	}
+}